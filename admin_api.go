@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type UserListDao struct {
+	Users []UserAccount `json:"users"`
+}
+
+type RoleDao struct {
+	Role string `json:"role"`
+}
+
+type AdminMazeDao struct {
+	MazeWithIdDao
+	UserId string `json:"userId"`
+}
+
+type AuditLogDao struct {
+	Events []AuditEvent `json:"events"`
+	Offset uint64       `json:"offset"`
+	Limit  uint64       `json:"limit"`
+}
+
+const (
+	AUDIT_DEFAULT_PAGE_SIZE = 50
+	AUDIT_MAX_PAGE_SIZE     = 500
+	// AUDIT_EXPORT_PAGE_SIZE is the number of events the export reads at once
+	AUDIT_EXPORT_PAGE_SIZE = 1000
+)
+
+func NewAdminApi(userController UserController, mazeController MazeController, auditLog AuditLog) ApiEndpoint {
+	return &adminApiImpl{
+		userController: userController,
+		mazeController: mazeController,
+		auditLog:       auditLog,
+	}
+}
+
+type adminApiImpl struct {
+	userController UserController
+	mazeController MazeController
+	auditLog       AuditLog
+}
+
+func (a *adminApiImpl) Init(router *mux.Router) {
+	router.HandleFunc("/admin/users", requireRole(a.userController, ROLE_ADMIN, a.ListUsers)).Methods("GET")
+	router.HandleFunc("/admin/users/{username}/role", requireRole(a.userController, ROLE_ADMIN, a.SetRole)).Methods("PUT").Headers("Content-Type", "application/json")
+	router.HandleFunc("/admin/users/{username}/disable", requireRole(a.userController, ROLE_ADMIN, a.DisableUser)).Methods("POST")
+	router.HandleFunc("/admin/users/{username}/enable", requireRole(a.userController, ROLE_ADMIN, a.EnableUser)).Methods("POST")
+	router.HandleFunc("/admin/users/{username}/logout", requireRole(a.userController, ROLE_ADMIN, a.ForceLogout)).Methods("POST")
+	router.HandleFunc("/admin/maze/{mazeId}", requireRole(a.userController, ROLE_MODERATOR, a.GetMaze)).Methods("GET")
+	router.HandleFunc("/admin/maze/{mazeId}", requireRole(a.userController, ROLE_MODERATOR, a.DeleteMaze)).Methods("DELETE")
+	router.HandleFunc("/admin/audit", requireRole(a.userController, ROLE_ADMIN, a.GetAuditLog)).Methods("GET")
+	router.HandleFunc("/admin/audit/export", requireRole(a.userController, ROLE_ADMIN, a.ExportAuditLog)).Methods("GET")
+}
+
+func (a *adminApiImpl) ListUsers(w http.ResponseWriter, r *http.Request) {
+	accounts, err := a.userController.ListAccounts()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, UserListDao{Users: accounts})
+}
+
+func (a *adminApiImpl) SetRole(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+
+	// Read request
+	var role RoleDao
+	err := json.NewDecoder(r.Body).Decode(&role)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+	if username == accountFromContext(r).Username && role.Role != ROLE_ADMIN {
+		writeInvalidParameter(w, "role", "admins can not remove their own admin role")
+		return
+	}
+
+	// Process request
+	err = a.userController.SetRole(accountFromContext(r).Username, username, role.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminApiImpl) DisableUser(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if username == accountFromContext(r).Username {
+		writeInvalidParameter(w, "username", "admins can not disable their own account")
+		return
+	}
+	a.setDisabled(w, r, username, true)
+}
+
+func (a *adminApiImpl) EnableUser(w http.ResponseWriter, r *http.Request) {
+	a.setDisabled(w, r, mux.Vars(r)["username"], false)
+}
+
+func (a *adminApiImpl) setDisabled(w http.ResponseWriter, r *http.Request, username string, disabled bool) {
+	err := a.userController.SetDisabled(accountFromContext(r).Username, username, disabled)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminApiImpl) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	err := a.userController.ForceLogout(accountFromContext(r).Username, mux.Vars(r)["username"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminApiImpl) GetMaze(w http.ResponseWriter, r *http.Request) {
+	maze, err := a.mazeController.GetMazeById(mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, AdminMazeDao{
+		MazeWithIdDao: MazeWithIdDao{
+			Id:         maze.Id,
+			MazeApiDao: *toMazeApiDao(maze),
+		},
+		UserId: maze.UserId,
+	})
+}
+
+func (a *adminApiImpl) DeleteMaze(w http.ResponseWriter, r *http.Request) {
+	err := a.mazeController.DeleteMaze(accountFromContext(r).Username, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAuditLog returns a page of audit events, filtered by the query parameters event, actor, subject,
+// since and until (RFC 3339), paginated with offset and limit.
+func (a *adminApiImpl) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := readAuditFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter.Limit, err = strconv.ParseUint(orDefault(r.URL.Query().Get("limit"), strconv.Itoa(AUDIT_DEFAULT_PAGE_SIZE)), 10, 64)
+	if err != nil || filter.Limit == 0 || filter.Limit > AUDIT_MAX_PAGE_SIZE {
+		writeInvalidParameter(w, "limit", "the limit must be between 1 and "+strconv.Itoa(AUDIT_MAX_PAGE_SIZE))
+		return
+	}
+	filter.Offset, err = strconv.ParseUint(orDefault(r.URL.Query().Get("offset"), "0"), 10, 64)
+	if err != nil {
+		writeInvalidParameter(w, "offset", "the offset must be numeric")
+		return
+	}
+
+	events, err := a.auditLog.Query(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, AuditLogDao{
+		Events: events,
+		Offset: filter.Offset,
+		Limit:  filter.Limit,
+	})
+}
+
+// ExportAuditLog writes all audit events matching the filter as JSON Lines. The events are read in pages of
+// AUDIT_EXPORT_PAGE_SIZE, so that the whole log is never held in memory.
+func (a *adminApiImpl) ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := readAuditFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter.Limit = AUDIT_EXPORT_PAGE_SIZE
+
+	events, err := a.auditLog.Query(filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	for {
+		for _, event := range events {
+			err = encoder.Encode(event)
+			if err != nil {
+				return
+			}
+		}
+		if len(events) < AUDIT_EXPORT_PAGE_SIZE {
+			return
+		}
+
+		// The status is sent already, a failing page ends the export early
+		filter.AfterId = events[len(events)-1].Id
+		events, err = a.auditLog.Query(filter)
+		if err != nil {
+			log.Println("failed to export the audit log:", err)
+			return
+		}
+	}
+}
+
+func readAuditFilter(r *http.Request) (AuditFilter, error) {
+	query := r.URL.Query()
+	filter := AuditFilter{
+		Event:   query.Get("event"),
+		Actor:   query.Get("actor"),
+		Subject: query.Get("subject"),
+	}
+	var err error
+	if since := query.Get("since"); since != "" {
+		filter.Since, err = time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, newCodedError(ERROR_CODE_INVALID_PARAMETER, "since must be a RFC 3339 timestamp").withField("since")
+		}
+	}
+	if until := query.Get("until"); until != "" {
+		filter.Until, err = time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, newCodedError(ERROR_CODE_INVALID_PARAMETER, "until must be a RFC 3339 timestamp").withField("until")
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newAdminTestRouter(role string) (*mux.Router, *UserControllerMock, *MazeControllerMock) {
+	router, userController, mazeController, _ := newAdminTestRouterWithAuditLog(role, newAuditLogMock())
+	return router, userController, mazeController
+}
+
+func newAdminTestRouterWithAuditLog(role string, auditLog AuditLog) (*mux.Router, *UserControllerMock, *MazeControllerMock, AuditLog) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "sessionid").Return("admin", nil)
+	userController.On("GetAccount", "admin").Return(&UserAccount{Username: "admin", Role: role}, nil)
+	mazeController := &MazeControllerMock{}
+	router := mux.NewRouter()
+	NewAdminApi(userController, mazeController, auditLog).Init(router)
+	return router, userController, mazeController, auditLog
+}
+
+func newAdminTestRequest(method string, url string, body []byte) *http.Request {
+	r := httptest.NewRequest(method, url, bytes.NewReader(body))
+	r.Header.Add("Authorization", "Bearer sessionid")
+	if body != nil {
+		r.Header.Add("Content-Type", "application/json")
+	}
+	return r
+}
+
+func Test_adminApiImpl_ListUsers(t *testing.T) {
+	router, userController, _ := newAdminTestRouter(ROLE_ADMIN)
+	userController.On("ListAccounts").Return([]UserAccount{
+		{Username: "admin", Role: ROLE_ADMIN},
+		{Username: "bob", Role: ROLE_USER, Disabled: true},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/users", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got UserListDao
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 2, len(got.Users))
+	assert.True(t, got.Users[1].Disabled)
+}
+
+func Test_adminApiImpl_ModeratorIsNoAdmin(t *testing.T) {
+	router, userController, _ := newAdminTestRouter(ROLE_MODERATOR)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("POST", "/admin/users/bob/disable", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	userController.AssertNotCalled(t, "SetDisabled", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_adminApiImpl_DisableUser(t *testing.T) {
+	router, userController, _ := newAdminTestRouter(ROLE_ADMIN)
+	userController.On("SetDisabled", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("POST", "/admin/users/bob/disable", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	userController.AssertCalled(t, "SetDisabled", "admin", "bob", true)
+
+	// Admins can not lock themselves out
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("POST", "/admin/users/admin/disable", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	userController.AssertNotCalled(t, "SetDisabled", "admin", "admin", true)
+}
+
+func Test_adminApiImpl_SetRole(t *testing.T) {
+	router, userController, _ := newAdminTestRouter(ROLE_ADMIN)
+	userController.On("SetRole", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	body, err := json.Marshal(&RoleDao{Role: ROLE_MODERATOR})
+	assert.Nil(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("PUT", "/admin/users/bob/role", body))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	userController.AssertCalled(t, "SetRole", "admin", "bob", ROLE_MODERATOR)
+}
+
+func Test_adminApiImpl_ForceLogout(t *testing.T) {
+	router, userController, _ := newAdminTestRouter(ROLE_ADMIN)
+	userController.On("ForceLogout", mock.Anything, mock.Anything).Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("POST", "/admin/users/bob/logout", nil))
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	userController.AssertCalled(t, "ForceLogout", "admin", "bob")
+}
+
+func Test_adminApiImpl_Maze(t *testing.T) {
+	router, _, mazeController := newAdminTestRouter(ROLE_MODERATOR)
+	maze := &Maze{Id: "8Wa", UserId: "bob", EntranceX: 1}
+	maze.InitWalls(8, 8)
+	mazeController.On("GetMazeById", "8Wa").Return(maze, nil)
+	mazeController.On("GetMazeById", "E42").Return((*Maze)(nil), ErrMazeNotFound)
+	mazeController.On("DeleteMaze", "admin", "8Wa").Return(nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/maze/8Wa", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got AdminMazeDao
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, "bob", got.UserId)
+	assert.Equal(t, "8x8", got.GridSize)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/maze/E42", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("DELETE", "/admin/maze/8Wa", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	mazeController.AssertCalled(t, "DeleteMaze", "admin", "8Wa")
+}
+
+func Test_adminApiImpl_ExportAuditLog_Pages(t *testing.T) {
+	auditLog := &auditLogImpl{
+		auditRepository: &auditRepositoryImpl{db: newAuditTestDb()},
+		clock: func() time.Time {
+			return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		},
+	}
+	for i := 0; i < AUDIT_EXPORT_PAGE_SIZE+2; i++ {
+		auditLog.Record(AUDIT_EVENT_LOGIN_SUCCESS, "bob", "bob", "192.0.2.1", "")
+		auditLog.Record(AUDIT_EVENT_LOGIN_SUCCESS, "alice", "alice", "192.0.2.2", "")
+	}
+	router, _, _, _ := newAdminTestRouterWithAuditLog(ROLE_ADMIN, auditLog)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/audit/export?actor=bob", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, AUDIT_EXPORT_PAGE_SIZE+2, len(lines))
+	var event AuditEvent
+	assert.Nil(t, json.Unmarshal([]byte(lines[len(lines)-1]), &event))
+	assert.Equal(t, uint64(2*AUDIT_EXPORT_PAGE_SIZE+3), event.Id)
+}
+
+func Test_adminApiImpl_AuditLog(t *testing.T) {
+	db := newAuditTestDb()
+	auditLog := &auditLogImpl{
+		auditRepository: &auditRepositoryImpl{db: db},
+		clock: func() time.Time {
+			return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		},
+	}
+	auditLog.Record(AUDIT_EVENT_LOGIN_FAILURE, "bob", "bob", "192.0.2.1", "invalid username or password")
+	auditLog.Record(AUDIT_EVENT_LOGIN_SUCCESS, "bob", "bob", "192.0.2.1", "")
+	auditLog.Record(AUDIT_EVENT_MAZE_CREATED, "bob", "8Wa", "", "")
+	auditLog.Record(AUDIT_EVENT_LOGIN_SUCCESS, "alice", "alice", "192.0.2.2", "")
+	router, _, _, _ := newAdminTestRouterWithAuditLog(ROLE_ADMIN, auditLog)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/audit?event=login.success&limit=1&offset=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got AuditLogDao
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 1, len(got.Events))
+	assert.Equal(t, "alice", got.Events[0].Actor)
+	assert.Equal(t, uint64(1), got.Offset)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/audit?limit=1000", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/audit/export?actor=bob", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/jsonl", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	var event AuditEvent
+	assert.Nil(t, json.Unmarshal([]byte(lines[2]), &event))
+	assert.Equal(t, AUDIT_EVENT_MAZE_CREATED, event.Event)
+	assert.Equal(t, "8Wa", event.Subject)
+
+	// Only admins can read the audit log
+	router, _, _, _ = newAdminTestRouterWithAuditLog(ROLE_MODERATOR, auditLog)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAdminTestRequest("GET", "/admin/audit", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
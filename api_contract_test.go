@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newContractTestRouter serves all v1 endpoints with mocks that answer every call successfully
+func newContractTestRouter() *mux.Router {
+	maze := &Maze{Id: "aaa", UserId: "alice", GridWidth: 3, GridHeight: 3}
+	maze.InitWalls(3, 3)
+	maze.EntranceX = 1
+
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "sessionid").Return("alice", nil)
+	userController.On("GetAccount", "alice").Return(&UserAccount{Username: "alice", Role: ROLE_ADMIN}, nil)
+	userController.On("CreateUser", mock.Anything, mock.Anything).Return("alice", nil)
+	userController.On("Login", mock.Anything, mock.Anything, mock.Anything).Return(&LoginResult{SessionId: "sessionid"}, nil)
+	userController.On("VerifyTwoFactor", mock.Anything, mock.Anything, mock.Anything).Return(&LoginResult{SessionId: "sessionid"}, nil)
+	userController.On("EnrollTwoFactor", "alice").Return(&TwoFactorEnrollment{Secret: "secret", Uri: "otpauth://totp/maze", RecoveryCodes: []string{"code"}}, nil)
+	userController.On("ListAccounts").Return([]UserAccount{{Username: "alice", Role: ROLE_ADMIN}}, nil)
+	userController.On("DeleteUser", "alice").Return(nil)
+
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetUserMazes", "alice").Return([]*Maze{maze}, nil)
+	mazeController.On("Generate", mock.Anything, mock.Anything).Return(maze, nil)
+	generated := &Maze{GridWidth: 3, GridHeight: 3, Walls: maze.Walls, Stats: computeMazeStats(maze)}
+	mazeController.On("GenerateForDifficulty", mock.Anything, mock.Anything, mock.Anything).Return(&MazeGenerationResult{Maze: generated, Target: MAZE_DIFFICULTY_LEVELS["easy"], Attempts: 1, Reached: true}, nil)
+	mazeController.On("CreateMaze", mock.Anything, mock.Anything).Return("aaa", nil)
+	mazeController.On("CreateTeamMaze", "alice", "bbb", mock.Anything).Return("aaa", nil)
+	mazeController.On("ValidateMaze", mock.Anything).Return(newMazeValidationReport(), nil)
+	mazeController.On("FindSolutionById", "aaa", "min").Return(&MazeSolution{Length: 3, Path: []string{"B1", "B2", "B3"}, Exit: "B3"}, nil)
+	mazeController.On("GetMazeById", "aaa").Return(maze, nil)
+	mazeController.On("GetTeamMazes", "bbb").Return([]*Maze{maze}, nil)
+	mazeController.On("GetMazeStats", maze).Return(computeMazeStats(maze), nil)
+
+	teamController := &TeamControllerMock{}
+	teamController.On("GetUserTeams", "alice").Return([]Team{{Id: "bbb", Name: "team", Role: TEAM_ROLE_OWNER}}, nil)
+	teamController.On("CreateTeam", "alice", mock.Anything).Return("bbb", nil)
+	teamController.On("GetTeam", "alice", "bbb").Return(&Team{Id: "bbb", Name: "team"}, []TeamMember{{Username: "alice", Role: TEAM_ROLE_OWNER}}, nil)
+	teamController.On("CheckTeamRole", "alice", "bbb", mock.Anything).Return(nil)
+
+	quotaController := newUnlimitedQuotaControllerMock()
+	quotaController.On("GetUsage", "alice").Return(&Usage{ResetsAt: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)}, nil)
+
+	auditLog := newAuditLogMock()
+	auditLog.On("Query", mock.Anything).Return([]AuditEvent{{Id: 1, Event: AUDIT_EVENT_LOGIN_SUCCESS, Actor: "alice", Subject: "alice"}}, nil)
+
+	router := mux.NewRouter()
+	initApiVersion(router, ApiVersion{
+		Prefix: "/v1",
+		Endpoints: []ApiEndpoint{
+			NewUserApi(userController, mazeController, quotaController),
+			NewMazeApi(mazeController, userController, quotaController, teamController),
+			NewAdminApi(userController, mazeController, auditLog),
+			NewTeamApi(teamController, mazeController, userController, quotaController),
+		},
+	})
+	return router
+}
+
+func Test_apiContract(t *testing.T) {
+	mazeBody := MazeApiDao{Entrace: "B1", GridSize: "3x3", Walls: &[]string{}}
+	imageBody := &bytes.Buffer{}
+	assert.Nil(t, (&mazeControllerImpl{}).DrawMaze(newTestMaze("B1", "#.#", "#.#", "#.#"), nil, DefaultMazeDrawOptions(), imageBody))
+	tests := []struct {
+		method   string
+		path     string
+		body     any
+		status   int
+		response any
+	}{
+		{"POST", "/user", User{Username: "alice", Password: "password"}, http.StatusCreated, &CreateUserResponse{}},
+		{"DELETE", "/user?export=true", nil, http.StatusOK, &UserExportDao{}},
+		{"GET", "/user/export", nil, http.StatusOK, &UserExportDao{}},
+		{"GET", "/user/usage", nil, http.StatusOK, &Usage{}},
+		{"POST", "/user/2fa", nil, http.StatusCreated, &TwoFactorEnrollment{}},
+		{"POST", "/login", User{Username: "alice", Password: "password"}, http.StatusOK, &LoginResult{}},
+		{"POST", "/login/2fa", TwoFactorLoginDao{TwoFactorToken: "token", Code: "123456"}, http.StatusOK, &LoginResult{}},
+		{"GET", "/maze", nil, http.StatusOK, &MyMazesDao{}},
+		{"POST", "/maze", mazeBody, http.StatusCreated, &CreateMazeResponse{}},
+		{"POST", "/maze/import/image", imageBody.Bytes(), http.StatusOK, &ImportedMazeDao{}},
+		{"POST", "/maze/import/image?save=true", imageBody.Bytes(), http.StatusCreated, &ImportedMazeDao{}},
+		{"POST", "/maze/validate", mazeBody, http.StatusOK, &MazeValidationReport{}},
+		{"GET", "/maze/generate", nil, http.StatusOK, &GeneratedMazeDao{}},
+		{"GET", "/maze/generate?difficulty=easy", nil, http.StatusOK, &GeneratedMazeDao{}},
+		{"GET", "/maze/aaa", nil, http.StatusOK, &MazeWithIdDao{}},
+		{"GET", "/maze/aaa/distances", nil, http.StatusOK, &MazeDistancesDao{}},
+		{"GET", "/maze/aaa/stats", nil, http.StatusOK, &MazeStatsDao{}},
+		{"GET", "/maze/aaa/solution", nil, http.StatusOK, &MazeSolution{}},
+		{"GET", "/teams", nil, http.StatusOK, &TeamListDao{}},
+		{"POST", "/teams", CreateTeamDao{Name: "team"}, http.StatusCreated, &CreateTeamResponse{}},
+		{"GET", "/teams/bbb", nil, http.StatusOK, &TeamDao{}},
+		{"GET", "/teams/bbb/maze", nil, http.StatusOK, &MyMazesDao{}},
+		{"POST", "/teams/bbb/maze", mazeBody, http.StatusCreated, &CreateTeamMazeResponse{}},
+		{"GET", "/admin/users", nil, http.StatusOK, &UserListDao{}},
+		{"GET", "/admin/maze/aaa", nil, http.StatusOK, &AdminMazeDao{}},
+		{"GET", "/admin/audit", nil, http.StatusOK, &AuditLogDao{}},
+		// Errors use the envelope on every route
+		{"GET", "/maze/generate?width=x", nil, http.StatusBadRequest, &ErrorResponse{}},
+		{"POST", "/teams", "{", http.StatusBadRequest, &ErrorResponse{}},
+	}
+	router := newContractTestRouter()
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var body io.Reader
+			contentType := "application/json"
+			switch b := tt.body.(type) {
+			case nil:
+			case []byte:
+				body = bytes.NewReader(b)
+				contentType = CONTENT_TYPE_PNG
+			case string:
+				body = strings.NewReader(b)
+			default:
+				data, err := json.Marshal(b)
+				assert.Nil(t, err)
+				body = bytes.NewReader(data)
+			}
+			req := httptest.NewRequest(tt.method, "/v1"+tt.path, body)
+			req.Header.Set("Authorization", "Bearer sessionid")
+			if body != nil {
+				req.Header.Set("Content-Type", contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code, w.Body.String())
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.True(t, json.Valid(w.Body.Bytes()), "invalid JSON: %s", w.Body.String())
+			decoder := json.NewDecoder(w.Body)
+			decoder.DisallowUnknownFields()
+			assert.Nil(t, decoder.Decode(tt.response))
+		})
+	}
+}
+
+// Test_apiContract_Coverage makes sure that every JSON response of the OpenAPI document has a contract test
+func Test_apiContract_Coverage(t *testing.T) {
+	covered := map[string]bool{
+		"POST /user/password": true, "POST /user/2fa/activate": true, "PUT /teams/{teamId}/members/{username}": true,
+		"DELETE /teams/{teamId}/members/{username}": true, "PUT /admin/users/{username}/role": true,
+		"POST /admin/users/{username}/disable": true, "POST /admin/users/{username}/enable": true,
+		"POST /admin/users/{username}/logout": true, "DELETE /admin/maze/{mazeId}": true,
+	}
+	for _, route := range []string{
+		"POST /user", "DELETE /user", "GET /user/export", "GET /user/usage", "POST /user/2fa", "POST /login", "POST /login/2fa",
+		"GET /maze", "POST /maze", "POST /maze/import/image", "POST /maze/validate", "GET /maze/generate", "GET /maze/{mazeId}", "GET /maze/{mazeId}/distances", "GET /maze/{mazeId}/solution", "GET /maze/{mazeId}/stats",
+		"GET /teams", "POST /teams", "GET /teams/{teamId}", "GET /teams/{teamId}/maze", "POST /teams/{teamId}/maze",
+		"GET /admin/users", "GET /admin/maze/{mazeId}", "GET /admin/audit",
+	} {
+		covered[route] = true
+	}
+
+	for path, pathItem := range newOpenApiDocument("/v1").Paths {
+		for method, operation := range pathItem {
+			for status, response := range operation.Responses {
+				if _, err := strconv.Atoi(status); err != nil {
+					continue
+				}
+				if _, ok := response.Content["application/json"]; ok && path != "/openapi.json" {
+					assert.True(t, covered[strings.ToUpper(method)+" "+path], "%s %s has no contract test", method, path)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+type ErrorBody struct {
+	Code    string        `json:"code"`
+	Message string        `json:"message"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+// ERROR_CODE_STATUS maps error codes to HTTP status codes, codes that are not listed are answered with 400.
+var ERROR_CODE_STATUS = map[string]int{
+	ERROR_CODE_INTERNAL:                      http.StatusInternalServerError,
+	ERROR_CODE_NOT_ACCEPTABLE:                http.StatusNotAcceptable,
+	ERROR_CODE_AUTH_INVALID_HEADER:           http.StatusUnauthorized,
+	ERROR_CODE_AUTH_INVALID_SESSION:          http.StatusUnauthorized,
+	ERROR_CODE_AUTH_INVALID_CREDENTIALS:      http.StatusUnauthorized,
+	ERROR_CODE_AUTH_INVALID_TWO_FACTOR:       http.StatusUnauthorized,
+	ERROR_CODE_AUTH_INVALID_TWO_FACTOR_TOKEN: http.StatusUnauthorized,
+	ERROR_CODE_AUTH_ACCOUNT_DISABLED:         http.StatusForbidden,
+	ERROR_CODE_AUTH_ROLE_REQUIRED:            http.StatusForbidden,
+	ERROR_CODE_AUTH_TOO_MANY_ATTEMPTS:        http.StatusTooManyRequests,
+	ERROR_CODE_TWO_FACTOR_NOT_ENABLED:        http.StatusConflict,
+	ERROR_CODE_TWO_FACTOR_NOT_ENROLLED:       http.StatusConflict,
+	ERROR_CODE_TWO_FACTOR_ALREADY_ENABLED:    http.StatusConflict,
+	ERROR_CODE_USER_NOT_FOUND:                http.StatusNotFound,
+	ERROR_CODE_USER_ALREADY_EXISTS:           http.StatusConflict,
+	ERROR_CODE_USER_WRONG_PASSWORD:           http.StatusForbidden,
+	ERROR_CODE_MAZE_NOT_FOUND:                http.StatusNotFound,
+	ERROR_CODE_MAZE_INVALID_SIZE:             http.StatusUnprocessableEntity,
+	ERROR_CODE_MAZE_INVALID_ENTRANCE:         http.StatusUnprocessableEntity,
+	ERROR_CODE_MAZE_NO_SOLUTION:              http.StatusUnprocessableEntity,
+	ERROR_CODE_MAZE_MULTIPLE_EXITS:           http.StatusUnprocessableEntity,
+	ERROR_CODE_MAZE_EXIT_NOT_AT_BOTTOM:       http.StatusUnprocessableEntity,
+	ERROR_CODE_TEAM_NOT_FOUND:                http.StatusNotFound,
+	ERROR_CODE_TEAM_ROLE_REQUIRED:            http.StatusForbidden,
+	ERROR_CODE_TEAM_LAST_OWNER:               http.StatusConflict,
+	ERROR_CODE_QUOTA_EXCEEDED:                http.StatusForbidden,
+	ERROR_CODE_QUOTA_DAILY_LIMIT:             http.StatusTooManyRequests,
+}
+
+// writeError answers with the JSON error envelope. Errors without a code are logged and hidden
+// behind a generic internal error, so database and other internal messages never reach clients.
+func writeError(w http.ResponseWriter, err error) {
+	coded := toCodedError(err)
+	status, ok := ERROR_CODE_STATUS[coded.Code]
+	if !ok {
+		status = http.StatusBadRequest
+	}
+	if coded.Code == ERROR_CODE_INTERNAL {
+		log.Println("internal error:", err)
+	}
+
+	var tooManyAttempts *TooManyLoginAttemptsError
+	if errors.As(err, &tooManyAttempts) {
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(tooManyAttempts.RetryAfter), 10))
+	}
+	var dailyLimitExceeded *DailyLimitExceededError
+	if errors.As(err, &dailyLimitExceeded) {
+		w.Header().Set("Retry-After", strconv.FormatInt(retryAfterSeconds(dailyLimitExceeded.RetryAfter), 10))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{
+		Error: ErrorBody{
+			Code:    coded.Code,
+			Message: coded.Message,
+			Details: coded.Details,
+		},
+	})
+}
+
+// writeErrorCode answers with an error that is detected by the handler itself, like a malformed parameter.
+func writeErrorCode(w http.ResponseWriter, code string, message string) {
+	writeError(w, newCodedError(code, message))
+}
+
+// writeInvalidParameter answers with a request.invalid_parameter error naming the parameter
+func writeInvalidParameter(w http.ResponseWriter, parameter string, message string) {
+	writeError(w, newCodedError(ERROR_CODE_INVALID_PARAMETER, message).withField(parameter))
+}
+
+// writeInvalidJson answers with a request.invalid_json error for a request body that can not be decoded
+func writeInvalidJson(w http.ResponseWriter, err error) {
+	writeErrorCode(w, ERROR_CODE_INVALID_JSON, "the request body is not valid JSON: "+err.Error())
+}
+
+func toCodedError(err error) *CodedError {
+	var coded *CodedError
+	if errors.As(err, &coded) {
+		return coded
+	}
+	var tooManyAttempts *TooManyLoginAttemptsError
+	if errors.As(err, &tooManyAttempts) {
+		return newCodedError(ERROR_CODE_AUTH_TOO_MANY_ATTEMPTS, err.Error())
+	}
+	var quotaExceeded *QuotaExceededError
+	if errors.As(err, &quotaExceeded) {
+		return newCodedError(ERROR_CODE_QUOTA_EXCEEDED, err.Error())
+	}
+	var dailyLimitExceeded *DailyLimitExceededError
+	if errors.As(err, &dailyLimitExceeded) {
+		return newCodedError(ERROR_CODE_QUOTA_DAILY_LIMIT, err.Error())
+	}
+	var passwordPolicy *PasswordPolicyError
+	if errors.As(err, &passwordPolicy) {
+		coded := newCodedError(ERROR_CODE_USER_WEAK_PASSWORD, err.Error())
+		for _, violation := range passwordPolicy.Violations {
+			coded.Details = append(coded.Details, ErrorDetail{Field: "password", Message: violation})
+		}
+		return coded
+	}
+	return newCodedError(ERROR_CODE_INTERNAL, "internal server error")
+}
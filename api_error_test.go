@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeErrorResponse(t *testing.T, w *httptest.ResponseRecorder) ErrorBody {
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var response ErrorResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	return response.Error
+}
+
+func Test_writeError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantRetry  string
+	}{
+		{name: "coded", err: ErrMazeNotFound, wantStatus: http.StatusNotFound, wantCode: ERROR_CODE_MAZE_NOT_FOUND},
+		{name: "wrapped coded", err: fmt.Errorf("loading: %w", ErrTeamRoleRequired), wantStatus: http.StatusForbidden, wantCode: ERROR_CODE_TEAM_ROLE_REQUIRED},
+		{name: "validation", err: ErrMazeMultipleExits, wantStatus: http.StatusUnprocessableEntity, wantCode: ERROR_CODE_MAZE_MULTIPLE_EXITS},
+		{name: "unmapped code", err: newCodedError(ERROR_CODE_MAZE_INVALID_POSITION, "Invalid position"), wantStatus: http.StatusBadRequest, wantCode: ERROR_CODE_MAZE_INVALID_POSITION},
+		{name: "throttled", err: &TooManyLoginAttemptsError{RetryAfter: 1500 * time.Millisecond}, wantStatus: http.StatusTooManyRequests, wantCode: ERROR_CODE_AUTH_TOO_MANY_ATTEMPTS, wantRetry: "2"},
+		{name: "quota", err: &QuotaExceededError{Message: "too many mazes"}, wantStatus: http.StatusForbidden, wantCode: ERROR_CODE_QUOTA_EXCEEDED},
+		{name: "daily limit", err: &DailyLimitExceededError{RetryAfter: time.Minute}, wantStatus: http.StatusTooManyRequests, wantCode: ERROR_CODE_QUOTA_DAILY_LIMIT, wantRetry: "60"},
+		{name: "internal", err: errors.New("no such table: mazes"), wantStatus: http.StatusInternalServerError, wantCode: ERROR_CODE_INTERNAL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeError(w, tt.err)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantRetry, w.Header().Get("Retry-After"))
+			body := decodeErrorResponse(t, w)
+			assert.Equal(t, tt.wantCode, body.Code)
+			assert.NotEmpty(t, body.Message)
+			assert.NotContains(t, body.Message, "no such table")
+		})
+	}
+}
+
+func Test_writeError_Details(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, &PasswordPolicyError{Violations: []string{"too short", "too common"}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	body := decodeErrorResponse(t, w)
+	assert.Equal(t, ERROR_CODE_USER_WEAK_PASSWORD, body.Code)
+	assert.Equal(t, []ErrorDetail{
+		{Field: "password", Message: "too short"},
+		{Field: "password", Message: "too common"},
+	}, body.Details)
+
+	w = httptest.NewRecorder()
+	writeInvalidParameter(w, "steps", "the steps parameter must be either 'min' or 'max'")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	body = decodeErrorResponse(t, w)
+	assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, body.Code)
+	assert.Equal(t, "steps", body.Details[0].Field)
+}
+
+func Test_CodedError_Is(t *testing.T) {
+	err := ErrMazeInvalidEntrance.withField("entrance")
+	assert.ErrorIs(t, err, ErrMazeInvalidEntrance)
+	assert.NotErrorIs(t, err, ErrMazeNoSolution)
+	assert.Equal(t, []ErrorDetail{{Field: "entrance", Message: "Invalid entrance"}}, err.Details)
+	// The shared sentinel is not modified
+	assert.Empty(t, ErrMazeInvalidEntrance.Details)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeJson answers with the body encoded as JSON. The body is encoded before anything is written,
+// so encoding errors are still answered with the error envelope instead of a truncated body.
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(encoded, '\n'))
+}
+
+// writeBinary answers with an already rendered body of the content type
+func writeBinary(w http.ResponseWriter, status int, contentType string, body *bytes.Buffer) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// setCacheHeaders lets clients cache the response, but they have to revalidate it with the ETag
+func setCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+}
+
+// checkNotModified answers 304 Not Modified and returns true if the If-None-Match header lists the ETag
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			setCacheHeaders(w, etag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
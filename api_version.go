@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ApiVersion groups the endpoints that are served below a common prefix. Each version gets its own
+// endpoints, so a later version can register handlers with different DAOs next to the existing one.
+type ApiVersion struct {
+	Prefix    string
+	Endpoints []ApiEndpoint
+}
+
+// UNVERSIONED_API_DEPRECATED and UNVERSIONED_API_SUNSET announce when the routes without version prefix
+// were deprecated and when they will be removed.
+var (
+	UNVERSIONED_API_DEPRECATED = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	UNVERSIONED_API_SUNSET     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+func initApiVersion(router *mux.Router, version ApiVersion) {
+	subrouter := router.PathPrefix(version.Prefix).Subrouter()
+	for _, endpoint := range version.Endpoints {
+		endpoint.Init(subrouter)
+	}
+}
+
+// initDeprecatedAliases serves the endpoints of the version at the root as well. Responses carry the
+// Deprecation and Sunset headers and link to the same route of the successor version.
+func initDeprecatedAliases(router *mux.Router, version ApiVersion, deprecated time.Time, sunset time.Time) {
+	subrouter := router.NewRoute().Subrouter()
+	subrouter.Use(deprecationMiddleware(version.Prefix, deprecated, sunset))
+	for _, endpoint := range version.Endpoints {
+		endpoint.Init(subrouter)
+	}
+}
+
+func deprecationMiddleware(successorPrefix string, deprecated time.Time, sunset time.Time) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecated.Unix(), 10))
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Set("Link", "<"+successorPrefix+r.URL.Path+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
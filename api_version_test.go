@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_initDeprecatedAliases(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("abc", nil)
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetUserMazes", "abc").Return([]*Maze{}, nil)
+	v1 := ApiVersion{
+		Prefix:    "/v1",
+		Endpoints: []ApiEndpoint{NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{})},
+	}
+	router := mux.NewRouter()
+	initApiVersion(router, v1)
+	initDeprecatedAliases(router, v1, time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC), time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC))
+
+	request := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Versioned route
+	w := request("/v1/maze")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+
+	// Deprecated alias
+	w = request("/maze")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "@1792368000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/maze>; rel="successor-version"`, w.Header().Get("Link"))
+
+	// Unknown versions are not routed
+	w = request("/v2/maze")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mazeController.AssertNumberOfCalls(t, "GetUserMazes", 2)
+}
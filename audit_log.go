@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// AuditLog records security and data changing events. Recording never fails the audited action,
+// errors are only logged.
+type AuditLog interface {
+	Record(event string, actor string, subject string, ip string, details string)
+	Query(filter AuditFilter) ([]AuditEvent, error)
+}
+
+func NewAuditLog(auditRepository AuditRepository) AuditLog {
+	return &auditLogImpl{
+		auditRepository: auditRepository,
+		clock:           time.Now,
+	}
+}
+
+type auditLogImpl struct {
+	auditRepository AuditRepository
+	clock           func() time.Time
+}
+
+func (a *auditLogImpl) Record(event string, actor string, subject string, ip string, details string) {
+	err := a.auditRepository.Insert(&AuditEvent{
+		Time:    a.clock().UTC(),
+		Event:   event,
+		Actor:   actor,
+		Subject: subject,
+		Ip:      ip,
+		Details: details,
+	})
+	if err != nil {
+		log.Println("failed to write audit event", event, "for", subject+":", err)
+	}
+}
+
+func (a *auditLogImpl) Query(filter AuditFilter) ([]AuditEvent, error) {
+	return a.auditRepository.Select(filter)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type AuditLogMock struct {
+	mock.Mock
+}
+
+func (m *AuditLogMock) Record(event string, actor string, subject string, ip string, details string) {
+	m.Called(event, actor, subject, ip, details)
+}
+
+func (m *AuditLogMock) Query(filter AuditFilter) ([]AuditEvent, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]AuditEvent), args.Error(1)
+}
+
+func newAuditLogMock() *AuditLogMock {
+	m := &AuditLogMock{}
+	m.On("Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	return m
+}
+
+func Test_auditLogImpl_Record(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	a := &auditLogImpl{
+		auditRepository: &auditRepositoryImpl{db: newAuditTestDb()},
+		clock: func() time.Time {
+			return now
+		},
+	}
+
+	a.Record(AUDIT_EVENT_USER_CREATED, "bob", "bob", "", "")
+
+	events, err := a.Query(AuditFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, []AuditEvent{
+		{Id: 1, Time: now.UTC(), Event: AUDIT_EVENT_USER_CREATED, Actor: "bob", Subject: "bob"},
+	}, events)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+const AUDIT_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS audit_log (id INTEGER PRIMARY KEY, created_at INTEGER NOT NULL, event VARCHAR(64) NOT NULL, actor VARCHAR(256) NOT NULL, subject VARCHAR(256) NOT NULL, ip VARCHAR(64) NOT NULL, details TEXT NOT NULL)"
+
+// The audit log is append-only, the database rejects changes to existing entries
+var AUDIT_REPO_CREATE_TRIGGERS = []string{
+	"CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END",
+	"CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log BEGIN SELECT RAISE(ABORT, 'the audit log is append-only'); END",
+}
+
+const (
+	AUDIT_EVENT_USER_CREATED    = "user.created"
+	AUDIT_EVENT_USER_DELETED    = "user.deleted"
+	AUDIT_EVENT_LOGIN_SUCCESS   = "login.success"
+	AUDIT_EVENT_LOGIN_FAILURE   = "login.failure"
+	AUDIT_EVENT_SESSION_REVOKED = "session.revoked"
+	AUDIT_EVENT_MAZE_CREATED    = "maze.created"
+	AUDIT_EVENT_MAZE_DELETED    = "maze.deleted"
+	AUDIT_EVENT_ROLE_CHANGE     = "role.changed"
+)
+
+type AuditEvent struct {
+	Id      uint64    `json:"id"`
+	Time    time.Time `json:"time"`
+	Event   string    `json:"event"`
+	Actor   string    `json:"actor"`
+	Subject string    `json:"subject"`
+	Ip      string    `json:"ip,omitempty"`
+	Details string    `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit events, empty fields match everything and a Limit of 0 returns all events.
+// AfterId skips the events up to this id, so that pages of a long export follow each other without gaps.
+type AuditFilter struct {
+	Event   string
+	Actor   string
+	Subject string
+	Since   time.Time
+	Until   time.Time
+	AfterId uint64
+	Offset  uint64
+	Limit   uint64
+}
+
+type AuditRepository interface {
+	Insert(event *AuditEvent) error
+	Select(filter AuditFilter) ([]AuditEvent, error)
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	_, err := db.Exec(AUDIT_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	for _, statement := range AUDIT_REPO_CREATE_TRIGGERS {
+		_, err = db.Exec(statement)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return &auditRepositoryImpl{
+		db: db,
+	}
+}
+
+type auditRepositoryImpl struct {
+	db *sql.DB
+}
+
+func (a *auditRepositoryImpl) Insert(event *AuditEvent) error {
+	result, err := a.db.Exec("INSERT INTO audit_log (created_at, event, actor, subject, ip, details) VALUES (?, ?, ?, ?, ?, ?)", event.Time.UnixMilli(), event.Event, event.Actor, event.Subject, event.Ip, event.Details)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	event.Id = uint64(id)
+	return nil
+}
+
+// Select returns the matching events in the order they were written.
+func (a *auditRepositoryImpl) Select(filter AuditFilter) ([]AuditEvent, error) {
+	var conditions []string
+	var args []any
+	if filter.Event != "" {
+		conditions = append(conditions, "event = ?")
+		args = append(args, filter.Event)
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Subject != "" {
+		conditions = append(conditions, "subject = ?")
+		args = append(args, filter.Subject)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UnixMilli())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UnixMilli())
+	}
+	if filter.AfterId > 0 {
+		conditions = append(conditions, "id > ?")
+		args = append(args, filter.AfterId)
+	}
+
+	query := "SELECT id, created_at, event, actor, subject, ip, details FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	} else if filter.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+
+	rows, err := a.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var createdAt int64
+		err = rows.Scan(&event.Id, &createdAt, &event.Event, &event.Actor, &event.Subject, &event.Ip, &event.Details)
+		if err != nil {
+			return nil, err
+		}
+		event.Time = time.UnixMilli(createdAt).UTC()
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
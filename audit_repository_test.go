@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuditTestDb() *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(AUDIT_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	for _, statement := range AUDIT_REPO_CREATE_TRIGGERS {
+		_, err = db.Exec(statement)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return db
+}
+
+func Test_auditRepositoryImpl_Select(t *testing.T) {
+	a := &auditRepositoryImpl{
+		db: newAuditTestDb(),
+	}
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []*AuditEvent{
+		{Time: start, Event: AUDIT_EVENT_LOGIN_FAILURE, Actor: "bob", Subject: "bob", Ip: "192.0.2.1", Details: "invalid username or password"},
+		{Time: start.Add(time.Minute), Event: AUDIT_EVENT_LOGIN_SUCCESS, Actor: "bob", Subject: "bob", Ip: "192.0.2.1"},
+		{Time: start.Add(2 * time.Minute), Event: AUDIT_EVENT_MAZE_CREATED, Actor: "bob", Subject: "8Wa"},
+		{Time: start.Add(3 * time.Minute), Event: AUDIT_EVENT_MAZE_DELETED, Actor: "admin", Subject: "8Wa", Details: "owner bob"},
+	}
+	for i, event := range events {
+		assert.Nil(t, a.Insert(event))
+		assert.Equal(t, uint64(i+1), event.Id)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []uint64
+	}{
+		{name: "all", filter: AuditFilter{}, want: []uint64{1, 2, 3, 4}},
+		{name: "event", filter: AuditFilter{Event: AUDIT_EVENT_LOGIN_SUCCESS}, want: []uint64{2}},
+		{name: "actor", filter: AuditFilter{Actor: "bob"}, want: []uint64{1, 2, 3}},
+		{name: "subject", filter: AuditFilter{Subject: "8Wa"}, want: []uint64{3, 4}},
+		{name: "time range", filter: AuditFilter{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)}, want: []uint64{2, 3}},
+		{name: "page", filter: AuditFilter{Offset: 1, Limit: 2}, want: []uint64{2, 3}},
+		{name: "offset without limit", filter: AuditFilter{Offset: 3}, want: []uint64{4}},
+		{name: "after id", filter: AuditFilter{Actor: "bob", AfterId: 1, Limit: 1}, want: []uint64{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.Select(tt.filter)
+			assert.Nil(t, err)
+			ids := []uint64{}
+			for _, event := range got {
+				ids = append(ids, event.Id)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	got, err := a.Select(AuditFilter{Event: AUDIT_EVENT_LOGIN_FAILURE})
+	assert.Nil(t, err)
+	assert.Equal(t, *events[0], got[0])
+}
+
+func Test_auditRepositoryImpl_AppendOnly(t *testing.T) {
+	db := newAuditTestDb()
+	a := &auditRepositoryImpl{
+		db: db,
+	}
+	assert.Nil(t, a.Insert(&AuditEvent{Time: time.Now(), Event: AUDIT_EVENT_USER_CREATED, Actor: "bob", Subject: "bob"}))
+
+	_, err := db.Exec("UPDATE audit_log SET actor = 'alice'")
+	assert.NotNil(t, err)
+	_, err = db.Exec("DELETE FROM audit_log")
+	assert.NotNil(t, err)
+}
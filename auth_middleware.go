@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const ACCOUNT_CONTEXT_KEY contextKey = "account"
+
+// requireRole only calls the handler if the session belongs to an account with at least the required role.
+// The account is available to the handler through accountFromContext.
+func requireRole(userController UserController, requiredRole string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := getUserId(r, userController)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		account, err := userController.GetAccount(userId)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if account == nil {
+			writeErrorCode(w, ERROR_CODE_AUTH_INVALID_SESSION, "invalid session")
+			return
+		}
+		if !hasRole(account.Role, requiredRole) {
+			writeErrorCode(w, ERROR_CODE_AUTH_ROLE_REQUIRED, "the role '"+requiredRole+"' is required")
+			return
+		}
+
+		handler(w, r.WithContext(context.WithValue(r.Context(), ACCOUNT_CONTEXT_KEY, account)))
+	}
+}
+
+func accountFromContext(r *http.Request) *UserAccount {
+	account, _ := r.Context().Value(ACCOUNT_CONTEXT_KEY).(*UserAccount)
+	return account
+}
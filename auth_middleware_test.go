@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_requireRole(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		account       *UserAccount
+		wantCode      int
+	}{
+		{
+			name:          "missing session",
+			authorization: "",
+			wantCode:      http.StatusUnauthorized,
+		},
+		{
+			name:          "role too low",
+			authorization: "Bearer sessionid",
+			account:       &UserAccount{Username: "abc", Role: ROLE_USER},
+			wantCode:      http.StatusForbidden,
+		},
+		{
+			name:          "role sufficient",
+			authorization: "Bearer sessionid",
+			account:       &UserAccount{Username: "abc", Role: ROLE_ADMIN},
+			wantCode:      http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userController := &UserControllerMock{}
+			userController.On("GetUserForSession", "sessionid").Return("abc", nil)
+			userController.On("GetAccount", "abc").Return(tt.account, nil)
+
+			var handlerAccount *UserAccount
+			handler := requireRole(userController, ROLE_MODERATOR, func(w http.ResponseWriter, r *http.Request) {
+				handlerAccount = accountFromContext(r)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/admin/users", nil)
+			if tt.authorization != "" {
+				r.Header.Add("Authorization", tt.authorization)
+			}
+			handler(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.account, handlerAccount)
+			} else {
+				assert.Nil(t, handlerAccount)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	CONTENT_TYPE_JSON   = "application/json"
+	CONTENT_TYPE_TEXT   = "text/plain"
+	CONTENT_TYPE_PNG    = "image/png"
+	CONTENT_TYPE_SVG    = "image/svg+xml"
+	CONTENT_TYPE_GIF    = "image/gif"
+	CONTENT_TYPE_PDF    = "application/pdf"
+	CONTENT_TYPE_BINARY = "application/octet-stream"
+)
+
+// MAZE_REPRESENTATIONS are the content types of a maze, in the order they are preferred
+var MAZE_REPRESENTATIONS = []string{CONTENT_TYPE_JSON, CONTENT_TYPE_TEXT, CONTENT_TYPE_PNG, CONTENT_TYPE_SVG, CONTENT_TYPE_BINARY}
+
+// negotiateContentType picks the offered content type the client accepts with the highest quality.
+// Offers are given in the order the server prefers them, which decides between equal qualities and
+// wildcards. An empty Accept header accepts the first offer.
+func negotiateContentType(accept string, offers []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	best := ""
+	bestQuality := 0.0
+	for _, offer := range offers {
+		quality := acceptQuality(accept, offer)
+		if quality > bestQuality {
+			best = offer
+			bestQuality = quality
+		}
+	}
+	return best, best != ""
+}
+
+// acceptQuality returns the quality of the most specific media range that matches the content type
+func acceptQuality(accept string, contentType string) float64 {
+	quality := 0.0
+	specificity := -1
+	for _, mediaRange := range strings.Split(accept, ",") {
+		parameters := strings.Split(mediaRange, ";")
+		rangeType := strings.ToLower(strings.TrimSpace(parameters[0]))
+		rangeSpecificity := mediaRangeSpecificity(rangeType, contentType)
+		if rangeSpecificity <= specificity {
+			continue
+		}
+		rangeQuality := 1.0
+		for _, parameter := range parameters[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(parameter), "=")
+			if found && strings.TrimSpace(name) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil && q >= 0 && q <= 1 {
+					rangeQuality = q
+				}
+			}
+		}
+		quality = rangeQuality
+		specificity = rangeSpecificity
+	}
+	return quality
+}
+
+// mediaRangeSpecificity is 2 for an exact match, 1 for type/* and 0 for */*, -1 if the range does not match
+func mediaRangeSpecificity(mediaRange string, contentType string) int {
+	if mediaRange == contentType {
+		return 2
+	}
+	if mediaRange == "*/*" {
+		return 0
+	}
+	mainType, subType, _ := strings.Cut(mediaRange, "/")
+	if subType == "*" && strings.HasPrefix(contentType, mainType+"/") {
+		return 1
+	}
+	return -1
+}
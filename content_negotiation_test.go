@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_negotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", CONTENT_TYPE_JSON, true},
+		{"*/*", CONTENT_TYPE_JSON, true},
+		{"text/plain", CONTENT_TYPE_TEXT, true},
+		{"image/*", CONTENT_TYPE_PNG, true},
+		{"image/*, image/svg+xml", CONTENT_TYPE_PNG, true},
+		{"image/*;q=0.5, image/svg+xml", CONTENT_TYPE_SVG, true},
+		{"text/html,application/xhtml+xml,image/png;q=0.9,*/*;q=0.8", CONTENT_TYPE_PNG, true},
+		{"application/json;q=0, */*", CONTENT_TYPE_TEXT, true},
+		{"IMAGE/SVG+XML", CONTENT_TYPE_SVG, true},
+		{"application/octet-stream", CONTENT_TYPE_BINARY, true},
+		{"text/html", "", false},
+		{"image/png;q=0", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			got, ok := negotiateContentType(tt.accept, MAZE_REPRESENTATIONS)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
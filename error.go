@@ -0,0 +1,104 @@
+package main
+
+const (
+	ERROR_CODE_INTERNAL          = "internal"
+	ERROR_CODE_INVALID_JSON      = "request.invalid_json"
+	ERROR_CODE_INVALID_PARAMETER = "request.invalid_parameter"
+	ERROR_CODE_NOT_ACCEPTABLE    = "request.not_acceptable"
+
+	ERROR_CODE_AUTH_INVALID_HEADER           = "auth.invalid_authorization_header"
+	ERROR_CODE_AUTH_INVALID_SESSION          = "auth.invalid_session"
+	ERROR_CODE_AUTH_INVALID_CREDENTIALS      = "auth.invalid_credentials"
+	ERROR_CODE_AUTH_ACCOUNT_DISABLED         = "auth.account_disabled"
+	ERROR_CODE_AUTH_TOO_MANY_ATTEMPTS        = "auth.too_many_attempts"
+	ERROR_CODE_AUTH_INVALID_TWO_FACTOR       = "auth.invalid_two_factor_code"
+	ERROR_CODE_AUTH_INVALID_TWO_FACTOR_TOKEN = "auth.invalid_two_factor_token"
+	ERROR_CODE_AUTH_ROLE_REQUIRED            = "auth.role_required"
+
+	ERROR_CODE_TWO_FACTOR_NOT_ENABLED     = "two_factor.not_enabled"
+	ERROR_CODE_TWO_FACTOR_NOT_ENROLLED    = "two_factor.not_enrolled"
+	ERROR_CODE_TWO_FACTOR_ALREADY_ENABLED = "two_factor.already_enabled"
+
+	ERROR_CODE_USER_NOT_FOUND        = "user.not_found"
+	ERROR_CODE_USER_ALREADY_EXISTS   = "user.already_exists"
+	ERROR_CODE_USER_INVALID_USERNAME = "user.invalid_username"
+	ERROR_CODE_USER_WEAK_PASSWORD    = "user.weak_password"
+	ERROR_CODE_USER_WRONG_PASSWORD   = "user.wrong_password"
+	ERROR_CODE_USER_INVALID_ROLE     = "user.invalid_role"
+
+	ERROR_CODE_MAZE_NOT_FOUND              = "maze.not_found"
+	ERROR_CODE_MAZE_INVALID_POSITION       = "maze.invalid_position"
+	ERROR_CODE_MAZE_INVALID_GRID_SIZE      = "maze.invalid_grid_size"
+	ERROR_CODE_MAZE_WALL_OUT_OF_RANGE      = "maze.wall_out_of_range"
+	ERROR_CODE_MAZE_INVALID_SIZE           = "maze.invalid_size"
+	ERROR_CODE_MAZE_INVALID_ENTRANCE       = "maze.invalid_entrance"
+	ERROR_CODE_MAZE_NO_SOLUTION            = "maze.no_solution"
+	ERROR_CODE_MAZE_MULTIPLE_EXITS         = "maze.multiple_exits"
+	ERROR_CODE_MAZE_EXIT_NOT_AT_BOTTOM     = "maze.exit_not_on_bottom_edge"
+	ERROR_CODE_MAZE_ENTRANCE_IS_WALL       = "maze.entrance_is_wall"
+	ERROR_CODE_MAZE_UNREACHABLE_REGION     = "maze.unreachable_region"
+	ERROR_CODE_MAZE_MULTIPLE_SOLUTIONS     = "maze.multiple_solutions"
+	ERROR_CODE_MAZE_INVALID_ASCII          = "maze.invalid_ascii"
+	ERROR_CODE_MAZE_INVALID_WALLS_ENCODING = "maze.invalid_walls_encoding"
+	ERROR_CODE_MAZE_INVALID_WALLS_DATA     = "maze.invalid_walls_data"
+	ERROR_CODE_MAZE_INVALID_IMAGE          = "maze.invalid_image"
+
+	ERROR_CODE_TEAM_NOT_FOUND     = "team.not_found"
+	ERROR_CODE_TEAM_ROLE_REQUIRED = "team.role_required"
+	ERROR_CODE_TEAM_INVALID_NAME  = "team.invalid_name"
+	ERROR_CODE_TEAM_INVALID_ROLE  = "team.invalid_role"
+	ERROR_CODE_TEAM_LAST_OWNER    = "team.last_owner"
+
+	ERROR_CODE_QUOTA_EXCEEDED    = "quota.exceeded"
+	ERROR_CODE_QUOTA_DAILY_LIMIT = "quota.daily_limit_exceeded"
+)
+
+// ErrorDetail points to the part of a request that caused an error.
+type ErrorDetail struct {
+	Field   string `json:"field,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// CodedError carries a stable, machine readable code next to the human readable message.
+// Two coded errors are considered equal by errors.Is if their codes match.
+type CodedError struct {
+	Code    string
+	Message string
+	Details []ErrorDetail
+}
+
+func newCodedError(code string, message string) *CodedError {
+	return &CodedError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *CodedError) Error() string {
+	return e.Message
+}
+
+func (e *CodedError) Is(target error) bool {
+	t, ok := target.(*CodedError)
+	return ok && t.Code == e.Code
+}
+
+// withField adds the field to coded errors, other errors are returned unchanged.
+func withField(err error, field string) error {
+	coded, ok := err.(*CodedError)
+	if !ok {
+		return err
+	}
+	return coded.withField(field)
+}
+
+// withField returns a copy of the error that names the offending request field.
+func (e *CodedError) withField(field string) *CodedError {
+	return &CodedError{
+		Code:    e.Code,
+		Message: e.Message,
+		Details: append(append([]ErrorDetail{}, e.Details...), ErrorDetail{Field: field, Message: e.Message}),
+	}
+}
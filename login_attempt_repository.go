@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+const LOGIN_ATTEMPT_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS login_attempts (throttle_key VARCHAR(300) NOT NULL PRIMARY KEY, failures INTEGER NOT NULL, last_failure INTEGER NOT NULL, locked_until INTEGER NOT NULL)"
+
+// NewLoginAttemptRepository returns a LoginAttemptStore that keeps the throttle state in the database,
+// so that it is shared between multiple instances and survives restarts.
+func NewLoginAttemptRepository(db *sql.DB) LoginAttemptStore {
+	_, err := db.Exec(LOGIN_ATTEMPT_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return &loginAttemptRepositoryImpl{
+		db: db,
+	}
+}
+
+type loginAttemptRepositoryImpl struct {
+	db *sql.DB
+}
+
+func (l *loginAttemptRepositoryImpl) Get(key string) (*LoginAttemptState, error) {
+	var failures uint32
+	var lastFailure, lockedUntil int64
+	err := l.db.QueryRow("SELECT failures, last_failure, locked_until FROM login_attempts WHERE throttle_key = ?", key).Scan(&failures, &lastFailure, &lockedUntil)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &LoginAttemptState{
+		Failures:    failures,
+		LastFailure: time.Unix(0, lastFailure),
+		LockedUntil: time.Unix(0, lockedUntil),
+	}, nil
+}
+
+// AddFailure increments the count in the database, so that concurrent attempts of several instances are all counted.
+// The lock is checked by the same statement and only the base delay is set, ExtendLock adds the backoff.
+func (l *loginAttemptRepositoryImpl) AddFailure(key string, policy LoginThrottlePolicy, now time.Time) (bool, error) {
+	provisionalLock := int64(0)
+	if policy.FreeAttempts < 1 {
+		provisionalLock = now.Add(policy.BaseDelay).UnixNano()
+	}
+	result, err := l.db.Exec(`INSERT INTO login_attempts (throttle_key, failures, last_failure, locked_until) VALUES (?, 1, ?, ?)
+		ON CONFLICT (throttle_key) DO UPDATE SET
+			failures = CASE WHEN last_failure < ? THEN 1 ELSE failures + 1 END,
+			locked_until = CASE WHEN (CASE WHEN last_failure < ? THEN 1 ELSE failures + 1 END) > ? THEN ? ELSE locked_until END,
+			last_failure = excluded.last_failure
+		WHERE locked_until <= excluded.last_failure`,
+		key, now.UnixNano(), provisionalLock,
+		now.Add(-policy.ResetAfter).UnixNano(),
+		now.Add(-policy.ResetAfter).UnixNano(), policy.FreeAttempts, now.Add(policy.BaseDelay).UnixNano())
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (l *loginAttemptRepositoryImpl) ExtendLock(key string, lockedUntil time.Time) error {
+	_, err := l.db.Exec("UPDATE login_attempts SET locked_until = MAX(locked_until, ?) WHERE throttle_key = ?", lockedUntil.UnixNano(), key)
+	return err
+}
+
+func (l *loginAttemptRepositoryImpl) RemoveFailure(key string) error {
+	_, err := l.db.Exec("UPDATE login_attempts SET failures = failures - 1 WHERE throttle_key = ? AND failures > 0", key)
+	return err
+}
+
+func (l *loginAttemptRepositoryImpl) Delete(key string) error {
+	_, err := l.db.Exec("DELETE FROM login_attempts WHERE throttle_key = ?", key)
+	return err
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLoginAttemptTestDb() *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(LOGIN_ATTEMPT_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func Test_loginAttemptRepositoryImpl(t *testing.T) {
+	l := &loginAttemptRepositoryImpl{
+		db: newLoginAttemptTestDb(),
+	}
+	policy := LoginThrottlePolicy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		ResetAfter:   time.Hour,
+	}
+	now := time.Unix(1682942400, 0)
+
+	got, err := l.Get("user:abc")
+	assert.Nil(t, err)
+	assert.Nil(t, got)
+
+	// The free attempts are counted without a lock, the next one locks for the base delay
+	for i := 0; i < 3; i++ {
+		counted, err := l.AddFailure("user:abc", policy, now)
+		assert.Nil(t, err)
+		assert.True(t, counted)
+	}
+	got, err = l.Get("user:abc")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), got.Failures)
+	assert.True(t, now.Equal(got.LastFailure))
+	assert.True(t, now.Add(time.Second).Equal(got.LockedUntil))
+
+	// A locked key is not counted, the lock is only extended
+	counted, err := l.AddFailure("user:abc", policy, now)
+	assert.Nil(t, err)
+	assert.False(t, counted)
+	assert.Nil(t, l.ExtendLock("user:abc", now.Add(time.Minute)))
+	assert.Nil(t, l.ExtendLock("user:abc", now.Add(time.Second)))
+	got, err = l.Get("user:abc")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), got.Failures)
+	assert.True(t, now.Add(time.Minute).Equal(got.LockedUntil))
+
+	// After the lock the count goes on, after ResetAfter it restarts
+	now = now.Add(time.Minute)
+	counted, err = l.AddFailure("user:abc", policy, now)
+	assert.Nil(t, err)
+	assert.True(t, counted)
+	assert.Nil(t, l.RemoveFailure("user:abc"))
+	got, err = l.Get("user:abc")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), got.Failures)
+	now = now.Add(2 * time.Hour)
+	counted, err = l.AddFailure("user:abc", policy, now)
+	assert.Nil(t, err)
+	assert.True(t, counted)
+	got, err = l.Get("user:abc")
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), got.Failures)
+
+	assert.Nil(t, l.Delete("user:abc"))
+	got, err = l.Get("user:abc")
+	assert.Nil(t, err)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type LoginThrottlePolicy struct {
+	FreeAttempts     uint32
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold uint32
+	LockoutDuration  time.Duration
+	ResetAfter       time.Duration
+}
+
+func DefaultUserLoginThrottlePolicy() LoginThrottlePolicy {
+	return LoginThrottlePolicy{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		ResetAfter:       time.Hour,
+	}
+}
+
+func DefaultIpLoginThrottlePolicy() LoginThrottlePolicy {
+	return LoginThrottlePolicy{
+		FreeAttempts:     10,
+		BaseDelay:        time.Second,
+		MaxDelay:         5 * time.Minute,
+		LockoutThreshold: 50,
+		LockoutDuration:  time.Hour,
+		ResetAfter:       time.Hour,
+	}
+}
+
+type LoginAttemptState struct {
+	Failures    uint32
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// LoginAttemptStore keeps the throttle state per key. Get returns nil if there is no state for the key.
+// AddFailure counts a failure in one atomic step with the check of the lock, it returns false without counting
+// if the key is locked. The count restarts after the ResetAfter of the policy, and a count above the free attempts
+// locks the key at least for the base delay, ExtendLock then sets the lock that belongs to the count.
+type LoginAttemptStore interface {
+	Get(key string) (*LoginAttemptState, error)
+	AddFailure(key string, policy LoginThrottlePolicy, now time.Time) (bool, error)
+	ExtendLock(key string, lockedUntil time.Time) error
+	RemoveFailure(key string) error
+	Delete(key string) error
+}
+
+type TooManyLoginAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyLoginAttemptsError) Error() string {
+	return fmt.Sprintf("too many login attempts, retry in %d seconds", retryAfterSeconds(e.RetryAfter))
+}
+
+func retryAfterSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// LoginThrottle counts every attempt as a failure before the credentials are checked, so that concurrent
+// attempts can not pass the throttle together. A successful login takes the attempt back.
+type LoginThrottle interface {
+	RegisterAttempt(username string, ip string) error
+	RegisterSuccess(username string, ip string) error
+}
+
+// NewLoginThrottle keeps only the counters, the failed attempts themselves are recorded in the audit log
+func NewLoginThrottle(store LoginAttemptStore, userPolicy LoginThrottlePolicy, ipPolicy LoginThrottlePolicy) LoginThrottle {
+	return &loginThrottleImpl{
+		store:      store,
+		userPolicy: userPolicy,
+		ipPolicy:   ipPolicy,
+		clock:      time.Now,
+	}
+}
+
+type loginThrottleImpl struct {
+	store      LoginAttemptStore
+	userPolicy LoginThrottlePolicy
+	ipPolicy   LoginThrottlePolicy
+	clock      func() time.Time
+}
+
+// RegisterAttempt returns a TooManyLoginAttemptsError if the ip or the username is locked. The ip is counted first,
+// so that attempts from a throttled ip do not count against the username.
+func (l *loginThrottleImpl) RegisterAttempt(username string, ip string) error {
+	now := l.clock()
+	err := l.registerAttemptForKey(ipThrottleKey(ip), l.ipPolicy, now)
+	if err != nil {
+		return err
+	}
+	return l.registerAttemptForKey(userThrottleKey(username), l.userPolicy, now)
+}
+
+func (l *loginThrottleImpl) RegisterSuccess(username string, ip string) error {
+	// Only the username is reset, otherwise an attacker could reset the ip counter with an own account
+	err := l.store.Delete(userThrottleKey(username))
+	if err != nil {
+		return err
+	}
+	return l.store.RemoveFailure(ipThrottleKey(ip))
+}
+
+func (l *loginThrottleImpl) registerAttemptForKey(key string, policy LoginThrottlePolicy, now time.Time) error {
+	counted, err := l.store.AddFailure(key, policy, now)
+	if err != nil {
+		return err
+	}
+	state, err := l.store.Get(key)
+	if err != nil || state == nil {
+		return err
+	}
+	if !counted {
+		return &TooManyLoginAttemptsError{RetryAfter: state.LockedUntil.Sub(now)}
+	}
+	lockedUntil := lockedUntilAfterFailure(policy, state.Failures, now)
+	if !lockedUntil.After(state.LockedUntil) {
+		return nil
+	}
+	return l.store.ExtendLock(key, lockedUntil)
+}
+
+// isLoginAttemptStateExpired tells if the count of the state restarts, a locked state is kept until the lock ends
+func isLoginAttemptStateExpired(state *LoginAttemptState, policy LoginThrottlePolicy, now time.Time) bool {
+	return !state.LockedUntil.After(now) && now.Sub(state.LastFailure) > policy.ResetAfter
+}
+
+// lockedUntilAfterFailure returns the lock for the given number of failures, the zero time if there is none
+func lockedUntilAfterFailure(policy LoginThrottlePolicy, failures uint32, now time.Time) time.Time {
+	if policy.LockoutThreshold > 0 && failures >= policy.LockoutThreshold {
+		return now.Add(policy.LockoutDuration)
+	}
+	if failures > policy.FreeAttempts {
+		return now.Add(backoffDelay(policy, failures-policy.FreeAttempts))
+	}
+	return time.Time{}
+}
+
+// backoffDelay doubles the base delay for every failure after the free attempts.
+func backoffDelay(policy LoginThrottlePolicy, exceededAttempts uint32) time.Duration {
+	delay := policy.BaseDelay
+	for i := uint32(1); i < exceededAttempts; i++ {
+		delay *= 2
+		if delay >= policy.MaxDelay {
+			return policy.MaxDelay
+		}
+	}
+	if policy.MaxDelay > 0 && delay > policy.MaxDelay {
+		return policy.MaxDelay
+	}
+	return delay
+}
+
+// LOGIN_THROTTLE_MAX_USERNAME_BYTES cuts the username of a throttle key, longer usernames can not be registered
+const LOGIN_THROTTLE_MAX_USERNAME_BYTES = 4 * USERNAME_MAX_LENGTH
+
+// LOGIN_THROTTLE_MEMORY_MAX_ENTRIES caps the keys of the memory store, spraying usernames or ips must not grow it
+// without bound. LOGIN_THROTTLE_MEMORY_SWEEP_INTERVAL is the time between two sweeps of the expired states.
+const (
+	LOGIN_THROTTLE_MEMORY_MAX_ENTRIES    = 100000
+	LOGIN_THROTTLE_MEMORY_SWEEP_INTERVAL = time.Minute
+)
+
+func userThrottleKey(username string) string {
+	if len(username) > LOGIN_THROTTLE_MAX_USERNAME_BYTES {
+		username = username[:LOGIN_THROTTLE_MAX_USERNAME_BYTES]
+	}
+	return "user:" + username
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func NewMemoryLoginAttemptStore() LoginAttemptStore {
+	return &memoryLoginAttemptStore{
+		entries:    map[string]memoryLoginAttempt{},
+		maxEntries: LOGIN_THROTTLE_MEMORY_MAX_ENTRIES,
+	}
+}
+
+type memoryLoginAttemptStore struct {
+	mutex      sync.Mutex
+	entries    map[string]memoryLoginAttempt
+	maxEntries int
+	lastSweep  time.Time
+}
+
+// memoryLoginAttempt can be dropped once it expired, the count would restart anyway
+type memoryLoginAttempt struct {
+	state     LoginAttemptState
+	expiresAt time.Time
+}
+
+func (m *memoryLoginAttemptStore) Get(key string) (*LoginAttemptState, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &entry.state, nil
+}
+
+func (m *memoryLoginAttemptStore) AddFailure(key string, policy LoginThrottlePolicy, now time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[key]
+	if ok && entry.state.LockedUntil.After(now) {
+		return false, nil
+	}
+	if !ok {
+		m.makeRoom(now)
+	}
+	if !ok || isLoginAttemptStateExpired(&entry.state, policy, now) {
+		entry.state = LoginAttemptState{}
+	}
+	entry.state.Failures++
+	entry.state.LastFailure = now
+	entry.state.LockedUntil = lockedUntilAfterFailure(policy, entry.state.Failures, now)
+	entry.expiresAt = laterTime(entry.state.LockedUntil, now.Add(policy.ResetAfter))
+	m.entries[key] = entry
+	return true, nil
+}
+
+// makeRoom sweeps the expired entries from time to time and whenever the store is full. If there are only
+// current entries, the one that expires first is dropped.
+func (m *memoryLoginAttemptStore) makeRoom(now time.Time) {
+	if len(m.entries) < m.maxEntries && now.Sub(m.lastSweep) < LOGIN_THROTTLE_MEMORY_SWEEP_INTERVAL {
+		return
+	}
+	m.lastSweep = now
+	for key, entry := range m.entries {
+		if !entry.expiresAt.After(now) {
+			delete(m.entries, key)
+		}
+	}
+	if len(m.entries) < m.maxEntries {
+		return
+	}
+	firstKey := ""
+	var firstExpiry time.Time
+	for key, entry := range m.entries {
+		if firstKey == "" || entry.expiresAt.Before(firstExpiry) {
+			firstKey = key
+			firstExpiry = entry.expiresAt
+		}
+	}
+	delete(m.entries, firstKey)
+}
+
+func (m *memoryLoginAttemptStore) ExtendLock(key string, lockedUntil time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[key]
+	if ok && lockedUntil.After(entry.state.LockedUntil) {
+		entry.state.LockedUntil = lockedUntil
+		entry.expiresAt = laterTime(entry.expiresAt, lockedUntil)
+		m.entries[key] = entry
+	}
+	return nil
+}
+
+func (m *memoryLoginAttemptStore) RemoveFailure(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[key]
+	if ok && entry.state.Failures > 0 {
+		entry.state.Failures--
+		m.entries[key] = entry
+	}
+	return nil
+}
+
+func (m *memoryLoginAttemptStore) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func laterTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
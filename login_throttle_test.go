@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLoginThrottle(now *time.Time) *loginThrottleImpl {
+	return &loginThrottleImpl{
+		store: NewMemoryLoginAttemptStore(),
+		userPolicy: LoginThrottlePolicy{
+			FreeAttempts:     2,
+			BaseDelay:        time.Second,
+			MaxDelay:         8 * time.Second,
+			LockoutThreshold: 10,
+			LockoutDuration:  time.Hour,
+			ResetAfter:       2 * time.Hour,
+		},
+		ipPolicy: LoginThrottlePolicy{
+			FreeAttempts:     100,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 1000,
+			LockoutDuration:  time.Hour,
+			ResetAfter:       2 * time.Hour,
+		},
+		clock: func() time.Time {
+			return *now
+		},
+	}
+}
+
+func retryAfterOf(err error) time.Duration {
+	var tooManyAttempts *TooManyLoginAttemptsError
+	if errors.As(err, &tooManyAttempts) {
+		return tooManyAttempts.RetryAfter
+	}
+	return 0
+}
+
+func Test_loginThrottleImpl_Backoff(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLoginThrottle(&now)
+
+	// The free attempts and the first attempt after them are not delayed
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	}
+
+	// Then the delay doubles with every failure until the maximum is reached
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		assert.Equal(t, want, retryAfterOf(l.RegisterAttempt("abc", "192.0.2.1")))
+		now = now.Add(want)
+		assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	}
+
+	// Other usernames from another ip are not affected
+	assert.Nil(t, l.RegisterAttempt("other", "192.0.2.2"))
+}
+
+func Test_loginThrottleImpl_Lockout(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLoginThrottle(&now)
+	l.userPolicy.FreeAttempts = 20
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	}
+	assert.Equal(t, time.Hour, retryAfterOf(l.RegisterAttempt("abc", "192.0.2.1")))
+
+	// The lockout applies to the username regardless of the ip
+	assert.Equal(t, time.Hour, retryAfterOf(l.RegisterAttempt("abc", "192.0.2.99")))
+
+	now = now.Add(time.Hour)
+	assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+}
+
+func Test_loginThrottleImpl_IpLimit(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLoginThrottle(&now)
+	l.ipPolicy.FreeAttempts = 3
+
+	// Spraying different usernames from one ip is throttled as well
+	for _, username := range []string{"a", "b", "c", "d"} {
+		assert.Nil(t, l.RegisterAttempt(username, "192.0.2.1"))
+	}
+	assert.Equal(t, time.Second, retryAfterOf(l.RegisterAttempt("e", "192.0.2.1")))
+	assert.Nil(t, l.RegisterAttempt("e", "192.0.2.2"))
+
+	// Attempts rejected for the ip do not count against the username
+	state, err := l.store.Get(userThrottleKey("e"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), state.Failures)
+}
+
+func Test_loginThrottleImpl_RegisterSuccess(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLoginThrottle(&now)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	}
+	now = now.Add(time.Second)
+	assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	assert.Nil(t, l.RegisterSuccess("abc", "192.0.2.1"))
+
+	// The next attempt counts as the first one again, the successful attempt is taken back from the ip
+	assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	ipState, err := l.store.Get(ipThrottleKey("192.0.2.1"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(5), ipState.Failures)
+}
+
+func Test_loginThrottleImpl_ResetAfter(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLoginThrottle(&now)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	}
+	now = now.Add(3 * time.Hour)
+	assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+	assert.Nil(t, l.RegisterAttempt("abc", "192.0.2.1"))
+}
+
+func Test_loginThrottleImpl_Concurrent(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Every connection would open an own in-memory database
+	db := newLoginAttemptTestDb()
+	db.SetMaxOpenConns(1)
+	for name, store := range map[string]LoginAttemptStore{
+		"memory":   NewMemoryLoginAttemptStore(),
+		"database": &loginAttemptRepositoryImpl{db: db},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := newTestLoginThrottle(&now)
+			l.store = store
+
+			// A parallel burst only gets the free attempts and the one after them through
+			var allowed int32
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := l.RegisterAttempt("abc", "192.0.2.1")
+					if err == nil {
+						atomic.AddInt32(&allowed, 1)
+					} else {
+						assert.NotZero(t, retryAfterOf(err))
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(3), allowed)
+			state, err := store.Get(userThrottleKey("abc"))
+			assert.Nil(t, err)
+			assert.Equal(t, uint32(3), state.Failures)
+			assert.Equal(t, now.Add(time.Second).UnixNano(), state.LockedUntil.UnixNano())
+		})
+	}
+}
+
+func Test_backoffDelay(t *testing.T) {
+	policy := LoginThrottlePolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  time.Minute,
+	}
+	assert.Equal(t, time.Second, backoffDelay(policy, 1))
+	assert.Equal(t, 4*time.Second, backoffDelay(policy, 3))
+	assert.Equal(t, time.Minute, backoffDelay(policy, 100))
+}
+
+func Test_memoryLoginAttemptStore_Sweep(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	policy := LoginThrottlePolicy{FreeAttempts: 5, ResetAfter: time.Hour}
+	m := NewMemoryLoginAttemptStore().(*memoryLoginAttemptStore)
+	m.maxEntries = 3
+
+	for _, key := range []string{"user:a", "user:b"} {
+		_, err := m.AddFailure(key, policy, now)
+		assert.Nil(t, err)
+	}
+
+	// Expired states are swept once the sweep interval passed
+	now = now.Add(2 * time.Hour)
+	_, err := m.AddFailure("user:c", policy, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(m.entries))
+
+	// A full store drops the state that expires first
+	for _, key := range []string{"user:d", "user:e", "user:f"} {
+		now = now.Add(time.Second)
+		_, err = m.AddFailure(key, policy, now)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3, len(m.entries))
+	state, err := m.Get("user:c")
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+}
+
+func Test_userThrottleKey(t *testing.T) {
+	assert.Equal(t, "user:abc", userThrottleKey("abc"))
+	assert.Equal(t, 5+LOGIN_THROTTLE_MAX_USERNAME_BYTES, len(userThrottleKey(strings.Repeat("a", 100000))))
+}
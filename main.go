@@ -4,6 +4,9 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	_ "github.com/mattn/go-sqlite3"
@@ -50,12 +53,27 @@ func main() {
 	sessionRepo := NewSessionRepository(db)
 	userRepo := NewUserRepository(db)
 	mazeRepo := NewMazeRepository(db, hashId)
-	userController := NewUserController(userRepo, sessionRepo)
-	mazeController := NewMazeController(mazeRepo, mazeSolver)
-	userApi := NewUserApi(userController)
-	mazeApi := NewMazeApi(mazeController, userController)
-	userApi.Init(router)
-	mazeApi.Init(router)
+	teamRepo := NewTeamRepository(db, hashId)
+	loginThrottle := NewLoginThrottle(newLoginAttemptStore(db), DefaultUserLoginThrottlePolicy(), DefaultIpLoginThrottlePolicy())
+	twoFactorRepo := NewTwoFactorRepository(db)
+	usageRepo := NewUsageRepository(db)
+	auditLog := NewAuditLog(NewAuditRepository(db))
+	promoteAdmins(userRepo)
+	userController := NewUserController(userRepo, sessionRepo, twoFactorRepo, loginThrottle, auditLog, newArgon2Params(), newPasswordPolicy())
+	quotaPolicy := newQuotaPolicy()
+	mazeController := NewMazeController(mazeRepo, mazeSolver, auditLog, quotaPolicy.MaxStoredMazes)
+	teamController := NewTeamController(teamRepo, userRepo)
+	quotaController := NewQuotaController(usageRepo, mazeRepo, quotaPolicy)
+	userApi := NewUserApi(userController, mazeController, quotaController)
+	mazeApi := NewMazeApi(mazeController, userController, quotaController, teamController)
+	adminApi := NewAdminApi(userController, mazeController, auditLog)
+	teamApi := NewTeamApi(teamController, mazeController, userController, quotaController)
+	v1 := ApiVersion{
+		Prefix:    "/v1",
+		Endpoints: []ApiEndpoint{userApi, mazeApi, adminApi, teamApi, NewOpenApi("/v1")},
+	}
+	initApiVersion(router, v1)
+	initDeprecatedAliases(router, v1, UNVERSIONED_API_DEPRECATED, UNVERSIONED_API_SUNSET)
 
 	// Start server
 	log.Println("Starting server ...")
@@ -63,3 +81,79 @@ func main() {
 	http.ListenAndServe(":8080", router)
 
 }
+
+// newLoginAttemptStore selects where the login throttle keeps its state, LOGIN_THROTTLE_STORE=db shares it via the database
+func newLoginAttemptStore(db *sql.DB) LoginAttemptStore {
+	if os.Getenv("LOGIN_THROTTLE_STORE") == "db" {
+		return NewLoginAttemptRepository(db)
+	}
+	return NewMemoryLoginAttemptStore()
+}
+
+// newArgon2Params reads the argon2 parameters for new password hashes from ARGON2_MEMORY, ARGON2_TIME and ARGON2_THREADS,
+// invalid values stop the server at startup instead of failing every login
+func newArgon2Params() Argon2Params {
+	params := DefaultArgon2Params()
+	params.Memory = uint32(envUint("ARGON2_MEMORY", uint64(params.Memory), 32))
+	params.Time = uint32(envUint("ARGON2_TIME", uint64(params.Time), 32))
+	params.Threads = uint8(envUint("ARGON2_THREADS", uint64(params.Threads), 8))
+	err := params.Validate()
+	if err != nil {
+		panic("invalid ARGON2_MEMORY, ARGON2_TIME or ARGON2_THREADS: " + err.Error())
+	}
+	return params
+}
+
+// newPasswordPolicy reads PASSWORD_MIN_LENGTH and an optional BREACHED_PASSWORDS_FILE replacing the builtin list
+func newPasswordPolicy() PasswordPolicy {
+	policy := DefaultPasswordPolicy()
+	policy.MinLength = int(envUint("PASSWORD_MIN_LENGTH", uint64(policy.MinLength), 16))
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		policy.BreachedPasswords, err = LoadBreachedPasswords(file)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return policy
+}
+
+// newQuotaPolicy reads QUOTA_MAX_MAZES, QUOTA_MAX_GRID_CELLS, QUOTA_GENERATIONS_PER_DAY and QUOTA_SOLVES_PER_DAY, 0 disables a limit
+func newQuotaPolicy() QuotaPolicy {
+	policy := DefaultQuotaPolicy()
+	policy.MaxStoredMazes = envUint("QUOTA_MAX_MAZES", policy.MaxStoredMazes, 64)
+	policy.MaxGridCells = envUint("QUOTA_MAX_GRID_CELLS", policy.MaxGridCells, 64)
+	policy.GenerationsPerDay = envUint("QUOTA_GENERATIONS_PER_DAY", policy.GenerationsPerDay, 64)
+	policy.SolvesPerDay = envUint("QUOTA_SOLVES_PER_DAY", policy.SolvesPerDay, 64)
+	return policy
+}
+
+func envUint(name string, defaultValue uint64, bitSize int) uint64 {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		panic(name + " must be a positive number: " + err.Error())
+	}
+	return result
+}
+
+// promoteAdmins grants the admin role to the comma separated usernames in ADMIN_USERS
+func promoteAdmins(userRepo UserRepository) {
+	for _, username := range strings.Split(os.Getenv("ADMIN_USERS"), ",") {
+		username = strings.TrimSpace(username)
+		if username == "" {
+			continue
+		}
+		err := userRepo.UpdateRole(username, ROLE_ADMIN)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"errors"
 	"regexp"
 	"strconv"
 	"strings"
@@ -9,17 +8,22 @@ import (
 
 type Maze struct {
 	Id         string
+	UserId     string
+	TeamId     string
 	EntranceX  uint16
 	EntranceY  uint16
 	GridWidth  uint16
 	GridHeight uint16
 	Walls      []byte
+	// Stats are nil until they are computed, see computeMazeStats
+	Stats *MazeStats
 }
 
 func (maze *Maze) InitWalls(width uint16, height uint16) {
 	maze.GridWidth = width
 	maze.GridHeight = height
-	numberOfBytes := ((maze.GridWidth * maze.GridHeight) / 8) + 1
+	// Calculated as int, the number of cells does not fit into uint16
+	numberOfBytes := (int(maze.GridWidth)*int(maze.GridHeight))/8 + 1
 	maze.Walls = make([]byte, numberOfBytes)
 	for i := 0; i < len(maze.Walls); i++ {
 		maze.Walls[i] = 0
@@ -27,11 +31,16 @@ func (maze *Maze) InitWalls(width uint16, height uint16) {
 }
 
 func (m *Maze) GetByteAddress(x uint16, y uint16) uint32 {
-	return uint32((y*m.GridWidth + x) / 8)
+	return m.cellIndex(x, y) / 8
 }
 
 func (m *Maze) GetBitAddress(x uint16, y uint16) uint8 {
-	return uint8((y*m.GridWidth + x) % 8)
+	return uint8(m.cellIndex(x, y) % 8)
+}
+
+// cellIndex numbers the cells row by row
+func (m *Maze) cellIndex(x uint16, y uint16) uint32 {
+	return uint32(y)*uint32(m.GridWidth) + uint32(x)
 }
 
 func (m *Maze) SetWall(x uint16, y uint16, isWall bool) {
@@ -70,7 +79,7 @@ var POSITION_ROW_PATTERN = regexp.MustCompile("[0-9]{1,2}$")
 
 func readPosition(position string) (uint16, uint16, error) {
 	if !POSITION_VALIDATION_PATTERN.MatchString(position) {
-		return 0, 0, errors.New("Invalid position")
+		return 0, 0, newCodedError(ERROR_CODE_MAZE_INVALID_POSITION, "Invalid position '"+position+"'")
 	}
 
 	// Column
@@ -83,8 +92,8 @@ func readPosition(position string) (uint16, uint16, error) {
 
 	// Row
 	row, err := strconv.Atoi(POSITION_ROW_PATTERN.FindString(position))
-	if err != nil {
-		return 0, 0, err
+	if err != nil || row < 1 {
+		return 0, 0, newCodedError(ERROR_CODE_MAZE_INVALID_POSITION, "Invalid position '"+position+"'")
 	}
 
 	return column, uint16(row - 1), nil
@@ -94,29 +103,30 @@ var GRIDSIZE_VALIDATION_PATTERN = regexp.MustCompile("^[0-9]{1,}x[0-9]{1,}$")
 
 func readGridSize(gridsize string) (uint16, uint16, error) {
 	if !GRIDSIZE_VALIDATION_PATTERN.MatchString(gridsize) {
-		return 0, 0, errors.New("Invalid grid size")
+		return 0, 0, newCodedError(ERROR_CODE_MAZE_INVALID_GRID_SIZE, "Invalid grid size")
 	}
 	sizes := strings.Split(gridsize, "x")
 	width, err := strconv.ParseUint(sizes[0], 10, 16)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, newCodedError(ERROR_CODE_MAZE_INVALID_GRID_SIZE, "Invalid grid size, the width is at most 65535")
 	}
 	height, err := strconv.ParseUint(sizes[1], 10, 16)
 	if err != nil {
-		return 0, 0, err
+		return 0, 0, newCodedError(ERROR_CODE_MAZE_INVALID_GRID_SIZE, "Invalid grid size, the height is at most 65535")
 	}
 
 	return uint16(width), uint16(height), nil
 }
 
 func applyStringWalls(maze *Maze, walls []string) error {
-	for _, wall := range walls {
+	for i, wall := range walls {
+		field := "walls[" + strconv.Itoa(i) + "]"
 		x, y, err := readPosition(wall)
 		if err != nil {
-			return err
+			return withField(err, field)
 		}
 		if x >= maze.GridWidth || y >= maze.GridHeight {
-			return errors.New("wall position out of range x=" + strconv.Itoa(int(x)) + " y=" + strconv.Itoa(int(y)))
+			return newCodedError(ERROR_CODE_MAZE_WALL_OUT_OF_RANGE, "wall position out of range x="+strconv.Itoa(int(x))+" y="+strconv.Itoa(int(y))).withField(field)
 		}
 		maze.SetWall(x, y, true)
 	}
@@ -125,10 +135,10 @@ func applyStringWalls(maze *Maze, walls []string) error {
 
 func stringsToPath(path []string) (*PathItem, error) {
 	var pathItem *PathItem
-	for _, p := range path {
+	for i, p := range path {
 		x, y, err := readPosition(p)
 		if err != nil {
-			return nil, err
+			return nil, withField(err, "path["+strconv.Itoa(i)+"]")
 		}
 
 		pathItem = &PathItem{X: x, Y: y, Prev: pathItem}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+)
+
+const (
+	MAZE_ANIMATION_DEFAULT_FPS        = 10
+	MAZE_ANIMATION_MAX_FPS            = 50
+	MAZE_ANIMATION_DEFAULT_MAX_FRAMES = 200
+	MAZE_ANIMATION_MAX_FRAMES         = 1000
+	MAZE_ANIMATION_DEFAULT_CELL_SIZE  = 8
+	// MAZE_ANIMATION_FINAL_DELAY holds the last frame for two seconds before the animation starts again
+	MAZE_ANIMATION_FINAL_DELAY = 200
+	// MAZE_ANIMATION_MAX_STEPS bounds the recorded steps, longer searches are only shown up to this step
+	MAZE_ANIMATION_MAX_STEPS = 1 << 20
+	// MAZE_ANIMATION_MAX_PIXELS is smaller than MAZE_DRAW_MAX_PIXELS, because every frame may cover the whole image
+	MAZE_ANIMATION_MAX_PIXELS = 4 << 20
+)
+
+type MazeAnimationOptions struct {
+	Draw MazeDrawOptions
+	// Fps is the number of frames per second, GIF delays are counted in hundredths of a second
+	Fps int
+	// MaxFrames bounds the frames including the first and the last one, the steps are spread evenly over them
+	MaxFrames int
+}
+
+func DefaultMazeAnimationOptions() MazeAnimationOptions {
+	draw := DefaultMazeDrawOptions()
+	draw.CellSize = MAZE_ANIMATION_DEFAULT_CELL_SIZE
+	return MazeAnimationOptions{
+		Draw:      draw,
+		Fps:       MAZE_ANIMATION_DEFAULT_FPS,
+		MaxFrames: MAZE_ANIMATION_DEFAULT_MAX_FRAMES,
+	}
+}
+
+// validateMazeAnimationOptions checks the options before the search or the generation starts
+func validateMazeAnimationOptions(width uint16, height uint16, options MazeAnimationOptions) error {
+	if options.Fps < 1 || options.Fps > MAZE_ANIMATION_MAX_FPS {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the fps must be between 1 and %d", MAZE_ANIMATION_MAX_FPS)).withField("fps")
+	}
+	if options.MaxFrames < 2 || options.MaxFrames > MAZE_ANIMATION_MAX_FRAMES {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the maxFrames must be between 2 and %d", MAZE_ANIMATION_MAX_FRAMES)).withField("maxFrames")
+	}
+	layout, err := newMazeDrawLayout(&Maze{GridWidth: width, GridHeight: height}, options.Draw)
+	if err != nil {
+		return err
+	}
+	if layout.bounds.Dx()*layout.bounds.Dy() > MAZE_ANIMATION_MAX_PIXELS {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the animation must not have more than %d pixels, use a smaller cellSize", MAZE_ANIMATION_MAX_PIXELS)).withField("cellSize")
+	}
+	return nil
+}
+
+type mazeTraceStep struct {
+	x uint16
+	y uint16
+}
+
+// mazeTrace records the steps of a solver or generator for the animation
+type mazeTrace struct {
+	steps []mazeTraceStep
+}
+
+func (t *mazeTrace) record(x uint16, y uint16) {
+	if len(t.steps) < MAZE_ANIMATION_MAX_STEPS {
+		t.steps = append(t.steps, mazeTraceStep{x, y})
+	}
+}
+
+// mazeAnimation paints on a canvas and turns the cells that changed since the last frame into the next frame,
+// the frames are not disposed, so each one only covers the changes
+type mazeAnimation struct {
+	layout *mazeDrawLayout
+	canvas *image.Paletted
+	dirty  image.Rectangle
+	delay  int
+	gif    *gif.GIF
+}
+
+func newMazeAnimation(maze *Maze, options MazeAnimationOptions) (*mazeAnimation, error) {
+	err := validateMazeAnimationOptions(maze.GridWidth, maze.GridHeight, options)
+	if err != nil {
+		return nil, err
+	}
+	img, layout, err := renderMaze(maze, nil, options.Draw)
+	if err != nil {
+		return nil, err
+	}
+
+	theme := options.Draw.Theme
+	palette := color.Palette{theme.Background, theme.Wall, theme.Floor, theme.Path, theme.Entrance, theme.Exit,
+		theme.Grid, theme.Label, theme.Visited, theme.Frontier}
+	canvas := image.NewPaletted(img.Bounds(), palette)
+	for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			canvas.SetColorIndex(x, y, uint8(palette.Index(img.RGBAAt(x, y))))
+		}
+	}
+
+	animation := &mazeAnimation{
+		layout: layout,
+		canvas: canvas,
+		dirty:  canvas.Bounds(),
+		delay:  100 / options.Fps,
+		gif: &gif.GIF{
+			Config: image.Config{ColorModel: palette, Width: canvas.Bounds().Dx(), Height: canvas.Bounds().Dy()},
+		},
+	}
+	animation.flush(animation.delay)
+	return animation, nil
+}
+
+func (a *mazeAnimation) fill(x uint16, y uint16, c color.RGBA) {
+	drawMazeCell(a.canvas, a.layout, x, y, c, c != a.layout.options.Theme.Wall)
+	a.dirty = a.dirty.Union(a.layout.cell(x, y))
+}
+
+func (a *mazeAnimation) markers(maze *Maze, path *PathItem) {
+	drawMazeMarkers(a.canvas, a.layout, maze, path)
+	a.dirty = a.dirty.Union(a.layout.cell(maze.EntranceX, maze.EntranceY))
+	if path != nil {
+		a.dirty = a.dirty.Union(a.layout.cell(path.X, path.Y))
+	} else if exitX, exitY, ok := findExit(maze); ok {
+		a.dirty = a.dirty.Union(a.layout.cell(exitX, exitY))
+	}
+}
+
+// play spreads the steps evenly over the frames that are left between the first and the last frame
+func (a *mazeAnimation) play(steps []mazeTraceStep, maxFrames int, paint func(chunk []mazeTraceStep)) {
+	frames := maxFrames - 2
+	if frames < 1 {
+		frames = 1
+	}
+	chunkSize := (len(steps) + frames - 1) / frames
+	for start := 0; start < len(steps); start += chunkSize {
+		end := start + chunkSize
+		if end > len(steps) {
+			end = len(steps)
+		}
+		paint(steps[start:end])
+		a.flush(a.delay)
+	}
+}
+
+// flush adds the cells that changed as frame
+func (a *mazeAnimation) flush(delay int) {
+	if a.dirty.Empty() {
+		return
+	}
+	frame := image.NewPaletted(a.dirty, a.canvas.Palette)
+	for y := a.dirty.Min.Y; y < a.dirty.Max.Y; y++ {
+		copy(frame.Pix[frame.PixOffset(a.dirty.Min.X, y):frame.PixOffset(a.dirty.Max.X, y)],
+			a.canvas.Pix[a.canvas.PixOffset(a.dirty.Min.X, y):a.canvas.PixOffset(a.dirty.Max.X, y)])
+	}
+	a.gif.Image = append(a.gif.Image, frame)
+	a.gif.Delay = append(a.gif.Delay, delay)
+	a.gif.Disposal = append(a.gif.Disposal, gif.DisposalNone)
+	a.dirty = image.Rectangle{}
+}
+
+// finish holds the last frame, so the result stays visible before the animation starts again
+func (a *mazeAnimation) finish(w io.Writer) error {
+	if a.dirty.Empty() {
+		a.gif.Delay[len(a.gif.Delay)-1] = MAZE_ANIMATION_FINAL_DELAY
+	} else {
+		a.flush(MAZE_ANIMATION_FINAL_DELAY)
+	}
+	return gif.EncodeAll(w, a.gif)
+}
+
+// animateSolution plays back the cells in the order the solver visited them, the current cell is highlighted as
+// frontier, and ends with the path of the solution
+func animateSolution(maze *Maze, trace *mazeTrace, path *PathItem, options MazeAnimationOptions, w io.Writer) error {
+	animation, err := newMazeAnimation(maze, options)
+	if err != nil {
+		return err
+	}
+	theme := options.Draw.Theme
+	var frontier *mazeTraceStep
+	animation.play(trace.steps, options.MaxFrames, func(chunk []mazeTraceStep) {
+		if frontier != nil {
+			animation.fill(frontier.x, frontier.y, theme.Visited)
+		}
+		for _, step := range chunk {
+			animation.fill(step.x, step.y, theme.Visited)
+		}
+		frontier = &chunk[len(chunk)-1]
+		animation.fill(frontier.x, frontier.y, theme.Frontier)
+	})
+
+	if frontier != nil {
+		animation.fill(frontier.x, frontier.y, theme.Visited)
+	}
+	for item := path; item != nil; item = item.Prev {
+		animation.fill(item.X, item.Y, theme.Path)
+	}
+	if options.Draw.Markers {
+		animation.markers(maze, path)
+	}
+	return animation.finish(w)
+}
+
+// animateGeneration starts from solid walls and carves the cells of the maze in the order the generator opened them
+func animateGeneration(maze *Maze, trace *mazeTrace, options MazeAnimationOptions, w io.Writer) error {
+	solid := &Maze{EntranceX: maze.EntranceX, EntranceY: maze.EntranceY}
+	solid.InitWalls(maze.GridWidth, maze.GridHeight)
+	for i := range solid.Walls {
+		solid.Walls[i] = 255
+	}
+	solidOptions := options
+	solidOptions.Draw.Markers = false
+	animation, err := newMazeAnimation(solid, solidOptions)
+	if err != nil {
+		return err
+	}
+	theme := options.Draw.Theme
+	var frontier *mazeTraceStep
+	animation.play(trace.steps, options.MaxFrames, func(chunk []mazeTraceStep) {
+		if frontier != nil {
+			animation.fill(frontier.x, frontier.y, theme.Floor)
+		}
+		for _, step := range chunk {
+			animation.fill(step.x, step.y, theme.Floor)
+		}
+		frontier = &chunk[len(chunk)-1]
+		animation.fill(frontier.x, frontier.y, theme.Frontier)
+	})
+
+	if frontier != nil {
+		animation.fill(frontier.x, frontier.y, theme.Floor)
+	}
+	if options.Draw.Markers {
+		animation.markers(maze, nil)
+	}
+	return animation.finish(w)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// composeGif plays the frames without disposal onto one image, like a viewer shows the last frame
+func composeGif(t *testing.T, data *bytes.Buffer) (*gif.GIF, *image.RGBA) {
+	animation, err := gif.DecodeAll(data)
+	assert.Nil(t, err)
+	canvas := image.NewRGBA(image.Rect(0, 0, animation.Config.Width, animation.Config.Height))
+	for _, frame := range animation.Image {
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	}
+	return animation, canvas
+}
+
+func Test_mazeControllerImpl_AnimateSolution(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"#.#.#",
+		"###.#",
+	)
+	m := &mazeControllerImpl{mazeSolver: NewMazeSolver()}
+	options := DefaultMazeAnimationOptions()
+	options.Draw.CellSize = 2
+	options.Draw.Markers = true
+	theme := options.Draw.Theme
+
+	var buffer bytes.Buffer
+	assert.Nil(t, m.AnimateSolution(maze, "min", options, &buffer))
+	animation, img := composeGif(t, &buffer)
+
+	// The first frame is the maze, then one frame per visit and the solution
+	assert.Equal(t, image.Rect(0, 0, 10, 8), animation.Image[0].Bounds())
+	assert.Equal(t, 9, len(animation.Image))
+	assert.Equal(t, []int{10, 10, 10, 10, 10, 10, 10, 10, MAZE_ANIMATION_FINAL_DELAY}, animation.Delay)
+	assert.Equal(t, theme.Entrance, colorAt(img, 2, 0))
+	assert.Equal(t, theme.Path, colorAt(img, 2, 2))
+	assert.Equal(t, theme.Path, colorAt(img, 6, 4))
+	assert.Equal(t, theme.Exit, colorAt(img, 6, 6))
+	assert.Equal(t, theme.Visited, colorAt(img, 2, 4), "the dead end was visited")
+	assert.Equal(t, theme.Wall, colorAt(img, 0, 0))
+
+	t.Run("no solution", func(t *testing.T) {
+		closed := newTestMaze("B1", "#.#", "###")
+		err := m.AnimateSolution(closed, "min", options, &bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrMazeNoSolution)
+	})
+}
+
+func Test_animateSolution_MaxFrames(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#######",
+		"#.......#",
+		"#.......#",
+		"#.......#",
+		"#######.#",
+	)
+	m := &mazeControllerImpl{mazeSolver: NewMazeSolver()}
+	options := DefaultMazeAnimationOptions()
+	options.MaxFrames = 5
+	options.Fps = 25
+
+	var buffer bytes.Buffer
+	assert.Nil(t, m.AnimateSolution(maze, "max", options, &buffer))
+	animation, _ := composeGif(t, &buffer)
+	assert.LessOrEqual(t, len(animation.Image), 5)
+	assert.Equal(t, 4, animation.Delay[1])
+}
+
+func Test_animateGeneration(t *testing.T) {
+	trace := &mazeTrace{}
+	maze := generateMaze(12, 9, trace.record)
+	options := DefaultMazeAnimationOptions()
+	options.Draw.CellSize = 3
+	options.Draw.GridLines = true
+	options.MaxFrames = 20
+
+	var buffer bytes.Buffer
+	assert.Nil(t, animateGeneration(maze, trace, options, &buffer))
+	animation, img := composeGif(t, &buffer)
+	assert.LessOrEqual(t, len(animation.Image), 20)
+
+	// Playing back all frames gives the drawing of the generated maze
+	expected, _, err := renderMaze(maze, nil, options.Draw)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Pix, img.Pix)
+}
+
+func Test_generateMaze_CarveHook(t *testing.T) {
+	trace := &mazeTrace{}
+	maze := generateMaze(10, 10, trace.record)
+
+	carved := map[mazeTraceStep]bool{}
+	for _, step := range trace.steps {
+		assert.False(t, carved[step], "carved twice")
+		assert.False(t, maze.IsWall(step.x, step.y))
+		carved[step] = true
+	}
+	open := 0
+	for y := uint16(0); y < maze.GridHeight; y++ {
+		for x := uint16(0); x < maze.GridWidth; x++ {
+			if !maze.IsWall(x, y) {
+				open++
+			}
+		}
+	}
+	assert.Equal(t, open, len(carved))
+	assert.Equal(t, mazeTraceStep{maze.EntranceX, maze.EntranceY}, trace.steps[0])
+}
+
+func Test_validateMazeAnimationOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(options *MazeAnimationOptions)
+		field  string
+	}{
+		{"fps zero", func(o *MazeAnimationOptions) { o.Fps = 0 }, "fps"},
+		{"fps too high", func(o *MazeAnimationOptions) { o.Fps = MAZE_ANIMATION_MAX_FPS + 1 }, "fps"},
+		{"single frame", func(o *MazeAnimationOptions) { o.MaxFrames = 1 }, "maxFrames"},
+		{"too many frames", func(o *MazeAnimationOptions) { o.MaxFrames = MAZE_ANIMATION_MAX_FRAMES + 1 }, "maxFrames"},
+		{"draw options", func(o *MazeAnimationOptions) { o.Draw.Labels = true; o.Draw.CellSize = 2 }, "labels"},
+		{"too many pixels", func(o *MazeAnimationOptions) { o.Draw.CellSize = 64 }, "cellSize"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultMazeAnimationOptions()
+			tt.modify(&options)
+			err := validateMazeAnimationOptions(100, 100, options)
+			assert.Equal(t, tt.field, err.(*CodedError).Details[0].Field)
+		})
+	}
+	assert.Nil(t, validateMazeAnimationOptions(100, 100, DefaultMazeAnimationOptions()))
+}
+
+func Test_mazeTrace_Limit(t *testing.T) {
+	trace := &mazeTrace{steps: make([]mazeTraceStep, MAZE_ANIMATION_MAX_STEPS-1)}
+	trace.record(1, 2)
+	trace.record(3, 4)
+	assert.Equal(t, MAZE_ANIMATION_MAX_STEPS, len(trace.steps))
+	assert.Equal(t, mazeTraceStep{1, 2}, trace.steps[len(trace.steps)-1])
+}
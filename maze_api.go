@@ -1,25 +1,41 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
 
+// MazeApiDao lists the walls in Walls, or carries them encoded in WallsData, see WALLS_ENCODINGS
 type MazeApiDao struct {
-	Entrace  string   `json:"entrance"`
-	GridSize string   `json:"gridSize"`
-	Walls    []string `json:"walls"`
+	Entrace  string `json:"entrance"`
+	GridSize string `json:"gridSize"`
+	// Walls is listed even without walls, it is only left out when the walls are sent in WallsData
+	Walls         *[]string `json:"walls,omitempty"`
+	WallsEncoding string    `json:"wallsEncoding,omitempty"`
+	WallsData     string    `json:"wallsData,omitempty"`
+}
+
+// wallList returns the listed walls, nil if there is no list
+func (m *MazeApiDao) wallList() []string {
+	if m.Walls == nil {
+		return nil
+	}
+	return *m.Walls
 }
 
 type MazeWithIdDao struct {
 	MazeApiDao
 	Id string `json:"id"`
+	// Difficulty is only listed with the mazes of GET /maze, see MazeStats
+	Difficulty *float64 `json:"difficulty,omitempty"`
 }
 
 type DrawMazeApiDao struct {
@@ -27,137 +43,701 @@ type DrawMazeApiDao struct {
 	Path []string `json:"path"`
 }
 
+// MazePdfRequestDao lists the mazes of a PDF in the order they are printed, a maze may be listed several times
+type MazePdfRequestDao struct {
+	MazeIds []string `json:"mazeIds"`
+}
+
+// MazeDistancesDao holds a row of distances from the entrance for every row of the maze, walls and cells that can not
+// be reached are -1
+type MazeDistancesDao struct {
+	Entrace          string  `json:"entrance"`
+	GridSize         string  `json:"gridSize"`
+	MaxDistance      int     `json:"maxDistance"`
+	ReachableCells   int     `json:"reachableCells"`
+	UnreachableCells int     `json:"unreachableCells"`
+	Distances        [][]int `json:"distances"`
+}
+
+// GeneratedMazeDao is a generated maze, Difficulty is only set if a difficulty was requested
+type GeneratedMazeDao struct {
+	MazeApiDao
+	Difficulty *MazeGenerationDao `json:"difficulty,omitempty"`
+}
+
+// MazeGenerationDao reports how close the generated maze came to the target, Score is its MazeStats.Difficulty
+type MazeGenerationDao struct {
+	Target     MazeDifficultyTarget   `json:"target"`
+	Score      float64                `json:"score"`
+	Reached    bool                   `json:"reached"`
+	Attempts   int                    `json:"attempts"`
+	Parameters MazeGenerateParameters `json:"parameters"`
+	Stats      MazeStats              `json:"stats"`
+}
+
+// MazeStatsDao describes the structure of a stored maze, see MazeStats
+type MazeStatsDao struct {
+	Id       string `json:"id"`
+	Entrace  string `json:"entrance"`
+	GridSize string `json:"gridSize"`
+	MazeStats
+}
+
 type MyMazesDao struct {
 	Mazes []MazeWithIdDao `json:"mazes"`
 }
 
+// MAZE_TEXT_MAX_BODY_SIZE and MAZE_IMAGE_MAX_BODY_SIZE limit mazes that are posted as text or image,
+// the grid size quota applies afterwards
+const (
+	MAZE_TEXT_MAX_BODY_SIZE  = 4 << 20
+	MAZE_IMAGE_MAX_BODY_SIZE = 16 << 20
+)
+
+// ImportedMazeDao is the maze that was read from an image, the id is only set if it was saved
+type ImportedMazeDao struct {
+	MazeApiDao
+	MazeId string `json:"mazeId,omitempty"`
+}
+
+type CreateMazeResponse struct {
+	MazeId string `json:"mazeId"`
+}
+
 type MazeSolutionResponse struct {
 	Path []string `json:"path"`
 }
 
-func NewMazeApi(mazeController MazeController, userController UserController) ApiEndpoint {
+func NewMazeApi(mazeController MazeController, userController UserController, quotaController QuotaController, teamController TeamController) ApiEndpoint {
 	return &mazeApiImpl{
-		mazeController: mazeController,
-		userController: userController,
+		mazeController:  mazeController,
+		userController:  userController,
+		quotaController: quotaController,
+		teamController:  teamController,
 	}
 }
 
 type mazeApiImpl struct {
-	mazeController MazeController
-	userController UserController
+	mazeController  MazeController
+	userController  UserController
+	quotaController QuotaController
+	teamController  TeamController
 }
 
 func (m *mazeApiImpl) Init(router *mux.Router) {
 	router.HandleFunc("/maze", m.GetMyMazes).Methods("GET")
 	router.HandleFunc("/maze", m.CreateMaze).Methods("POST").Headers("Content-Type", "application/json")
+	router.HandleFunc("/maze", m.CreateMazeFromText).Methods("POST").HeadersRegexp("Content-Type", "^text/plain(;.*)?$")
+	router.HandleFunc("/maze/import/image", m.ImportImage).Methods("POST").HeadersRegexp("Content-Type", "^image/(png|gif|jpeg)$")
+	router.HandleFunc("/maze/validate", m.ValidateMaze).Methods("POST").Headers("Content-Type", "application/json")
+	router.HandleFunc("/maze/pdf", m.PrintMazes).Methods("POST").Headers("Content-Type", "application/json")
 	router.HandleFunc("/maze/draw", m.DrawMaze).Methods("POST").Headers("Content-Type", "application/json")
 	router.HandleFunc("/maze/generate", m.Generate).Methods("GET")
+	router.HandleFunc("/maze/generate/animation", m.AnimateGeneration).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}", m.GetMaze).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/distances", m.GetDistances).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/export", m.ExportMaze).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/image", m.GetMazeImage).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/pdf", m.PrintMaze).Methods("GET")
 	router.HandleFunc("/maze/{mazeId}/solution", m.FindSolutionById).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/stats", m.GetStats).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/solution/animation", m.AnimateSolution).Methods("GET")
+	router.HandleFunc("/maze/{mazeId}/solution/image", m.GetSolutionImage).Methods("GET")
 }
 
 func (m *mazeApiImpl) FindSolutionById(w http.ResponseWriter, r *http.Request) {
-	_, err := getUserId(r, m.userController)
+	userId, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, err)
 		return
 	}
 
 	mazeId := mux.Vars(r)["mazeId"]
 	if mazeId == "" {
-		http.Error(w, "the mazeId must be provided", http.StatusBadRequest)
+		writeInvalidParameter(w, "mazeId", "the mazeId must be provided")
 		return
 	}
 
 	stepsParam := orDefault(r.URL.Query().Get("steps"), "min")
 	if stepsParam != "min" && stepsParam != "max" {
-		http.Error(w, "the steps parameter must be either 'min' or 'max'", http.StatusBadRequest)
+		writeInvalidParameter(w, "steps", "the steps parameter must be either 'min' or 'max'")
+		return
+	}
+	maze, err := m.getOwnMaze(userId, mazeId)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	solution, err := m.mazeController.FindSolutionById(mazeId, stepsParam)
+	err = m.quotaController.Consume(userId, USAGE_ACTION_SOLVE)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	err = json.NewEncoder(w).Encode(solution)
+	solution, err := m.mazeController.FindSolutionById(maze.Id, stepsParam)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+
+	writeJson(w, http.StatusOK, solution)
 }
 
-func (m *mazeApiImpl) GetMyMazes(w http.ResponseWriter, r *http.Request) {
+// GetMazeImage draws a stored maze, see writeMazeImage
+func (m *mazeApiImpl) GetMazeImage(w http.ResponseWriter, r *http.Request) {
+	m.writeMazeImage(w, r, false)
+}
+
+// GetSolutionImage draws a stored maze with the solution that FindSolutionById returns, see writeMazeImage
+func (m *mazeApiImpl) GetSolutionImage(w http.ResponseWriter, r *http.Request) {
+	m.writeMazeImage(w, r, true)
+}
+
+// writeMazeImage draws in the format of ?format=png|svg or else of the Accept header. The ETag is derived from the maze
+// and the options, so revalidations are answered without drawing or solving the maze again.
+func (m *mazeApiImpl) writeMazeImage(w http.ResponseWriter, r *http.Request, withSolution bool) {
 	userId, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, err)
 		return
 	}
 
-	mazes, err := m.mazeController.GetUserMazes(userId)
+	// Read request
+	steps := ""
+	if withSolution {
+		steps = orDefault(r.URL.Query().Get("steps"), "min")
+		if steps != "min" && steps != "max" {
+			writeInvalidParameter(w, "steps", "the steps parameter must be either 'min' or 'max'")
+			return
+		}
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		w.Header().Set("Vary", "Accept")
+		offers := []string{CONTENT_TYPE_PNG, CONTENT_TYPE_SVG}
+		contentType, ok := negotiateContentType(r.Header.Get("Accept"), offers)
+		if !ok {
+			writeErrorCode(w, ERROR_CODE_NOT_ACCEPTABLE, "the image is available as "+strings.Join(offers, ", "))
+			return
+		}
+		format = mazeDrawFormatOf(contentType)
+	}
+	contentType, ok := MAZE_DRAW_FORMATS[format]
+	if !ok {
+		writeInvalidParameter(w, "format", "the format must be either 'png' or 'svg'")
+		return
+	}
+	options, err := readFormatDrawOptions(format, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	maze, err := m.getOwnMaze(userId, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	_, err = newMazeDrawLayout(maze, options)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	etag, err := mazeDrawingETag(maze, format, steps, options)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if checkNotModified(w, r, etag) {
+		return
+	}
+
+	// Process request
+	var path *PathItem
+	if withSolution {
+		err = m.quotaController.Consume(userId, USAGE_ACTION_SOLVE)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		solution, err := m.mazeController.FindSolutionById(maze.Id, steps)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		path, err = stringsToPath(solution.Path)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	var image bytes.Buffer
+	err = m.drawMaze(maze, path, format, options, &image)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	setCacheHeaders(w, etag)
+	writeBinary(w, http.StatusOK, contentType, &image)
+}
+
+// GetDistances returns the distances of all cells of a stored maze from the entrance as JSON, or with ?format=png
+// or the Accept header as drawing with the cells coloured by their distance
+func (m *mazeApiImpl) GetDistances(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	var response MyMazesDao
-	for _, maze := range mazes {
-		response.Mazes = append(response.Mazes, MazeWithIdDao{
-			Id: maze.Id,
-			MazeApiDao: MazeApiDao{
-				Entrace:  toApiAddress(maze.EntranceX, maze.EntranceY),
-				GridSize: fmt.Sprintf("%dx%d", maze.GridWidth, maze.GridHeight),
-				Walls:    maze.WallsToStrings(),
-			},
+	// Read request
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		w.Header().Set("Vary", "Accept")
+		offers := []string{CONTENT_TYPE_JSON, CONTENT_TYPE_PNG}
+		contentType, ok := negotiateContentType(r.Header.Get("Accept"), offers)
+		if !ok {
+			writeErrorCode(w, ERROR_CODE_NOT_ACCEPTABLE, "the distances are available as "+strings.Join(offers, ", "))
+			return
+		}
+		format = orDefault(mazeDrawFormatOf(contentType), MAZE_DISTANCE_FORMAT_JSON)
+	}
+	if format != MAZE_DISTANCE_FORMAT_JSON && format != MAZE_DRAW_FORMAT_PNG {
+		writeInvalidParameter(w, "format", "the format must be either 'json' or 'png'")
+		return
+	}
+	options, err := readMazeDrawOptions(r, DefaultMazeDistanceOptions())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	maze, err := m.getOwnMaze(userId, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Process request
+	if format == MAZE_DISTANCE_FORMAT_JSON {
+		distances := mazeDistances(maze)
+		writeJson(w, http.StatusOK, MazeDistancesDao{
+			Entrace:          toApiAddress(maze.EntranceX, maze.EntranceY),
+			GridSize:         fmt.Sprintf("%dx%d", maze.GridWidth, maze.GridHeight),
+			MaxDistance:      distances.Max,
+			ReachableCells:   distances.Reachable,
+			UnreachableCells: distances.Unreachable,
+			Distances:        distances.rows(),
 		})
+		return
 	}
-	err = json.NewEncoder(w).Encode(response)
+	var image bytes.Buffer
+	err = m.mazeController.DrawMaze(maze, nil, options, &image)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+
+	// Write response
+	writeBinary(w, http.StatusOK, CONTENT_TYPE_PNG, &image)
 }
 
-func (m *mazeApiImpl) Generate(w http.ResponseWriter, r *http.Request) {
-	_, err := getUserId(r, m.userController)
+// GetStats returns the stats stored with the maze, they are computed for mazes stored before there were stats
+func (m *mazeApiImpl) GetStats(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	maze, err := m.getOwnMaze(userId, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	stats, err := m.mazeController.GetMazeStats(maze)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, MazeStatsDao{
+		Id:        maze.Id,
+		Entrace:   toApiAddress(maze.EntranceX, maze.EntranceY),
+		GridSize:  fmt.Sprintf("%dx%d", maze.GridWidth, maze.GridHeight),
+		MazeStats: *stats,
+	})
+}
+
+// PrintMaze returns a stored maze as printable PDF, see readMazePdfOptions for the layout
+func (m *mazeApiImpl) PrintMaze(w http.ResponseWriter, r *http.Request) {
+	mazeId := mux.Vars(r)["mazeId"]
+	m.writeMazePdf(w, r, []string{mazeId}, "maze-"+mazeId+".pdf")
+}
+
+// PrintMazes returns the stored mazes of the request body as one PDF, several mazes can share a page
+func (m *mazeApiImpl) PrintMazes(w http.ResponseWriter, r *http.Request) {
+	var request MazePdfRequestDao
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+	m.writeMazePdf(w, r, request.MazeIds, "mazes.pdf")
+}
+
+// writeMazePdf prints the own mazes, each solution that is printed consumes a solve of the quota
+func (m *mazeApiImpl) writeMazePdf(w http.ResponseWriter, r *http.Request, mazeIds []string, filename string) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	options, err := readMazePdfOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	steps := orDefault(r.URL.Query().Get("steps"), "min")
+	if steps != "min" && steps != "max" {
+		writeInvalidParameter(w, "steps", "the steps parameter must be either 'min' or 'max'")
+		return
+	}
+	if len(mazeIds) < 1 || len(mazeIds) > MAZE_PDF_MAX_MAZES {
+		writeInvalidParameter(w, "mazeIds", fmt.Sprintf("between 1 and %d mazes can be printed at once", MAZE_PDF_MAX_MAZES))
+		return
+	}
+	sheets := []MazePdfSheet{}
+	for _, mazeId := range mazeIds {
+		maze, err := m.getOwnMaze(userId, mazeId)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		sheets = append(sheets, MazePdfSheet{Maze: maze})
+	}
+
+	// Process request
+	if options.Solutions {
+		for i, sheet := range sheets {
+			err = m.quotaController.Consume(userId, USAGE_ACTION_SOLVE)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			solution, err := m.mazeController.FindSolutionById(sheet.Maze.Id, steps)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			sheets[i].Solution, err = stringsToPath(solution.Path)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+	}
+	var document bytes.Buffer
+	err = drawMazePdf(sheets, options, &document)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	writeBinary(w, http.StatusOK, CONTENT_TYPE_PDF, &document)
+}
+
+// AnimateSolution plays back the search for the solution of a stored maze as animated GIF
+func (m *mazeApiImpl) AnimateSolution(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	stepsParam := orDefault(r.URL.Query().Get("steps"), "min")
+	if stepsParam != "min" && stepsParam != "max" {
+		writeInvalidParameter(w, "steps", "the steps parameter must be either 'min' or 'max'")
+		return
+	}
+	options, err := readMazeAnimationOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	maze, err := m.getOwnMaze(userId, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = m.quotaController.Consume(userId, USAGE_ACTION_SOLVE)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Process request
+	var animation bytes.Buffer
+	err = m.mazeController.AnimateSolution(maze, stepsParam, options, &animation)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	writeBinary(w, http.StatusOK, CONTENT_TYPE_GIF, &animation)
+}
+
+// AnimateGeneration generates a maze like Generate and returns the carving as animated GIF
+func (m *mazeApiImpl) AnimateGeneration(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	width, height, err := readGenerateSize(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	options, err := readMazeAnimationOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = m.quotaController.CheckGridSize(width, height)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = m.quotaController.Consume(userId, USAGE_ACTION_GENERATE)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Process request
+	var animation bytes.Buffer
+	err = m.mazeController.AnimateGeneration(width, height, options, &animation)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	writeBinary(w, http.StatusOK, CONTENT_TYPE_GIF, &animation)
+}
+
+// GetMaze returns the maze in the representation that is selected with the Accept header
+func (m *mazeApiImpl) GetMaze(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	contentType, ok := negotiateContentType(r.Header.Get("Accept"), MAZE_REPRESENTATIONS)
+	if !ok {
+		writeErrorCode(w, ERROR_CODE_NOT_ACCEPTABLE, "the maze is available as "+strings.Join(MAZE_REPRESENTATIONS, ", "))
+		return
+	}
+
+	maze, err := m.getOwnMaze(userId, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var body bytes.Buffer
+	switch contentType {
+	case CONTENT_TYPE_JSON:
+		wallsEncoding, err := readWallsEncoding(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		mazeDao, err := toEncodedMazeApiDao(maze, wallsEncoding)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJson(w, http.StatusOK, MazeWithIdDao{
+			Id:         maze.Id,
+			MazeApiDao: *mazeDao,
+		})
+		return
+	case CONTENT_TYPE_TEXT:
+		body.WriteString(toAsciiExport(maze))
+		contentType = CONTENT_TYPE_TEXT + "; charset=utf-8"
+	case CONTENT_TYPE_PNG, CONTENT_TYPE_SVG:
+		var options MazeDrawOptions
+		format := mazeDrawFormatOf(contentType)
+		options, err = readFormatDrawOptions(format, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		err = m.drawMaze(maze, nil, format, options, &body)
+	case CONTENT_TYPE_BINARY:
+		var data []byte
+		data, err = maze.MarshalBinary()
+		body.Write(data)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeBinary(w, http.StatusOK, contentType, &body)
+}
+
+// getOwnMaze answers mazes of other users like missing ones, so that their ids can not be probed. Team mazes are
+// shared with every member of the team.
+func (m *mazeApiImpl) getOwnMaze(userId string, mazeId string) (*Maze, error) {
+	maze, err := m.mazeController.GetMazeById(mazeId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, err
+	}
+	if maze.UserId == userId {
+		return maze, nil
+	}
+	if maze.TeamId != "" && m.teamController.CheckTeamRole(userId, maze.TeamId, TEAM_ROLE_VIEWER) == nil {
+		return maze, nil
+	}
+	return nil, ErrMazeNotFound
+}
+
+func (m *mazeApiImpl) GetMyMazes(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	widthStr := orDefault(r.URL.Query().Get("width"), "16")
-	heightStr := orDefault(r.URL.Query().Get("height"), "16")
+	wallsEncoding, err := readWallsEncoding(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	filter, err := readMazeListFilter(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	mazes, err := m.mazeController.GetUserMazes(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := MyMazesDao{Mazes: []MazeWithIdDao{}}
+	for _, maze := range filterMazes(mazes, filter) {
+		mazeDao, err := toEncodedMazeApiDao(maze, wallsEncoding)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		mazeWithId := MazeWithIdDao{
+			Id:         maze.Id,
+			MazeApiDao: *mazeDao,
+		}
+		if maze.Stats != nil {
+			mazeWithId.Difficulty = &maze.Stats.Difficulty
+		}
+		response.Mazes = append(response.Mazes, mazeWithId)
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+func (m *mazeApiImpl) Generate(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
 	// Validate
-	width, err := strconv.ParseUint(widthStr, 10, 32)
+	width, height, err := readGenerateSize(r)
 	if err != nil {
-		http.Error(w, "the width must be numeric", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	height, err := strconv.ParseUint(heightStr, 10, 32)
+	wallsEncoding, err := readWallsEncoding(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var target *MazeDifficultyTarget
+	if value := r.URL.Query().Get("difficulty"); value != "" {
+		parsed, err := parseMazeDifficultyTarget(value)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		err = validateDifficultyGenerateSize(width, height)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		target = &parsed
+	}
+	err = m.quotaController.CheckGridSize(width, height)
 	if err != nil {
-		http.Error(w, "the height must be numeric", http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	err = m.quotaController.Consume(userId, USAGE_ACTION_GENERATE)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
 	// Process
-	maze, err := m.mazeController.Generate(uint16(width), uint16(height))
+	var maze *Maze
+	var generation *MazeGenerationDao
+	if target == nil {
+		maze, err = m.mazeController.Generate(width, height)
+	} else {
+		var result *MazeGenerationResult
+		result, err = m.mazeController.GenerateForDifficulty(width, height, *target)
+		if err == nil {
+			maze = result.Maze
+			generation = &MazeGenerationDao{
+				Target:     result.Target,
+				Score:      maze.Stats.Difficulty,
+				Reached:    result.Reached,
+				Attempts:   result.Attempts,
+				Parameters: result.Parameters,
+				Stats:      *maze.Stats,
+			}
+		}
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
 	// Write response
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(toMazeApiDao(maze))
+	mazeDao, err := toEncodedMazeApiDao(maze, wallsEncoding)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, GeneratedMazeDao{MazeApiDao: *mazeDao, Difficulty: generation})
 }
 
 func (m *mazeApiImpl) CreateMaze(w http.ResponseWriter, r *http.Request) {
 	userId, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, err)
 		return
 	}
 
@@ -165,95 +745,380 @@ func (m *mazeApiImpl) CreateMaze(w http.ResponseWriter, r *http.Request) {
 	var mazeDao MazeApiDao
 	err = json.NewDecoder(r.Body).Decode(&mazeDao)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeInvalidJson(w, err)
+		return
+	}
+	maze, err := fromMazeApiDao(&mazeDao, m.quotaController.CheckGridSize)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 
-	entranceX, entranceY, err := readPosition(mazeDao.Entrace)
+	m.storeMaze(w, userId, maze)
+}
+
+// CreateMazeFromText stores a maze that is drawn as ASCII art, see readAsciiMaze for the format
+func (m *mazeApiImpl) CreateMazeFromText(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	width, height, err := readGridSize(mazeDao.GridSize)
+
+	// Read request
+	text, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAZE_TEXT_MAX_BODY_SIZE))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeErrorCode(w, ERROR_CODE_MAZE_INVALID_ASCII, "the maze can not be read: "+err.Error())
 		return
 	}
-	maze := &Maze{
-		EntranceX: entranceX,
-		EntranceY: entranceY,
+	maze, err := readAsciiMaze(string(text))
+	if err != nil {
+		writeError(w, err)
+		return
 	}
-	maze.InitWalls(width, height)
-	err = applyStringWalls(maze, mazeDao.Walls)
+
+	m.storeMaze(w, userId, maze)
+}
+
+func (m *mazeApiImpl) storeMaze(w http.ResponseWriter, userId string, maze *Maze) {
+	mazeId, err := m.createMaze(userId, maze)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
-	// Process request
-	mazeId, err := m.mazeController.CreateMaze(userId, maze)
+	// Write response
+	writeJson(w, http.StatusCreated, CreateMazeResponse{MazeId: mazeId})
+}
+
+func (m *mazeApiImpl) createMaze(userId string, maze *Maze) (string, error) {
+	err := m.quotaController.CheckGridSize(maze.GridWidth, maze.GridHeight)
+	if err != nil {
+		return "", err
+	}
+	err = m.quotaController.CheckStoredMazes(userId)
+	if err != nil {
+		return "", err
+	}
+	return m.mazeController.CreateMaze(userId, maze)
+}
+
+// ImportImage reads a maze from a PNG, GIF or JPEG image, see importMazeImage. With ?save=true the maze is stored as well.
+func (m *mazeApiImpl) ImportImage(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	options, err := readMazeImageImportOptions(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	saveParam := orDefault(r.URL.Query().Get("save"), "false")
+	if saveParam != "true" && saveParam != "false" {
+		writeInvalidParameter(w, "save", "the save parameter must be either 'true' or 'false'")
+		return
+	}
+	options.CheckGridSize = m.quotaController.CheckGridSize
+	maze, err := readMazeImage(http.MaxBytesReader(w, r.Body, MAZE_IMAGE_MAX_BODY_SIZE), options)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
+	// Process request
+	response := ImportedMazeDao{MazeApiDao: *toMazeApiDao(maze)}
+	status := http.StatusOK
+	if saveParam == "true" {
+		response.MazeId, err = m.createMaze(userId, maze)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		status = http.StatusCreated
+	}
+
 	// Write response
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte(fmt.Sprintf(`{"mazeId": "%s"}`, mazeId)))
+	writeJson(w, status, response)
 }
 
-func (m *mazeApiImpl) DrawMaze(w http.ResponseWriter, r *http.Request) {
+// readMazeDrawOptions reads cellSize, margin, theme and the switches grid, labels and markers, missing parameters keep the defaults
+func readMazeDrawOptions(r *http.Request, defaults MazeDrawOptions) (MazeDrawOptions, error) {
+	options := defaults
+	query := r.URL.Query()
+	if value := query.Get("cellSize"); value != "" {
+		cellSize, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the cellSize must be a number of pixels").withField("cellSize")
+		}
+		options.CellSize = int(cellSize)
+	}
+	if value := query.Get("margin"); value != "" {
+		margin, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the margin must be a number of pixels").withField("margin")
+		}
+		options.Margin = int(margin)
+	}
+	if value := query.Get("theme"); value != "" {
+		theme, ok := MAZE_THEMES[value]
+		if !ok {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the theme must be one of "+strings.Join(mazeThemeNames(), ", ")).withField("theme")
+		}
+		options.Theme = theme
+	}
+	for _, flag := range []struct {
+		name  string
+		value *bool
+	}{{"grid", &options.GridLines}, {"labels", &options.Labels}, {"markers", &options.Markers}} {
+		value := orDefault(query.Get(flag.name), strconv.FormatBool(*flag.value))
+		if value != "true" && value != "false" {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the "+flag.name+" parameter must be either 'true' or 'false'").withField(flag.name)
+		}
+		*flag.value = value == "true"
+	}
+	return options, nil
+}
+
+// readGenerateSize reads the width and height of a maze to generate, both default to 16
+func readGenerateSize(r *http.Request) (uint16, uint16, error) {
+	width, err := strconv.ParseUint(orDefault(r.URL.Query().Get("width"), "16"), 10, 16)
+	if err != nil {
+		return 0, 0, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the width must be a number up to 65535").withField("width")
+	}
+	height, err := strconv.ParseUint(orDefault(r.URL.Query().Get("height"), "16"), 10, 16)
+	if err != nil {
+		return 0, 0, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the height must be a number up to 65535").withField("height")
+	}
+	return uint16(width), uint16(height), validateGenerateSize(uint16(width), uint16(height))
+}
+
+// readMazeListFilter reads minDifficulty, maxDifficulty and sort of the maze list
+func readMazeListFilter(r *http.Request) (MazeListFilter, error) {
+	filter := DefaultMazeListFilter()
+	query := r.URL.Query()
+	for _, limit := range []struct {
+		name  string
+		value *float64
+	}{
+		{"minDifficulty", &filter.MinDifficulty},
+		{"maxDifficulty", &filter.MaxDifficulty},
+	} {
+		if value := query.Get(limit.name); value != "" {
+			difficulty, err := strconv.ParseFloat(value, 64)
+			if err != nil || difficulty < 0 || difficulty > 100 {
+				return filter, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the "+limit.name+" must be a number from 0 to 100").withField(limit.name)
+			}
+			*limit.value = difficulty
+		}
+	}
+	if filter.MinDifficulty > filter.MaxDifficulty {
+		return filter, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the minDifficulty must not be above the maxDifficulty").withField("minDifficulty")
+	}
+	filter.Sort = query.Get("sort")
+	if filter.Sort != "" && filter.Sort != MAZE_SORT_DIFFICULTY && filter.Sort != MAZE_SORT_DIFFICULTY_DESC {
+		return filter, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the sort must be either 'difficulty' or '-difficulty'").withField("sort")
+	}
+	return filter, nil
+}
+
+// readMazeAnimationOptions reads fps and maxFrames next to the options of drawings
+func readMazeAnimationOptions(r *http.Request) (MazeAnimationOptions, error) {
+	options := DefaultMazeAnimationOptions()
+	draw, err := readMazeDrawOptions(r, options.Draw)
+	if err != nil {
+		return options, err
+	}
+	options.Draw = draw
+	query := r.URL.Query()
+	if value := query.Get("fps"); value != "" {
+		fps, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the fps must be a number of frames per second").withField("fps")
+		}
+		options.Fps = int(fps)
+	}
+	if value := query.Get("maxFrames"); value != "" {
+		maxFrames, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the maxFrames must be a number of frames").withField("maxFrames")
+		}
+		options.MaxFrames = int(maxFrames)
+	}
+	return options, nil
+}
+
+// readMazePdfOptions reads pageSize, perPage, title and the switch solutions, missing parameters keep the defaults
+func readMazePdfOptions(r *http.Request) (MazePdfOptions, error) {
+	options := DefaultMazePdfOptions()
+	query := r.URL.Query()
+	options.PageSize = orDefault(query.Get("pageSize"), options.PageSize)
+	if value := query.Get("perPage"); value != "" {
+		perPage, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "perPage must be a number of mazes").withField("perPage")
+		}
+		options.PerPage = int(perPage)
+	}
+	options.Title = orDefault(query.Get("title"), options.Title)
+	solutions := orDefault(query.Get("solutions"), "false")
+	if solutions != "true" && solutions != "false" {
+		return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the solutions parameter must be either 'true' or 'false'").withField("solutions")
+	}
+	options.Solutions = solutions == "true"
+	return options, validateMazePdfOptions(options)
+}
+
+func readMazeImageImportOptions(r *http.Request) (MazeImageImportOptions, error) {
+	options := DefaultMazeImageImportOptions()
+	query := r.URL.Query()
+	if value := query.Get("cellSize"); value != "" {
+		cellSize, err := strconv.ParseUint(value, 10, 16)
+		if err != nil || cellSize == 0 {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the cellSize must be a positive number of pixels").withField("cellSize")
+		}
+		options.CellSize = int(cellSize)
+	}
+	if value := query.Get("threshold"); value != "" {
+		threshold, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the threshold must be a brightness between 0 and 255").withField("threshold")
+		}
+		options.Threshold = uint8(threshold)
+	}
+	if value := query.Get("marker"); value != "" {
+		marker, err := readHexColor(value)
+		if err != nil {
+			return options, newCodedError(ERROR_CODE_INVALID_PARAMETER, "the marker "+err.Error()).withField("marker")
+		}
+		options.Marker = marker
+	}
+	options.Entrance = query.Get("entrance")
+	return options, nil
+}
+
+// ExportMaze downloads the maze as ASCII art, the exit is marked if the maze has exactly one
+func (m *mazeApiImpl) ExportMaze(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, m.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	maze, err := m.getOwnMaze(userId, mux.Vars(r)["mazeId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="maze-`+maze.Id+`.txt"`)
+	writeBinary(w, http.StatusOK, CONTENT_TYPE_TEXT+"; charset=utf-8", bytes.NewBufferString(toAsciiExport(maze)))
+}
+
+// ValidateMaze reports all problems of the submitted maze at once without storing it
+func (m *mazeApiImpl) ValidateMaze(w http.ResponseWriter, r *http.Request) {
 	_, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeError(w, err)
 		return
 	}
 
 	// Read request
-	var mazeDao DrawMazeApiDao
+	var mazeDao MazeApiDao
 	err = json.NewDecoder(r.Body).Decode(&mazeDao)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeInvalidJson(w, err)
 		return
 	}
-	entranceX, entranceY, err := readPosition(mazeDao.Entrace)
+	report := newMazeValidationReport()
+	maze, err := validateMazeApiDao(&mazeDao, report, m.quotaController.CheckGridSize)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	width, height, err := readGridSize(mazeDao.GridSize)
+
+	// Process request
+	if maze != nil {
+		structureReport, err := m.mazeController.ValidateMaze(maze)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		structureReport.Issues = append(report.Issues, structureReport.Issues...)
+		report = structureReport
+	}
+	report.finish()
+
+	// Write response
+	writeJson(w, http.StatusOK, report)
+}
+
+// DrawMaze draws the maze with the path as PNG or with ?format=svg as SVG, the query parameters style the drawing,
+// see readMazeDrawOptions
+func (m *mazeApiImpl) DrawMaze(w http.ResponseWriter, r *http.Request) {
+	_, err := getUserId(r, m.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	maze := &Maze{
-		EntranceX: entranceX,
-		EntranceY: entranceY,
+
+	// Read request
+	format := orDefault(r.URL.Query().Get("format"), MAZE_DRAW_FORMAT_PNG)
+	contentType, ok := MAZE_DRAW_FORMATS[format]
+	if !ok {
+		writeInvalidParameter(w, "format", "the format must be either 'png' or 'svg'")
+		return
 	}
-	maze.InitWalls(width, height)
-	err = applyStringWalls(maze, mazeDao.Walls)
+	options, err := readFormatDrawOptions(format, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var mazeDao DrawMazeApiDao
+	err = json.NewDecoder(r.Body).Decode(&mazeDao)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeInvalidJson(w, err)
 		return
 	}
-	for _, b := range maze.Walls {
-		fmt.Println(b)
+	maze, err := fromMazeApiDao(&mazeDao.MazeApiDao, m.quotaController.CheckGridSize)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
 	pathItem, err := stringsToPath(mazeDao.Path)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	// Process request
-	err = m.mazeController.DrawMaze(maze, pathItem, w)
+	var image bytes.Buffer
+	err = m.drawMaze(maze, pathItem, format, options, &image)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	// Write response
-	w.WriteHeader(http.StatusOK)
+	writeBinary(w, http.StatusOK, contentType, &image)
+}
+
+// readFormatDrawOptions reads the options of a drawing in the format, SVG drawings start from larger cells
+func readFormatDrawOptions(format string, r *http.Request) (MazeDrawOptions, error) {
+	if format == MAZE_DRAW_FORMAT_SVG {
+		return readMazeDrawOptions(r, DefaultMazeSvgOptions())
+	}
+	return readMazeDrawOptions(r, DefaultMazeDrawOptions())
+}
+
+func (m *mazeApiImpl) drawMaze(maze *Maze, path *PathItem, format string, options MazeDrawOptions, w io.Writer) error {
+	if format == MAZE_DRAW_FORMAT_SVG {
+		return drawMazeSvg(maze, path, options, w)
+	}
+	return m.mazeController.DrawMaze(maze, path, options, w)
 }
 
 func orDefault(value string, defaultValue string) string {
@@ -264,28 +1129,109 @@ func orDefault(value string, defaultValue string) string {
 }
 
 func toMazeApiDao(maze *Maze) *MazeApiDao {
+	walls := maze.WallsToStrings()
+	if walls == nil {
+		walls = []string{}
+	}
 	return &MazeApiDao{
 		Entrace:  toApiAddress(maze.EntranceX, maze.EntranceY),
 		GridSize: fmt.Sprintf("%dx%d", maze.GridWidth, maze.GridHeight),
-		Walls:    maze.WallsToStrings(),
+		Walls:    &walls,
+	}
+}
+
+// fromMazeApiDao reads the maze, the grid size is checked before the walls are allocated
+func fromMazeApiDao(mazeDao *MazeApiDao, checkGridSize func(width uint16, height uint16) error) (*Maze, error) {
+	entranceX, entranceY, err := readPosition(mazeDao.Entrace)
+	if err != nil {
+		return nil, withField(err, "entrance")
+	}
+	width, height, err := readGridSize(mazeDao.GridSize)
+	if err != nil {
+		return nil, withField(err, "gridSize")
+	}
+	if entranceX >= width || entranceY >= height {
+		return nil, ErrMazeInvalidEntrance.withField("entrance")
+	}
+	err = checkGridSize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	maze := &Maze{
+		EntranceX: entranceX,
+		EntranceY: entranceY,
 	}
+	maze.InitWalls(width, height)
+	err = applyMazeApiDaoWalls(maze, mazeDao)
+	if err != nil {
+		return nil, err
+	}
+	return maze, nil
+}
+
+func applyMazeApiDaoWalls(maze *Maze, mazeDao *MazeApiDao) error {
+	encoding := orDefault(mazeDao.WallsEncoding, WALLS_ENCODING_LIST)
+	if !isValidWallsEncoding(encoding) {
+		return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_ENCODING, fmt.Sprintf("the walls encoding must be one of %v", WALLS_ENCODINGS)).withField("wallsEncoding")
+	}
+	if encoding == WALLS_ENCODING_LIST {
+		if mazeDao.WallsData != "" {
+			return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "wallsData requires a bitset wallsEncoding").withField("wallsData")
+		}
+		return applyStringWalls(maze, mazeDao.wallList())
+	}
+	if len(mazeDao.wallList()) > 0 {
+		return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "walls can not be listed together with wallsData").withField("walls")
+	}
+	return decodeWalls(maze, encoding, mazeDao.WallsData)
+}
+
+// toEncodedMazeApiDao returns the maze with the walls in the requested encoding
+func toEncodedMazeApiDao(maze *Maze, encoding string) (*MazeApiDao, error) {
+	if encoding == WALLS_ENCODING_LIST {
+		return toMazeApiDao(maze), nil
+	}
+	data, err := encodeWalls(maze, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &MazeApiDao{
+		Entrace:       toApiAddress(maze.EntranceX, maze.EntranceY),
+		GridSize:      fmt.Sprintf("%dx%d", maze.GridWidth, maze.GridHeight),
+		WallsEncoding: encoding,
+		WallsData:     data,
+	}, nil
 }
 
 var AUTHHEADER_VALID_PATTERN = regexp.MustCompile(`^Bearer [a-zA-Z0-9]{1,}$`)
 
 func getUserId(r *http.Request, userController UserController) (string, error) {
+	sessionId, err := getSessionId(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Find user for sessionid
+	userId, err := userController.GetUserForSession(sessionId)
+	if err != nil {
+		return "", err
+	}
+	if userId == "" {
+		return "", newCodedError(ERROR_CODE_AUTH_INVALID_SESSION, "invalid session")
+	}
+	return userId, nil
+}
+
+func getSessionId(r *http.Request) (string, error) {
 	authHeader := r.Header.Get("Authorization")
 	if len(authHeader) < 7 {
-		return "", errors.New("invalid authorization header")
+		return "", newCodedError(ERROR_CODE_AUTH_INVALID_HEADER, "invalid authorization header")
 	}
 
 	if !AUTHHEADER_VALID_PATTERN.MatchString(authHeader) {
-		return "", errors.New("invalid authorization header pattern")
+		return "", newCodedError(ERROR_CODE_AUTH_INVALID_HEADER, "invalid authorization header pattern")
 	}
 
 	// Remove the 'Bearer '
-	sessionId := authHeader[7:]
-
-	// Find user for sessionid
-	return userController.GetUserForSession(sessionId)
+	return authHeader[7:], nil
 }
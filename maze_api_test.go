@@ -2,13 +2,18 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"image/png"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -29,8 +34,17 @@ func (m *MazeControllerMock) CreateMaze(userId string, maze *Maze) (string, erro
 	args := m.Called(maze)
 	return args.String(0), args.Error(1)
 }
-func (m *MazeControllerMock) DrawMaze(maze *Maze, pathItem *PathItem, w io.Writer) error {
-	args := m.Called(maze, pathItem, w)
+func (m *MazeControllerMock) DrawMaze(maze *Maze, pathItem *PathItem, options MazeDrawOptions, w io.Writer) error {
+	args := m.Called(maze, pathItem, options, w)
+	return args.Error(0)
+}
+
+func (m *MazeControllerMock) AnimateSolution(maze *Maze, steps string, options MazeAnimationOptions, w io.Writer) error {
+	args := m.Called(maze, steps, options, w)
+	return args.Error(0)
+}
+func (m *MazeControllerMock) AnimateGeneration(width uint16, height uint16, options MazeAnimationOptions, w io.Writer) error {
+	args := m.Called(width, height, options, w)
 	return args.Error(0)
 }
 
@@ -39,6 +53,74 @@ func (m *MazeControllerMock) FindSolutionById(mazeId string, steps string) (*Maz
 	return args.Get(0).(*MazeSolution), args.Error(1)
 }
 
+func (m *MazeControllerMock) GetMazeById(mazeId string) (*Maze, error) {
+	args := m.Called(mazeId)
+	return args.Get(0).(*Maze), args.Error(1)
+}
+
+func (m *MazeControllerMock) CreateTeamMaze(userId string, teamId string, maze *Maze) (string, error) {
+	args := m.Called(userId, teamId, maze)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MazeControllerMock) GetTeamMazes(teamId string) ([]*Maze, error) {
+	args := m.Called(teamId)
+	return args.Get(0).([]*Maze), args.Error(1)
+}
+
+func (m *MazeControllerMock) GenerateForDifficulty(width uint16, height uint16, target MazeDifficultyTarget) (*MazeGenerationResult, error) {
+	args := m.Called(width, height, target)
+	return args.Get(0).(*MazeGenerationResult), args.Error(1)
+}
+
+func (m *MazeControllerMock) GetMazeStats(maze *Maze) (*MazeStats, error) {
+	args := m.Called(maze)
+	return args.Get(0).(*MazeStats), args.Error(1)
+}
+
+func (m *MazeControllerMock) DeleteMaze(actor string, mazeId string) error {
+	args := m.Called(actor, mazeId)
+	return args.Error(0)
+}
+
+func (m *MazeControllerMock) ValidateMaze(maze *Maze) (*MazeValidationReport, error) {
+	args := m.Called(maze)
+	return args.Get(0).(*MazeValidationReport), args.Error(1)
+}
+
+type QuotaControllerMock struct {
+	mock.Mock
+}
+
+func (m *QuotaControllerMock) CheckGridSize(width uint16, height uint16) error {
+	args := m.Called(width, height)
+	return args.Error(0)
+}
+
+func (m *QuotaControllerMock) CheckStoredMazes(userId string) error {
+	args := m.Called(userId)
+	return args.Error(0)
+}
+
+func (m *QuotaControllerMock) Consume(userId string, action string) error {
+	args := m.Called(userId, action)
+	return args.Error(0)
+}
+
+func (m *QuotaControllerMock) GetUsage(userId string) (*Usage, error) {
+	args := m.Called(userId)
+	return args.Get(0).(*Usage), args.Error(1)
+}
+
+// newUnlimitedQuotaControllerMock allows every request
+func newUnlimitedQuotaControllerMock() *QuotaControllerMock {
+	m := &QuotaControllerMock{}
+	m.On("CheckGridSize", mock.Anything, mock.Anything).Return(nil)
+	m.On("CheckStoredMazes", mock.Anything).Return(nil)
+	m.On("Consume", mock.Anything, mock.Anything).Return(nil)
+	return m
+}
+
 func Test_mazeApiImpl_CreateMaze(t *testing.T) {
 	type fields struct {
 		mazeController MazeController
@@ -74,7 +156,7 @@ func Test_mazeApiImpl_CreateMaze(t *testing.T) {
 					req := httptest.NewRequest("POST", "/maze", serializeMazeApiDao(&MazeApiDao{
 						Entrace:  "A1",
 						GridSize: "10x10",
-						Walls:    []string{},
+						Walls:    &[]string{},
 					}))
 					req.Header.Add("Authorization", "Bearer bbaaaaab")
 					return req
@@ -84,8 +166,11 @@ func Test_mazeApiImpl_CreateMaze(t *testing.T) {
 				if w.Code != http.StatusCreated {
 					t.Errorf("CreateMaze() status code = %v, want %v", w.Code, http.StatusOK)
 				}
-				if w.Body.String() != `{"mazeId": "aaa"}` {
-					t.Errorf("CreateMaze() body = %v, want %v", w.Body.String(), `{"mazeId": "aaa"}`)
+				if w.Body.String() != "{\"mazeId\":\"aaa\"}\n" {
+					t.Errorf("CreateMaze() body = %v, want %v", w.Body.String(), `{"mazeId":"aaa"}`)
+				}
+				if w.Header().Get("Content-Type") != "application/json" {
+					t.Errorf("CreateMaze() content type = %v, want %v", w.Header().Get("Content-Type"), "application/json")
 				}
 			},
 		},
@@ -93,8 +178,9 @@ func Test_mazeApiImpl_CreateMaze(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &mazeApiImpl{
-				mazeController: tt.fields.mazeController,
-				userController: tt.fields.userController,
+				mazeController:  tt.fields.mazeController,
+				userController:  tt.fields.userController,
+				quotaController: newUnlimitedQuotaControllerMock(),
 			}
 			m.CreateMaze(tt.args.w, tt.args.r)
 			tt.verify(t, &tt.fields, tt.args.w.(*httptest.ResponseRecorder))
@@ -174,6 +260,7 @@ func Test_mazeApiImpl_FindSolutionById(t *testing.T) {
 			fields: fields{
 				mazeController: func() MazeController {
 					m := &MazeControllerMock{}
+					m.On("GetMazeById", "abcd").Return(&Maze{Id: "abcd", UserId: "aaa"}, nil)
 					m.On("FindSolutionById", mock.Anything, mock.Anything).Return(&MazeSolution{
 						Length: 10,
 						Path:   []string{"A1", "A2"},
@@ -202,17 +289,1109 @@ func Test_mazeApiImpl_FindSolutionById(t *testing.T) {
 				if w.Code != http.StatusOK {
 					t.Errorf("FindSolutionById() status code = %v, want %v", w.Code, http.StatusOK)
 				}
+				m := f.mazeController.(*MazeControllerMock)
+				m.AssertCalled(t, "FindSolutionById", "abcd", "min")
+			},
+		},
+		{
+			name: "Maze of another user",
+			fields: fields{
+				mazeController: func() MazeController {
+					m := &MazeControllerMock{}
+					m.On("GetMazeById", "abcd").Return(&Maze{Id: "abcd", UserId: "bbb"}, nil)
+					return m
+				}(),
+				userController: func() UserController {
+					m := &UserControllerMock{}
+					m.On("GetUserForSession", mock.Anything).Return("aaa", nil)
+					return m
+				}(),
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: func() *http.Request {
+					req := httptest.NewRequest("GET", "/maze/10/solution?steps=min", nil)
+					req.Header.Add("Authorization", "Bearer bbaaaaab")
+					req = mux.SetURLVars(req, map[string]string{
+						"mazeId": "abcd",
+					})
+					return req
+				}(),
+			},
+			verify: func(t *testing.T, f *fields, w *httptest.ResponseRecorder) {
+				if w.Code != http.StatusNotFound {
+					t.Errorf("FindSolutionById() status code = %v, want %v", w.Code, http.StatusNotFound)
+				}
+				m := f.mazeController.(*MazeControllerMock)
+				m.AssertNotCalled(t, "FindSolutionById", mock.Anything, mock.Anything)
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &mazeApiImpl{
-				mazeController: tt.fields.mazeController,
-				userController: tt.fields.userController,
+				mazeController:  tt.fields.mazeController,
+				userController:  tt.fields.userController,
+				quotaController: newUnlimitedQuotaControllerMock(),
 			}
 			m.FindSolutionById(tt.args.w, tt.args.r)
 			tt.verify(t, &tt.fields, tt.args.w.(*httptest.ResponseRecorder))
 		})
 	}
 }
+
+func Test_mazeApiImpl_Quota(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("abc", nil)
+	mazeController := &MazeControllerMock{}
+	quotaController := &QuotaControllerMock{}
+	quotaController.On("CheckGridSize", uint16(10), uint16(10)).Return(nil)
+	quotaController.On("CheckGridSize", mock.Anything, mock.Anything).Return(&QuotaExceededError{Message: "too many cells"})
+	quotaController.On("CheckStoredMazes", "abc").Return(&QuotaExceededError{Message: "too many mazes"})
+	quotaController.On("Consume", "abc", USAGE_ACTION_GENERATE).Return(&DailyLimitExceededError{Action: USAGE_ACTION_GENERATE, Limit: 5, RetryAfter: 90 * time.Second})
+	m := &mazeApiImpl{
+		mazeController:  mazeController,
+		userController:  userController,
+		quotaController: quotaController,
+	}
+
+	// Too large grids are rejected before generating anything
+	req := httptest.NewRequest("GET", "/maze/generate?width=1000&height=1000", nil)
+	req.Header.Add("Authorization", "Bearer bbaaaaab")
+	w := httptest.NewRecorder()
+	m.Generate(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "too many cells")
+
+	// Used up daily generations
+	req = httptest.NewRequest("GET", "/maze/generate?width=10&height=10", nil)
+	req.Header.Add("Authorization", "Bearer bbaaaaab")
+	w = httptest.NewRecorder()
+	m.Generate(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+
+	// Stored maze limit
+	req = httptest.NewRequest("POST", "/maze", serializeMazeApiDao(&MazeApiDao{
+		Entrace:  "A1",
+		GridSize: "10x10",
+		Walls:    &[]string{},
+	}))
+	req.Header.Add("Authorization", "Bearer bbaaaaab")
+	w = httptest.NewRecorder()
+	m.CreateMaze(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "too many mazes")
+
+	// Submitted grids are checked before their walls are allocated
+	for _, handler := range []http.HandlerFunc{m.CreateMaze, m.DrawMaze, m.ValidateMaze} {
+		req = httptest.NewRequest("POST", "/maze", serializeMazeApiDao(&MazeApiDao{
+			Entrace:  "A1",
+			GridSize: "65535x65535",
+			Walls:    &[]string{},
+		}))
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		w = httptest.NewRecorder()
+		handler(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "too many cells")
+	}
+
+	mazeController.AssertNotCalled(t, "Generate", mock.Anything, mock.Anything)
+	mazeController.AssertNotCalled(t, "CreateMaze", mock.Anything)
+	mazeController.AssertNotCalled(t, "ValidateMaze", mock.Anything)
+}
+
+func Test_mazeApiImpl_ErrorEnvelope(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("abc", nil)
+	userController.On("GetUserForSession", "unknown").Return("", nil)
+	mazeController := &MazeControllerMock{}
+	mazeController.On("CreateMaze", mock.Anything).Return("", ErrMazeInvalidEntrance.withField("entrance"))
+	mazeController.On("GetMazeById", "E42").Return((*Maze)(nil), ErrMazeNotFound)
+	m := &mazeApiImpl{
+		mazeController:  mazeController,
+		userController:  userController,
+		quotaController: newUnlimitedQuotaControllerMock(),
+	}
+	newRequest := func(method string, url string, body io.Reader, session string) *http.Request {
+		req := httptest.NewRequest(method, url, body)
+		req.Header.Add("Authorization", "Bearer "+session)
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		request    *http.Request
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{
+			name:       "unknown session",
+			handler:    m.GetMyMazes,
+			request:    newRequest("GET", "/maze", nil, "unknown"),
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   ERROR_CODE_AUTH_INVALID_SESSION,
+		},
+		{
+			name:       "malformed body",
+			handler:    m.CreateMaze,
+			request:    newRequest("POST", "/maze", bytes.NewBufferString("{"), "bbaaaaab"),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   ERROR_CODE_INVALID_JSON,
+		},
+		{
+			name:    "wall out of range",
+			handler: m.CreateMaze,
+			request: newRequest("POST", "/maze", serializeMazeApiDao(&MazeApiDao{
+				Entrace:  "A1",
+				GridSize: "4x4",
+				Walls:    &[]string{"A2", "E1"},
+			}), "bbaaaaab"),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   ERROR_CODE_MAZE_WALL_OUT_OF_RANGE,
+			wantField:  "walls[1]",
+		},
+		{
+			name:    "validation error of the controller",
+			handler: m.CreateMaze,
+			request: newRequest("POST", "/maze", serializeMazeApiDao(&MazeApiDao{
+				Entrace:  "B2",
+				GridSize: "4x4",
+				Walls:    &[]string{},
+			}), "bbaaaaab"),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   ERROR_CODE_MAZE_INVALID_ENTRANCE,
+			wantField:  "entrance",
+		},
+		{
+			name:       "unknown maze",
+			handler:    m.FindSolutionById,
+			request:    mux.SetURLVars(newRequest("GET", "/maze/E42/solution", nil, "bbaaaaab"), map[string]string{"mazeId": "E42"}),
+			wantStatus: http.StatusNotFound,
+			wantCode:   ERROR_CODE_MAZE_NOT_FOUND,
+		},
+		{
+			name:       "invalid parameter",
+			handler:    m.Generate,
+			request:    newRequest("GET", "/maze/generate?width=70000", nil, "bbaaaaab"),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   ERROR_CODE_INVALID_PARAMETER,
+			wantField:  "width",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, tt.request)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			body := decodeErrorResponse(t, w)
+			assert.Equal(t, tt.wantCode, body.Code)
+			if tt.wantField != "" {
+				assert.Equal(t, tt.wantField, body.Details[0].Field)
+			}
+		})
+	}
+}
+
+func Test_mazeApiImpl_GetMaze(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	maze.Id = "aaa"
+	maze.UserId = "alice"
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetMazeById", "aaa").Return(maze, nil)
+	mazeController.On("GetMazeById", "bbb").Return(&Maze{Id: "bbb", UserId: "bob"}, nil)
+	teamMaze := newTestMaze("B1", "#.#", "#.#")
+	mazeController.On("GetMazeById", "ccc").Return(teamMaze, nil)
+	otherTeamMaze := newTestMaze("B1", "#.#", "#.#")
+	mazeController.On("GetMazeById", "ddd").Return(otherTeamMaze, nil)
+	teamMaze.Id, teamMaze.UserId, teamMaze.TeamId = "ccc", "bob", "8Wa"
+	otherTeamMaze.Id, otherTeamMaze.UserId, otherTeamMaze.TeamId = "ddd", "bob", "9Xb"
+	mazeController.On("DrawMaze", maze, (*PathItem)(nil), DefaultMazeDrawOptions(), mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(io.Writer).Write([]byte("\x89PNG"))
+	}).Return(nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	teamController := &TeamControllerMock{}
+	teamController.On("CheckTeamRole", "alice", "8Wa", TEAM_ROLE_VIEWER).Return(nil)
+	teamController.On("CheckTeamRole", "alice", "9Xb", TEAM_ROLE_VIEWER).Return(ErrTeamNotFound)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), teamController).Init(router)
+
+	request := func(mazeId string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/maze/"+mazeId, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		if accept != "" {
+			req.Header.Add("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"", "application/json", `{"entrance":"B1","gridSize":"3x3","walls":["A1","A2","A3","C1","C3"],"id":"aaa"}` + "\n"},
+		{"text/plain", "text/plain; charset=utf-8", "#E#\n#..\n#X#\n"},
+		{"image/png", "image/png", "\x89PNG"},
+		{"application/octet-stream", "application/octet-stream", "MAZ\x01\x00\x03\x00\x03\x00\x01\x00\x00\x4d\x01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := request("aaa", tt.accept)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+
+	t.Run("svg", func(t *testing.T) {
+		w := request("aaa", "image/svg+xml")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "<svg "))
+		assert.Contains(t, w.Body.String(), `d="M0 0H1V3H0ZM2 0H3V1H2ZM2 2H3V3H2Z"`)
+	})
+
+	t.Run("svg with options", func(t *testing.T) {
+		w := request("aaa?cellSize=20&theme=dark&markers=false", "image/svg+xml")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `width="60" height="60"`)
+		assert.Contains(t, w.Body.String(), `fill="#101418"`)
+		assert.NotContains(t, w.Body.String(), `rx="0.15"`)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := request("aaa", "text/html")
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Equal(t, ERROR_CODE_NOT_ACCEPTABLE, decodeErrorResponse(t, w).Code)
+	})
+
+	t.Run("maze of another user", func(t *testing.T) {
+		w := request("bbb", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, ERROR_CODE_MAZE_NOT_FOUND, decodeErrorResponse(t, w).Code)
+	})
+
+	t.Run("maze of a team", func(t *testing.T) {
+		w := request("ccc", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"ccc"`)
+
+		w = request("ddd", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, ERROR_CODE_MAZE_NOT_FOUND, decodeErrorResponse(t, w).Code)
+	})
+}
+
+func Test_mazeApiImpl_AsciiImportExport(t *testing.T) {
+	mazeController := &MazeControllerMock{}
+	mazeController.On("CreateMaze", mock.Anything, mock.Anything).Return("aaa", nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(method string, url string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		req.Header.Add("Content-Type", "text/plain; charset=utf-8")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("import", func(t *testing.T) {
+		w := request("POST", "/maze", "#E#\n#.#\n#X#\n")
+		assert.Equal(t, http.StatusCreated, w.Code)
+		maze := mazeController.Calls[len(mazeController.Calls)-1].Arguments.Get(0).(*Maze)
+		assert.Equal(t, "#E#\n#.#\n#.#\n", maze.ToAscii())
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		w := request("POST", "/maze", "#E#\n#?#\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := decodeErrorResponse(t, w)
+		assert.Equal(t, ERROR_CODE_MAZE_INVALID_ASCII, body.Code)
+		assert.Equal(t, "line 2, column 2: unexpected character '?', expected '#', '.', 'E' or 'X'", body.Message)
+		assert.Equal(t, []ErrorDetail{{Line: 2, Column: 2, Message: "unexpected character '?', expected '#', '.', 'E' or 'X'"}}, body.Details)
+	})
+
+	t.Run("export", func(t *testing.T) {
+		maze := newTestMaze("B1",
+			"#.#",
+			"#.#",
+			"#.#",
+		)
+		maze.Id = "bbb"
+		maze.UserId = "alice"
+		mazeController.On("GetMazeById", "bbb").Return(maze, nil)
+
+		w := request("GET", "/maze/bbb/export", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="maze-bbb.txt"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "#E#\n#.#\n#X#\n", w.Body.String())
+	})
+}
+
+func Test_mazeApiImpl_DrawMaze(t *testing.T) {
+	mazeController := &MazeControllerMock{}
+	mazeController.On("DrawMaze", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(io.Writer).Write([]byte("\x89PNG"))
+	}).Return(nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(query string) *httptest.ResponseRecorder {
+		body := `{"entrance":"B1","gridSize":"3x3","walls":["A1","C1"],"path":["B1","B2"]}`
+		req := httptest.NewRequest("POST", "/maze/draw?"+query, strings.NewReader(body))
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("options", func(t *testing.T) {
+		w := request("cellSize=12&margin=4&theme=blueprint&grid=true&labels=true&markers=true")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Equal(t, MazeDrawOptions{
+			CellSize:  12,
+			Margin:    4,
+			Theme:     MAZE_THEMES["blueprint"],
+			GridLines: true,
+			Labels:    true,
+			Markers:   true,
+		}, mazeController.Calls[len(mazeController.Calls)-1].Arguments.Get(2))
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		calls := len(mazeController.Calls)
+		w := request("format=svg&theme=blueprint")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 30 30"`))
+		assert.Contains(t, w.Body.String(), `points="1.5,0.5 1.5,1.5"`)
+		assert.Contains(t, w.Body.String(), `fill="#1f4e8c"`)
+		assert.Equal(t, calls, len(mazeController.Calls))
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		w := request("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, DefaultMazeDrawOptions(), mazeController.Calls[len(mazeController.Calls)-1].Arguments.Get(2))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"cellSize=-1", "margin=x", "theme=neon", "grid=yes", "labels=1", "markers=on", "format=gif", "format=svg&cellSize=0"} {
+			w := request(query)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, decodeErrorResponse(t, w).Code, query)
+		}
+	})
+}
+
+func Test_mazeApiImpl_Images(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#.#",
+		"#.#",
+	)
+	maze.Id = "aaa"
+	maze.UserId = "alice"
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetMazeById", "aaa").Return(maze, nil)
+	mazeController.On("GetMazeById", "bbb").Return(&Maze{Id: "bbb", UserId: "bob"}, nil)
+	mazeController.On("FindSolutionById", "aaa", mock.Anything).Return(&MazeSolution{Length: 3, Path: []string{"B1", "B2", "B3"}, Exit: "B3"}, nil)
+	mazeController.On("DrawMaze", maze, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(io.Writer).Write([]byte("\x89PNG"))
+	}).Return(nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	quotaController := newUnlimitedQuotaControllerMock()
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, quotaController, &TeamControllerMock{}).Init(router)
+
+	request := func(url string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Add(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	solveCalls := func() int {
+		count := 0
+		for _, call := range quotaController.Calls {
+			if call.Method == "Consume" && call.Arguments.Get(1) == USAGE_ACTION_SOLVE {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("png", func(t *testing.T) {
+		w := request("/maze/aaa/image?cellSize=8")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+		assert.Regexp(t, `^"[0-9a-f]{32}"$`, w.Header().Get("ETag"))
+		assert.Equal(t, "\x89PNG", w.Body.String())
+		call := mazeController.Calls[len(mazeController.Calls)-1]
+		assert.Equal(t, (*PathItem)(nil), call.Arguments.Get(1))
+		assert.Equal(t, 8, call.Arguments.Get(2).(MazeDrawOptions).CellSize)
+	})
+
+	t.Run("svg by format and by Accept", func(t *testing.T) {
+		w := request("/maze/aaa/image?format=svg")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "<svg "))
+
+		negotiated := request("/maze/aaa/image", "Accept", "image/svg+xml")
+		assert.Equal(t, http.StatusOK, negotiated.Code)
+		assert.Equal(t, w.Header().Get("ETag"), negotiated.Header().Get("ETag"))
+		assert.Equal(t, "Accept", negotiated.Header().Get("Vary"))
+	})
+
+	t.Run("solution", func(t *testing.T) {
+		before := solveCalls()
+		w := request("/maze/aaa/solution/image?format=svg&steps=max")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `points="1.5,0.5 1.5,2.5"`)
+		assert.Equal(t, before+1, solveCalls())
+		mazeController.AssertCalled(t, "FindSolutionById", "aaa", "max")
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		etag := request("/maze/aaa/image").Header().Get("ETag")
+		assert.Equal(t, etag, request("/maze/aaa/image").Header().Get("ETag"))
+		assert.NotEqual(t, etag, request("/maze/aaa/image?theme=dark").Header().Get("ETag"))
+		assert.NotEqual(t, etag, request("/maze/aaa/image?format=svg").Header().Get("ETag"))
+		assert.NotEqual(t, etag, request("/maze/aaa/solution/image").Header().Get("ETag"))
+		assert.NotEqual(t, request("/maze/aaa/solution/image?steps=min").Header().Get("ETag"),
+			request("/maze/aaa/solution/image?steps=max").Header().Get("ETag"))
+	})
+
+	t.Run("not modified", func(t *testing.T) {
+		etag := request("/maze/aaa/solution/image").Header().Get("ETag")
+		before := solveCalls()
+		w := request("/maze/aaa/solution/image", "If-None-Match", `"other", W/`+etag)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, before, solveCalls(), "revalidation does not solve the maze")
+
+		w = request("/maze/aaa/solution/image", "If-None-Match", `"other"`)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			url    string
+			accept string
+			status int
+			code   string
+		}{
+			{"/maze/bbb/image", "", http.StatusNotFound, ERROR_CODE_MAZE_NOT_FOUND},
+			{"/maze/aaa/image?format=gif", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER},
+			{"/maze/aaa/image?cellSize=0", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER},
+			{"/maze/aaa/image", "text/html", http.StatusNotAcceptable, ERROR_CODE_NOT_ACCEPTABLE},
+			{"/maze/aaa/solution/image?steps=all", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER},
+		}
+		for _, tt := range tests {
+			w := request(tt.url, "Accept", tt.accept)
+			assert.Equal(t, tt.status, w.Code, tt.url)
+			assert.Equal(t, tt.code, decodeErrorResponse(t, w).Code, tt.url)
+			assert.Empty(t, w.Header().Get("ETag"), tt.url)
+		}
+	})
+}
+
+func Test_mazeApiImpl_GetDistances(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	maze.Id = "aaa"
+	maze.UserId = "alice"
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetMazeById", "aaa").Return(maze, nil)
+	mazeController.On("GetMazeById", "bbb").Return(&Maze{Id: "bbb", UserId: "bob"}, nil)
+	mazeController.On("DrawMaze", maze, (*PathItem)(nil), mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(io.Writer).Write([]byte("\x89PNG"))
+	}).Return(nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(url string, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		req.Header.Add("Accept", accept)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("json", func(t *testing.T) {
+		w := request("/maze/aaa/distances", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response MazeDistancesDao
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, MazeDistancesDao{
+			Entrace:          "B1",
+			GridSize:         "3x3",
+			MaxDistance:      2,
+			ReachableCells:   4,
+			UnreachableCells: 0,
+			Distances:        [][]int{{-1, 0, -1}, {-1, 1, 2}, {-1, 2, -1}},
+		}, response)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	})
+
+	t.Run("png by format and by Accept", func(t *testing.T) {
+		for _, w := range []*httptest.ResponseRecorder{
+			request("/maze/aaa/distances?format=png&theme=dark", ""),
+			request("/maze/aaa/distances?theme=dark", "image/png"),
+		} {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+			assert.Equal(t, "\x89PNG", w.Body.String())
+			options := mazeController.Calls[len(mazeController.Calls)-1].Arguments.Get(2).(MazeDrawOptions)
+			assert.True(t, options.Distances)
+			assert.Equal(t, MAZE_DISTANCE_DEFAULT_CELL_SIZE, options.CellSize)
+			assert.Equal(t, MAZE_THEMES["dark"], options.Theme)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			url    string
+			accept string
+			status int
+			code   string
+		}{
+			{"/maze/bbb/distances", "", http.StatusNotFound, ERROR_CODE_MAZE_NOT_FOUND},
+			{"/maze/aaa/distances?format=svg", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER},
+			{"/maze/aaa/distances?format=png&cellSize=x", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER},
+			{"/maze/aaa/distances", "image/svg+xml", http.StatusNotAcceptable, ERROR_CODE_NOT_ACCEPTABLE},
+		}
+		for _, tt := range tests {
+			w := request(tt.url, tt.accept)
+			assert.Equal(t, tt.status, w.Code, tt.url)
+			assert.Equal(t, tt.code, decodeErrorResponse(t, w).Code, tt.url)
+		}
+	})
+}
+
+func Test_mazeApiImpl_GetStats(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	maze.Id = "aaa"
+	maze.UserId = "alice"
+	stats := computeMazeStats(maze)
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetMazeById", "aaa").Return(maze, nil)
+	mazeController.On("GetMazeById", "bbb").Return(&Maze{Id: "bbb", UserId: "bob"}, nil)
+	mazeController.On("GetMazeStats", maze).Return(stats, nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/maze/aaa/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response MazeStatsDao
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, MazeStatsDao{Id: "aaa", Entrace: "B1", GridSize: "3x3", MazeStats: *stats}, response)
+	assert.Equal(t, "B3", response.Exit)
+	assert.Equal(t, 1, response.DeadEnds, "C2 is a dead end")
+
+	w = request("/maze/bbb/stats")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mazeController.AssertNumberOfCalls(t, "GetMazeStats", 1)
+}
+
+func Test_mazeApiImpl_GetMyMazes_Difficulty(t *testing.T) {
+	newMaze := func(id string, difficulty float64) *Maze {
+		maze := newTestMaze("B1", "#.#", "#.#")
+		maze.Id = id
+		maze.Stats = &MazeStats{Version: MAZE_STATS_VERSION, Difficulty: difficulty}
+		return maze
+	}
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetUserMazes", "alice").Return([]*Maze{newMaze("a", 35), newMaze("b", 12.5), newMaze("c", 80)}, nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	list := func(url string) ([]string, []float64) {
+		w := request(url)
+		assert.Equal(t, http.StatusOK, w.Code, url)
+		var response MyMazesDao
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+		ids, difficulties := []string{}, []float64{}
+		for _, maze := range response.Mazes {
+			ids = append(ids, maze.Id)
+			difficulties = append(difficulties, *maze.Difficulty)
+		}
+		return ids, difficulties
+	}
+
+	ids, difficulties := list("/maze")
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+	assert.Equal(t, []float64{35, 12.5, 80}, difficulties)
+	ids, _ = list("/maze?sort=difficulty")
+	assert.Equal(t, []string{"b", "a", "c"}, ids)
+	ids, _ = list("/maze?sort=-difficulty&minDifficulty=20")
+	assert.Equal(t, []string{"c", "a"}, ids)
+	ids, _ = list("/maze?maxDifficulty=35")
+	assert.Equal(t, []string{"a", "b"}, ids)
+
+	for url, field := range map[string]string{
+		"/maze?minDifficulty=x":                   "minDifficulty",
+		"/maze?maxDifficulty=101":                 "maxDifficulty",
+		"/maze?minDifficulty=50&maxDifficulty=40": "minDifficulty",
+		"/maze?sort=size":                         "sort",
+	} {
+		w := request(url)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		response := decodeErrorResponse(t, w)
+		assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, response.Code, url)
+		assert.Equal(t, field, response.Details[0].Field, url)
+	}
+}
+
+func Test_mazeApiImpl_Pdf(t *testing.T) {
+	newMaze := func(id string, userId string) *Maze {
+		maze := newTestMaze("B1",
+			"#.#",
+			"#.#",
+			"#.#",
+		)
+		maze.Id = id
+		maze.UserId = userId
+		return maze
+	}
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetMazeById", "aaa").Return(newMaze("aaa", "alice"), nil)
+	mazeController.On("GetMazeById", "ccc").Return(newMaze("ccc", "alice"), nil)
+	mazeController.On("GetMazeById", "bbb").Return(newMaze("bbb", "bob"), nil)
+	mazeController.On("FindSolutionById", mock.Anything, mock.Anything).Return(&MazeSolution{Length: 3, Path: []string{"B1", "B2", "B3"}, Exit: "B3"}, nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	quotaController := newUnlimitedQuotaControllerMock()
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, quotaController, &TeamControllerMock{}).Init(router)
+
+	request := func(method string, url string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		if body != "" {
+			req.Header.Add("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	solveCalls := func() int {
+		count := 0
+		for _, call := range quotaController.Calls {
+			if call.Method == "Consume" && call.Arguments.Get(1) == USAGE_ACTION_SOLVE {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("single maze", func(t *testing.T) {
+		w := request("GET", "/maze/aaa/pdf?pageSize=letter&title=Homework", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="maze-aaa.pdf"`, w.Header().Get("Content-Disposition"))
+		pdf := readTestPdf(t, w.Body.Bytes())
+		assert.Len(t, pdf.find("/Type /Page "), 1)
+		assert.Contains(t, pdf.objects[4], "/Title (Homework) /Subject (1 maze) /Keywords (aaa)")
+		assert.Contains(t, pdf.objects[5], "/MediaBox [0 0 612 792]")
+		mazeController.AssertNotCalled(t, "FindSolutionById", mock.Anything, mock.Anything)
+	})
+
+	t.Run("batch with solutions", func(t *testing.T) {
+		before := solveCalls()
+		w := request("POST", "/maze/pdf?perPage=4&solutions=true&steps=max", `{"mazeIds": ["aaa", "ccc", "aaa"]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `attachment; filename="mazes.pdf"`, w.Header().Get("Content-Disposition"))
+		pdf := readTestPdf(t, w.Body.Bytes())
+		pageIds := pdf.find("/Type /Page ")
+		assert.Len(t, pageIds, 2)
+		assert.Contains(t, pdf.objects[4], "/Subject (3 mazes) /Keywords (aaa ccc aaa)")
+		assert.Contains(t, pdf.streams[pageIds[1]+1], "(3. Solution, length 3)")
+		assert.Equal(t, before+3, solveCalls())
+		mazeController.AssertCalled(t, "FindSolutionById", "ccc", "max")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			method string
+			url    string
+			body   string
+			status int
+			code   string
+			field  string
+		}{
+			{"GET", "/maze/bbb/pdf", "", http.StatusNotFound, ERROR_CODE_MAZE_NOT_FOUND, ""},
+			{"POST", "/maze/pdf", `{"mazeIds": ["aaa", "bbb"]}`, http.StatusNotFound, ERROR_CODE_MAZE_NOT_FOUND, ""},
+			{"POST", "/maze/pdf", `{"mazeIds": []}`, http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER, "mazeIds"},
+			{"POST", "/maze/pdf", `{"mazeIds": `, http.StatusBadRequest, ERROR_CODE_INVALID_JSON, ""},
+			{"GET", "/maze/aaa/pdf?pageSize=a3", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER, "pageSize"},
+			{"GET", "/maze/aaa/pdf?perPage=17", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER, "perPage"},
+			{"GET", "/maze/aaa/pdf?solutions=yes", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER, "solutions"},
+			{"GET", "/maze/aaa/pdf?solutions=true&steps=all", "", http.StatusBadRequest, ERROR_CODE_INVALID_PARAMETER, "steps"},
+		}
+		for _, tt := range tests {
+			w := request(tt.method, tt.url, tt.body)
+			assert.Equal(t, tt.status, w.Code, tt.url)
+			response := decodeErrorResponse(t, w)
+			assert.Equal(t, tt.code, response.Code, tt.url)
+			if tt.field != "" {
+				assert.Equal(t, tt.field, response.Details[0].Field, tt.url)
+			}
+		}
+	})
+}
+
+func Test_mazeApiImpl_Animations(t *testing.T) {
+	maze := newTestMaze("B1", "#.#", "#.#")
+	maze.Id = "aaa"
+	maze.UserId = "alice"
+	writeGif := func(args mock.Arguments) {
+		args.Get(3).(io.Writer).Write([]byte("GIF89a"))
+	}
+	mazeController := &MazeControllerMock{}
+	mazeController.On("GetMazeById", "aaa").Return(maze, nil)
+	mazeController.On("AnimateSolution", maze, mock.Anything, mock.Anything, mock.Anything).Run(writeGif).Return(nil)
+	mazeController.On("AnimateGeneration", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(writeGif).Return(nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	quotaController := newUnlimitedQuotaControllerMock()
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, quotaController, &TeamControllerMock{}).Init(router)
+
+	request := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	lastCall := func() mock.Arguments {
+		return mazeController.Calls[len(mazeController.Calls)-1].Arguments
+	}
+
+	t.Run("solution", func(t *testing.T) {
+		w := request("/maze/aaa/solution/animation?steps=max&fps=20&maxFrames=50&theme=dark")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+		assert.Equal(t, "GIF89a", w.Body.String())
+		expected := DefaultMazeAnimationOptions()
+		expected.Fps = 20
+		expected.MaxFrames = 50
+		expected.Draw.Theme = MAZE_THEMES["dark"]
+		assert.Equal(t, "max", lastCall().Get(1))
+		assert.Equal(t, expected, lastCall().Get(2))
+		quotaController.AssertCalled(t, "Consume", "alice", USAGE_ACTION_SOLVE)
+	})
+
+	t.Run("generation", func(t *testing.T) {
+		w := request("/maze/generate/animation?width=20&height=10")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+		assert.Equal(t, uint16(20), lastCall().Get(0))
+		assert.Equal(t, uint16(10), lastCall().Get(1))
+		assert.Equal(t, DefaultMazeAnimationOptions(), lastCall().Get(2))
+		quotaController.AssertCalled(t, "Consume", "alice", USAGE_ACTION_GENERATE)
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, url := range []string{
+			"/maze/aaa/solution/animation?steps=all",
+			"/maze/aaa/solution/animation?fps=fast",
+			"/maze/aaa/solution/animation?maxFrames=-1",
+			"/maze/generate/animation?width=x",
+			"/maze/generate/animation?width=2",
+			"/maze/generate/animation?theme=neon",
+		} {
+			w := request(url)
+			assert.Equal(t, http.StatusBadRequest, w.Code, url)
+			assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, decodeErrorResponse(t, w).Code, url)
+		}
+	})
+}
+
+func Test_mazeApiImpl_ImportImage(t *testing.T) {
+	expected := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"###.#",
+	)
+	imageData := &bytes.Buffer{}
+	assert.Nil(t, png.Encode(imageData, drawTestMazeImage(t, expected, nil, 4)))
+	mazeController := &MazeControllerMock{}
+	mazeController.On("CreateMaze", mock.Anything, mock.Anything).Return("aaa", nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(url string, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, bytes.NewReader(body))
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		req.Header.Add("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("import", func(t *testing.T) {
+		w := request("/maze/import/image", "image/png", imageData.Bytes())
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"entrance":"B1","gridSize":"5x3","walls":["A1","A2","A3","B3","C1","C3","D1","E1","E2","E3"]}`+"\n", w.Body.String())
+		mazeController.AssertNotCalled(t, "CreateMaze", mock.Anything, mock.Anything)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		w := request("/maze/import/image?save=true&cellSize=4", "image/png", imageData.Bytes())
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var response ImportedMazeDao
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&response))
+		assert.Equal(t, "aaa", response.MazeId)
+		maze := mazeController.Calls[len(mazeController.Calls)-1].Arguments.Get(0).(*Maze)
+		assert.Equal(t, expected.ToAscii(), maze.ToAscii())
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"save=yes", "cellSize=0", "threshold=256", "marker=green"} {
+			w := request("/maze/import/image?"+query, "image/png", imageData.Bytes())
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, decodeErrorResponse(t, w).Code, query)
+		}
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		w := request("/maze/import/image", "image/gif", []byte("GIF89a"))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, ERROR_CODE_MAZE_INVALID_IMAGE, decodeErrorResponse(t, w).Code)
+
+		w = request("/maze/import/image", "image/bmp", imageData.Bytes())
+		assert.NotEqual(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("quota", func(t *testing.T) {
+		quotaController := &QuotaControllerMock{}
+		quotaController.On("CheckGridSize", uint16(5), uint16(3)).Return(&QuotaExceededError{Message: "too many cells"})
+		router := mux.NewRouter()
+		NewMazeApi(mazeController, userController, quotaController, &TeamControllerMock{}).Init(router)
+		req := httptest.NewRequest("POST", "/maze/import/image", bytes.NewReader(imageData.Bytes()))
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		req.Header.Add("Content-Type", "image/png")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "too many cells")
+	})
+}
+
+func Test_mazeApiImpl_WallsEncoding(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	bitset := base64.StdEncoding.EncodeToString([]byte{0b01001101, 0b00000001})
+	mazeController := &MazeControllerMock{}
+	mazeController.On("CreateMaze", mock.Anything, mock.Anything).Return("aaa", nil)
+	mazeController.On("Generate", uint16(3), uint16(3)).Return(maze, nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, newUnlimitedQuotaControllerMock(), &TeamControllerMock{}).Init(router)
+
+	request := func(method string, url string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, body)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		req.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("create", func(t *testing.T) {
+		w := request("POST", "/maze", serializeMazeApiDao(&MazeApiDao{Entrace: "B1", GridSize: "3x3", WallsEncoding: WALLS_ENCODING_BITSET, WallsData: bitset}))
+		assert.Equal(t, http.StatusCreated, w.Code)
+		created := mazeController.Calls[len(mazeController.Calls)-1].Arguments.Get(0).(*Maze)
+		assert.Equal(t, maze.ToAscii(), created.ToAscii())
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		w := request("POST", "/maze", serializeMazeApiDao(&MazeApiDao{Entrace: "B1", GridSize: "5x5", WallsEncoding: WALLS_ENCODING_BITSET, WallsData: bitset}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		body := decodeErrorResponse(t, w)
+		assert.Equal(t, ERROR_CODE_MAZE_INVALID_WALLS_DATA, body.Code)
+		assert.Equal(t, "wallsData", body.Details[0].Field)
+	})
+
+	t.Run("entrance outside the grid", func(t *testing.T) {
+		w := request("POST", "/maze", serializeMazeApiDao(&MazeApiDao{Entrace: "A99", GridSize: "5x5", Walls: &[]string{}}))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		body := decodeErrorResponse(t, w)
+		assert.Equal(t, ERROR_CODE_MAZE_INVALID_ENTRANCE, body.Code)
+		assert.Equal(t, "entrance", body.Details[0].Field)
+	})
+
+	t.Run("walls and data", func(t *testing.T) {
+		w := request("POST", "/maze", serializeMazeApiDao(&MazeApiDao{Entrace: "B1", GridSize: "3x3", Walls: &[]string{"A1"}, WallsEncoding: WALLS_ENCODING_BITSET, WallsData: bitset}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, ERROR_CODE_MAZE_INVALID_WALLS_DATA, decodeErrorResponse(t, w).Code)
+	})
+
+	t.Run("generate", func(t *testing.T) {
+		w := request("GET", "/maze/generate?width=3&height=3&wallsEncoding=bitset-base64", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var generated MazeApiDao
+		assert.Nil(t, json.NewDecoder(w.Body).Decode(&generated))
+		assert.Equal(t, MazeApiDao{Entrace: "B1", GridSize: "3x3", WallsEncoding: WALLS_ENCODING_BITSET, WallsData: bitset}, generated)
+	})
+
+	t.Run("no walls", func(t *testing.T) {
+		open := newTestMaze("B1",
+			"...",
+			"...",
+		)
+		listed, err := json.Marshal(toMazeApiDao(open))
+		assert.Nil(t, err)
+		assert.Equal(t, `{"entrance":"B1","gridSize":"3x2","walls":[]}`, string(listed))
+		encoded, err := toEncodedMazeApiDao(open, WALLS_ENCODING_BITSET)
+		assert.Nil(t, err)
+		assert.Nil(t, encoded.Walls)
+	})
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		w := request("GET", "/maze/generate?width=3&height=3&wallsEncoding=rle", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, ERROR_CODE_MAZE_INVALID_WALLS_ENCODING, decodeErrorResponse(t, w).Code)
+	})
+}
+
+func Test_mazeApiImpl_Generate_Difficulty(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	maze.Stats = computeMazeStats(maze)
+	result := &MazeGenerationResult{
+		Maze:       maze,
+		Target:     MazeDifficultyTarget{Name: "40", Min: 35, Max: 45},
+		Parameters: MazeGenerateParameters{Straightness: 0.5, ExitDepth: 0.7},
+		Attempts:   MAZE_GENERATE_MAX_ATTEMPTS,
+	}
+	mazeController := &MazeControllerMock{}
+	mazeController.On("Generate", uint16(3), uint16(3)).Return(maze, nil)
+	mazeController.On("GenerateForDifficulty", uint16(3), uint16(3), mock.Anything).Return(result, nil)
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("alice", nil)
+	quotaController := newUnlimitedQuotaControllerMock()
+	router := mux.NewRouter()
+	NewMazeApi(mazeController, userController, quotaController, &TeamControllerMock{}).Init(router)
+
+	request := func(url string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Add("Authorization", "Bearer bbaaaaab")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/maze/generate?width=3&height=3&difficulty=40")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var generated GeneratedMazeDao
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&generated))
+	assert.Equal(t, "B1", generated.Entrace)
+	assert.Equal(t, &MazeGenerationDao{
+		Target:     result.Target,
+		Score:      maze.Stats.Difficulty,
+		Reached:    false,
+		Attempts:   MAZE_GENERATE_MAX_ATTEMPTS,
+		Parameters: result.Parameters,
+		Stats:      *maze.Stats,
+	}, generated.Difficulty)
+	mazeController.AssertCalled(t, "GenerateForDifficulty", uint16(3), uint16(3), MazeDifficultyTarget{Name: "40", Min: 35, Max: 45})
+
+	w = request("/maze/generate?width=3&height=3&difficulty=expert")
+	assert.Equal(t, http.StatusOK, w.Code)
+	mazeController.AssertCalled(t, "GenerateForDifficulty", uint16(3), uint16(3), MAZE_DIFFICULTY_LEVELS["expert"])
+
+	// Without difficulty the maze is generated once and there is no report
+	w = request("/maze/generate?width=3&height=3")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "difficulty")
+
+	w = request("/maze/generate?width=3&height=3&difficulty=insane")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	response := decodeErrorResponse(t, w)
+	assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, response.Code)
+	assert.Equal(t, "difficulty", response.Details[0].Field)
+
+	// Too small grids can not be generated, and difficulties only for bounded grids
+	for url, field := range map[string]string{
+		"/maze/generate?width=2&height=3":                   "width",
+		"/maze/generate?width=3&height=1":                   "height",
+		"/maze/generate?width=100&height=100&difficulty=40": "difficulty",
+	} {
+		w = request(url)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		response = decodeErrorResponse(t, w)
+		assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, response.Code, url)
+		assert.Equal(t, field, response.Details[0].Field, url)
+	}
+	quotaController.AssertNumberOfCalls(t, "Consume", 3)
+}
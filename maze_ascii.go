@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAZE_ASCII_WALL     = '#'
+	MAZE_ASCII_OPEN     = '.'
+	MAZE_ASCII_ENTRANCE = 'E'
+	MAZE_ASCII_EXIT     = 'X'
+
+	// MAZE_ASCII_MAX_ERRORS limits the error details of a malformed maze
+	MAZE_ASCII_MAX_ERRORS = 20
+	MAZE_ASCII_MAX_SIZE   = 65535
+)
+
+// ToAscii draws the maze with one character per cell and one line per row
+func (m *Maze) ToAscii() string {
+	return m.toAscii(m.GridWidth, m.GridHeight)
+}
+
+// ToAsciiWithExit draws the maze like ToAscii and marks the exit
+func (m *Maze) ToAsciiWithExit(exitX uint16, exitY uint16) string {
+	return m.toAscii(exitX, exitY)
+}
+
+func (m *Maze) toAscii(exitX uint16, exitY uint16) string {
+	var builder strings.Builder
+	builder.Grow((int(m.GridWidth) + 1) * int(m.GridHeight))
+	for y := uint16(0); y < m.GridHeight; y++ {
+		for x := uint16(0); x < m.GridWidth; x++ {
+			switch {
+			case x == m.EntranceX && y == m.EntranceY:
+				builder.WriteByte(MAZE_ASCII_ENTRANCE)
+			case x == exitX && y == exitY:
+				builder.WriteByte(MAZE_ASCII_EXIT)
+			case m.IsWall(x, y):
+				builder.WriteByte(MAZE_ASCII_WALL)
+			default:
+				builder.WriteByte(MAZE_ASCII_OPEN)
+			}
+		}
+		builder.WriteByte('\n')
+	}
+	return builder.String()
+}
+
+// readAsciiMaze reads a maze drawn with '#' for walls, '.' for open cells, 'E' for the entrance and an optional 'X'
+// for the exit, which must be on the bottom row. The exit only documents the drawing, it is derived from the walls
+// like for every other maze. All problems are reported as details with their line and column.
+func readAsciiMaze(text string) (*Maze, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_ASCII, "the maze is empty")
+	}
+	width := len(lines[0])
+	if width > MAZE_ASCII_MAX_SIZE || len(lines) > MAZE_ASCII_MAX_SIZE {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_ASCII, "the maze must not be wider or higher than "+strconv.Itoa(MAZE_ASCII_MAX_SIZE)+" cells")
+	}
+
+	errs := &asciiErrors{}
+	maze := &Maze{}
+	maze.InitWalls(uint16(width), uint16(len(lines)))
+	entranceLine, entranceColumn := 0, 0
+	exitLine, exitColumn := 0, 0
+	for y, line := range lines {
+		if len(line) != width {
+			errs.add(y+1, 0, fmt.Sprintf("expected %d columns like the first line, found %d", width, len(line)))
+			continue
+		}
+		for x := 0; x < len(line); x++ {
+			switch line[x] {
+			case MAZE_ASCII_WALL:
+				maze.SetWall(uint16(x), uint16(y), true)
+			case MAZE_ASCII_OPEN:
+			case MAZE_ASCII_ENTRANCE:
+				if entranceLine != 0 {
+					errs.add(y+1, x+1, fmt.Sprintf("second entrance, the first one is at line %d, column %d", entranceLine, entranceColumn))
+					continue
+				}
+				entranceLine, entranceColumn = y+1, x+1
+				maze.EntranceX, maze.EntranceY = uint16(x), uint16(y)
+			case MAZE_ASCII_EXIT:
+				if exitLine != 0 {
+					errs.add(y+1, x+1, fmt.Sprintf("second exit, the first one is at line %d, column %d", exitLine, exitColumn))
+					continue
+				}
+				exitLine, exitColumn = y+1, x+1
+				if y != len(lines)-1 {
+					errs.add(y+1, x+1, "the exit must be on the last line")
+				}
+			default:
+				errs.add(y+1, x+1, fmt.Sprintf("unexpected character %q, expected '#', '.', 'E' or 'X'", line[x]))
+			}
+		}
+	}
+	if entranceLine == 0 && len(errs.details) == 0 {
+		errs.add(0, 0, "the maze has no entrance 'E'")
+	}
+	if len(errs.details) > 0 {
+		return nil, errs.toError()
+	}
+	return maze, nil
+}
+
+type asciiErrors struct {
+	details []ErrorDetail
+	count   int
+}
+
+func (e *asciiErrors) add(line int, column int, message string) {
+	e.count++
+	if len(e.details) < MAZE_ASCII_MAX_ERRORS {
+		e.details = append(e.details, ErrorDetail{Line: line, Column: column, Message: message})
+	}
+}
+
+// toError uses the first problem as message, prefixed with its location
+func (e *asciiErrors) toError() error {
+	first := e.details[0]
+	message := first.Message
+	if first.Column > 0 {
+		message = fmt.Sprintf("line %d, column %d: %s", first.Line, first.Column, message)
+	} else if first.Line > 0 {
+		message = fmt.Sprintf("line %d: %s", first.Line, message)
+	}
+	if e.count > 1 {
+		message += fmt.Sprintf(" (and %d more problems)", e.count-1)
+	}
+	return &CodedError{
+		Code:    ERROR_CODE_MAZE_INVALID_ASCII,
+		Message: message,
+		Details: e.details,
+	}
+}
+
+// toAsciiExport draws the maze and marks the exit if there is exactly one
+func toAsciiExport(maze *Maze) string {
+	if exitX, exitY, ok := findExit(maze); ok {
+		return maze.ToAsciiWithExit(exitX, exitY)
+	}
+	return maze.ToAscii()
+}
+
+// findExit returns the open cell on the bottom row that is reachable from the entrance, if there is exactly one
+func findExit(maze *Maze) (uint16, uint16, bool) {
+	if maze.GridWidth == 0 || maze.GridHeight == 0 || maze.EntranceX >= maze.GridWidth || maze.EntranceY >= maze.GridHeight ||
+		maze.IsWall(maze.EntranceX, maze.EntranceY) {
+		return 0, 0, false
+	}
+	reachable, _ := exploreFromEntrance(maze)
+	y := maze.GridHeight - 1
+	found := false
+	exitX := uint16(0)
+	for x := uint16(0); x < maze.GridWidth; x++ {
+		if !reachable[int(y)*int(maze.GridWidth)+int(x)] || (x == maze.EntranceX && y == maze.EntranceY) {
+			continue
+		}
+		if found {
+			return 0, 0, false
+		}
+		found = true
+		exitX = x
+	}
+	return exitX, y, found
+}
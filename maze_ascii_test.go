@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Maze_ToAscii(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	assert.Equal(t, "#E#\n#..\n#.#\n", maze.ToAscii())
+	assert.Equal(t, "#E#\n#..\n#X#\n", maze.ToAsciiWithExit(1, 2))
+	assert.Equal(t, "#E#\n#..\n#X#\n", toAsciiExport(maze))
+}
+
+func Test_readAsciiMaze(t *testing.T) {
+	maze, err := readAsciiMaze("#E###\r\n#...#\r\n###X#\r\n\r\n")
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(5), maze.GridWidth)
+	assert.Equal(t, uint16(3), maze.GridHeight)
+	assert.Equal(t, uint16(1), maze.EntranceX)
+	assert.Equal(t, uint16(0), maze.EntranceY)
+	assert.Equal(t, "#E###\n#...#\n###.#\n", maze.ToAscii())
+	assert.Equal(t, "#E###\n#...#\n###X#\n", toAsciiExport(maze))
+
+	// The exit is optional
+	maze, err = readAsciiMaze("E\n.")
+	assert.Nil(t, err)
+	assert.Equal(t, "E\n.\n", maze.ToAscii())
+}
+
+func Test_readAsciiMaze_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		message string
+		details []ErrorDetail
+	}{
+		{
+			name:    "empty",
+			text:    "\n\n",
+			message: "the maze is empty",
+		},
+		{
+			name:    "no entrance",
+			text:    "#.#\n#.#\n",
+			message: "the maze has no entrance 'E'",
+			details: []ErrorDetail{{Message: "the maze has no entrance 'E'"}},
+		},
+		{
+			name:    "unexpected characters",
+			text:    "#E#\n#o#\n#.a\n",
+			message: "line 2, column 2: unexpected character 'o', expected '#', '.', 'E' or 'X' (and 1 more problems)",
+			details: []ErrorDetail{
+				{Line: 2, Column: 2, Message: "unexpected character 'o', expected '#', '.', 'E' or 'X'"},
+				{Line: 3, Column: 3, Message: "unexpected character 'a', expected '#', '.', 'E' or 'X'"},
+			},
+		},
+		{
+			name:    "ragged lines",
+			text:    "#E#\n#.\n#.#\n",
+			message: "line 2: expected 3 columns like the first line, found 2",
+			details: []ErrorDetail{{Line: 2, Message: "expected 3 columns like the first line, found 2"}},
+		},
+		{
+			name:    "two entrances and exits",
+			text:    "#EE\nX..\n#X.\n",
+			message: "line 1, column 3: second entrance, the first one is at line 1, column 2 (and 2 more problems)",
+			details: []ErrorDetail{
+				{Line: 1, Column: 3, Message: "second entrance, the first one is at line 1, column 2"},
+				{Line: 2, Column: 1, Message: "the exit must be on the last line"},
+				{Line: 3, Column: 2, Message: "second exit, the first one is at line 2, column 1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maze, err := readAsciiMaze(tt.text)
+			assert.Nil(t, maze)
+			coded, ok := err.(*CodedError)
+			assert.True(t, ok)
+			assert.Equal(t, ERROR_CODE_MAZE_INVALID_ASCII, coded.Code)
+			assert.Equal(t, tt.message, coded.Message)
+			assert.Equal(t, tt.details, coded.Details)
+		})
+	}
+}
+
+func Test_findExit(t *testing.T) {
+	_, _, ok := findExit(newTestMaze("B1",
+		"#.#",
+		"...",
+		"...",
+	))
+	assert.False(t, ok, "several exits")
+
+	_, _, ok = findExit(newTestMaze("B1",
+		"#.#",
+		"###",
+		"#.#",
+	))
+	assert.False(t, ok, "no exit")
+
+	x, y, ok := findExit(newTestMaze("A1",
+		"..#",
+		"#.#",
+		"#.#",
+	))
+	assert.True(t, ok)
+	assert.Equal(t, uint16(1), x)
+	assert.Equal(t, uint16(2), y)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// MAZE_BINARY_MAGIC starts the compact binary form of a maze, the last byte is the format version
+var MAZE_BINARY_MAGIC = []byte{'M', 'A', 'Z', 1}
+
+const MAZE_BINARY_HEADER_SIZE = 12
+
+var ErrInvalidMazeBinary = errors.New("invalid binary maze")
+
+// MarshalBinary encodes the maze as magic, width, height, entrance x and y as big endian uint16,
+// followed by one bit per cell in row order, least significant bit first. A set bit is a wall.
+func (m *Maze) MarshalBinary() ([]byte, error) {
+	cells := int(m.GridWidth) * int(m.GridHeight)
+	data := make([]byte, MAZE_BINARY_HEADER_SIZE+(cells+7)/8)
+	copy(data, MAZE_BINARY_MAGIC)
+	binary.BigEndian.PutUint16(data[4:], m.GridWidth)
+	binary.BigEndian.PutUint16(data[6:], m.GridHeight)
+	binary.BigEndian.PutUint16(data[8:], m.EntranceX)
+	binary.BigEndian.PutUint16(data[10:], m.EntranceY)
+	walls := data[MAZE_BINARY_HEADER_SIZE:]
+	for y := uint16(0); y < m.GridHeight; y++ {
+		for x := uint16(0); x < m.GridWidth; x++ {
+			if m.IsWall(x, y) {
+				i := int(y)*int(m.GridWidth) + int(x)
+				walls[i/8] |= 1 << (i % 8)
+			}
+		}
+	}
+	return data, nil
+}
+
+func (m *Maze) UnmarshalBinary(data []byte) error {
+	if len(data) < MAZE_BINARY_HEADER_SIZE || string(data[:4]) != string(MAZE_BINARY_MAGIC) {
+		return ErrInvalidMazeBinary
+	}
+	width := binary.BigEndian.Uint16(data[4:])
+	height := binary.BigEndian.Uint16(data[6:])
+	walls := data[MAZE_BINARY_HEADER_SIZE:]
+	if len(walls) != (int(width)*int(height)+7)/8 {
+		return ErrInvalidMazeBinary
+	}
+
+	m.EntranceX = binary.BigEndian.Uint16(data[8:])
+	m.EntranceY = binary.BigEndian.Uint16(data[10:])
+	m.InitWalls(width, height)
+	for y := uint16(0); y < height; y++ {
+		for x := uint16(0); x < width; x++ {
+			i := int(y)*int(width) + int(x)
+			m.SetWall(x, y, walls[i/8]&(1<<(i%8)) != 0)
+		}
+	}
+	return nil
+}
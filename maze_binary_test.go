@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Maze_MarshalBinary(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+
+	data, err := maze.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{'M', 'A', 'Z', 1, 0, 3, 0, 3, 0, 1, 0, 0, 0b01001101, 0b00000001}, data)
+
+	var decoded Maze
+	assert.Nil(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, maze.ToAscii(), decoded.ToAscii())
+	assert.Equal(t, uint16(1), decoded.EntranceX)
+
+	assert.Equal(t, ErrInvalidMazeBinary, decoded.UnmarshalBinary(data[:13]))
+	assert.Equal(t, ErrInvalidMazeBinary, decoded.UnmarshalBinary([]byte("MAZ")))
+	assert.Equal(t, ErrInvalidMazeBinary, decoded.UnmarshalBinary(append([]byte("ZAM"), data[3:]...)))
+}
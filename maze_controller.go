@@ -1,11 +1,8 @@
 package main
 
 import (
-	"errors"
-	"image"
-	"image/color"
-	"image/png"
 	"io"
+	"log"
 	"math/rand"
 	"time"
 )
@@ -19,25 +16,48 @@ type PathItem struct {
 type MazeController interface {
 	GetUserMazes(userId string) ([]*Maze, error)
 	Generate(width uint16, height uint16) (*Maze, error)
+	GenerateForDifficulty(width uint16, height uint16, target MazeDifficultyTarget) (*MazeGenerationResult, error)
 	CreateMaze(userId string, maze *Maze) (string, error)
-	DrawMaze(maze *Maze, pathItem *PathItem, w io.Writer) error
+	CreateTeamMaze(userId string, teamId string, maze *Maze) (string, error)
+	GetTeamMazes(teamId string) ([]*Maze, error)
+	DrawMaze(maze *Maze, pathItem *PathItem, options MazeDrawOptions, w io.Writer) error
+	AnimateSolution(maze *Maze, steps string, options MazeAnimationOptions, w io.Writer) error
+	AnimateGeneration(width uint16, height uint16, options MazeAnimationOptions, w io.Writer) error
 	FindSolutionById(mazeId string, steps string) (*MazeSolution, error)
+	GetMazeById(mazeId string) (*Maze, error)
+	GetMazeStats(maze *Maze) (*MazeStats, error)
+	DeleteMaze(actor string, mazeId string) error
+	ValidateMaze(maze *Maze) (*MazeValidationReport, error)
 }
 
-func NewMazeController(mazeRepository MazeRepository, mazeSolver MazeSolver) MazeController {
+var (
+	ErrMazeNotFound        = newCodedError(ERROR_CODE_MAZE_NOT_FOUND, "maze not found")
+	ErrMazeInvalidSize     = newCodedError(ERROR_CODE_MAZE_INVALID_SIZE, "Invalid maze size")
+	ErrMazeInvalidEntrance = newCodedError(ERROR_CODE_MAZE_INVALID_ENTRANCE, "Invalid entrance")
+	ErrMazeNoSolution      = newCodedError(ERROR_CODE_MAZE_NO_SOLUTION, "No solution found")
+	ErrMazeMultipleExits   = newCodedError(ERROR_CODE_MAZE_MULTIPLE_EXITS, "Multiple exits found")
+	ErrMazeExitNotAtBottom = newCodedError(ERROR_CODE_MAZE_EXIT_NOT_AT_BOTTOM, "Exit is not on the bottom edge")
+)
+
+// NewMazeController stores at most maxStoredMazes mazes per user, 0 allows any number
+func NewMazeController(mazeRepository MazeRepository, mazeSolver MazeSolver, auditLog AuditLog, maxStoredMazes uint64) MazeController {
 	return &mazeControllerImpl{
 		mazeRepository: mazeRepository,
 		mazeSolver:     mazeSolver,
+		auditLog:       auditLog,
+		maxStoredMazes: maxStoredMazes,
 	}
 }
 
 type mazeControllerImpl struct {
 	mazeRepository MazeRepository
 	mazeSolver     MazeSolver
+	auditLog       AuditLog
+	maxStoredMazes uint64
 }
 
 func (m *mazeControllerImpl) FindSolutionById(mazeId string, steps string) (*MazeSolution, error) {
-	maze, err := m.mazeRepository.SelectById(mazeId)
+	maze, err := m.GetMazeById(mazeId)
 	if err != nil {
 		return nil, err
 	}
@@ -46,8 +66,13 @@ func (m *mazeControllerImpl) FindSolutionById(mazeId string, steps string) (*Maz
 	if err != nil {
 		return nil, err
 	}
+	return selectSolution(solutions, steps)
+}
+
+// selectSolution returns the shortest solution for steps == min and the longest otherwise
+func selectSolution(solutions []MazeSolution, steps string) (*MazeSolution, error) {
 	if len(solutions) == 0 {
-		return nil, errors.New("No solution found")
+		return nil, ErrMazeNoSolution
 	}
 
 	// For steps == min return the shortest solution
@@ -71,113 +96,306 @@ func (m *mazeControllerImpl) FindSolutionById(mazeId string, steps string) (*Maz
 	return &max, nil
 }
 
+func (m *mazeControllerImpl) GetMazeById(mazeId string) (*Maze, error) {
+	maze, err := m.mazeRepository.SelectById(mazeId)
+	if err != nil {
+		return nil, err
+	}
+	if maze == nil {
+		return nil, ErrMazeNotFound
+	}
+	return maze, nil
+}
+
+func (m *mazeControllerImpl) DeleteMaze(actor string, mazeId string) error {
+	maze, err := m.GetMazeById(mazeId)
+	if err != nil {
+		return err
+	}
+	err = m.mazeRepository.DeleteById(mazeId)
+	if err != nil {
+		return err
+	}
+	m.auditLog.Record(AUDIT_EVENT_MAZE_DELETED, actor, mazeId, "", "owner "+maze.UserId)
+	return nil
+}
+
+// GetUserMazes returns the mazes with their stats, missing stats are computed and stored
 func (m *mazeControllerImpl) GetUserMazes(userId string) ([]*Maze, error) {
-	return m.mazeRepository.SelectAllByUserId(userId)
+	mazes, err := m.mazeRepository.SelectAllByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	return m.withMazeStats(mazes)
+}
+
+func (m *mazeControllerImpl) withMazeStats(mazes []*Maze) ([]*Maze, error) {
+	for _, maze := range mazes {
+		_, err := m.GetMazeStats(maze)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mazes, nil
+}
+
+// GetMazeStats returns the stored stats of the maze. Mazes stored before the stats or with stats of another
+// MAZE_STATS_VERSION get their stats computed and stored now.
+func (m *mazeControllerImpl) GetMazeStats(maze *Maze) (*MazeStats, error) {
+	if maze.Stats != nil && maze.Stats.Version == MAZE_STATS_VERSION {
+		return maze.Stats, nil
+	}
+	maze.Stats = computeMazeStats(maze)
+	err := m.mazeRepository.UpdateStats(maze.Id, maze.Stats)
+	if err != nil {
+		return nil, err
+	}
+	return maze.Stats, nil
+}
+
+// storeMazeStats computes the stats of a maze that was just stored. Failing to store them does not fail the
+// creation, GetMazeStats computes them again later.
+func (m *mazeControllerImpl) storeMazeStats(mazeId string, maze *Maze) {
+	maze.Stats = computeMazeStats(maze)
+	err := m.mazeRepository.UpdateStats(mazeId, maze.Stats)
+	if err != nil {
+		log.Println("failed to store the stats of maze", mazeId+":", err)
+	}
 }
 
 func (m *mazeControllerImpl) Generate(width uint16, height uint16) (*Maze, error) {
-	maze := &Maze{}
-	maze.InitWalls(width, height)
-	for i := 0; i < len(maze.Walls); i++ {
-		maze.Walls[i] = 255
+	err := validateGenerateSize(width, height)
+	if err != nil {
+		return nil, err
 	}
+	return generateMaze(width, height, nil), nil
+}
 
-	// Set entrace
-	maze.EntranceX = 1 + uint16(rand.Int31n(int32(maze.GridWidth-2)))
-	maze.EntranceY = 0
-	maze.SetWall(maze.EntranceX, maze.EntranceY, false)
+// GenerateForDifficulty generates mazes until one is within the difficulty band, see generateMazeForDifficulty
+func (m *mazeControllerImpl) GenerateForDifficulty(width uint16, height uint16, target MazeDifficultyTarget) (*MazeGenerationResult, error) {
+	err := validateDifficultyGenerateSize(width, height)
+	if err != nil {
+		return nil, err
+	}
+	return generateMazeForDifficulty(width, height, target), nil
+}
 
-	// Fill the maze
-	generateMaze_nextCell(maze.EntranceX, maze.EntranceY+1, maze)
+// generateMaze carves the maze out of solid walls, the hook is called for every cell that is carved
+func generateMaze(width uint16, height uint16, carve MazeTraceHook) *Maze {
+	maze := carveMaze(width, height, 0, carve)
+	setOpen := func(x uint16, y uint16) {
+		maze.SetWall(x, y, false)
+		if carve != nil {
+			carve(x, y)
+		}
+	}
 
 	// Set exit
 	longestPath := findLongestPathFromEntrace(maze)
 	path := longestPath
 	for path != nil {
 		if path.X == 1 {
-			maze.SetWall(0, path.Y, false)
+			setOpen(0, path.Y)
 			break
 		}
 		if path.Y == 1 {
-			maze.SetWall(path.X, 0, false)
+			setOpen(path.X, 0)
 			break
 		}
 		if path.X == maze.GridWidth-2 {
-			maze.SetWall(maze.GridWidth-1, path.Y, false)
+			setOpen(maze.GridWidth-1, path.Y)
 			break
 		}
 		if path.Y == maze.GridHeight-2 {
-			maze.SetWall(path.X, maze.GridHeight-1, false)
+			setOpen(path.X, maze.GridHeight-1)
 			break
 		}
 		path = path.Prev
 	}
 
-	return maze, nil
+	return maze
+}
+
+// carveMaze opens the entrance on the top edge and carves the maze from there, the exit is left to the caller.
+// The straightness is the chance to carve on in the same direction before trying the others.
+func carveMaze(width uint16, height uint16, straightness float64, carve MazeTraceHook) *Maze {
+	maze := &Maze{}
+	maze.InitWalls(width, height)
+	for i := 0; i < len(maze.Walls); i++ {
+		maze.Walls[i] = 255
+	}
+
+	// Set entrace
+	maze.EntranceX = 1 + uint16(rand.Int31n(int32(maze.GridWidth-2)))
+	maze.EntranceY = 0
+	maze.SetWall(maze.EntranceX, maze.EntranceY, false)
+	if carve != nil {
+		carve(maze.EntranceX, maze.EntranceY)
+	}
+
+	// Fill the maze
+	generateMaze_nextCell(maze.EntranceX, maze.EntranceY+1, MAZE_HEADING_DOWN, straightness, maze, carve)
+	return maze
 }
 
 func (m *mazeControllerImpl) CreateMaze(userId string, maze *Maze) (string, error) {
+	err := m.validateMaze(maze)
+	if err != nil {
+		return "", err
+	}
+
+	mazeId, err := m.mazeRepository.Insert(userId, maze.EntranceX, maze.EntranceY, maze.GridWidth, maze.GridHeight, maze.Walls, m.maxStoredMazes)
+	if err != nil {
+		return "", err
+	}
+	m.storeMazeStats(mazeId, maze)
+	m.auditLog.Record(AUDIT_EVENT_MAZE_CREATED, userId, mazeId, "", "")
+	return mazeId, nil
+}
+
+// CreateTeamMaze validates the maze like CreateMaze and stores it for the team with the user as author.
+func (m *mazeControllerImpl) CreateTeamMaze(userId string, teamId string, maze *Maze) (string, error) {
+	err := m.validateMaze(maze)
+	if err != nil {
+		return "", err
+	}
+
+	mazeId, err := m.mazeRepository.InsertForTeam(teamId, userId, maze.EntranceX, maze.EntranceY, maze.GridWidth, maze.GridHeight, maze.Walls, m.maxStoredMazes)
+	if err != nil {
+		return "", err
+	}
+	m.storeMazeStats(mazeId, maze)
+	m.auditLog.Record(AUDIT_EVENT_MAZE_CREATED, userId, mazeId, "", "team "+teamId)
+	return mazeId, nil
+}
+
+// GetTeamMazes returns the mazes of the team with their stats like GetUserMazes
+func (m *mazeControllerImpl) GetTeamMazes(teamId string) ([]*Maze, error) {
+	mazes, err := m.mazeRepository.SelectAllByTeamId(teamId)
+	if err != nil {
+		return nil, err
+	}
+	return m.withMazeStats(mazes)
+}
+
+func (m *mazeControllerImpl) validateMaze(maze *Maze) error {
 	if maze == nil || maze.GridWidth == 0 || maze.GridHeight == 0 {
-		return "", errors.New("Invalid maze size")
+		return ErrMazeInvalidSize
+	}
+	if maze.EntranceX >= maze.GridWidth || maze.EntranceY >= maze.GridHeight {
+		return ErrMazeInvalidEntrance.withField("entrance")
 	}
 	if maze.EntranceX != 0 && maze.EntranceY != 0 && maze.EntranceX != maze.GridWidth-1 && maze.EntranceY != maze.GridHeight-1 {
-		return "", errors.New("Invalid entrance")
+		return ErrMazeInvalidEntrance.withField("entrance")
 	}
 
 	// Check that at least one solution can be found
 	solutions, err := m.mazeSolver.FindSolutions(maze)
 	if err != nil {
-		return "", err
+		return err
 	}
 	if len(solutions) == 0 {
-		return "", errors.New("No solution found")
+		return ErrMazeNoSolution
 	}
 
 	// Make sure there is only one exit
 	exit := solutions[0].Exit
 	for _, solution := range solutions {
 		if solution.Exit != exit {
-			return "", errors.New("Multiple exits found")
+			return multipleExitsError(solutions)
 		}
 	}
 
 	// Make sure that the exit is on the bottom edge
 	_, exitY, err := readPosition(exit)
 	if err != nil {
-		return "", err
+		return err
 	}
 	if exitY != maze.GridHeight-1 {
-		return "", errors.New("Exit is not on the bottom edge")
+		return ErrMazeExitNotAtBottom
 	}
 
-	return m.mazeRepository.Insert(userId, maze.EntranceX, maze.EntranceY, maze.GridWidth, maze.GridHeight, maze.Walls)
+	return nil
 }
 
-func (m *mazeControllerImpl) DrawMaze(maze *Maze, path *PathItem, w io.Writer) error {
-	var img = image.NewRGBA(image.Rect(0, 0, int(maze.GridWidth), int(maze.GridHeight)))
+// ValidateMaze reports every problem of the maze instead of stopping at the first one, nothing is stored.
+func (m *mazeControllerImpl) ValidateMaze(maze *Maze) (*MazeValidationReport, error) {
+	report := newMazeValidationReport()
+	err := validateMazeStructure(maze, report)
+	if err != nil {
+		return nil, err
+	}
+	report.finish()
+	return report, nil
+}
 
-	// Draw maze
-	for x := uint16(0); x < maze.GridWidth; x++ {
-		for y := uint16(0); y < maze.GridHeight; y++ {
-			if maze.IsWall(x, y) {
-				img.Set(int(x), int(y), color.RGBA{0, 0, 0, 255})
-			} else {
-				img.Set(int(x), int(y), color.RGBA{255, 255, 255, 255})
-			}
+// multipleExitsError lists every exit that was found in the error details
+func multipleExitsError(solutions []MazeSolution) error {
+	err := &CodedError{
+		Code:    ErrMazeMultipleExits.Code,
+		Message: ErrMazeMultipleExits.Message,
+	}
+	seen := map[string]bool{}
+	for _, solution := range solutions {
+		if seen[solution.Exit] {
+			continue
 		}
+		seen[solution.Exit] = true
+		err.Details = append(err.Details, ErrorDetail{Message: "exit at " + solution.Exit})
 	}
+	return err
+}
 
-	// Draw path
-	for path != nil {
-		img.Set(int(path.X), int(path.Y), color.RGBA{255, 0, 0, 255})
-		path = path.Prev
+func (m *mazeControllerImpl) DrawMaze(maze *Maze, path *PathItem, options MazeDrawOptions, w io.Writer) error {
+	return drawMazePng(maze, path, options, w)
+}
+
+// AnimateSolution writes a GIF of the search for the solution that FindSolutionById would return for the steps
+func (m *mazeControllerImpl) AnimateSolution(maze *Maze, steps string, options MazeAnimationOptions, w io.Writer) error {
+	err := validateMazeAnimationOptions(maze.GridWidth, maze.GridHeight, options)
+	if err != nil {
+		return err
+	}
+	trace := &mazeTrace{}
+	solutions, err := m.mazeSolver.TraceSolutions(maze, trace.record)
+	if err != nil {
+		return err
+	}
+	solution, err := selectSolution(solutions, steps)
+	if err != nil {
+		return err
 	}
+	path, err := stringsToPath(solution.Path)
+	if err != nil {
+		return err
+	}
+	return animateSolution(maze, trace, path, options, w)
+}
 
-	png.Encode(w, img)
-	return nil
+// AnimateGeneration generates a maze and writes a GIF of the order in which its cells were carved
+func (m *mazeControllerImpl) AnimateGeneration(width uint16, height uint16, options MazeAnimationOptions, w io.Writer) error {
+	err := validateGenerateSize(width, height)
+	if err != nil {
+		return err
+	}
+	err = validateMazeAnimationOptions(width, height, options)
+	if err != nil {
+		return err
+	}
+	trace := &mazeTrace{}
+	maze := generateMaze(width, height, trace.record)
+	return animateGeneration(maze, trace, options, w)
 }
 
-func generateMaze_nextCell(x uint16, y uint16, maze *Maze) {
+// The headings of generateMaze_nextCell, in the order of its branches
+const (
+	MAZE_HEADING_RIGHT = iota
+	MAZE_HEADING_LEFT
+	MAZE_HEADING_DOWN
+	MAZE_HEADING_UP
+)
+
+func generateMaze_nextCell(x uint16, y uint16, heading int, straightness float64, maze *Maze, carve MazeTraceHook) {
 	// We are at the right border
 	if x == maze.GridWidth-1 {
 		return
@@ -209,27 +427,31 @@ func generateMaze_nextCell(x uint16, y uint16, maze *Maze) {
 	}
 
 	maze.SetWall(x, y, false)
+	if carve != nil {
+		carve(x, y)
+	}
+
+	// Make all possible branches, in the order of the headings
+	branches := []func(){
+		func() { generateMaze_nextCell(x+1, y, MAZE_HEADING_RIGHT, straightness, maze, carve) },
+		func() { generateMaze_nextCell(x-1, y, MAZE_HEADING_LEFT, straightness, maze, carve) },
+		func() { generateMaze_nextCell(x, y+1, MAZE_HEADING_DOWN, straightness, maze, carve) },
+		func() { generateMaze_nextCell(x, y-1, MAZE_HEADING_UP, straightness, maze, carve) },
+	}
 
-	// Make all possible branches
-	var branches []func()
-	branches = append(branches, func() {
-		generateMaze_nextCell(x+1, y, maze)
-	})
-	branches = append(branches, func() {
-		generateMaze_nextCell(x-1, y, maze)
-	})
-	branches = append(branches, func() {
-		generateMaze_nextCell(x, y+1, maze)
-	})
-	branches = append(branches, func() {
-		generateMaze_nextCell(x, y-1, maze)
-	})
-
-	// Shuffle branches and call them
+	// Shuffle branches and call them, with the chance of the straightness the current heading goes first
 	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(branches), func(i, j int) { branches[i], branches[j] = branches[j], branches[i] })
-	for _, branch := range branches {
-		branch()
+	order := []int{MAZE_HEADING_RIGHT, MAZE_HEADING_LEFT, MAZE_HEADING_DOWN, MAZE_HEADING_UP}
+	rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	if straightness > 0 && rand.Float64() < straightness {
+		for i, branch := range order {
+			if branch == heading {
+				order[0], order[i] = order[i], order[0]
+			}
+		}
+	}
+	for _, branch := range order {
+		branches[branch]()
 	}
 }
 
@@ -3,6 +3,7 @@ package main
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -14,13 +15,17 @@ func (m *MazeSolverMock) FindSolutions(maze *Maze) ([]MazeSolution, error) {
 	args := m.Called(maze)
 	return args.Get(0).([]MazeSolution), args.Error(1)
 }
+func (m *MazeSolverMock) TraceSolutions(maze *Maze, visit MazeTraceHook) ([]MazeSolution, error) {
+	args := m.Called(maze, visit)
+	return args.Get(0).([]MazeSolution), args.Error(1)
+}
 
 type MazeRepositoryMock struct {
 	mock.Mock
 }
 
-func (m *MazeRepositoryMock) Insert(userId string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte) (string, error) {
-	args := m.Called(userId, entranceX, entranceY, gridWidth, gridHeight, walls)
+func (m *MazeRepositoryMock) Insert(userId string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error) {
+	args := m.Called(userId, entranceX, entranceY, gridWidth, gridHeight, walls, maxStoredMazes)
 	return args.String(0), args.Error(1)
 }
 func (m *MazeRepositoryMock) SelectById(id string) (*Maze, error) {
@@ -32,11 +37,36 @@ func (m *MazeRepositoryMock) CountAll() (uint64, error) {
 	return args.Get(0).(uint64), args.Error(1)
 }
 
+func (m *MazeRepositoryMock) CountByUserId(userId string) (uint64, error) {
+	args := m.Called(userId)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
 func (m *MazeRepositoryMock) SelectAllByUserId(userId string) ([]*Maze, error) {
 	args := m.Called(userId)
 	return args.Get(0).([]*Maze), args.Error(1)
 }
 
+func (m *MazeRepositoryMock) InsertForTeam(teamId string, userId string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error) {
+	args := m.Called(teamId, userId, entranceX, entranceY, gridWidth, gridHeight, walls, maxStoredMazes)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MazeRepositoryMock) SelectAllByTeamId(teamId string) ([]*Maze, error) {
+	args := m.Called(teamId)
+	return args.Get(0).([]*Maze), args.Error(1)
+}
+
+func (m *MazeRepositoryMock) UpdateStats(id string, stats *MazeStats) error {
+	args := m.Called(id, stats)
+	return args.Error(0)
+}
+
+func (m *MazeRepositoryMock) DeleteById(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
 func Test_mazeControllerImpl_CreateMaze(t *testing.T) {
 	type fields struct {
 		mazeRepository MazeRepository
@@ -82,13 +112,29 @@ func Test_mazeControllerImpl_CreateMaze(t *testing.T) {
 			want:    "",
 			wantErr: true,
 		},
+		{
+			name: "Entrance outside the grid",
+			args: args{
+				userId: "8Wa",
+				maze: &Maze{
+					EntranceX:  0,
+					EntranceY:  98,
+					GridWidth:  5,
+					GridHeight: 5,
+					Walls:      make([]byte, 4),
+				},
+			},
+			want:    "",
+			wantErr: true,
+		},
 
 		{
 			name: "Create",
 			fields: fields{
 				mazeRepository: func() MazeRepository {
 					repo := &MazeRepositoryMock{}
-					repo.On("Insert", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("8Wa", nil)
+					repo.On("Insert", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("8Wa", nil)
+					repo.On("UpdateStats", "8Wa", mock.Anything).Return(nil)
 					return repo
 				}(),
 				mazeSolver: func() MazeSolver {
@@ -120,6 +166,7 @@ func Test_mazeControllerImpl_CreateMaze(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &mazeControllerImpl{
+				auditLog:       newAuditLogMock(),
 				mazeRepository: tt.fields.mazeRepository,
 				mazeSolver:     tt.fields.mazeSolver,
 			}
@@ -233,6 +280,7 @@ func Test_mazeControllerImpl_FindSolutionById(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &mazeControllerImpl{
+				auditLog:       newAuditLogMock(),
 				mazeRepository: tt.fields.mazeRepository,
 				mazeSolver:     tt.fields.mazeSolver,
 			}
@@ -245,3 +293,149 @@ func Test_mazeControllerImpl_FindSolutionById(t *testing.T) {
 		})
 	}
 }
+
+func Test_mazeControllerImpl_DeleteMaze(t *testing.T) {
+	repo := &MazeRepositoryMock{}
+	repo.On("SelectById", "8Wa").Return(&Maze{Id: "8Wa", UserId: "abc"}, nil)
+	repo.On("SelectById", "E42").Return((*Maze)(nil), nil)
+	repo.On("DeleteById", mock.Anything).Return(nil)
+	m := &mazeControllerImpl{
+		auditLog:       newAuditLogMock(),
+		mazeRepository: repo,
+	}
+
+	auditLog := m.auditLog.(*AuditLogMock)
+
+	assert.Nil(t, m.DeleteMaze("moderator", "8Wa"))
+	repo.AssertCalled(t, "DeleteById", "8Wa")
+	auditLog.AssertCalled(t, "Record", AUDIT_EVENT_MAZE_DELETED, "moderator", "8Wa", "", "owner abc")
+
+	assert.ErrorIs(t, m.DeleteMaze("moderator", "E42"), ErrMazeNotFound)
+	repo.AssertNotCalled(t, "DeleteById", "E42")
+}
+
+func Test_mazeControllerImpl_CreateTeamMaze(t *testing.T) {
+	repo := &MazeRepositoryMock{}
+	repo.On("InsertForTeam", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("8Wa", nil)
+	repo.On("UpdateStats", "8Wa", mock.Anything).Return(nil)
+	m := &mazeControllerImpl{
+		auditLog:       newAuditLogMock(),
+		mazeRepository: repo,
+		mazeSolver:     NewMazeSolver(),
+		maxStoredMazes: 10,
+	}
+	valid := &Maze{
+		EntranceX:  0,
+		EntranceY:  0,
+		GridWidth:  8,
+		GridHeight: 8,
+		Walls: []byte{
+			68, 85, 20, 118, 18, 218, 74, 2, 0,
+		},
+	}
+
+	got, err := m.CreateTeamMaze("abc", "team", valid)
+	assert.Nil(t, err)
+	assert.Equal(t, "8Wa", got)
+	repo.AssertCalled(t, "InsertForTeam", "team", "abc", uint16(0), uint16(0), uint16(8), uint16(8), valid.Walls, uint64(10))
+	repo.AssertCalled(t, "UpdateStats", "8Wa", computeMazeStats(valid))
+	m.auditLog.(*AuditLogMock).AssertCalled(t, "Record", AUDIT_EVENT_MAZE_CREATED, "abc", "8Wa", "", "team team")
+
+	// The same validation as for user mazes applies
+	invalid := &Maze{EntranceX: 3, EntranceY: 3}
+	invalid.InitWalls(8, 8)
+	_, err = m.CreateTeamMaze("abc", "team", invalid)
+	assert.NotNil(t, err)
+}
+
+func Test_mazeControllerImpl_MultipleExits(t *testing.T) {
+	solver := &MazeSolverMock{}
+	solver.On("FindSolutions", mock.Anything).Return([]MazeSolution{
+		{Exit: "B8"},
+		{Exit: "B8"},
+		{Exit: "F8"},
+	}, nil)
+	m := &mazeControllerImpl{
+		mazeSolver: solver,
+		auditLog:   newAuditLogMock(),
+	}
+	maze := &Maze{}
+	maze.InitWalls(8, 8)
+
+	_, err := m.CreateMaze("abc", maze)
+	assert.ErrorIs(t, err, ErrMazeMultipleExits)
+	assert.Equal(t, []ErrorDetail{
+		{Message: "exit at B8"},
+		{Message: "exit at F8"},
+	}, err.(*CodedError).Details)
+}
+
+func Test_mazeControllerImpl_GetMazeStats(t *testing.T) {
+	repo := &MazeRepositoryMock{}
+	repo.On("UpdateStats", mock.Anything, mock.Anything).Return(nil)
+	m := &mazeControllerImpl{mazeRepository: repo}
+
+	stored := &MazeStats{Version: MAZE_STATS_VERSION, Difficulty: 42}
+	maze := newTestMaze("B1", "#.#", "#.#")
+	maze.Id = "8Wa"
+	maze.Stats = stored
+	got, err := m.GetMazeStats(maze)
+	assert.Nil(t, err)
+	assert.Same(t, stored, got)
+	repo.AssertNotCalled(t, "UpdateStats", mock.Anything, mock.Anything)
+
+	// Missing stats and stats of another version are computed and stored
+	for _, stats := range []*MazeStats{nil, {Version: MAZE_STATS_VERSION - 1}} {
+		maze.Stats = stats
+		got, err = m.GetMazeStats(maze)
+		assert.Nil(t, err)
+		assert.Equal(t, computeMazeStats(maze), got)
+		assert.Same(t, got, maze.Stats)
+		repo.AssertCalled(t, "UpdateStats", "8Wa", got)
+	}
+}
+
+func Test_mazeControllerImpl_GetUserMazes(t *testing.T) {
+	withStats := newTestMaze("B1", "#.#", "#.#")
+	withStats.Stats = &MazeStats{Version: MAZE_STATS_VERSION}
+	withoutStats := newTestMaze("B1", "#.#", "#.#")
+	withoutStats.Id = "8Wa"
+	repo := &MazeRepositoryMock{}
+	repo.On("SelectAllByUserId", "abc").Return([]*Maze{withStats, withoutStats}, nil)
+	repo.On("UpdateStats", "8Wa", mock.Anything).Return(nil)
+	m := &mazeControllerImpl{mazeRepository: repo}
+
+	mazes, err := m.GetUserMazes("abc")
+	assert.Nil(t, err)
+	assert.Len(t, mazes, 2)
+	assert.NotNil(t, mazes[1].Stats)
+	repo.AssertNumberOfCalls(t, "UpdateStats", 1)
+}
+
+func Test_mazeControllerImpl_GetTeamMazes(t *testing.T) {
+	maze := newTestMaze("B1", "#.#", "#.#")
+	maze.Id = "8Wa"
+	repo := &MazeRepositoryMock{}
+	repo.On("SelectAllByTeamId", "E42").Return([]*Maze{maze}, nil)
+	repo.On("UpdateStats", "8Wa", mock.Anything).Return(nil)
+	m := &mazeControllerImpl{mazeRepository: repo}
+
+	mazes, err := m.GetTeamMazes("E42")
+	assert.Nil(t, err)
+	assert.Len(t, mazes, 1)
+	assert.NotNil(t, mazes[0].Stats)
+	repo.AssertNumberOfCalls(t, "UpdateStats", 1)
+}
+
+func Test_mazeControllerImpl_GenerateForDifficulty(t *testing.T) {
+	m := &mazeControllerImpl{}
+	result, err := m.GenerateForDifficulty(10, 10, MAZE_DIFFICULTY_LEVELS["easy"])
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(10), result.Maze.GridWidth)
+	assert.NotNil(t, result.Maze.Stats)
+
+	for _, size := range [][2]uint16{{2, 10}, {10, 2}, {65, 64}} {
+		_, err = m.GenerateForDifficulty(size[0], size[1], MAZE_DIFFICULTY_LEVELS["easy"])
+		assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, toCodedError(err).Code, size)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import "image/color"
+
+const (
+	// MAZE_DISTANCE_DEFAULT_CELL_SIZE draws cells large enough to tell their colours apart
+	MAZE_DISTANCE_DEFAULT_CELL_SIZE = 8
+	// MAZE_DISTANCE_FORMAT_JSON returns the distances as numbers, MAZE_DRAW_FORMAT_PNG draws them
+	MAZE_DISTANCE_FORMAT_JSON = "json"
+)
+
+// DefaultMazeDistanceOptions draws the distances in cells of MAZE_DISTANCE_DEFAULT_CELL_SIZE
+func DefaultMazeDistanceOptions() MazeDrawOptions {
+	options := DefaultMazeDrawOptions()
+	options.CellSize = MAZE_DISTANCE_DEFAULT_CELL_SIZE
+	options.Distances = true
+	return options
+}
+
+// MazeDistances holds the number of steps from the entrance to every cell, walls and cells that can not be
+// reached are -1. Unreachable only counts the open cells that can not be reached.
+type MazeDistances struct {
+	width       int
+	cells       []int
+	Max         int
+	Reachable   int
+	Unreachable int
+}
+
+// mazeDistances searches the maze breadth first from the entrance, so every distance is the length of the shortest path
+func mazeDistances(maze *Maze) *MazeDistances {
+	width := int(maze.GridWidth)
+	distances := &MazeDistances{width: width, cells: make([]int, width*int(maze.GridHeight))}
+	open := 0
+	for cell := range distances.cells {
+		distances.cells[cell] = -1
+		if !maze.IsWall(uint16(cell%width), uint16(cell/width)) {
+			open++
+		}
+	}
+	if !maze.IsWall(maze.EntranceX, maze.EntranceY) {
+		start := int(maze.EntranceY)*width + int(maze.EntranceX)
+		distances.cells[start] = 0
+		queue := []int{start}
+		for len(queue) > 0 {
+			cell := queue[0]
+			queue = queue[1:]
+			distances.Reachable++
+			if distances.cells[cell] > distances.Max {
+				distances.Max = distances.cells[cell]
+			}
+			for _, neighbor := range openNeighbors(maze, cell) {
+				if distances.cells[neighbor] < 0 {
+					distances.cells[neighbor] = distances.cells[cell] + 1
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+	}
+	distances.Unreachable = open - distances.Reachable
+	return distances
+}
+
+func (d *MazeDistances) at(x uint16, y uint16) int {
+	return d.cells[int(y)*d.width+int(x)]
+}
+
+// rows returns the distances as matrix with a row for every row of the maze
+func (d *MazeDistances) rows() [][]int {
+	rows := [][]int{}
+	for start := 0; start < len(d.cells); start += d.width {
+		rows = append(rows, d.cells[start:start+d.width])
+	}
+	return rows
+}
+
+// color grades the reachable open cell from Near to Far of the theme, the other open cells are Unreachable
+func (d *MazeDistances) color(theme MazeTheme, x uint16, y uint16) color.RGBA {
+	distance := d.at(x, y)
+	if distance < 0 {
+		return theme.Unreachable
+	}
+	if d.Max == 0 {
+		return theme.Near
+	}
+	blend := func(near uint8, far uint8) uint8 {
+		return uint8(int(near) + (int(far)-int(near))*distance/d.Max)
+	}
+	return color.RGBA{
+		R: blend(theme.Near.R, theme.Far.R),
+		G: blend(theme.Near.G, theme.Far.G),
+		B: blend(theme.Near.B, theme.Far.B),
+		A: 255,
+	}
+}
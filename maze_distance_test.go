@@ -0,0 +1,71 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_mazeDistances(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"###.#",
+		"#.#.#",
+	)
+	distances := mazeDistances(maze)
+	assert.Equal(t, [][]int{
+		{-1, 0, -1, -1, -1},
+		{-1, 1, 2, 3, -1},
+		{-1, -1, -1, 4, -1},
+		{-1, -1, -1, 5, -1},
+	}, distances.rows())
+	assert.Equal(t, 5, distances.Max)
+	assert.Equal(t, 6, distances.Reachable)
+	assert.Equal(t, 1, distances.Unreachable, "B4 is open but walled in")
+}
+
+func Test_mazeDistances_ShortestPath(t *testing.T) {
+	// The loop is reached from both sides, every cell keeps the shorter way
+	maze := newTestMaze("A1",
+		"...",
+		".#.",
+		"...",
+	)
+	assert.Equal(t, [][]int{
+		{0, 1, 2},
+		{1, -1, 3},
+		{2, 3, 4},
+	}, mazeDistances(maze).rows())
+}
+
+func Test_mazeDistances_EntranceIsWall(t *testing.T) {
+	maze := newTestMaze("A1",
+		"#.",
+		"..",
+	)
+	distances := mazeDistances(maze)
+	assert.Equal(t, [][]int{{-1, -1}, {-1, -1}}, distances.rows())
+	assert.Equal(t, 0, distances.Reachable)
+	assert.Equal(t, 3, distances.Unreachable)
+}
+
+func Test_drawMazePng_Distances(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"###.#",
+		"#.#.#",
+	)
+	options := DefaultMazeDistanceOptions()
+	options.CellSize = 2
+	theme := options.Theme
+	img := drawTestMaze(t, maze, nil, options)
+
+	assert.Equal(t, theme.Wall, colorAt(img, 0, 0))
+	assert.Equal(t, theme.Near, colorAt(img, 2, 0), "the entrance is nearest")
+	assert.Equal(t, theme.Far, colorAt(img, 6, 6), "D4 is farthest")
+	assert.Equal(t, color.RGBA{0xe5, 0x99, 0x8a, 255}, colorAt(img, 4, 2), "C2 is two fifths of the way")
+	assert.Equal(t, theme.Unreachable, colorAt(img, 2, 6))
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	MAZE_DRAW_MAX_CELL_SIZE = 64
+	MAZE_DRAW_MAX_MARGIN    = 256
+	// MAZE_DRAW_MAX_PIXELS keeps the image buffer of a single drawing below 64 MiB
+	MAZE_DRAW_MAX_PIXELS = 16 << 20
+	// MAZE_DRAW_MIN_GRID_CELL_SIZE and MAZE_DRAW_MIN_LABEL_CELL_SIZE are the smallest cells that leave room for grid lines and labels
+	MAZE_DRAW_MIN_GRID_CELL_SIZE  = 3
+	MAZE_DRAW_MIN_LABEL_CELL_SIZE = 6
+	MAZE_DRAW_DEFAULT_THEME       = "classic"
+
+	MAZE_DRAW_FORMAT_PNG = "png"
+	MAZE_DRAW_FORMAT_SVG = "svg"
+)
+
+// MAZE_DRAWING_VERSION is part of the ETags of drawings, it has to change when the renderers draw differently
+const MAZE_DRAWING_VERSION = 1
+
+// MAZE_DRAW_FORMATS maps the formats of drawings to their content type
+var MAZE_DRAW_FORMATS = map[string]string{
+	MAZE_DRAW_FORMAT_PNG: CONTENT_TYPE_PNG,
+	MAZE_DRAW_FORMAT_SVG: CONTENT_TYPE_SVG,
+}
+
+// MazeTheme holds the colours of a drawing, Background fills the margin and the labels. Animations colour
+// the cells a search has seen with Visited and its current cell with Frontier. Distance drawings grade the
+// reachable cells from Near at the entrance to Far and fill the open cells that can not be reached with Unreachable.
+type MazeTheme struct {
+	Wall        color.RGBA
+	Floor       color.RGBA
+	Path        color.RGBA
+	Entrance    color.RGBA
+	Exit        color.RGBA
+	Grid        color.RGBA
+	Background  color.RGBA
+	Label       color.RGBA
+	Visited     color.RGBA
+	Frontier    color.RGBA
+	Near        color.RGBA
+	Far         color.RGBA
+	Unreachable color.RGBA
+}
+
+var MAZE_THEMES = map[string]MazeTheme{
+	"classic": {
+		Wall: rgb(0x000000), Floor: rgb(0xffffff), Path: rgb(0xff0000), Entrance: rgb(0x00c000),
+		Exit: rgb(0x0060ff), Grid: rgb(0xc0c0c0), Background: rgb(0xffffff), Label: rgb(0x000000),
+		Visited: rgb(0xffd8a8), Frontier: rgb(0xff8c00),
+		Near: rgb(0xffffcc), Far: rgb(0xbd0026), Unreachable: rgb(0x8000ff),
+	},
+	"dark": {
+		Wall: rgb(0x101418), Floor: rgb(0x2b3036), Path: rgb(0xff6e40), Entrance: rgb(0x69f0ae),
+		Exit: rgb(0x40c4ff), Grid: rgb(0x3c424a), Background: rgb(0x101418), Label: rgb(0xe0e0e0),
+		Visited: rgb(0x5c4b2a), Frontier: rgb(0xffab40),
+		Near: rgb(0x1b3a4b), Far: rgb(0xffca3a), Unreachable: rgb(0xff2e88),
+	},
+	"blueprint": {
+		Wall: rgb(0xffffff), Floor: rgb(0x1f4e8c), Path: rgb(0xffd54f), Entrance: rgb(0x81c784),
+		Exit: rgb(0xff8a65), Grid: rgb(0x3a6db3), Background: rgb(0x163a6b), Label: rgb(0xffffff),
+		Visited: rgb(0x2f69b0), Frontier: rgb(0x80deea),
+		Near: rgb(0x2f69b0), Far: rgb(0xffd54f), Unreachable: rgb(0xff5252),
+	},
+	"pastel": {
+		Wall: rgb(0x5d5c7a), Floor: rgb(0xfdf6e3), Path: rgb(0xe57373), Entrance: rgb(0x81c784),
+		Exit: rgb(0x64b5f6), Grid: rgb(0xe6dcc6), Background: rgb(0xfdf6e3), Label: rgb(0x5d5c7a),
+		Visited: rgb(0xf3d9b1), Frontier: rgb(0xffb74d),
+		Near: rgb(0xe8f5e9), Far: rgb(0x8e44ad), Unreachable: rgb(0xff1744),
+	},
+}
+
+// mazeDrawingETag identifies a drawing by the content of the maze and everything else that changes the drawing,
+// steps is empty for drawings without solution
+func mazeDrawingETag(maze *Maze, format string, steps string, options MazeDrawOptions) (string, error) {
+	data, err := maze.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%d\n%s\n%s\n%+v\n", MAZE_DRAWING_VERSION, format, steps, options)
+	hash.Write(data)
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`, nil
+}
+
+// mazeDrawFormatOf returns the format of drawings with the content type
+func mazeDrawFormatOf(contentType string) string {
+	for format, formatContentType := range MAZE_DRAW_FORMATS {
+		if formatContentType == contentType {
+			return format
+		}
+	}
+	return ""
+}
+
+func rgb(value uint32) color.RGBA {
+	return color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 255}
+}
+
+// mazeThemeNames lists the themes in alphabetical order for messages and the API documentation
+func mazeThemeNames() []string {
+	names := []string{}
+	for name := range MAZE_THEMES {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type MazeDrawOptions struct {
+	// CellSize is the edge length of a cell in pixels
+	CellSize int
+	// Margin is the space around the maze in pixels, labels are placed between margin and maze
+	Margin    int
+	Theme     MazeTheme
+	GridLines bool
+	Labels    bool
+	// Markers colours the entrance and the exit, the exit is the end of the path or the only exit of the maze
+	Markers bool
+	// Distances colours the open cells by their distance from the entrance instead of the floor, see mazeDistances
+	Distances bool
+}
+
+// DefaultMazeDrawOptions draws one pixel per cell in black and white, like the drawings always did
+func DefaultMazeDrawOptions() MazeDrawOptions {
+	return MazeDrawOptions{
+		CellSize: 1,
+		Theme:    MAZE_THEMES[MAZE_DRAW_DEFAULT_THEME],
+	}
+}
+
+// mazeDrawLayout places the maze, the labels and the margin in the image
+type mazeDrawLayout struct {
+	options     MazeDrawOptions
+	labelScale  int
+	origin      image.Point
+	bounds      image.Rectangle
+	columnLabel image.Rectangle
+	rowLabel    image.Rectangle
+}
+
+func newMazeDrawLayout(maze *Maze, options MazeDrawOptions) (*mazeDrawLayout, error) {
+	if options.CellSize < 1 || options.CellSize > MAZE_DRAW_MAX_CELL_SIZE {
+		return nil, newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the cellSize must be between 1 and %d pixels", MAZE_DRAW_MAX_CELL_SIZE)).withField("cellSize")
+	}
+	if options.Margin < 0 || options.Margin > MAZE_DRAW_MAX_MARGIN {
+		return nil, newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the margin must be between 0 and %d pixels", MAZE_DRAW_MAX_MARGIN)).withField("margin")
+	}
+	if options.GridLines && options.CellSize < MAZE_DRAW_MIN_GRID_CELL_SIZE {
+		return nil, newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("grid lines need a cellSize of at least %d pixels", MAZE_DRAW_MIN_GRID_CELL_SIZE)).withField("grid")
+	}
+	if options.Labels && options.CellSize < MAZE_DRAW_MIN_LABEL_CELL_SIZE {
+		return nil, newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("labels need a cellSize of at least %d pixels", MAZE_DRAW_MIN_LABEL_CELL_SIZE)).withField("labels")
+	}
+
+	layout := &mazeDrawLayout{options: options}
+	labelWidth, labelHeight := 0, 0
+	if options.Labels {
+		layout.labelScale = options.CellSize / MAZE_DRAW_MIN_LABEL_CELL_SIZE
+		labelHeight = (MAZE_FONT_GLYPH_HEIGHT + 2) * layout.labelScale
+		labelWidth = mazeFontTextWidth(strconv.Itoa(int(maze.GridHeight)), layout.labelScale) + 2*layout.labelScale
+	}
+	layout.origin = image.Pt(options.Margin+labelWidth, options.Margin+labelHeight)
+	mazeWidth := int(maze.GridWidth) * options.CellSize
+	mazeHeight := int(maze.GridHeight) * options.CellSize
+	layout.bounds = image.Rect(0, 0, layout.origin.X+mazeWidth+options.Margin, layout.origin.Y+mazeHeight+options.Margin)
+	layout.columnLabel = image.Rect(layout.origin.X, options.Margin, layout.origin.X+mazeWidth, layout.origin.Y)
+	layout.rowLabel = image.Rect(options.Margin, layout.origin.Y, layout.origin.X, layout.origin.Y+mazeHeight)
+	if layout.bounds.Dx()*layout.bounds.Dy() > MAZE_DRAW_MAX_PIXELS {
+		return nil, newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the drawing must not have more than %d pixels, use a smaller cellSize", MAZE_DRAW_MAX_PIXELS)).withField("cellSize")
+	}
+	return layout, nil
+}
+
+func (l *mazeDrawLayout) cell(x uint16, y uint16) image.Rectangle {
+	size := l.options.CellSize
+	min := l.origin.Add(image.Pt(int(x)*size, int(y)*size))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(size, size))}
+}
+
+// columnLabelText and rowLabelText name the columns and rows like the addresses of the API
+func columnLabelText(x uint16) string {
+	return strings.TrimSuffix(toApiAddress(x, 0), "1")
+}
+
+func rowLabelText(y uint16) string {
+	return strconv.Itoa(int(y) + 1)
+}
+
+// drawMazePng draws the maze with the path in the colours of the theme
+func drawMazePng(maze *Maze, path *PathItem, options MazeDrawOptions, w io.Writer) error {
+	img, _, err := renderMaze(maze, path, options)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+func renderMaze(maze *Maze, path *PathItem, options MazeDrawOptions) (*image.RGBA, *mazeDrawLayout, error) {
+	layout, err := newMazeDrawLayout(maze, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	theme := options.Theme
+	img := image.NewRGBA(layout.bounds)
+	draw.Draw(img, img.Bounds(), &image.Uniform{theme.Background}, image.Point{}, draw.Src)
+
+	// Draw maze
+	var distances *MazeDistances
+	if options.Distances {
+		distances = mazeDistances(maze)
+	}
+	for y := uint16(0); y < maze.GridHeight; y++ {
+		for x := uint16(0); x < maze.GridWidth; x++ {
+			if maze.IsWall(x, y) {
+				drawMazeCell(img, layout, x, y, theme.Wall, false)
+			} else if distances != nil {
+				drawMazeCell(img, layout, x, y, distances.color(theme, x, y), true)
+			} else {
+				drawMazeCell(img, layout, x, y, theme.Floor, true)
+			}
+		}
+	}
+
+	// Draw path
+	for item := path; item != nil; item = item.Prev {
+		drawMazeCell(img, layout, item.X, item.Y, theme.Path, true)
+	}
+
+	if options.Markers {
+		drawMazeMarkers(img, layout, maze, path)
+	}
+
+	if options.Labels {
+		for x := uint16(0); x < maze.GridWidth; x++ {
+			cell := layout.cell(x, 0)
+			area := image.Rect(cell.Min.X, layout.columnLabel.Min.Y, cell.Max.X, layout.columnLabel.Max.Y)
+			drawMazeFontText(img, area, columnLabelText(x), layout.labelScale, theme.Label)
+		}
+		for y := uint16(0); y < maze.GridHeight; y++ {
+			cell := layout.cell(0, y)
+			area := image.Rect(layout.rowLabel.Min.X, cell.Min.Y, layout.rowLabel.Max.X, cell.Max.Y)
+			drawMazeFontText(img, area, rowLabelText(y), layout.labelScale, theme.Label)
+		}
+	}
+
+	return img, layout, nil
+}
+
+// drawMazeCell fills the cell, open cells get the grid lines on their top and left side
+func drawMazeCell(img draw.Image, layout *mazeDrawLayout, x uint16, y uint16, c color.RGBA, open bool) {
+	cell := layout.cell(x, y)
+	draw.Draw(img, cell, &image.Uniform{c}, image.Point{}, draw.Src)
+	if open && layout.options.GridLines {
+		grid := &image.Uniform{layout.options.Theme.Grid}
+		draw.Draw(img, image.Rect(cell.Min.X, cell.Min.Y, cell.Max.X, cell.Min.Y+1), grid, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(cell.Min.X, cell.Min.Y, cell.Min.X+1, cell.Max.Y), grid, image.Point{}, draw.Src)
+	}
+}
+
+// drawMazeMarkers marks the entrance and the exit, which is the end of the path or the only exit of the maze
+func drawMazeMarkers(img draw.Image, layout *mazeDrawLayout, maze *Maze, path *PathItem) {
+	theme := layout.options.Theme
+	drawMazeMarker(img, layout, maze.EntranceX, maze.EntranceY, theme.Entrance)
+	if path != nil {
+		drawMazeMarker(img, layout, path.X, path.Y, theme.Exit)
+	} else if exitX, exitY, ok := findExit(maze); ok {
+		drawMazeMarker(img, layout, exitX, exitY, theme.Exit)
+	}
+}
+
+// drawMazeMarker fills the cell, larger cells keep a border of the floor around the marker
+func drawMazeMarker(img draw.Image, layout *mazeDrawLayout, x uint16, y uint16, c color.RGBA) {
+	cell := layout.cell(x, y)
+	inset := layout.options.CellSize / 6
+	draw.Draw(img, cell.Inset(inset), &image.Uniform{c}, image.Point{}, draw.Src)
+}
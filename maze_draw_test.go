@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drawTestMaze(t *testing.T, maze *Maze, path *PathItem, options MazeDrawOptions) image.Image {
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, drawMazePng(maze, path, options, buffer))
+	img, err := png.Decode(buffer)
+	assert.Nil(t, err)
+	return img
+}
+
+func colorAt(img image.Image, x int, y int) color.RGBA {
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+}
+
+func Test_drawMazePng_Default(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	path, err := stringsToPath([]string{"B1", "B2", "C2"})
+	assert.Nil(t, err)
+
+	img := drawTestMaze(t, maze, path, DefaultMazeDrawOptions())
+	assert.Equal(t, image.Rect(0, 0, 3, 3), img.Bounds())
+	assert.Equal(t, rgb(0x000000), colorAt(img, 0, 0))
+	assert.Equal(t, rgb(0xff0000), colorAt(img, 1, 0))
+	assert.Equal(t, rgb(0xff0000), colorAt(img, 2, 1))
+	assert.Equal(t, rgb(0xffffff), colorAt(img, 1, 2))
+}
+
+func Test_drawMazePng_CellSizeAndMargin(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#.#",
+	)
+	options := DefaultMazeDrawOptions()
+	options.CellSize = 10
+	options.Margin = 5
+	options.Theme = MAZE_THEMES["dark"]
+
+	img := drawTestMaze(t, maze, nil, options)
+	assert.Equal(t, image.Rect(0, 0, 40, 30), img.Bounds())
+	assert.Equal(t, options.Theme.Background, colorAt(img, 2, 2))
+	assert.Equal(t, options.Theme.Wall, colorAt(img, 5, 5))
+	assert.Equal(t, options.Theme.Wall, colorAt(img, 14, 24))
+	assert.Equal(t, options.Theme.Floor, colorAt(img, 15, 5))
+	assert.Equal(t, options.Theme.Floor, colorAt(img, 24, 24))
+	assert.Equal(t, options.Theme.Background, colorAt(img, 37, 27))
+}
+
+func Test_drawMazePng_Markers(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"###.#",
+	)
+	options := DefaultMazeDrawOptions()
+	options.CellSize = 6
+	options.Markers = true
+	theme := options.Theme
+
+	img := drawTestMaze(t, maze, nil, options)
+	assert.Equal(t, theme.Entrance, colorAt(img, 9, 3))
+	assert.Equal(t, theme.Floor, colorAt(img, 6, 0), "the marker keeps a border")
+	assert.Equal(t, theme.Exit, colorAt(img, 21, 15))
+
+	// The path ends at the exit
+	path, err := stringsToPath([]string{"B1", "B2", "C2"})
+	assert.Nil(t, err)
+	img = drawTestMaze(t, maze, path, options)
+	assert.Equal(t, theme.Entrance, colorAt(img, 9, 3))
+	assert.Equal(t, theme.Exit, colorAt(img, 15, 9))
+	assert.Equal(t, theme.Path, colorAt(img, 9, 9))
+	assert.Equal(t, theme.Floor, colorAt(img, 21, 15))
+}
+
+func Test_drawMazePng_GridLines(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#.#",
+	)
+	options := DefaultMazeDrawOptions()
+	options.CellSize = 4
+	options.GridLines = true
+	theme := options.Theme
+
+	img := drawTestMaze(t, maze, nil, options)
+	assert.Equal(t, theme.Grid, colorAt(img, 4, 4))
+	assert.Equal(t, theme.Grid, colorAt(img, 7, 4))
+	assert.Equal(t, theme.Grid, colorAt(img, 4, 7))
+	assert.Equal(t, theme.Floor, colorAt(img, 5, 5))
+	assert.Equal(t, theme.Wall, colorAt(img, 0, 0))
+}
+
+func Test_drawMazePng_Labels(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#.#",
+	)
+	options := DefaultMazeDrawOptions()
+	options.CellSize = 12
+	options.Labels = true
+	theme := options.Theme
+
+	// The labels are drawn in double size, the column labels take 14 pixels and the row labels 10 pixels
+	img := drawTestMaze(t, maze, nil, options)
+	assert.Equal(t, image.Rect(0, 0, 10+36, 14+24), img.Bounds())
+	assert.Equal(t, theme.Wall, colorAt(img, 10, 14))
+
+	countLabelPixels := func(area image.Rectangle) int {
+		count := 0
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				if colorAt(img, x, y) == theme.Label {
+					count++
+				}
+			}
+		}
+		return count
+	}
+	assert.Equal(t, 10*4, countLabelPixels(image.Rect(10, 0, 22, 14)), "A")
+	assert.Equal(t, 10*4, countLabelPixels(image.Rect(22, 0, 34, 14)), "B")
+	assert.Equal(t, 8*4, countLabelPixels(image.Rect(0, 14, 10, 26)), "1")
+	assert.Equal(t, 8*4, countLabelPixels(image.Rect(0, 26, 10, 38)), "2")
+}
+
+func Test_drawMazePng_InvalidOptions(t *testing.T) {
+	maze := newTestMaze("B1", "#.#")
+	tests := []struct {
+		name   string
+		modify func(options *MazeDrawOptions)
+		field  string
+	}{
+		{"cell size zero", func(o *MazeDrawOptions) { o.CellSize = 0 }, "cellSize"},
+		{"cell size too large", func(o *MazeDrawOptions) { o.CellSize = MAZE_DRAW_MAX_CELL_SIZE + 1 }, "cellSize"},
+		{"margin too large", func(o *MazeDrawOptions) { o.Margin = MAZE_DRAW_MAX_MARGIN + 1 }, "margin"},
+		{"grid in small cells", func(o *MazeDrawOptions) { o.GridLines = true; o.CellSize = 2 }, "grid"},
+		{"labels in small cells", func(o *MazeDrawOptions) { o.Labels = true; o.CellSize = 5 }, "labels"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultMazeDrawOptions()
+			tt.modify(&options)
+			err := drawMazePng(maze, nil, options, &bytes.Buffer{})
+			assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, err.(*CodedError).Code)
+			assert.Equal(t, tt.field, err.(*CodedError).Details[0].Field)
+		})
+	}
+
+	t.Run("too many pixels", func(t *testing.T) {
+		large := &Maze{}
+		large.InitWalls(2000, 2000)
+		options := DefaultMazeDrawOptions()
+		options.CellSize = 10
+		err := drawMazePng(large, nil, options, &bytes.Buffer{})
+		assert.Equal(t, "cellSize", err.(*CodedError).Details[0].Field)
+	})
+}
+
+func Test_mazeFontTextWidth(t *testing.T) {
+	assert.Equal(t, 0, mazeFontTextWidth("", 1))
+	assert.Equal(t, 3, mazeFontTextWidth("A", 1))
+	assert.Equal(t, 14, mazeFontTextWidth("12", 2))
+	for char, glyph := range MAZE_FONT {
+		for _, row := range glyph {
+			assert.Less(t, row, uint8(1<<MAZE_FONT_GLYPH_WIDTH), string(char))
+		}
+	}
+}
+
+func Test_mazeDrawingETag(t *testing.T) {
+	maze := newTestMaze("B1", "#.#", "#.#")
+	etag, err := mazeDrawingETag(maze, MAZE_DRAW_FORMAT_PNG, "", DefaultMazeDrawOptions())
+	assert.Nil(t, err)
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+
+	same, err := mazeDrawingETag(newTestMaze("B1", "#.#", "#.#"), MAZE_DRAW_FORMAT_PNG, "", DefaultMazeDrawOptions())
+	assert.Nil(t, err)
+	assert.Equal(t, etag, same, "the id and owner do not change the drawing")
+
+	maze.SetWall(1, 1, true)
+	changed, err := mazeDrawingETag(maze, MAZE_DRAW_FORMAT_PNG, "", DefaultMazeDrawOptions())
+	assert.Nil(t, err)
+	assert.NotEqual(t, etag, changed)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	MAZE_FONT_GLYPH_WIDTH  = 3
+	MAZE_FONT_GLYPH_HEIGHT = 5
+)
+
+// MAZE_FONT is a bitmap font for the coordinate labels, each row of a glyph uses the lowest three bits
+// with the leftmost pixel in the highest bit
+var MAZE_FONT = map[rune][MAZE_FONT_GLYPH_HEIGHT]uint8{
+	'A': {0b010, 0b101, 0b111, 0b101, 0b101},
+	'B': {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C': {0b011, 0b100, 0b100, 0b100, 0b011},
+	'D': {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E': {0b111, 0b100, 0b110, 0b100, 0b111},
+	'F': {0b111, 0b100, 0b110, 0b100, 0b100},
+	'G': {0b011, 0b100, 0b101, 0b101, 0b011},
+	'H': {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I': {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J': {0b001, 0b001, 0b001, 0b101, 0b010},
+	'K': {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L': {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M': {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N': {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O': {0b010, 0b101, 0b101, 0b101, 0b010},
+	'P': {0b110, 0b101, 0b110, 0b100, 0b100},
+	'Q': {0b010, 0b101, 0b101, 0b110, 0b011},
+	'R': {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S': {0b011, 0b100, 0b010, 0b001, 0b110},
+	'T': {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U': {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V': {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W': {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X': {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y': {0b101, 0b101, 0b010, 0b010, 0b010},
+	'Z': {0b111, 0b001, 0b010, 0b100, 0b111},
+	'0': {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1': {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2': {0b110, 0b001, 0b010, 0b100, 0b111},
+	'3': {0b110, 0b001, 0b010, 0b001, 0b110},
+	'4': {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5': {0b111, 0b100, 0b110, 0b001, 0b110},
+	'6': {0b011, 0b100, 0b111, 0b101, 0b111},
+	'7': {0b111, 0b001, 0b010, 0b010, 0b010},
+	'8': {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9': {0b111, 0b101, 0b111, 0b001, 0b110},
+}
+
+// MAZE_FONT_UNKNOWN_GLYPH replaces characters that are missing in the font
+var MAZE_FONT_UNKNOWN_GLYPH = [MAZE_FONT_GLYPH_HEIGHT]uint8{0b110, 0b001, 0b010, 0b000, 0b010}
+
+// mazeFontTextWidth is the width of the text in pixels, glyphs are separated by one column
+func mazeFontTextWidth(text string, scale int) int {
+	glyphs := len([]rune(text))
+	if glyphs == 0 {
+		return 0
+	}
+	return (glyphs*(MAZE_FONT_GLYPH_WIDTH+1) - 1) * scale
+}
+
+// drawMazeFontText draws the text centered into the area, every font pixel becomes a square of scale pixels
+func drawMazeFontText(img *image.RGBA, area image.Rectangle, text string, scale int, c color.RGBA) {
+	left := area.Min.X + (area.Dx()-mazeFontTextWidth(text, scale))/2
+	top := area.Min.Y + (area.Dy()-MAZE_FONT_GLYPH_HEIGHT*scale)/2
+	for i, char := range []rune(text) {
+		glyph, ok := MAZE_FONT[char]
+		if !ok {
+			glyph = MAZE_FONT_UNKNOWN_GLYPH
+		}
+		glyphLeft := left + i*(MAZE_FONT_GLYPH_WIDTH+1)*scale
+		for row := 0; row < MAZE_FONT_GLYPH_HEIGHT; row++ {
+			for column := 0; column < MAZE_FONT_GLYPH_WIDTH; column++ {
+				if glyph[row]&(1<<(MAZE_FONT_GLYPH_WIDTH-1-column)) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.SetRGBA(glyphLeft+column*scale+dx, top+row*scale+dy, c)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const (
+	// MAZE_GENERATE_MAX_ATTEMPTS bounds how many mazes are carved for a difficulty, the closest one is returned
+	MAZE_GENERATE_MAX_ATTEMPTS = 24
+	// MAZE_GENERATE_DIFFICULTY_TOLERANCE makes a band of a numeric difficulty
+	MAZE_GENERATE_DIFFICULTY_TOLERANCE = 5.0
+	// MAZE_GENERATE_PARAMETER_GAIN moves the parameters by this share of the missed difficulty
+	MAZE_GENERATE_PARAMETER_GAIN = 3.0
+	// MAZE_GENERATE_MIN_SIZE leaves room for the entrance between the corners of the top border
+	MAZE_GENERATE_MIN_SIZE = 3
+	// MAZE_GENERATE_DIFFICULTY_MAX_CELLS bounds the grid of a difficulty, one call carves up to
+	// MAZE_GENERATE_MAX_ATTEMPTS mazes
+	MAZE_GENERATE_DIFFICULTY_MAX_CELLS = 64 * 64
+)
+
+// validateGenerateSize is checked by every way to generate a maze, smaller grids have no room for the entrance
+func validateGenerateSize(width uint16, height uint16) error {
+	if width < MAZE_GENERATE_MIN_SIZE {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the width must be at least %d", MAZE_GENERATE_MIN_SIZE)).withField("width")
+	}
+	if height < MAZE_GENERATE_MIN_SIZE {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the height must be at least %d", MAZE_GENERATE_MIN_SIZE)).withField("height")
+	}
+	return nil
+}
+
+// validateDifficultyGenerateSize also limits the cells, so that a single call can not carve huge grids many times
+func validateDifficultyGenerateSize(width uint16, height uint16) error {
+	err := validateGenerateSize(width, height)
+	if err != nil {
+		return err
+	}
+	if int(width)*int(height) > MAZE_GENERATE_DIFFICULTY_MAX_CELLS {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("a difficulty can only be generated for grids of up to %d cells", MAZE_GENERATE_DIFFICULTY_MAX_CELLS)).withField("difficulty")
+	}
+	return nil
+}
+
+// MazeDifficultyTarget is a band of MazeStats.Difficulty, named after its level or its numeric target
+type MazeDifficultyTarget struct {
+	Name string  `json:"name"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+var MAZE_DIFFICULTY_LEVELS = map[string]MazeDifficultyTarget{
+	"easy":   {Name: "easy", Min: 0, Max: 30},
+	"medium": {Name: "medium", Min: 30, Max: 45},
+	"hard":   {Name: "hard", Min: 45, Max: 60},
+	"expert": {Name: "expert", Min: 60, Max: 100},
+}
+
+// mazeDifficultyLevelNames lists the levels from easy to expert
+func mazeDifficultyLevelNames() []string {
+	names := []string{}
+	for name := range MAZE_DIFFICULTY_LEVELS {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return MAZE_DIFFICULTY_LEVELS[names[i]].Min < MAZE_DIFFICULTY_LEVELS[names[j]].Min
+	})
+	return names
+}
+
+// parseMazeDifficultyTarget accepts the name of a level or a number from 0 to 100
+func parseMazeDifficultyTarget(value string) (MazeDifficultyTarget, error) {
+	if level, ok := MAZE_DIFFICULTY_LEVELS[value]; ok {
+		return level, nil
+	}
+	difficulty, err := strconv.ParseFloat(value, 64)
+	if err != nil || difficulty < 0 || difficulty > 100 {
+		return MazeDifficultyTarget{}, newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the difficulty must be one of %v or a number from 0 to 100", mazeDifficultyLevelNames())).withField("difficulty")
+	}
+	return MazeDifficultyTarget{
+		Name: strconv.FormatFloat(difficulty, 'f', -1, 64),
+		Min:  math.Max(0, difficulty-MAZE_GENERATE_DIFFICULTY_TOLERANCE),
+		Max:  math.Min(100, difficulty+MAZE_GENERATE_DIFFICULTY_TOLERANCE),
+	}, nil
+}
+
+// miss returns how far the difficulty is below (negative) or above the band
+func (t MazeDifficultyTarget) miss(difficulty float64) float64 {
+	if difficulty < t.Min {
+		return difficulty - t.Min
+	}
+	if difficulty > t.Max {
+		return difficulty - t.Max
+	}
+	return 0
+}
+
+// MazeGenerateParameters steer the generator. Straightness is the chance to carve on in the same direction, long
+// corridors have fewer dead ends and decision points. ExitDepth picks the exit among the edge cells ordered by their
+// distance from the entrance, from the nearest at 0 to the farthest at 1.
+type MazeGenerateParameters struct {
+	Straightness float64 `json:"straightness"`
+	ExitDepth    float64 `json:"exitDepth"`
+}
+
+// MazeGenerationResult is the maze closest to the target, Reached tells if it is within the band
+type MazeGenerationResult struct {
+	Maze       *Maze
+	Target     MazeDifficultyTarget
+	Parameters MazeGenerateParameters
+	Attempts   int
+	Reached    bool
+}
+
+// generateMazeForDifficulty carves mazes until the difficulty of one is within the band of the target, after
+// every miss the parameters move toward the band. Small mazes may never reach a high target.
+func generateMazeForDifficulty(width uint16, height uint16, target MazeDifficultyTarget) *MazeGenerationResult {
+	centre := (target.Min + target.Max) / 2
+	parameters := MazeGenerateParameters{
+		Straightness: clampUnit(1 - centre/100),
+		ExitDepth:    clampUnit(centre / 100),
+	}
+	var best *MazeGenerationResult
+	for attempt := 1; attempt <= MAZE_GENERATE_MAX_ATTEMPTS; attempt++ {
+		maze := carveMaze(width, height, parameters.Straightness, nil)
+		openMazeExitAtDepth(maze, parameters.ExitDepth)
+		maze.Stats = computeMazeStats(maze)
+
+		miss := target.miss(maze.Stats.Difficulty)
+		if best == nil || math.Abs(miss) < math.Abs(target.miss(best.Maze.Stats.Difficulty)) {
+			best = &MazeGenerationResult{Maze: maze, Target: target, Parameters: parameters}
+		}
+		best.Attempts = attempt
+		if miss == 0 {
+			best.Reached = true
+			break
+		}
+
+		// Too easy lengthens the way to the exit and bends the corridors, too hard does the opposite
+		step := MAZE_GENERATE_PARAMETER_GAIN * miss / 100
+		parameters.ExitDepth = clampUnit(parameters.ExitDepth - step)
+		parameters.Straightness = clampUnit(parameters.Straightness + step)
+	}
+	return best
+}
+
+// openMazeExitAtDepth opens the edge next to a reachable cell, the cells are ordered by their distance from the
+// entrance and the depth picks one of them. Corners, the entrance and its neighbours are no exits.
+func openMazeExitAtDepth(maze *Maze, depth float64) {
+	distances := mazeDistances(maze)
+	width, height := maze.GridWidth, maze.GridHeight
+	type exit struct {
+		x, y     uint16
+		distance int
+	}
+	exits := []exit{}
+	add := func(x uint16, y uint16, insideX uint16, insideY uint16) {
+		nextToEntrance := absInt(int(x)-int(maze.EntranceX))+absInt(int(y)-int(maze.EntranceY)) <= 1
+		if nextToEntrance || !maze.IsWall(x, y) {
+			return
+		}
+		if distance := distances.at(insideX, insideY); distance > 0 {
+			exits = append(exits, exit{x, y, distance + 1})
+		}
+	}
+	for x := uint16(1); x+1 < width; x++ {
+		add(x, 0, x, 1)
+		add(x, height-1, x, height-2)
+	}
+	for y := uint16(1); y+1 < height; y++ {
+		add(0, y, 1, y)
+		add(width-1, y, width-2, y)
+	}
+	if len(exits) == 0 {
+		return
+	}
+	sort.SliceStable(exits, func(i, j int) bool {
+		return exits[i].distance < exits[j].distance
+	})
+	chosen := exits[int(math.Round(depth*float64(len(exits)-1)))]
+	maze.SetWall(chosen.x, chosen.y, false)
+}
+
+func clampUnit(value float64) float64 {
+	return math.Max(0, math.Min(1, value))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseMazeDifficultyTarget(t *testing.T) {
+	target, err := parseMazeDifficultyTarget("hard")
+	assert.Nil(t, err)
+	assert.Equal(t, MAZE_DIFFICULTY_LEVELS["hard"], target)
+
+	target, err = parseMazeDifficultyTarget("42.5")
+	assert.Nil(t, err)
+	assert.Equal(t, MazeDifficultyTarget{Name: "42.5", Min: 37.5, Max: 47.5}, target)
+	target, err = parseMazeDifficultyTarget("2")
+	assert.Nil(t, err)
+	assert.Equal(t, MazeDifficultyTarget{Name: "2", Min: 0, Max: 7}, target)
+
+	for _, value := range []string{"insane", "-1", "101"} {
+		_, err = parseMazeDifficultyTarget(value)
+		codedError, ok := err.(*CodedError)
+		if assert.True(t, ok, value) {
+			assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, codedError.Code)
+			assert.Equal(t, "difficulty", codedError.Details[0].Field)
+		}
+	}
+}
+
+func Test_mazeDifficultyLevelNames(t *testing.T) {
+	assert.Equal(t, []string{"easy", "medium", "hard", "expert"}, mazeDifficultyLevelNames())
+}
+
+func Test_MazeDifficultyTarget_miss(t *testing.T) {
+	target := MazeDifficultyTarget{Min: 30, Max: 45}
+	assert.Equal(t, -10.0, target.miss(20))
+	assert.Equal(t, 0.0, target.miss(30))
+	assert.Equal(t, 0.0, target.miss(45))
+	assert.Equal(t, 5.0, target.miss(50))
+}
+
+func Test_openMazeExitAtDepth(t *testing.T) {
+	newMaze := func() *Maze {
+		return newTestMaze("C1",
+			"##.##",
+			"#...#",
+			"#.#.#",
+			"#####",
+		)
+	}
+
+	// B1 and D1 are next to the entrance, A2 is one of the nearest exits and E3 one of the farthest
+	nearest := newMaze()
+	openMazeExitAtDepth(nearest, 0)
+	assert.False(t, nearest.IsWall(0, 1))
+	assert.True(t, nearest.IsWall(1, 0))
+	assert.Equal(t, 4, computeMazeStats(nearest).SolutionLength)
+
+	farthest := newMaze()
+	openMazeExitAtDepth(farthest, 1)
+	assert.False(t, farthest.IsWall(4, 2))
+	assert.Equal(t, "E3", computeMazeStats(farthest).Exit)
+	assert.Equal(t, 5, computeMazeStats(farthest).SolutionLength)
+}
+
+func Test_generateMazeForDifficulty(t *testing.T) {
+	result := generateMazeForDifficulty(16, 16, MAZE_DIFFICULTY_LEVELS["easy"])
+	assert.Equal(t, MAZE_DIFFICULTY_LEVELS["easy"], result.Target)
+	assert.LessOrEqual(t, result.Attempts, MAZE_GENERATE_MAX_ATTEMPTS)
+	assert.Equal(t, computeMazeStats(result.Maze), result.Maze.Stats)
+	if result.Reached {
+		assert.LessOrEqual(t, result.Maze.Stats.Difficulty, 30.0)
+	}
+	assert.NotEmpty(t, result.Maze.Stats.Exit)
+
+	// A small maze can not be expert, the attempts are bounded and the closest maze is returned
+	result = generateMazeForDifficulty(6, 6, MAZE_DIFFICULTY_LEVELS["expert"])
+	assert.False(t, result.Reached)
+	assert.Equal(t, MAZE_GENERATE_MAX_ATTEMPTS, result.Attempts)
+	assert.Less(t, result.Maze.Stats.Difficulty, 60.0)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strconv"
+)
+
+const (
+	MAZE_IMAGE_DEFAULT_THRESHOLD = 128
+	// MAZE_IMAGE_MARKER_TOLERANCE is the largest difference per colour channel of a marker cell to the marker colour
+	MAZE_IMAGE_MARKER_TOLERANCE = 64
+	// MAZE_IMAGE_MAX_PIXELS allows 2048x2048 pixels, the decoded image takes up to 16 MB
+	MAZE_IMAGE_MAX_PIXELS = 4 << 20
+)
+
+// MAZE_IMAGE_DEFAULT_MARKER is the colour that marks the entrance, if the image has no marker the entrance is
+// the only gap in the top, left or right border.
+var MAZE_IMAGE_DEFAULT_MARKER = color.RGBA{0, 255, 0, 255}
+
+type MazeImageImportOptions struct {
+	// CellSize is the edge length of a cell in pixels, 0 detects it from the image
+	CellSize int
+	// Threshold separates walls from open cells, cells whose brightest channel is below it are walls,
+	// so that coloured paths and markers count as open
+	Threshold uint8
+	Marker    color.RGBA
+	// Entrance is the address of the entrance, if empty it is detected from the marker or the border gaps
+	Entrance string
+	// CheckGridSize rejects grids before the maze is built, nil allows every size
+	CheckGridSize func(width uint16, height uint16) error
+}
+
+func DefaultMazeImageImportOptions() MazeImageImportOptions {
+	return MazeImageImportOptions{
+		Threshold: MAZE_IMAGE_DEFAULT_THRESHOLD,
+		Marker:    MAZE_IMAGE_DEFAULT_MARKER,
+	}
+}
+
+const (
+	mazeImageOpen uint8 = iota
+	mazeImageWall
+	mazeImageMarker
+)
+
+// readMazeImage decodes a PNG, GIF or JPEG image and turns it into a maze
+func readMazeImage(r io.Reader, options MazeImageImportOptions) (*Maze, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, "the image can not be read: "+err.Error())
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, "the image can not be decoded: "+err.Error())
+	}
+	if config.Width*config.Height > MAZE_IMAGE_MAX_PIXELS {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, fmt.Sprintf("the image must not have more than %d pixels", MAZE_IMAGE_MAX_PIXELS))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, "the image can not be decoded: "+err.Error())
+	}
+	return importMazeImage(img, options)
+}
+
+// importMazeImage classifies every cell of the image by the average colour of its inner pixels,
+// so that blurred edges of scaled or lossy compressed images do not matter.
+func importMazeImage(img image.Image, options MazeImageImportOptions) (*Maze, error) {
+	bounds := img.Bounds()
+	cellSize := options.CellSize
+	if cellSize == 0 {
+		cellSize = detectCellSize(img, options)
+	}
+	if bounds.Dx()%cellSize != 0 || bounds.Dy()%cellSize != 0 {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, fmt.Sprintf("the image of %dx%d pixels can not be split into cells of %d pixels", bounds.Dx(), bounds.Dy(), cellSize)).withField("cellSize")
+	}
+	width := bounds.Dx() / cellSize
+	height := bounds.Dy() / cellSize
+	if width > MAZE_ASCII_MAX_SIZE || height > MAZE_ASCII_MAX_SIZE {
+		return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, fmt.Sprintf("the maze must not be wider or higher than %d cells", MAZE_ASCII_MAX_SIZE)).withField("cellSize")
+	}
+	if options.CheckGridSize != nil {
+		err := options.CheckGridSize(uint16(width), uint16(height))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	maze := &Maze{}
+	maze.InitWalls(uint16(width), uint16(height))
+	markers := []image.Point{}
+	margin := cellSize / 4
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			cell := image.Rect(x*cellSize+margin, y*cellSize+margin, (x+1)*cellSize-margin, (y+1)*cellSize-margin).Add(bounds.Min)
+			switch classifyColor(averageColor(img, cell), options) {
+			case mazeImageWall:
+				maze.SetWall(uint16(x), uint16(y), true)
+			case mazeImageMarker:
+				markers = append(markers, image.Pt(x, y))
+			}
+		}
+	}
+
+	if options.Entrance != "" {
+		x, y, err := readPosition(options.Entrance)
+		if err != nil {
+			return nil, withField(err, "entrance")
+		}
+		if x >= maze.GridWidth || y >= maze.GridHeight || maze.IsWall(x, y) {
+			return nil, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, "the entrance "+options.Entrance+" is not an open cell of the maze").withField("entrance")
+		}
+		maze.EntranceX = x
+		maze.EntranceY = y
+		return maze, nil
+	}
+
+	entrance, err := findImageEntrance(maze, markers)
+	if err != nil {
+		return nil, err
+	}
+	maze.EntranceX = uint16(entrance.X)
+	maze.EntranceY = uint16(entrance.Y)
+	return maze, nil
+}
+
+func findImageEntrance(maze *Maze, markers []image.Point) (image.Point, error) {
+	if len(markers) == 1 {
+		return markers[0], nil
+	}
+	if len(markers) > 1 {
+		return image.Point{}, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, fmt.Sprintf("the image has %d cells in the marker colour, only the entrance may be marked", len(markers)))
+	}
+
+	// The exit is on the bottom border, so the entrance is the gap in one of the other borders
+	gaps := []image.Point{}
+	width := int(maze.GridWidth)
+	height := int(maze.GridHeight)
+	for y := 0; y < height-1; y++ {
+		for x := 0; x < width; x++ {
+			onBorder := y == 0 || x == 0 || x == width-1
+			if onBorder && !maze.IsWall(uint16(x), uint16(y)) {
+				gaps = append(gaps, image.Pt(x, y))
+			}
+		}
+	}
+	if len(gaps) != 1 {
+		return image.Point{}, newCodedError(ERROR_CODE_MAZE_INVALID_IMAGE, fmt.Sprintf("the entrance can not be detected, the top, left and right border have %d gaps, mark the entrance in the marker colour or name it", len(gaps)))
+	}
+	return gaps[0], nil
+}
+
+// detectCellSize returns the greatest common divisor of all runs of equally classified pixels in rows and columns,
+// the classes take one byte per pixel
+func detectCellSize(img image.Image, options MazeImageImportOptions) int {
+	bounds := img.Bounds()
+	width := bounds.Dx()
+	size := gcd(width, bounds.Dy())
+	classes := make([]uint8, width*bounds.Dy())
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < width; x++ {
+			classes[y*width+x] = classifyColor(img.At(bounds.Min.X+x, bounds.Min.Y+y), options)
+		}
+	}
+	for y := 0; y < bounds.Dy() && size > 1; y++ {
+		run := 1
+		for x := 1; x <= width; x++ {
+			if x < width && classes[y*width+x] == classes[y*width+x-1] {
+				run++
+				continue
+			}
+			size = gcd(size, run)
+			run = 1
+		}
+	}
+	for x := 0; x < width && size > 1; x++ {
+		run := 1
+		for y := 1; y <= bounds.Dy(); y++ {
+			if y < bounds.Dy() && classes[y*width+x] == classes[(y-1)*width+x] {
+				run++
+				continue
+			}
+			size = gcd(size, run)
+			run = 1
+		}
+	}
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+func averageColor(img image.Image, rect image.Rectangle) color.Color {
+	var r, g, b, n uint64
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += uint64(cr)
+			g += uint64(cg)
+			b += uint64(cb)
+			n++
+		}
+	}
+	if n == 0 {
+		return color.White
+	}
+	return color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), 0xffff}
+}
+
+func classifyColor(c color.Color, options MazeImageImportOptions) uint8 {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	if channelDistance(rgba.R, options.Marker.R) <= MAZE_IMAGE_MARKER_TOLERANCE &&
+		channelDistance(rgba.G, options.Marker.G) <= MAZE_IMAGE_MARKER_TOLERANCE &&
+		channelDistance(rgba.B, options.Marker.B) <= MAZE_IMAGE_MARKER_TOLERANCE {
+		return mazeImageMarker
+	}
+	brightness := rgba.R
+	if rgba.G > brightness {
+		brightness = rgba.G
+	}
+	if rgba.B > brightness {
+		brightness = rgba.B
+	}
+	if brightness < options.Threshold {
+		return mazeImageWall
+	}
+	return mazeImageOpen
+}
+
+func channelDistance(a uint8, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// readHexColor reads colours in the form #rrggbb
+func readHexColor(value string) (color.RGBA, error) {
+	if len(value) != 7 || value[0] != '#' {
+		return color.RGBA{}, fmt.Errorf("the colour must have the form #rrggbb")
+	}
+	rgb, err := strconv.ParseUint(value[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("the colour must have the form #rrggbb")
+	}
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}, nil
+}
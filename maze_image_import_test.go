@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// generatedTestMazeOptions names the entrance, generated mazes may have the exit in the top border as well
+func generatedTestMazeOptions(maze *Maze) MazeImageImportOptions {
+	options := DefaultMazeImageImportOptions()
+	options.Entrance = toApiAddress(maze.EntranceX, maze.EntranceY)
+	return options
+}
+
+// drawTestMazeImage draws the maze with DrawMaze in cells of cellSize pixels
+func drawTestMazeImage(t *testing.T, maze *Maze, path *PathItem, cellSize int) *image.RGBA {
+	options := DefaultMazeDrawOptions()
+	options.CellSize = cellSize
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, (&mazeControllerImpl{}).DrawMaze(maze, path, options, buffer))
+	drawn, err := png.Decode(buffer)
+	assert.Nil(t, err)
+
+	img := image.NewRGBA(drawn.Bounds())
+	draw.Draw(img, img.Bounds(), drawn, image.Point{}, draw.Src)
+	return img
+}
+
+func assertSameMaze(t *testing.T, expected *Maze, actual *Maze) {
+	if !assert.NotNil(t, actual) {
+		return
+	}
+	assert.Equal(t, expected.GridWidth, actual.GridWidth)
+	assert.Equal(t, expected.GridHeight, actual.GridHeight)
+	assert.Equal(t, toApiAddress(expected.EntranceX, expected.EntranceY), toApiAddress(actual.EntranceX, actual.EntranceY))
+	assert.Equal(t, expected.ToAscii(), actual.ToAscii())
+}
+
+func Test_importMazeImage_RoundTrip(t *testing.T) {
+	m := &mazeControllerImpl{}
+	generated, err := m.Generate(17, 11)
+	assert.Nil(t, err)
+
+	for _, cellSize := range []int{1, 2, 5, 12} {
+		img := drawTestMazeImage(t, generated, nil, cellSize)
+		maze, err := importMazeImage(img, generatedTestMazeOptions(generated))
+		assert.Nil(t, err, "cell size %d", cellSize)
+		assertSameMaze(t, generated, maze)
+	}
+}
+
+func Test_importMazeImage_PathIsOpen(t *testing.T) {
+	m := &mazeControllerImpl{}
+	generated, err := m.Generate(15, 15)
+	assert.Nil(t, err)
+
+	img := drawTestMazeImage(t, generated, findLongestPathFromEntrace(generated), 3)
+	maze, err := importMazeImage(img, generatedTestMazeOptions(generated))
+	assert.Nil(t, err)
+	assertSameMaze(t, generated, maze)
+}
+
+func Test_importMazeImage_Marker(t *testing.T) {
+	// Two gaps in the top border, the marker decides
+	expected := newTestMaze("D1", "#.#.#", "#...#", "###.#")
+	img := drawTestMazeImage(t, expected, nil, 4)
+	draw.Draw(img, image.Rect(12, 0, 16, 4), &image.Uniform{MAZE_IMAGE_DEFAULT_MARKER}, image.Point{}, draw.Src)
+
+	maze, err := importMazeImage(img, DefaultMazeImageImportOptions())
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+
+	_, err = importMazeImage(drawTestMazeImage(t, expected, nil, 4), DefaultMazeImageImportOptions())
+	assert.Equal(t, ERROR_CODE_MAZE_INVALID_IMAGE, err.(*CodedError).Code)
+
+	options := DefaultMazeImageImportOptions()
+	options.Marker = color.RGBA{0, 0, 255, 255}
+	blue := drawTestMazeImage(t, expected, nil, 4)
+	draw.Draw(blue, image.Rect(12, 0, 16, 4), &image.Uniform{options.Marker}, image.Point{}, draw.Src)
+	maze, err = importMazeImage(blue, options)
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+}
+
+func Test_importMazeImage_Entrance(t *testing.T) {
+	expected := newTestMaze("D1", "#.#.#", "#...#", "###.#")
+	img := drawTestMazeImage(t, expected, nil, 1)
+
+	options := DefaultMazeImageImportOptions()
+	options.Entrance = "D1"
+	maze, err := importMazeImage(img, options)
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+
+	for _, entrance := range []string{"A1", "F1", "D9", "1"} {
+		options.Entrance = entrance
+		_, err = importMazeImage(img, options)
+		assert.NotNil(t, err, entrance)
+		assert.Equal(t, "entrance", err.(*CodedError).Details[0].Field, entrance)
+	}
+}
+
+func Test_importMazeImage_SideEntrance(t *testing.T) {
+	expected := newTestMaze("A2", "#####", "....#", "###.#")
+	maze, err := importMazeImage(drawTestMazeImage(t, expected, nil, 2), DefaultMazeImageImportOptions())
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+}
+
+func Test_importMazeImage_CellSize(t *testing.T) {
+	expected := newTestMaze("B1", "#.###", "#...#", "###.#")
+	img := drawTestMazeImage(t, expected, nil, 6)
+
+	options := DefaultMazeImageImportOptions()
+	options.CellSize = 6
+	maze, err := importMazeImage(img, options)
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+
+	options.CellSize = 4
+	_, err = importMazeImage(img, options)
+	assert.Equal(t, ERROR_CODE_MAZE_INVALID_IMAGE, err.(*CodedError).Code)
+	assert.Equal(t, "cellSize", err.(*CodedError).Details[0].Field)
+}
+
+func Test_importMazeImage_CheckGridSize(t *testing.T) {
+	expected := newTestMaze("B1", "#.###", "#...#", "###.#")
+	options := DefaultMazeImageImportOptions()
+	checked := ""
+	options.CheckGridSize = func(width uint16, height uint16) error {
+		checked = fmt.Sprintf("%dx%d", width, height)
+		return &QuotaExceededError{Message: "too many cells"}
+	}
+	_, err := importMazeImage(drawTestMazeImage(t, expected, nil, 4), options)
+	assert.Equal(t, "5x3", checked)
+	assert.Equal(t, &QuotaExceededError{Message: "too many cells"}, err)
+}
+
+func Test_readMazeImage(t *testing.T) {
+	expected := newTestMaze("B1", "#.###", "#...#", "###.#")
+	img := drawTestMazeImage(t, expected, nil, 8)
+
+	pngData := &bytes.Buffer{}
+	assert.Nil(t, png.Encode(pngData, img))
+	maze, err := readMazeImage(pngData, DefaultMazeImageImportOptions())
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+
+	gifData := &bytes.Buffer{}
+	assert.Nil(t, gif.Encode(gifData, img, nil))
+	maze, err = readMazeImage(gifData, DefaultMazeImageImportOptions())
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+
+	// The edges of lossy images are blurred, so the cell size has to be given
+	jpegData := &bytes.Buffer{}
+	assert.Nil(t, jpeg.Encode(jpegData, img, &jpeg.Options{Quality: 75}))
+	options := DefaultMazeImageImportOptions()
+	options.CellSize = 8
+	maze, err = readMazeImage(jpegData, options)
+	assert.Nil(t, err)
+	assertSameMaze(t, expected, maze)
+
+	_, err = readMazeImage(bytes.NewReader([]byte("no image")), DefaultMazeImageImportOptions())
+	assert.Equal(t, ERROR_CODE_MAZE_INVALID_IMAGE, err.(*CodedError).Code)
+}
+
+func Test_readHexColor(t *testing.T) {
+	c, err := readHexColor("#12ab0F")
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{0x12, 0xab, 0x0f, 255}, c)
+
+	for _, value := range []string{"", "12ab0f", "#12ab0", "#12ab0g", "#12ab0f0"} {
+		_, err := readHexColor(value)
+		assert.NotNil(t, err, value)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MAZE_PDF_PAGE_A4     = "a4"
+	MAZE_PDF_PAGE_LETTER = "letter"
+
+	MAZE_PDF_MAX_PER_PAGE     = 16
+	MAZE_PDF_MAX_MAZES        = 100
+	MAZE_PDF_MAX_TITLE_LENGTH = 100
+	MAZE_PDF_DEFAULT_TITLE    = "Mazes"
+
+	// The layout is measured in points of 1/72 inch
+	MAZE_PDF_MARGIN         = 36
+	MAZE_PDF_GAP            = 18
+	MAZE_PDF_TITLE_SIZE     = 18
+	MAZE_PDF_CAPTION_SIZE   = 9
+	MAZE_PDF_FOOTER_SIZE    = 8
+	MAZE_PDF_PATH_WIDTH     = 0.4
+	MAZE_PDF_MARKER_INSET   = 0.15
+	MAZE_PDF_PRODUCER       = "Codingchallenge MazeAPI"
+	MAZE_PDF_SOLUTION_TITLE = " - Solutions"
+)
+
+// MAZE_PDF_PAGE_SIZES are the portrait page sizes in points
+var MAZE_PDF_PAGE_SIZES = map[string][2]float64{
+	MAZE_PDF_PAGE_A4:     {595.28, 841.89},
+	MAZE_PDF_PAGE_LETTER: {612, 792},
+}
+
+type MazePdfOptions struct {
+	PageSize string
+	// PerPage is the number of mazes on a page, they share the page in equally sized slots
+	PerPage int
+	Title   string
+	// Solutions adds pages with the solutions in the same layout after the pages with the mazes
+	Solutions bool
+	// CreatedAt is the creation date in the metadata of the document
+	CreatedAt time.Time
+}
+
+func DefaultMazePdfOptions() MazePdfOptions {
+	return MazePdfOptions{
+		PageSize:  MAZE_PDF_PAGE_A4,
+		PerPage:   1,
+		Title:     MAZE_PDF_DEFAULT_TITLE,
+		CreatedAt: time.Now(),
+	}
+}
+
+// MazePdfSheet is a maze of the document, Solution is only drawn on the solution pages
+type MazePdfSheet struct {
+	Maze     *Maze
+	Solution *PathItem
+}
+
+func validateMazePdfOptions(options MazePdfOptions) error {
+	if _, ok := MAZE_PDF_PAGE_SIZES[options.PageSize]; !ok {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, "the pageSize must be one of "+strings.Join(mazePdfPageSizeNames(), ", ")).withField("pageSize")
+	}
+	if options.PerPage < 1 || options.PerPage > MAZE_PDF_MAX_PER_PAGE {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("perPage must be between 1 and %d mazes", MAZE_PDF_MAX_PER_PAGE)).withField("perPage")
+	}
+	if len([]rune(options.Title)) > MAZE_PDF_MAX_TITLE_LENGTH {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the title must not be longer than %d characters", MAZE_PDF_MAX_TITLE_LENGTH)).withField("title")
+	}
+	return nil
+}
+
+// mazePdfPageSizeNames lists the page sizes in alphabetical order for messages and the API documentation
+func mazePdfPageSizeNames() []string {
+	names := []string{}
+	for name := range MAZE_PDF_PAGE_SIZES {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mazePdfGrid arranges perPage slots in the columns and rows that leave the largest square for each maze
+func mazePdfGrid(perPage int, width float64, height float64) (int, int) {
+	bestColumns, bestRows, bestSize := 1, perPage, -1.0
+	for columns := 1; columns <= perPage; columns++ {
+		rows := (perPage + columns - 1) / columns
+		slotWidth := (width - float64(columns-1)*MAZE_PDF_GAP) / float64(columns)
+		slotHeight := (height-float64(rows-1)*MAZE_PDF_GAP)/float64(rows) - 2*MAZE_PDF_CAPTION_SIZE
+		size := math.Min(slotWidth, slotHeight)
+		if size > bestSize {
+			bestColumns, bestRows, bestSize = columns, rows, size
+		}
+	}
+	return bestColumns, bestRows
+}
+
+// drawMazePdf prints the mazes as PDF, each page has the title on top, a caption above every maze and the page number
+// at the bottom. The mazes are drawn in cell units with the walls as merged outlines like drawMazeSvg, so they stay sharp
+// at every size. Only the standard font Helvetica is used, which viewers provide without embedding it.
+func drawMazePdf(sheets []MazePdfSheet, options MazePdfOptions, w io.Writer) error {
+	if len(sheets) < 1 || len(sheets) > MAZE_PDF_MAX_MAZES {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("a document holds between 1 and %d mazes", MAZE_PDF_MAX_MAZES)).withField("mazeIds")
+	}
+	err := validateMazePdfOptions(options)
+	if err != nil {
+		return err
+	}
+	pageSize := MAZE_PDF_PAGE_SIZES[options.PageSize]
+	mazePages := (len(sheets) + options.PerPage - 1) / options.PerPage
+	pageCount := mazePages
+	if options.Solutions {
+		pageCount *= 2
+	}
+
+	pdf := newPdfWriter()
+	catalog, pages, font, info := pdf.reserve(), pdf.reserve(), pdf.reserve(), pdf.reserve()
+	kids := []string{}
+	for page := 0; page < pageCount; page++ {
+		pageId, contentId := pdf.reserve(), pdf.reserve()
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageId))
+		first := page % mazePages * options.PerPage
+		last := first + options.PerPage
+		if last > len(sheets) {
+			last = len(sheets)
+		}
+		content := drawMazePdfPage(sheets[first:last], first, page >= mazePages, options, page+1, pageCount)
+		err = pdf.stream(contentId, "", content)
+		if err != nil {
+			return err
+		}
+		pdf.object(pageId, fmt.Sprintf("<</Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources <</Font <</F1 %d 0 R>>>> /Contents %d 0 R>>",
+			pages, pdfNumber(pageSize[0]), pdfNumber(pageSize[1]), font, contentId))
+	}
+	pdf.object(catalog, fmt.Sprintf("<</Type /Catalog /Pages %d 0 R>>", pages))
+	pdf.object(pages, fmt.Sprintf("<</Type /Pages /Kids [%s] /Count %d>>", strings.Join(kids, " "), pageCount))
+	pdf.object(font, "<</Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding>>")
+
+	mazeIds := []string{}
+	for _, sheet := range sheets {
+		if sheet.Maze.Id != "" {
+			mazeIds = append(mazeIds, sheet.Maze.Id)
+		}
+	}
+	subject := fmt.Sprintf("%d mazes", len(sheets))
+	if len(sheets) == 1 {
+		subject = "1 maze"
+	}
+	pdf.object(info, fmt.Sprintf("<</Title %s /Subject %s /Keywords %s /Producer %s /CreationDate %s>>",
+		pdfString(options.Title), pdfString(subject), pdfString(strings.Join(mazeIds, " ")),
+		pdfString(MAZE_PDF_PRODUCER), pdfString(pdfDate(options.CreatedAt))))
+	return pdf.writeTo(w, catalog, info)
+}
+
+// drawMazePdfPage returns the content stream of a page, first is the index of the first sheet in the document
+func drawMazePdfPage(sheets []MazePdfSheet, first int, solutions bool, options MazePdfOptions, page int, pageCount int) []byte {
+	pageSize := MAZE_PDF_PAGE_SIZES[options.PageSize]
+	theme := MAZE_THEMES[MAZE_DRAW_DEFAULT_THEME]
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s rg\n", pdfColor(theme.Label))
+
+	// Header and footer
+	title := options.Title
+	if solutions {
+		title += MAZE_PDF_SOLUTION_TITLE
+	}
+	top := pageSize[1] - MAZE_PDF_MARGIN
+	writePdfText(&out, title, MAZE_PDF_TITLE_SIZE, pageSize[0]/2, top-MAZE_PDF_TITLE_SIZE, pageSize[0]-2*MAZE_PDF_MARGIN)
+	writePdfText(&out, fmt.Sprintf("Page %d of %d", page, pageCount), MAZE_PDF_FOOTER_SIZE, pageSize[0]/2, MAZE_PDF_MARGIN, pageSize[0]-2*MAZE_PDF_MARGIN)
+
+	// The slots fill the space between header and footer
+	left := float64(MAZE_PDF_MARGIN)
+	top -= 2 * MAZE_PDF_TITLE_SIZE
+	width := pageSize[0] - 2*MAZE_PDF_MARGIN
+	height := top - MAZE_PDF_MARGIN - 2*MAZE_PDF_FOOTER_SIZE
+	columns, rows := mazePdfGrid(options.PerPage, width, height)
+	slotWidth := (width - float64(columns-1)*MAZE_PDF_GAP) / float64(columns)
+	slotHeight := (height - float64(rows-1)*MAZE_PDF_GAP) / float64(rows)
+	for i, sheet := range sheets {
+		slotLeft := left + float64(i%columns)*(slotWidth+MAZE_PDF_GAP)
+		slotTop := top - float64(i/columns)*(slotHeight+MAZE_PDF_GAP)
+		maze := sheet.Maze
+		caption := fmt.Sprintf("%d. %dx%d, entrance %s", first+i+1, maze.GridWidth, maze.GridHeight, toApiAddress(maze.EntranceX, maze.EntranceY))
+		var path *PathItem
+		if solutions {
+			path = sheet.Solution
+			caption = fmt.Sprintf("%d. Solution, length %d", first+i+1, countPathLength(path))
+		}
+		writePdfText(&out, caption, MAZE_PDF_CAPTION_SIZE, slotLeft+slotWidth/2, slotTop-MAZE_PDF_CAPTION_SIZE, slotWidth)
+
+		// The largest square cells that fit below the caption, the maze is centred horizontally
+		mazeHeight := slotHeight - 2*MAZE_PDF_CAPTION_SIZE
+		cellSize := math.Min(slotWidth/float64(maze.GridWidth), mazeHeight/float64(maze.GridHeight))
+		mazeLeft := slotLeft + (slotWidth-cellSize*float64(maze.GridWidth))/2
+		writePdfMaze(&out, maze, path, cellSize, mazeLeft, slotTop-2*MAZE_PDF_CAPTION_SIZE)
+	}
+	return out.Bytes()
+}
+
+// writePdfText centres the text on x, text that is wider than maxWidth is set in a smaller size
+func writePdfText(out *bytes.Buffer, text string, fontSize float64, x float64, baseline float64, maxWidth float64) {
+	width := pdfTextWidth(text, fontSize)
+	if width > maxWidth {
+		fontSize *= maxWidth / width
+		width = maxWidth
+	}
+	fmt.Fprintf(out, "BT /F1 %s Tf %s %s Td %s Tj ET\n", pdfNumber(fontSize), pdfNumber(x-width/2), pdfNumber(baseline), pdfString(text))
+}
+
+// writePdfMaze draws the maze in cell units below the top left corner, the y axis is flipped to run down like the rows
+func writePdfMaze(out *bytes.Buffer, maze *Maze, path *PathItem, cellSize float64, left float64, top float64) {
+	theme := MAZE_THEMES[MAZE_DRAW_DEFAULT_THEME]
+	fmt.Fprintf(out, "q %s 0 0 %s %s %s cm\n", pdfNumber(cellSize), pdfNumber(-cellSize), pdfNumber(left), pdfNumber(top))
+	fmt.Fprintf(out, "%s rg 0 0 %d %d re f\n", pdfColor(theme.Floor), maze.GridWidth, maze.GridHeight)
+
+	outlines := wallOutlines(maze)
+	if len(outlines) > 0 {
+		fmt.Fprintf(out, "%s rg\n", pdfColor(theme.Wall))
+	}
+	for _, outline := range outlines {
+		for i, point := range outline {
+			operator := "l"
+			if i == 0 {
+				operator = "m"
+			}
+			fmt.Fprintf(out, "%d %d %s ", point.x, point.y, operator)
+		}
+		out.WriteString("h\n")
+	}
+	if len(outlines) > 0 {
+		out.WriteString("f\n")
+	}
+
+	if path != nil {
+		fmt.Fprintf(out, "%s RG %s w 1 J 1 j\n", pdfColor(theme.Path), pdfNumber(MAZE_PDF_PATH_WIDTH))
+		for i, point := range pathCorners(path) {
+			operator := "l"
+			if i == 0 {
+				operator = "m"
+			}
+			fmt.Fprintf(out, "%d.5 %d.5 %s ", point.x, point.y, operator)
+		}
+		out.WriteString("S\n")
+	}
+
+	writePdfMarker(out, maze.EntranceX, maze.EntranceY, pdfColor(theme.Entrance))
+	if path != nil {
+		writePdfMarker(out, path.X, path.Y, pdfColor(theme.Exit))
+	} else if exitX, exitY, ok := findExit(maze); ok {
+		writePdfMarker(out, exitX, exitY, pdfColor(theme.Exit))
+	}
+	out.WriteString("Q\n")
+}
+
+func writePdfMarker(out *bytes.Buffer, x uint16, y uint16, color string) {
+	size := 1 - 2*MAZE_PDF_MARKER_INSET
+	fmt.Fprintf(out, "%s rg %s %s %s %s re f\n", color,
+		pdfNumber(float64(x)+MAZE_PDF_MARKER_INSET), pdfNumber(float64(y)+MAZE_PDF_MARKER_INSET), pdfNumber(size), pdfNumber(size))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPdfSheets(t *testing.T, count int) []MazePdfSheet {
+	sheets := []MazePdfSheet{}
+	for i := 0; i < count; i++ {
+		maze := newTestMaze("B1",
+			"#.###",
+			"#...#",
+			"###.#",
+		)
+		maze.Id = string(rune('a' + i))
+		path, err := stringsToPath([]string{"B1", "B2", "C2", "D2", "D3"})
+		assert.Nil(t, err)
+		sheets = append(sheets, MazePdfSheet{Maze: maze, Solution: path})
+	}
+	return sheets
+}
+
+func Test_drawMazePdf(t *testing.T) {
+	options := DefaultMazePdfOptions()
+	options.Title = "Class (4b)"
+	options.CreatedAt = time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+	var out bytes.Buffer
+	assert.Nil(t, drawMazePdf(newTestPdfSheets(t, 1), options, &out))
+
+	pdf := readTestPdf(t, out.Bytes())
+	assert.Equal(t, []int{1}, pdf.find("/Type /Catalog"))
+	assert.Equal(t, "<</Type /Pages /Kids [5 0 R] /Count 1>>", pdf.objects[2])
+	assert.Equal(t, "<</Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding>>", pdf.objects[3])
+	assert.Equal(t, `<</Title (Class \(4b\)) /Subject (1 maze) /Keywords (a) /Producer (Codingchallenge MazeAPI) /CreationDate (D:20240301083000Z)>>`, pdf.objects[4])
+	assert.Contains(t, pdf.objects[5], "/MediaBox [0 0 595.28 841.89]")
+
+	content := pdf.streams[6]
+	assert.Contains(t, content, `Tj ET`)
+	assert.Contains(t, content, `(Class \(4b\)) Tj`)
+	assert.Contains(t, content, `(1. 5x3, entrance B1) Tj`)
+	assert.Contains(t, content, `(Page 1 of 1) Tj`)
+	// The walls are merged outlines in cell units
+	assert.Contains(t, content, "0 0 m 1 0 l 1 2 l 3 2 l 3 3 l 0 3 l h\n2 0 m 5 0 l 5 3 l 4 3 l 4 1 l 2 1 l h\nf\n")
+	// Entrance and exit are marked, without solution there is no path
+	assert.Contains(t, content, "0 0.753 0 rg 1.15 0.15 0.7 0.7 re f\n")
+	assert.Contains(t, content, "0 0.376 1 rg 3.15 2.15 0.7 0.7 re f\n")
+	assert.NotContains(t, content, " S\n")
+}
+
+func Test_drawMazePdf_Solutions(t *testing.T) {
+	options := DefaultMazePdfOptions()
+	options.Solutions = true
+	options.PerPage = 2
+	options.PageSize = MAZE_PDF_PAGE_LETTER
+	var out bytes.Buffer
+	assert.Nil(t, drawMazePdf(newTestPdfSheets(t, 3), options, &out))
+
+	pdf := readTestPdf(t, out.Bytes())
+	assert.Contains(t, pdf.objects[2], "/Count 4")
+	pageIds := pdf.find("/Type /Page ")
+	assert.Len(t, pageIds, 4)
+	contents := []string{}
+	for _, id := range pageIds {
+		assert.Contains(t, pdf.objects[id], "/MediaBox [0 0 612 792]")
+		contents = append(contents, pdf.streams[id+1])
+	}
+
+	// Two pages with the mazes, then two pages with the solutions of the same mazes
+	assert.Contains(t, contents[0], "(1. 5x3, entrance B1)")
+	assert.Contains(t, contents[0], "(2. 5x3, entrance B1)")
+	assert.Contains(t, contents[1], "(3. 5x3, entrance B1)")
+	assert.Contains(t, contents[2], "(Mazes - Solutions) Tj")
+	assert.Contains(t, contents[2], "(1. Solution, length 5)")
+	assert.Contains(t, contents[3], "(3. Solution, length 5)")
+	assert.Contains(t, contents[3], "(Page 4 of 4)")
+	for i, content := range contents {
+		assert.Equal(t, i >= 2, strings.Contains(content, "1.5 0.5 m 1.5 1.5 l 3.5 1.5 l 3.5 2.5 l S\n"), "path on page %d", i+1)
+	}
+	assert.Contains(t, pdf.objects[4], "/Keywords (a b c)")
+}
+
+func Test_drawMazePdf_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		sheets  int
+		options func(options *MazePdfOptions)
+		field   string
+	}{
+		{"no mazes", 0, func(options *MazePdfOptions) {}, "mazeIds"},
+		{"too many mazes", MAZE_PDF_MAX_MAZES + 1, func(options *MazePdfOptions) {}, "mazeIds"},
+		{"page size", 1, func(options *MazePdfOptions) { options.PageSize = "a3" }, "pageSize"},
+		{"per page", 1, func(options *MazePdfOptions) { options.PerPage = MAZE_PDF_MAX_PER_PAGE + 1 }, "perPage"},
+		{"title", 1, func(options *MazePdfOptions) { options.Title = strings.Repeat("ä", MAZE_PDF_MAX_TITLE_LENGTH+1) }, "title"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultMazePdfOptions()
+			tt.options(&options)
+			err := drawMazePdf(newTestPdfSheets(t, tt.sheets), options, &bytes.Buffer{})
+			codedError, ok := err.(*CodedError)
+			if assert.True(t, ok, "%v", err) {
+				assert.Equal(t, ERROR_CODE_INVALID_PARAMETER, codedError.Code)
+				assert.Equal(t, tt.field, codedError.Details[0].Field)
+			}
+		})
+	}
+}
+
+func Test_mazePdfGrid(t *testing.T) {
+	tests := []struct {
+		perPage int
+		columns int
+		rows    int
+	}{
+		{1, 1, 1},
+		{2, 1, 2},
+		{4, 2, 2},
+		{6, 2, 3},
+		{9, 3, 3},
+		{12, 3, 4},
+	}
+	for _, tt := range tests {
+		columns, rows := mazePdfGrid(tt.perPage, 523, 700)
+		assert.Equal(t, [2]int{tt.columns, tt.rows}, [2]int{columns, rows}, "perPage %d", tt.perPage)
+	}
+}
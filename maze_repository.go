@@ -2,18 +2,30 @@ package main
 
 import (
 	"database/sql"
-	"log"
+	"encoding/json"
 
 	"github.com/speps/go-hashids"
 )
 
-const MAZE_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS mazes (id INTEGER PRIMARY KEY, user_id VARCHAR(255) NOT NULL, entrance_x INTEGER NOT NULL, entrance_y INTEGER NOT NULL, grid_width INTEGER NOT NULL, grid_height INTEGER NOT NULL, walls BLOB NOT NULL)"
+const MAZE_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS mazes (id INTEGER PRIMARY KEY, user_id VARCHAR(255) NOT NULL, entrance_x INTEGER NOT NULL, entrance_y INTEGER NOT NULL, grid_width INTEGER NOT NULL, grid_height INTEGER NOT NULL, walls BLOB NOT NULL, team_id INTEGER, stats TEXT)"
+
+var MAZE_REPO_MIGRATIONS = []string{
+	"ALTER TABLE mazes ADD COLUMN team_id INTEGER",
+	"ALTER TABLE mazes ADD COLUMN stats TEXT",
+}
+
+const MAZE_REPO_SELECT_COLUMNS = "SELECT id, user_id, team_id, entrance_x, entrance_y, grid_width, grid_height, walls, stats FROM mazes"
 
 type MazeRepository interface {
-	Insert(user_id string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte) (string, error)
+	Insert(user_id string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error)
+	InsertForTeam(teamId string, userId string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error)
 	SelectById(id string) (*Maze, error)
 	SelectAllByUserId(userId string) ([]*Maze, error)
+	SelectAllByTeamId(teamId string) ([]*Maze, error)
+	UpdateStats(id string, stats *MazeStats) error
 	CountAll() (uint64, error)
+	CountByUserId(userId string) (uint64, error)
+	DeleteById(id string) error
 }
 
 func NewMazeRepository(db *sql.DB, hashid *hashids.HashID) MazeRepository {
@@ -21,6 +33,10 @@ func NewMazeRepository(db *sql.DB, hashid *hashids.HashID) MazeRepository {
 	if err != nil {
 		panic(err)
 	}
+	err = migrate(db, MAZE_REPO_MIGRATIONS)
+	if err != nil {
+		panic(err)
+	}
 	return &mazeRepositoryImpl{
 		db:     db,
 		hashid: hashid,
@@ -32,11 +48,36 @@ type mazeRepositoryImpl struct {
 	hashid *hashids.HashID
 }
 
-func (m *mazeRepositoryImpl) Insert(user_id string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte) (string, error) {
-	result, err := m.db.Exec("INSERT INTO mazes (user_id,entrance_x, entrance_y, grid_width, grid_height, walls) VALUES (?, ?, ?, ?, ?, ?)", user_id, entranceX, entranceY, gridWidth, gridHeight, walls)
+// Insert stores the maze unless the user stored maxStoredMazes mazes already, 0 allows any number.
+func (m *mazeRepositoryImpl) Insert(user_id string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error) {
+	return m.insert(nil, user_id, entranceX, entranceY, gridWidth, gridHeight, walls, maxStoredMazes)
+}
+
+// InsertForTeam stores a maze owned by the team, the user is recorded as its author and the maze counts against
+// maxStoredMazes of the user.
+func (m *mazeRepositoryImpl) InsertForTeam(teamId string, userId string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error) {
+	decodedTeamId, err := m.hashid.DecodeInt64WithError(teamId)
 	if err != nil {
 		return "", err
 	}
+	return m.insert(decodedTeamId[0], userId, entranceX, entranceY, gridWidth, gridHeight, walls, maxStoredMazes)
+}
+
+// insert counts the mazes of the user and inserts the new one in a single statement, so that concurrent requests can
+// not exceed the limit together.
+func (m *mazeRepositoryImpl) insert(teamId any, userId string, entranceX uint16, entranceY uint16, gridWidth uint16, gridHeight uint16, walls []byte, maxStoredMazes uint64) (string, error) {
+	result, err := m.db.Exec("INSERT INTO mazes (user_id, team_id, entrance_x, entrance_y, grid_width, grid_height, walls) SELECT ?, ?, ?, ?, ?, ?, ? WHERE ? = 0 OR (SELECT COUNT(*) FROM mazes WHERE user_id = ?) < ?",
+		userId, teamId, entranceX, entranceY, gridWidth, gridHeight, walls, maxStoredMazes, userId, maxStoredMazes)
+	if err != nil {
+		return "", err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if inserted == 0 {
+		return "", storedMazesExceededError(maxStoredMazes)
+	}
 	id, err := result.LastInsertId()
 	if err != nil {
 		return "", err
@@ -45,13 +86,14 @@ func (m *mazeRepositoryImpl) Insert(user_id string, entranceX uint16, entranceY
 	return m.hashid.EncodeInt64([]int64{int64(id)})
 }
 
+// SelectById returns nil if there is no maze with the id, including ids that are not valid hash ids.
 func (m *mazeRepositoryImpl) SelectById(id string) (*Maze, error) {
 	decoded, err := m.hashid.DecodeInt64WithError(id)
-	if err != nil {
-		return nil, err
+	if err != nil || len(decoded) != 1 {
+		return nil, nil
 	}
 
-	rows, err := m.db.Query("SELECT id, entrance_x, entrance_y, grid_width, grid_height, walls FROM mazes WHERE id = ?", decoded[0])
+	rows, err := m.db.Query(MAZE_REPO_SELECT_COLUMNS+" WHERE id = ?", decoded[0])
 	if err != nil {
 		return nil, err
 	}
@@ -61,17 +103,22 @@ func (m *mazeRepositoryImpl) SelectById(id string) (*Maze, error) {
 		return nil, nil
 	}
 
-	maze := &Maze{}
-	var walls []byte
-	var resultId uint64
-	err = rows.Scan(&resultId, &maze.EntranceX, &maze.EntranceY, &maze.GridWidth, &maze.GridHeight, &walls)
+	return m.scanMaze(rows)
+}
+
+// UpdateStats stores the stats as JSON, they are read back with the maze.
+func (m *mazeRepositoryImpl) UpdateStats(id string, stats *MazeStats) error {
+	decoded, err := m.hashid.DecodeInt64WithError(id)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	maze.Walls = walls
-	log.Println(resultId)
-	maze.Id, err = m.hashid.EncodeInt64([]int64{int64(resultId)})
-	return maze, err
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec("UPDATE mazes SET stats = ? WHERE id = ?", string(data), decoded[0])
+	return err
 }
 
 func (m *mazeRepositoryImpl) CountAll() (uint64, error) {
@@ -94,8 +141,31 @@ func (m *mazeRepositoryImpl) CountAll() (uint64, error) {
 	return count, nil
 }
 
+// CountByUserId counts the mazes the user stored, including the ones authored for a team.
+func (m *mazeRepositoryImpl) CountByUserId(userId string) (uint64, error) {
+	var count uint64
+	err := m.db.QueryRow("SELECT COUNT(*) FROM mazes WHERE user_id = ?", userId).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SelectAllByUserId returns the mazes owned by the user, mazes the user authored for a team are not included.
 func (m *mazeRepositoryImpl) SelectAllByUserId(userId string) ([]*Maze, error) {
-	rows, err := m.db.Query("SELECT id, entrance_x, entrance_y, grid_width, grid_height, walls FROM mazes WHERE user_id = ?", userId)
+	return m.selectMazes(MAZE_REPO_SELECT_COLUMNS+" WHERE user_id = ? AND team_id IS NULL", userId)
+}
+
+func (m *mazeRepositoryImpl) SelectAllByTeamId(teamId string) ([]*Maze, error) {
+	decoded, err := m.hashid.DecodeInt64WithError(teamId)
+	if err != nil {
+		return nil, err
+	}
+	return m.selectMazes(MAZE_REPO_SELECT_COLUMNS+" WHERE team_id = ?", decoded[0])
+}
+
+func (m *mazeRepositoryImpl) selectMazes(query string, args ...any) ([]*Maze, error) {
+	rows, err := m.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -103,20 +173,54 @@ func (m *mazeRepositoryImpl) SelectAllByUserId(userId string) ([]*Maze, error) {
 
 	mazes := []*Maze{}
 	for rows.Next() {
-		maze := &Maze{}
-		var walls []byte
-		var resultId uint64
-		err = rows.Scan(&resultId, &maze.EntranceX, &maze.EntranceY, &maze.GridWidth, &maze.GridHeight, &walls)
+		maze, err := m.scanMaze(rows)
 		if err != nil {
 			return nil, err
 		}
-		maze.Walls = walls
-		maze.Id, err = m.hashid.EncodeInt64([]int64{int64(resultId)})
+		mazes = append(mazes, maze)
+	}
+
+	return mazes, nil
+}
+
+// scanMaze reads a row selected with MAZE_REPO_SELECT_COLUMNS
+func (m *mazeRepositoryImpl) scanMaze(rows *sql.Rows) (*Maze, error) {
+	maze := &Maze{}
+	var walls []byte
+	var resultId uint64
+	var teamId sql.NullInt64
+	var stats sql.NullString
+	err := rows.Scan(&resultId, &maze.UserId, &teamId, &maze.EntranceX, &maze.EntranceY, &maze.GridWidth, &maze.GridHeight, &walls, &stats)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Valid {
+		maze.Stats = &MazeStats{}
+		err = json.Unmarshal([]byte(stats.String), maze.Stats)
+		if err != nil {
+			return nil, err
+		}
+	}
+	maze.Walls = walls
+	maze.Id, err = m.hashid.EncodeInt64([]int64{int64(resultId)})
+	if err != nil {
+		return nil, err
+	}
+	if teamId.Valid {
+		maze.TeamId, err = m.hashid.EncodeInt64([]int64{teamId.Int64})
 		if err != nil {
 			return nil, err
 		}
-		mazes = append(mazes, maze)
 	}
+	return maze, nil
+}
 
-	return mazes, nil
+func (m *mazeRepositoryImpl) DeleteById(id string) error {
+	decoded, err := m.hashid.DecodeInt64WithError(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec("DELETE FROM mazes WHERE id = ?", decoded[0])
+	return err
 }
@@ -77,7 +77,7 @@ func Test_mazeRepositoryImpl_Insert(t *testing.T) {
 				db:     tt.fields.db,
 				hashid: tt.fields.hashid,
 			}
-			got, err := m.Insert(tt.args.userId, tt.args.entranceX, tt.args.entranceY, tt.args.gridWidth, tt.args.gridHeight, tt.args.walls)
+			got, err := m.Insert(tt.args.userId, tt.args.entranceX, tt.args.entranceY, tt.args.gridWidth, tt.args.gridHeight, tt.args.walls, 0)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("mazeRepositoryImpl.Insert() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -116,7 +116,7 @@ func Test_mazeRepositoryImpl_SelectById(t *testing.T) {
 						db:     db,
 						hashid: newHashId(),
 					}
-					m.Insert("8Wa", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5})
+					m.Insert("8Wa", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
 					return db
 				}(),
 				hashid: newHashId(),
@@ -126,6 +126,7 @@ func Test_mazeRepositoryImpl_SelectById(t *testing.T) {
 			},
 			want: &Maze{
 				Id:         "8Wa",
+				UserId:     "8Wa",
 				EntranceX:  3,
 				EntranceY:  4,
 				GridWidth:  10,
@@ -192,11 +193,11 @@ func Test_mazeRepositoryImpl_SelectAllByUserId(t *testing.T) {
 						db:     db,
 						hashid: newHashId(),
 					}
-					_, err = m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5})
+					_, err = m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
 					if err != nil {
 						panic(err)
 					}
-					_, err = m.Insert("username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5})
+					_, err = m.Insert("username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 0)
 					if err != nil {
 						panic(err)
 					}
@@ -242,3 +243,138 @@ func Test_mazeRepositoryImpl_SelectAllByUserId(t *testing.T) {
 		})
 	}
 }
+
+func Test_mazeRepositoryImpl_DeleteById(t *testing.T) {
+	m := &mazeRepositoryImpl{
+		db:     newMazeTestDb(),
+		hashid: newHashId(),
+	}
+	first, err := m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+	second, err := m.Insert("username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+
+	assert.Nil(t, m.DeleteById(first))
+
+	got, err := m.SelectById(first)
+	assert.Nil(t, err)
+	assert.Nil(t, got)
+	got, err = m.SelectById(second)
+	assert.Nil(t, err)
+	assert.Equal(t, "username", got.UserId)
+}
+
+func Test_mazeRepositoryImpl_TeamMazes(t *testing.T) {
+	m := &mazeRepositoryImpl{
+		db:     newMazeTestDb(),
+		hashid: newHashId(),
+	}
+	teamId, err := newHashId().EncodeInt64([]int64{7})
+	assert.Nil(t, err)
+	otherTeamId, err := newHashId().EncodeInt64([]int64{8})
+	assert.Nil(t, err)
+
+	_, err = m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+	teamMazeId, err := m.InsertForTeam(teamId, "username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+	_, err = m.InsertForTeam(otherTeamId, "other", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+
+	teamMazes, err := m.SelectAllByTeamId(teamId)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(teamMazes))
+	assert.Equal(t, teamMazeId, teamMazes[0].Id)
+	assert.Equal(t, teamId, teamMazes[0].TeamId)
+	assert.Equal(t, "username", teamMazes[0].UserId)
+
+	// Team mazes are not listed as mazes of the author
+	userMazes, err := m.SelectAllByUserId("username")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(userMazes))
+	assert.Equal(t, "", userMazes[0].TeamId)
+}
+
+func Test_mazeRepositoryImpl_CountByUserId(t *testing.T) {
+	m := &mazeRepositoryImpl{
+		db:     newMazeTestDb(),
+		hashid: newHashId(),
+	}
+	teamId, err := newHashId().EncodeInt64([]int64{7})
+	assert.Nil(t, err)
+
+	_, err = m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+	_, err = m.InsertForTeam(teamId, "username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+	_, err = m.Insert("other", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+
+	count, err := m.CountByUserId("username")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), count)
+}
+
+func Test_mazeRepositoryImpl_Insert_MaxStoredMazes(t *testing.T) {
+	m := &mazeRepositoryImpl{
+		db:     newMazeTestDb(),
+		hashid: newHashId(),
+	}
+	teamId, err := newHashId().EncodeInt64([]int64{7})
+	assert.Nil(t, err)
+
+	_, err = m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 2)
+	assert.Nil(t, err)
+	_, err = m.InsertForTeam(teamId, "username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 2)
+	assert.Nil(t, err)
+	_, err = m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 2)
+	var quotaExceeded *QuotaExceededError
+	assert.ErrorAs(t, err, &quotaExceeded)
+	_, err = m.InsertForTeam(teamId, "username", 4, 5, 11, 11, []byte{1, 2, 3, 4, 5}, 2)
+	assert.ErrorAs(t, err, &quotaExceeded)
+
+	// Other users have their own limit
+	_, err = m.Insert("other", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 2)
+	assert.Nil(t, err)
+	count, err := m.CountByUserId("username")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), count)
+}
+
+func Test_mazeRepositoryImpl_UpdateStats(t *testing.T) {
+	m := &mazeRepositoryImpl{
+		db:     newMazeTestDb(),
+		hashid: newHashId(),
+	}
+	id, err := m.Insert("username", 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
+	assert.Nil(t, err)
+
+	// Mazes are stored without stats
+	got, err := m.SelectById(id)
+	assert.Nil(t, err)
+	assert.Nil(t, got.Stats)
+
+	stats := &MazeStats{Version: MAZE_STATS_VERSION, JunctionHistogram: []int{0, 2, 3, 1, 0}, Exit: "D10", Difficulty: 12.5}
+	assert.Nil(t, m.UpdateStats(id, stats))
+	got, err = m.SelectById(id)
+	assert.Nil(t, err)
+	assert.Equal(t, stats, got.Stats)
+	mazes, err := m.SelectAllByUserId("username")
+	assert.Nil(t, err)
+	assert.Equal(t, stats, mazes[0].Stats)
+}
+
+func Test_NewMazeRepository_MigratesStats(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.Nil(t, err)
+	_, err = db.Exec("CREATE TABLE mazes (id INTEGER PRIMARY KEY, user_id VARCHAR(255) NOT NULL, entrance_x INTEGER NOT NULL, entrance_y INTEGER NOT NULL, grid_width INTEGER NOT NULL, grid_height INTEGER NOT NULL, walls BLOB NOT NULL)")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO mazes (user_id, entrance_x, entrance_y, grid_width, grid_height, walls) VALUES ('username', 1, 0, 2, 2, x'00')")
+	assert.Nil(t, err)
+
+	m := NewMazeRepository(db, newHashId())
+	mazes, err := m.SelectAllByUserId("username")
+	assert.Nil(t, err)
+	assert.Len(t, mazes, 1)
+	assert.Nil(t, mazes[0].Stats)
+}
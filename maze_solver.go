@@ -10,8 +10,13 @@ type MazeSolution struct {
 	Exit   string   `json:"exit"`
 }
 
+// MazeTraceHook is called for every cell that an algorithm visits, in the order of the visits
+type MazeTraceHook func(x uint16, y uint16)
+
 type MazeSolver interface {
 	FindSolutions(maze *Maze) ([]MazeSolution, error)
+	// TraceSolutions finds the same solutions as FindSolutions and reports every step of the search to the hook
+	TraceSolutions(maze *Maze, visit MazeTraceHook) ([]MazeSolution, error)
 }
 
 func NewMazeSolver() MazeSolver {
@@ -22,12 +27,16 @@ type mazeSolverImpl struct {
 }
 
 func (m *mazeSolverImpl) FindSolutions(maze *Maze) ([]MazeSolution, error) {
+	return m.TraceSolutions(maze, nil)
+}
+
+func (m *mazeSolverImpl) TraceSolutions(maze *Maze, visit MazeTraceHook) ([]MazeSolution, error) {
 	firstPathItem := &PathItem{
 		X: maze.EntranceX,
 		Y: maze.EntranceY,
 	}
 	var completePaths []*PathItem
-	findAllPathsToExit_cell(maze, firstPathItem, &completePaths)
+	findAllPathsToExit_cell(maze, firstPathItem, &completePaths, visit)
 
 	var result []MazeSolution
 	for _, path := range completePaths {
@@ -157,7 +166,11 @@ func countPathLength(path *PathItem) uint16 {
 	return count
 }
 
-func findAllPathsToExit_cell(maze *Maze, lastPathItem *PathItem, completePaths *[]*PathItem) {
+func findAllPathsToExit_cell(maze *Maze, lastPathItem *PathItem, completePaths *[]*PathItem, visit MazeTraceHook) {
+	if visit != nil {
+		visit(lastPathItem.X, lastPathItem.Y)
+	}
+
 	// Look for an exit
 	if lastPathItem.Y == maze.GridHeight-1 {
 		// Every path that reaches the bottom is a complete path
@@ -174,7 +187,7 @@ func findAllPathsToExit_cell(maze *Maze, lastPathItem *PathItem, completePaths *
 			Y:    lastPathItem.Y,
 			Prev: lastPathItem,
 		}
-		findAllPathsToExit_cell(maze, nextPathItem, completePaths)
+		findAllPathsToExit_cell(maze, nextPathItem, completePaths, visit)
 	}
 
 	// Look down
@@ -186,7 +199,7 @@ func findAllPathsToExit_cell(maze *Maze, lastPathItem *PathItem, completePaths *
 			Y:    lastPathItem.Y + 1,
 			Prev: lastPathItem,
 		}
-		findAllPathsToExit_cell(maze, nextPathItem, completePaths)
+		findAllPathsToExit_cell(maze, nextPathItem, completePaths, visit)
 	}
 
 	// Look left
@@ -198,7 +211,7 @@ func findAllPathsToExit_cell(maze *Maze, lastPathItem *PathItem, completePaths *
 			Y:    lastPathItem.Y,
 			Prev: lastPathItem,
 		}
-		findAllPathsToExit_cell(maze, nextPathItem, completePaths)
+		findAllPathsToExit_cell(maze, nextPathItem, completePaths, visit)
 	}
 
 	// Look up
@@ -210,6 +223,6 @@ func findAllPathsToExit_cell(maze *Maze, lastPathItem *PathItem, completePaths *
 			Y:    lastPathItem.Y - 1,
 			Prev: lastPathItem,
 		}
-		findAllPathsToExit_cell(maze, nextPathItem, completePaths)
+		findAllPathsToExit_cell(maze, nextPathItem, completePaths, visit)
 	}
 }
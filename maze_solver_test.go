@@ -121,3 +121,20 @@ func Test_mazeSolverImpl_FindSolutions(t *testing.T) {
 		})
 	}
 }
+
+func Test_mazeSolverImpl_TraceSolutions(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.##",
+		"#..#",
+		"##.#",
+	)
+	visits := []string{}
+	solutions, err := NewMazeSolver().TraceSolutions(maze, func(x uint16, y uint16) {
+		visits = append(visits, toApiAddress(x, y))
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"B1", "B2", "C2", "C3"}, visits)
+	found, err := NewMazeSolver().FindSolutions(maze)
+	assert.Nil(t, err)
+	assert.Equal(t, found, solutions)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// MAZE_STATS_VERSION is stored with the stats, stats of another version are computed again
+	MAZE_STATS_VERSION = 1
+
+	// The difficulty adds up these weighted parts, each of them grows from 0 to 1
+	MAZE_DIFFICULTY_SIZE_WEIGHT     = 0.25
+	MAZE_DIFFICULTY_DECISION_WEIGHT = 0.25
+	MAZE_DIFFICULTY_WINDING_WEIGHT  = 0.2
+	MAZE_DIFFICULTY_DEAD_END_WEIGHT = 0.15
+	MAZE_DIFFICULTY_DETOUR_WEIGHT   = 0.15
+	// The size, decision and dead end parts are half way to 1 at their scale
+	MAZE_DIFFICULTY_SIZE_SCALE     = 400.0 // reachable cells
+	MAZE_DIFFICULTY_DECISION_SCALE = 10.0  // decision points on the solution
+	MAZE_DIFFICULTY_DEAD_END_SCALE = 0.1   // dead ends per reachable cell
+)
+
+// MazeStats describes the structure of a maze. Except for OpenCells only the cells that can be reached from the
+// entrance are counted, the solution is the shortest path from the entrance to the exit.
+type MazeStats struct {
+	Version        int `json:"version"`
+	OpenCells      int `json:"openCells"`
+	ReachableCells int `json:"reachableCells"`
+	// DeadEnds have a single open neighbour, the entrance and the exit are no dead ends
+	DeadEnds int `json:"deadEnds"`
+	// Junctions have three or four open neighbours
+	Junctions int `json:"junctions"`
+	// JunctionHistogram counts the cells by their number of open neighbours, from 0 to 4
+	JunctionHistogram []int `json:"junctionHistogram"`
+	// BranchingFactor is the average number of ways on at the cells that are no dead ends
+	BranchingFactor float64 `json:"branchingFactor"`
+	// LongestCorridor is the most connected cells with exactly two open neighbours
+	LongestCorridor int    `json:"longestCorridor"`
+	Exit            string `json:"exit,omitempty"`
+	// SolutionLength counts the cells of the solution like MazeSolution.Length, it is 0 without exit
+	SolutionLength int `json:"solutionLength"`
+	// SolutionRatio is the share of the open cells that is on the solution
+	SolutionRatio float64 `json:"solutionRatio"`
+	// DecisionPoints are the junctions on the solution
+	DecisionPoints int `json:"decisionPoints"`
+	// Tortuosity divides the steps of the solution by the Manhattan distance of the entrance and the exit
+	Tortuosity float64 `json:"tortuosity"`
+	// Difficulty scores the maze from 0 to 100, see the MAZE_DIFFICULTY_* constants
+	Difficulty float64 `json:"difficulty"`
+}
+
+// computeMazeStats searches the maze breadth first, unlike the solver it does not follow every path
+func computeMazeStats(maze *Maze) *MazeStats {
+	distances := mazeDistances(maze)
+	stats := &MazeStats{
+		Version:           MAZE_STATS_VERSION,
+		OpenCells:         distances.Reachable + distances.Unreachable,
+		ReachableCells:    distances.Reachable,
+		JunctionHistogram: make([]int, 5),
+	}
+	if stats.ReachableCells == 0 {
+		return stats
+	}
+
+	// Count the open neighbours
+	width := distances.width
+	entrance := int(maze.EntranceY)*width + int(maze.EntranceX)
+	exit, hasExit := mazeStatsExit(maze, distances)
+	degrees := make([]int, len(distances.cells))
+	ways, waysCells := 0, 0
+	for cell, distance := range distances.cells {
+		if distance < 0 {
+			continue
+		}
+		degrees[cell] = len(openNeighbors(maze, cell))
+		stats.JunctionHistogram[degrees[cell]]++
+		if degrees[cell] >= 3 {
+			stats.Junctions++
+		}
+		if degrees[cell] <= 1 && cell != entrance && (!hasExit || cell != exit) {
+			stats.DeadEnds++
+		}
+		if degrees[cell] >= 2 {
+			ways += degrees[cell] - 1
+			waysCells++
+		}
+	}
+	if waysCells > 0 {
+		stats.BranchingFactor = roundStat(float64(ways)/float64(waysCells), 3)
+	}
+	stats.LongestCorridor = longestMazeCorridor(maze, degrees)
+
+	// Walk the solution back from the exit
+	if hasExit {
+		stats.Exit = toApiAddress(uint16(exit%width), uint16(exit/width))
+		stats.SolutionLength = distances.cells[exit] + 1
+		for cell := exit; cell != entrance; {
+			for _, neighbor := range openNeighbors(maze, cell) {
+				if distances.cells[neighbor] == distances.cells[cell]-1 {
+					cell = neighbor
+					break
+				}
+			}
+			if degrees[cell] >= 3 {
+				stats.DecisionPoints++
+			}
+		}
+		stats.SolutionRatio = roundStat(float64(stats.SolutionLength)/float64(stats.OpenCells), 3)
+		manhattan := absInt(exit%width-entrance%width) + absInt(exit/width-entrance/width)
+		stats.Tortuosity = roundStat(float64(stats.SolutionLength-1)/float64(manhattan), 3)
+	}
+	stats.Difficulty = mazeDifficulty(stats)
+	return stats
+}
+
+// mazeStatsExit returns the exit the solver finds on the bottom edge. Generated mazes may exit on another edge, then
+// the reachable edge cell farthest from the entrance is the exit.
+func mazeStatsExit(maze *Maze, distances *MazeDistances) (int, bool) {
+	width := distances.width
+	if x, y, ok := findExit(maze); ok {
+		return int(y)*width + int(x), true
+	}
+	exit := -1
+	for cell, distance := range distances.cells {
+		x, y := cell%width, cell/width
+		onEdge := x == 0 || y == 0 || x == width-1 || y == int(maze.GridHeight)-1
+		if onEdge && distance > 0 && (exit < 0 || distance > distances.cells[exit]) {
+			exit = cell
+		}
+	}
+	return exit, exit >= 0
+}
+
+// longestMazeCorridor returns the size of the largest group of connected cells with exactly two open neighbours
+func longestMazeCorridor(maze *Maze, degrees []int) int {
+	longest := 0
+	visited := make([]bool, len(degrees))
+	for start := range degrees {
+		if degrees[start] != 2 || visited[start] {
+			continue
+		}
+		size := 0
+		visited[start] = true
+		stack := []int{start}
+		for len(stack) > 0 {
+			cell := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			for _, neighbor := range openNeighbors(maze, cell) {
+				if degrees[neighbor] == 2 && !visited[neighbor] {
+					visited[neighbor] = true
+					stack = append(stack, neighbor)
+				}
+			}
+		}
+		if size > longest {
+			longest = size
+		}
+	}
+	return longest
+}
+
+// mazeDifficulty weighs the size, the decisions and the winding of the solution, and how many dead ends and detours
+// lead away from it
+func mazeDifficulty(stats *MazeStats) float64 {
+	size := float64(stats.ReachableCells)
+	decisions := float64(stats.DecisionPoints)
+	deadEnds := float64(stats.DeadEnds) / math.Max(1, size)
+	difficulty := MAZE_DIFFICULTY_SIZE_WEIGHT*size/(size+MAZE_DIFFICULTY_SIZE_SCALE) +
+		MAZE_DIFFICULTY_DECISION_WEIGHT*decisions/(decisions+MAZE_DIFFICULTY_DECISION_SCALE) +
+		MAZE_DIFFICULTY_DEAD_END_WEIGHT*deadEnds/(deadEnds+MAZE_DIFFICULTY_DEAD_END_SCALE)
+	if stats.Tortuosity > 1 {
+		difficulty += MAZE_DIFFICULTY_WINDING_WEIGHT * (1 - 1/stats.Tortuosity)
+	}
+	if stats.SolutionLength > 0 {
+		difficulty += MAZE_DIFFICULTY_DETOUR_WEIGHT * (1 - stats.SolutionRatio)
+	}
+	return roundStat(100*difficulty, 1)
+}
+
+func roundStat(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+const (
+	// MAZE_SORT_DIFFICULTY lists the easiest mazes first, MAZE_SORT_DIFFICULTY_DESC the hardest
+	MAZE_SORT_DIFFICULTY      = "difficulty"
+	MAZE_SORT_DIFFICULTY_DESC = "-difficulty"
+)
+
+// MazeListFilter keeps the mazes with a difficulty from MinDifficulty to MaxDifficulty, without Sort the mazes keep
+// their order
+type MazeListFilter struct {
+	MinDifficulty float64
+	MaxDifficulty float64
+	Sort          string
+}
+
+func DefaultMazeListFilter() MazeListFilter {
+	return MazeListFilter{MinDifficulty: 0, MaxDifficulty: 100}
+}
+
+func (f MazeListFilter) limitsDifficulty() bool {
+	return f.MinDifficulty > 0 || f.MaxDifficulty < 100
+}
+
+// filterMazes applies the filter, mazes without stats only pass a filter that does not limit the difficulty and are
+// sorted last
+func filterMazes(mazes []*Maze, filter MazeListFilter) []*Maze {
+	filtered := []*Maze{}
+	for _, maze := range mazes {
+		if maze.Stats == nil {
+			if !filter.limitsDifficulty() {
+				filtered = append(filtered, maze)
+			}
+			continue
+		}
+		if maze.Stats.Difficulty >= filter.MinDifficulty && maze.Stats.Difficulty <= filter.MaxDifficulty {
+			filtered = append(filtered, maze)
+		}
+	}
+	if filter.Sort != "" {
+		sort.SliceStable(filtered, func(i, j int) bool {
+			if filtered[i].Stats == nil || filtered[j].Stats == nil {
+				return filtered[j].Stats == nil && filtered[i].Stats != nil
+			}
+			if filter.Sort == MAZE_SORT_DIFFICULTY_DESC {
+				return filtered[i].Stats.Difficulty > filtered[j].Stats.Difficulty
+			}
+			return filtered[i].Stats.Difficulty < filtered[j].Stats.Difficulty
+		})
+	}
+	return filtered
+}
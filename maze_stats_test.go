@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_computeMazeStats(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#####",
+		"#...#.#",
+		"#.#...#",
+		"#.#.#.#",
+		"###.###",
+	)
+	assert.Equal(t, &MazeStats{
+		Version:           MAZE_STATS_VERSION,
+		OpenCells:         13,
+		ReachableCells:    13,
+		DeadEnds:          3,
+		Junctions:         3,
+		JunctionHistogram: []int{0, 5, 5, 3, 0},
+		BranchingFactor:   1.375,
+		LongestCorridor:   2,
+		Exit:              "D5",
+		SolutionLength:    7,
+		SolutionRatio:     0.538,
+		DecisionPoints:    2,
+		Tortuosity:        1,
+		Difficulty:        22.3,
+	}, computeMazeStats(maze))
+}
+
+func Test_computeMazeStats_ExitOnSide(t *testing.T) {
+	// Generated mazes may exit on any edge, the farthest open edge cell is the exit
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"#.#..",
+		"#####",
+	)
+	stats := computeMazeStats(maze)
+	assert.Equal(t, "E3", stats.Exit)
+	assert.Equal(t, 6, stats.SolutionLength)
+	assert.Equal(t, 1, stats.DeadEnds)
+	assert.Equal(t, 1, stats.DecisionPoints)
+}
+
+func Test_computeMazeStats_Winding(t *testing.T) {
+	straight := computeMazeStats(newTestMaze("B1",
+		"#.###",
+		"#.###",
+		"#.###",
+		"#.###",
+	))
+	assert.Equal(t, 1.0, straight.Tortuosity)
+	assert.Equal(t, 2, straight.LongestCorridor, "the entrance and the exit have a single open neighbour")
+
+	winding := computeMazeStats(newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"###.#",
+		"#...#",
+		"#.###",
+	))
+	assert.Equal(t, 2.0, winding.Tortuosity)
+	assert.Greater(t, winding.Difficulty, straight.Difficulty)
+}
+
+func Test_computeMazeStats_EntranceIsWall(t *testing.T) {
+	stats := computeMazeStats(newTestMaze("A1",
+		"#.",
+		"..",
+	))
+	assert.Equal(t, 3, stats.OpenCells)
+	assert.Equal(t, 0, stats.ReachableCells)
+	assert.Equal(t, "", stats.Exit)
+	assert.Equal(t, 0.0, stats.Difficulty)
+}
+
+func Test_filterMazes(t *testing.T) {
+	withDifficulty := func(id string, difficulty float64) *Maze {
+		return &Maze{Id: id, Stats: &MazeStats{Difficulty: difficulty}}
+	}
+	mazes := []*Maze{withDifficulty("a", 40), {Id: "b"}, withDifficulty("c", 10), withDifficulty("d", 70)}
+	ids := func(mazes []*Maze) []string {
+		result := []string{}
+		for _, maze := range mazes {
+			result = append(result, maze.Id)
+		}
+		return result
+	}
+
+	filter := DefaultMazeListFilter()
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids(filterMazes(mazes, filter)))
+	filter.Sort = MAZE_SORT_DIFFICULTY
+	assert.Equal(t, []string{"c", "a", "d", "b"}, ids(filterMazes(mazes, filter)))
+	filter.Sort = MAZE_SORT_DIFFICULTY_DESC
+	assert.Equal(t, []string{"d", "a", "c", "b"}, ids(filterMazes(mazes, filter)))
+	filter.MinDifficulty = 10
+	filter.MaxDifficulty = 40
+	assert.Equal(t, []string{"a", "c"}, ids(filterMazes(mazes, filter)))
+}
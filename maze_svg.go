@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// MAZE_SVG_CELL_SIZE is the default edge length of a cell, vector drawings scale without loss so it only sets the initial size
+const MAZE_SVG_CELL_SIZE = 10
+
+// DefaultMazeSvgOptions draws cells of MAZE_SVG_CELL_SIZE with the entrance and exit marked
+func DefaultMazeSvgOptions() MazeDrawOptions {
+	options := DefaultMazeDrawOptions()
+	options.CellSize = MAZE_SVG_CELL_SIZE
+	options.Markers = true
+	return options
+}
+
+// drawMazeSvg draws the maze with the same layout as drawMazePng. The maze is drawn in cell units inside a scaled group,
+// all walls form a single path of merged outlines and the path is a polyline through the centres of its cells.
+func drawMazeSvg(maze *Maze, path *PathItem, options MazeDrawOptions, w io.Writer) error {
+	layout, err := newMazeDrawLayout(maze, options)
+	if err != nil {
+		return err
+	}
+	theme := options.Theme
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="%d" height="%d">`,
+		layout.bounds.Dx(), layout.bounds.Dy(), layout.bounds.Dx(), layout.bounds.Dy())
+	fmt.Fprintf(out, `<rect width="%d" height="%d" fill="%s"/>`, layout.bounds.Dx(), layout.bounds.Dy(), svgColor(theme.Background))
+	fmt.Fprintf(out, `<g transform="translate(%d %d) scale(%d)">`, layout.origin.X, layout.origin.Y, options.CellSize)
+	fmt.Fprintf(out, `<rect width="%d" height="%d" fill="%s"/>`, maze.GridWidth, maze.GridHeight, svgColor(theme.Floor))
+
+	// The walls are drawn above the grid lines, so the lines only show between open cells
+	if options.GridLines {
+		fmt.Fprintf(out, `<path stroke="%s" stroke-width="1" vector-effect="non-scaling-stroke" d="`, svgColor(theme.Grid))
+		for x := uint16(1); x < maze.GridWidth; x++ {
+			fmt.Fprintf(out, "M%d 0V%d", x, maze.GridHeight)
+		}
+		for y := uint16(1); y < maze.GridHeight; y++ {
+			fmt.Fprintf(out, "M0 %dH%d", y, maze.GridWidth)
+		}
+		out.WriteString(`"/>`)
+	}
+
+	fmt.Fprintf(out, `<path fill="%s" d="`, svgColor(theme.Wall))
+	for _, outline := range wallOutlines(maze) {
+		writeSvgOutline(out, outline)
+	}
+	out.WriteString(`"/>`)
+
+	if path != nil {
+		fmt.Fprintf(out, `<polyline fill="none" stroke="%s" stroke-width="0.4" stroke-linecap="round" stroke-linejoin="round" points="`, svgColor(theme.Path))
+		for i, point := range pathCorners(path) {
+			if i > 0 {
+				out.WriteString(" ")
+			}
+			fmt.Fprintf(out, "%d.5,%d.5", point.x, point.y)
+		}
+		out.WriteString(`"/>`)
+	}
+
+	if options.Markers {
+		writeSvgMarker(out, maze.EntranceX, maze.EntranceY, theme.Entrance)
+		if path != nil {
+			writeSvgMarker(out, path.X, path.Y, theme.Exit)
+		} else if exitX, exitY, ok := findExit(maze); ok {
+			writeSvgMarker(out, exitX, exitY, theme.Exit)
+		}
+	}
+	out.WriteString(`</g>`)
+
+	if options.Labels {
+		fontSize := (MAZE_FONT_GLYPH_HEIGHT + 1) * layout.labelScale
+		fmt.Fprintf(out, `<g fill="%s" font-family="monospace" font-size="%d" text-anchor="middle" dominant-baseline="central">`, svgColor(theme.Label), fontSize)
+		for x := uint16(0); x < maze.GridWidth; x++ {
+			cell := layout.cell(x, 0)
+			fmt.Fprintf(out, `<text x="%s" y="%s">%s</text>`, svgHalf(cell.Min.X+cell.Max.X), svgHalf(layout.columnLabel.Min.Y+layout.columnLabel.Max.Y), columnLabelText(x))
+		}
+		for y := uint16(0); y < maze.GridHeight; y++ {
+			cell := layout.cell(0, y)
+			fmt.Fprintf(out, `<text x="%s" y="%s">%s</text>`, svgHalf(layout.rowLabel.Min.X+layout.rowLabel.Max.X), svgHalf(cell.Min.Y+cell.Max.Y), rowLabelText(y))
+		}
+		out.WriteString(`</g>`)
+	}
+
+	out.WriteString("</svg>\n")
+	return out.Flush()
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// svgHalf writes the half of the value without trailing zeros
+func svgHalf(value int) string {
+	return strconv.FormatFloat(float64(value)/2, 'f', -1, 64)
+}
+
+func writeSvgMarker(out *bufio.Writer, x uint16, y uint16, c color.RGBA) {
+	fmt.Fprintf(out, `<rect x="%d.15" y="%d.15" width="0.7" height="0.7" rx="0.15" fill="%s"/>`, x, y, svgColor(c))
+}
+
+// writeSvgOutline writes the corners of the closed outline with absolute horizontal and vertical lines
+func writeSvgOutline(out *bufio.Writer, outline []svgPoint) {
+	fmt.Fprintf(out, "M%d %d", outline[0].x, outline[0].y)
+	for i := 1; i < len(outline); i++ {
+		if outline[i].x != outline[i-1].x {
+			fmt.Fprintf(out, "H%d", outline[i].x)
+		} else {
+			fmt.Fprintf(out, "V%d", outline[i].y)
+		}
+	}
+	out.WriteString("Z")
+}
+
+type svgPoint struct {
+	x int
+	y int
+}
+
+type svgEdge struct {
+	from svgPoint
+	to   svgPoint
+}
+
+// wallOutlines merges adjacent wall cells into closed outlines and returns their corners. Every cell side between a wall
+// and an open cell or the border is an edge, which runs clockwise around the wall, so holes in a wall run counterclockwise
+// and stay open with the nonzero fill rule.
+func wallOutlines(maze *Maze) [][]svgPoint {
+	isWall := func(x int, y int) bool {
+		return x >= 0 && y >= 0 && x < int(maze.GridWidth) && y < int(maze.GridHeight) && maze.IsWall(uint16(x), uint16(y))
+	}
+	edges := []svgEdge{}
+	for y := 0; y < int(maze.GridHeight); y++ {
+		for x := 0; x < int(maze.GridWidth); x++ {
+			if !isWall(x, y) {
+				continue
+			}
+			if !isWall(x, y-1) {
+				edges = append(edges, svgEdge{svgPoint{x, y}, svgPoint{x + 1, y}})
+			}
+			if !isWall(x+1, y) {
+				edges = append(edges, svgEdge{svgPoint{x + 1, y}, svgPoint{x + 1, y + 1}})
+			}
+			if !isWall(x, y+1) {
+				edges = append(edges, svgEdge{svgPoint{x + 1, y + 1}, svgPoint{x, y + 1}})
+			}
+			if !isWall(x-1, y) {
+				edges = append(edges, svgEdge{svgPoint{x, y + 1}, svgPoint{x, y}})
+			}
+		}
+	}
+
+	// Two walls that only touch at a corner give that corner two outgoing edges, any choice closes the outlines
+	outgoing := map[svgPoint][]int{}
+	for i, edge := range edges {
+		outgoing[edge.from] = append(outgoing[edge.from], i)
+	}
+	used := make([]bool, len(edges))
+	outlines := [][]svgPoint{}
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		points := []svgPoint{edges[start].from}
+		current := start
+		for {
+			used[current] = true
+			next := -1
+			for _, candidate := range outgoing[edges[current].to] {
+				if !used[candidate] {
+					next = candidate
+					break
+				}
+			}
+			if next == -1 {
+				break
+			}
+			points = append(points, edges[next].from)
+			current = next
+		}
+		outlines = append(outlines, corners(points))
+	}
+	sort.SliceStable(outlines, func(i, j int) bool {
+		return outlines[i][0].y < outlines[j][0].y || (outlines[i][0].y == outlines[j][0].y && outlines[i][0].x < outlines[j][0].x)
+	})
+	return outlines
+}
+
+// corners drops the points of a closed outline that lie on a straight line between their neighbours
+func corners(points []svgPoint) []svgPoint {
+	result := []svgPoint{}
+	for i, point := range points {
+		previous := points[(i+len(points)-1)%len(points)]
+		next := points[(i+1)%len(points)]
+		if (previous.x == point.x && point.x == next.x) || (previous.y == point.y && point.y == next.y) {
+			continue
+		}
+		result = append(result, point)
+	}
+	return result
+}
+
+// pathCorners returns the cells of the path from the entrance to the exit where the direction changes
+func pathCorners(path *PathItem) []svgPoint {
+	// The path is linked from the exit back to the entrance
+	points := []svgPoint{}
+	for item := path; item != nil; item = item.Prev {
+		points = append(points, svgPoint{int(item.X), int(item.Y)})
+	}
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	if len(points) < 3 {
+		return points
+	}
+	result := []svgPoint{points[0]}
+	for i := 1; i < len(points)-1; i++ {
+		previous, point, next := points[i-1], points[i], points[i+1]
+		if (previous.x == point.x && point.x == next.x) || (previous.y == point.y && point.y == next.y) {
+			continue
+		}
+		result = append(result, point)
+	}
+	return append(result, points[len(points)-1])
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_wallOutlines(t *testing.T) {
+	tests := []struct {
+		name     string
+		maze     *Maze
+		expected [][]svgPoint
+	}{
+		{
+			name:     "merged row",
+			maze:     newTestMaze("A2", "###", "..."),
+			expected: [][]svgPoint{{{0, 0}, {3, 0}, {3, 1}, {0, 1}}},
+		},
+		{
+			name: "ring with hole",
+			maze: newTestMaze("B2", "###", "#.#", "###"),
+			expected: [][]svgPoint{
+				{{0, 0}, {3, 0}, {3, 3}, {0, 3}},
+				{{2, 1}, {1, 1}, {1, 2}, {2, 2}},
+			},
+		},
+		{
+			name: "corner",
+			maze: newTestMaze("B1", "#.", "##"),
+			expected: [][]svgPoint{
+				{{0, 0}, {1, 0}, {1, 1}, {2, 1}, {2, 2}, {0, 2}},
+			},
+		},
+		{
+			name:     "no walls",
+			maze:     newTestMaze("A1", "..", ".."),
+			expected: [][]svgPoint{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, wallOutlines(tt.maze))
+		})
+	}
+}
+
+func Test_wallOutlines_TouchingCorners(t *testing.T) {
+	// Walls that touch diagonally still give closed outlines covering both cells
+	outlines := wallOutlines(newTestMaze("B1", "#.", ".#"))
+	edges := 0
+	for _, outline := range outlines {
+		edges += len(outline)
+	}
+	assert.Equal(t, 8, edges)
+}
+
+func Test_pathCorners(t *testing.T) {
+	path, err := stringsToPath([]string{"B1", "B2", "B3", "C3", "D3", "D4"})
+	assert.Nil(t, err)
+	assert.Equal(t, []svgPoint{{1, 0}, {1, 2}, {3, 2}, {3, 3}}, pathCorners(path))
+
+	path, err = stringsToPath([]string{"B1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []svgPoint{{1, 0}}, pathCorners(path))
+}
+
+func Test_drawMazeSvg(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"###.#",
+	)
+	path, err := stringsToPath([]string{"B1", "B2", "C2", "D2", "D3"})
+	assert.Nil(t, err)
+	options := DefaultMazeSvgOptions()
+	options.Margin = 4
+	options.CellSize = 12
+	options.GridLines = true
+	options.Labels = true
+	options.Theme = MAZE_THEMES["pastel"]
+
+	var buffer bytes.Buffer
+	assert.Nil(t, drawMazeSvg(maze, path, options, &buffer))
+	svg := buffer.String()
+
+	// 2 columns of labels and the margin on both sides
+	assert.True(t, strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 78 58" width="78" height="58">`), svg)
+	assert.Contains(t, svg, `<g transform="translate(14 18) scale(12)">`)
+	assert.Contains(t, svg, `<path fill="#5d5c7a" d="M0 0H1V2H3V3H0ZM2 0H5V3H4V1H2Z"/>`)
+	assert.Contains(t, svg, `points="1.5,0.5 1.5,1.5 3.5,1.5 3.5,2.5"`)
+	assert.Contains(t, svg, `fill="#81c784"`)
+	assert.Contains(t, svg, `<text x="20" y="11">A</text>`)
+	assert.Contains(t, svg, `<text x="9" y="24">1</text>`)
+	assert.Contains(t, svg, `vector-effect="non-scaling-stroke"`)
+
+	// The drawing is well formed XML
+	decoder := xml.NewDecoder(&buffer)
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		if err != nil {
+			break
+		}
+	}
+}
+
+func Test_drawMazeSvg_InvalidOptions(t *testing.T) {
+	options := DefaultMazeSvgOptions()
+	options.Labels = true
+	options.CellSize = 4
+	err := drawMazeSvg(newTestMaze("B1", "#.#"), nil, options, &bytes.Buffer{})
+	assert.Equal(t, "labels", err.(*CodedError).Details[0].Field)
+}
@@ -77,6 +77,18 @@ func TestMaze_GetByteAddress(t *testing.T) {
 			},
 			want: 31,
 		},
+		{
+			name: "More cells than uint16",
+			fields: fields{
+				GridWidth:  500,
+				GridHeight: 500,
+			},
+			args: args{
+				x: 499,
+				y: 499,
+			},
+			want: 31249,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -117,6 +129,22 @@ func TestMaze_InitWalls(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "500x500",
+			args: args{
+				x: 500,
+				y: 500,
+			},
+			verify: func(maze *Maze) {
+				if len(maze.Walls) != 31251 {
+					t.Errorf("len(Maze.Walls) = %v, want %v", len(maze.Walls), 31251)
+				}
+				maze.SetWall(499, 499, true)
+				if !maze.IsWall(499, 499) || maze.IsWall(499, 498) {
+					t.Errorf("Maze.SetWall(499, 499) did not set exactly the last cell")
+				}
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -259,6 +287,15 @@ func Test_readPosition(t *testing.T) {
 			want1:   0,
 			wantErr: true,
 		},
+		{
+			name: "row zero",
+			args: args{
+				position: "A0",
+			},
+			want:    0,
+			want1:   0,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	MAZE_ISSUE_SEVERITY_ERROR   = "error"
+	MAZE_ISSUE_SEVERITY_WARNING = "warning"
+
+	// Mazes with loops have exponentially many paths, counting them stops after these steps or this time
+	MAZE_VALIDATION_MAX_PATH_STEPS = 1000000
+	MAZE_VALIDATION_MAX_PATH_TIME  = 2 * time.Second
+)
+
+// MazeIssue is a single finding of the validation. Errors prevent storing the maze, warnings do not.
+type MazeIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	Field    string `json:"field,omitempty"`
+	Position string `json:"position,omitempty"`
+}
+
+type MazeRegion struct {
+	Size  int      `json:"size"`
+	Cells []string `json:"cells"`
+}
+
+type MazeExitReport struct {
+	Exit           string `json:"exit"`
+	Solutions      int    `json:"solutions"`
+	ShortestLength uint64 `json:"shortestLength"`
+	LongestLength  uint64 `json:"longestLength"`
+}
+
+type MazeValidationReport struct {
+	Valid         bool             `json:"valid"`
+	Perfect       bool             `json:"perfect"`
+	Issues        []MazeIssue      `json:"issues"`
+	Exits         []MazeExitReport `json:"exits"`
+	SolutionCount int              `json:"solutionCount"`
+	// SolutionCountCapped tells that counting the paths stopped early, the counts are lower bounds then
+	SolutionCountCapped bool         `json:"solutionCountCapped"`
+	Loops               int          `json:"loops"`
+	UnreachableRegions  []MazeRegion `json:"unreachableRegions"`
+}
+
+func newMazeValidationReport() *MazeValidationReport {
+	return &MazeValidationReport{
+		Issues:             []MazeIssue{},
+		Exits:              []MazeExitReport{},
+		UnreachableRegions: []MazeRegion{},
+	}
+}
+
+func (r *MazeValidationReport) addError(code string, message string, field string, position string) {
+	r.Issues = append(r.Issues, MazeIssue{Severity: MAZE_ISSUE_SEVERITY_ERROR, Code: code, Message: message, Field: field, Position: position})
+}
+
+func (r *MazeValidationReport) addWarning(code string, message string, position string) {
+	r.Issues = append(r.Issues, MazeIssue{Severity: MAZE_ISSUE_SEVERITY_WARNING, Code: code, Message: message, Position: position})
+}
+
+// addCodedError adds the error as issue, keeping the field of coded errors
+func (r *MazeValidationReport) addCodedError(err error, field string, position string) {
+	coded := toCodedError(err)
+	r.addError(coded.Code, coded.Message, field, position)
+}
+
+// codedErrorField returns the field the error names, or the fallback for errors without field
+func codedErrorField(err error, fallback string) string {
+	details := toCodedError(err).Details
+	if len(details) == 0 {
+		return fallback
+	}
+	return details[0].Field
+}
+
+func (r *MazeValidationReport) hasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == MAZE_ISSUE_SEVERITY_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// finish derives the summary flags once all checks ran
+func (r *MazeValidationReport) finish() {
+	r.Valid = !r.hasErrors()
+	r.Perfect = r.Valid && r.Loops == 0 && r.SolutionCount == 1 && len(r.UnreachableRegions) == 0
+}
+
+// validateMazeApiDao reads the maze like fromMazeApiDao, but collects every problem instead of stopping
+// at the first one. The maze is nil if the grid size can not be read, a grid size beyond the quota is an error.
+func validateMazeApiDao(mazeDao *MazeApiDao, report *MazeValidationReport, checkGridSize func(width uint16, height uint16) error) (*Maze, error) {
+	entranceX, entranceY, entranceErr := readPosition(mazeDao.Entrace)
+	if entranceErr != nil {
+		report.addCodedError(entranceErr, "entrance", mazeDao.Entrace)
+	}
+	width, height, err := readGridSize(mazeDao.GridSize)
+	if err != nil {
+		report.addCodedError(err, "gridSize", "")
+	} else if quotaErr := checkGridSize(width, height); quotaErr != nil {
+		return nil, quotaErr
+	}
+
+	var maze *Maze
+	if err == nil && entranceErr == nil {
+		maze = &Maze{
+			EntranceX: entranceX,
+			EntranceY: entranceY,
+		}
+		maze.InitWalls(width, height)
+	}
+	if orDefault(mazeDao.WallsEncoding, WALLS_ENCODING_LIST) != WALLS_ENCODING_LIST {
+		if maze == nil {
+			return nil, nil
+		}
+		// Encoded walls are either complete or unusable, so they are checked as a whole
+		err = applyMazeApiDaoWalls(maze, mazeDao)
+		if err != nil {
+			report.addCodedError(err, codedErrorField(err, "wallsData"), "")
+			return nil, nil
+		}
+		return maze, nil
+	}
+	for i, wall := range mazeDao.wallList() {
+		field := "walls[" + strconv.Itoa(i) + "]"
+		x, y, err := readPosition(wall)
+		if err != nil {
+			report.addCodedError(err, field, wall)
+			continue
+		}
+		if maze == nil {
+			continue
+		}
+		if x >= maze.GridWidth || y >= maze.GridHeight {
+			report.addError(ERROR_CODE_MAZE_WALL_OUT_OF_RANGE, fmt.Sprintf("the wall %s is outside of the %dx%d grid", wall, maze.GridWidth, maze.GridHeight), field, wall)
+			continue
+		}
+		maze.SetWall(x, y, true)
+	}
+	return maze, nil
+}
+
+// validateMazeStructure checks everything mazeControllerImpl.validateMaze checks and reports all findings at once.
+func validateMazeStructure(maze *Maze, report *MazeValidationReport) error {
+	if maze.GridWidth == 0 || maze.GridHeight == 0 {
+		report.addError(ERROR_CODE_MAZE_INVALID_SIZE, ErrMazeInvalidSize.Message, "gridSize", "")
+		return nil
+	}
+	entrance := toApiAddress(maze.EntranceX, maze.EntranceY)
+	if maze.EntranceX >= maze.GridWidth || maze.EntranceY >= maze.GridHeight {
+		report.addError(ERROR_CODE_MAZE_INVALID_ENTRANCE, "the entrance is outside of the grid", "entrance", entrance)
+		return nil
+	}
+	if maze.EntranceX != 0 && maze.EntranceY != 0 && maze.EntranceX != maze.GridWidth-1 && maze.EntranceY != maze.GridHeight-1 {
+		report.addError(ERROR_CODE_MAZE_INVALID_ENTRANCE, "the entrance must be on the border of the grid", "entrance", entrance)
+	}
+	if maze.IsWall(maze.EntranceX, maze.EntranceY) {
+		report.addError(ERROR_CODE_MAZE_ENTRANCE_IS_WALL, "the entrance is a wall", "entrance", entrance)
+		return nil
+	}
+
+	reachable, loops := exploreFromEntrance(maze)
+	report.Loops = loops
+	report.UnreachableRegions = findUnreachableRegions(maze, reachable)
+	for _, region := range report.UnreachableRegions {
+		report.addWarning(ERROR_CODE_MAZE_UNREACHABLE_REGION, fmt.Sprintf("%d open cells can not be reached from the entrance", region.Size), region.Cells[0])
+	}
+
+	exits, complete := countMazeSolutions(maze, MAZE_VALIDATION_MAX_PATH_STEPS, time.Now().Add(MAZE_VALIDATION_MAX_PATH_TIME))
+	if !complete {
+		exits = addMissingExits(maze, reachable, exits)
+		report.SolutionCountCapped = true
+	}
+	report.Exits = exits
+	for _, exit := range exits {
+		report.SolutionCount += exit.Solutions
+	}
+	if len(exits) == 0 {
+		report.addError(ERROR_CODE_MAZE_NO_SOLUTION, "no path leads from the entrance to the bottom edge", "", "")
+		return nil
+	}
+	if len(report.Exits) > 1 {
+		for _, exit := range report.Exits {
+			report.addError(ERROR_CODE_MAZE_MULTIPLE_EXITS, "exit found at "+exit.Exit, "", exit.Exit)
+		}
+		return nil
+	}
+	if report.SolutionCountCapped {
+		report.addWarning(ERROR_CODE_MAZE_MULTIPLE_SOLUTIONS, fmt.Sprintf("at least %d different paths lead to the exit, counting stopped", report.SolutionCount), report.Exits[0].Exit)
+	} else if report.SolutionCount > 1 {
+		report.addWarning(ERROR_CODE_MAZE_MULTIPLE_SOLUTIONS, fmt.Sprintf("%d different paths lead to the exit", report.SolutionCount), report.Exits[0].Exit)
+	}
+	return nil
+}
+
+// exploreFromEntrance marks all open cells reachable from the entrance and counts the independent loops
+// between them, which is the number of passages minus the number of cells plus one.
+func exploreFromEntrance(maze *Maze) ([]bool, int) {
+	width := int(maze.GridWidth)
+	reachable := make([]bool, width*int(maze.GridHeight))
+	start := int(maze.EntranceY)*width + int(maze.EntranceX)
+	reachable[start] = true
+	queue := []int{start}
+	cells := 0
+	passages := 0
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		cells++
+		for _, neighbor := range openNeighbors(maze, cell) {
+			// Every passage is seen from both of its cells
+			passages++
+			if !reachable[neighbor] {
+				reachable[neighbor] = true
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return reachable, passages/2 - cells + 1
+}
+
+func findUnreachableRegions(maze *Maze, reachable []bool) []MazeRegion {
+	width := int(maze.GridWidth)
+	visited := append([]bool{}, reachable...)
+	regions := []MazeRegion{}
+	for start := range visited {
+		if visited[start] || maze.IsWall(uint16(start%width), uint16(start/width)) {
+			continue
+		}
+		var region []int
+		visited[start] = true
+		queue := []int{start}
+		for len(queue) > 0 {
+			cell := queue[0]
+			queue = queue[1:]
+			region = append(region, cell)
+			for _, neighbor := range openNeighbors(maze, cell) {
+				if !visited[neighbor] {
+					visited[neighbor] = true
+					queue = append(queue, neighbor)
+				}
+			}
+		}
+		sort.Ints(region)
+		cells := make([]string, len(region))
+		for i, cell := range region {
+			cells[i] = toApiAddress(uint16(cell%width), uint16(cell/width))
+		}
+		regions = append(regions, MazeRegion{Size: len(cells), Cells: cells})
+	}
+	return regions
+}
+
+// openNeighbors returns the cell indexes of the open cells next to the cell
+func openNeighbors(maze *Maze, cell int) []int {
+	width := int(maze.GridWidth)
+	height := int(maze.GridHeight)
+	x := cell % width
+	y := cell / width
+	neighbors := make([]int, 0, 4)
+	if x+1 < width && !maze.IsWall(uint16(x+1), uint16(y)) {
+		neighbors = append(neighbors, cell+1)
+	}
+	if y+1 < height && !maze.IsWall(uint16(x), uint16(y+1)) {
+		neighbors = append(neighbors, cell+width)
+	}
+	if x > 0 && !maze.IsWall(uint16(x-1), uint16(y)) {
+		neighbors = append(neighbors, cell-1)
+	}
+	if y > 0 && !maze.IsWall(uint16(x), uint16(y-1)) {
+		neighbors = append(neighbors, cell-width)
+	}
+	return neighbors
+}
+
+// countMazeSolutions follows the same paths as the solver, a path ends at the first cell of the bottom edge. Only the
+// exits and the lengths are kept. After maxSteps steps or at the deadline it stops and complete is false.
+func countMazeSolutions(maze *Maze, maxSteps int, deadline time.Time) (exits []MazeExitReport, complete bool) {
+	width := int(maze.GridWidth)
+	bottom := int(maze.GridHeight) - 1
+	exits = []MazeExitReport{}
+	index := map[int]int{}
+	onPath := make([]bool, width*int(maze.GridHeight))
+	path := []int{}
+	next := []int{}
+	enter := func(cell int) {
+		length := uint64(len(path) + 1)
+		if cell/width != bottom {
+			onPath[cell] = true
+			path = append(path, cell)
+			next = append(next, 0)
+			return
+		}
+		i, ok := index[cell]
+		if !ok {
+			i = len(exits)
+			index[cell] = i
+			exits = append(exits, MazeExitReport{
+				Exit:           toApiAddress(uint16(cell%width), uint16(cell/width)),
+				ShortestLength: length,
+				LongestLength:  length,
+			})
+		}
+		exit := &exits[i]
+		exit.Solutions++
+		if length < exit.ShortestLength {
+			exit.ShortestLength = length
+		}
+		if length > exit.LongestLength {
+			exit.LongestLength = length
+		}
+	}
+
+	enter(int(maze.EntranceY)*width + int(maze.EntranceX))
+	for steps := 0; len(path) > 0; steps++ {
+		if steps >= maxSteps || (steps%1024 == 0 && time.Now().After(deadline)) {
+			return exits, false
+		}
+		top := len(path) - 1
+		neighbors := openNeighbors(maze, path[top])
+		if next[top] == len(neighbors) {
+			onPath[path[top]] = false
+			path = path[:top]
+			next = next[:top]
+			continue
+		}
+		neighbor := neighbors[next[top]]
+		next[top]++
+		if !onPath[neighbor] {
+			enter(neighbor)
+		}
+	}
+	return exits, true
+}
+
+// addMissingExits adds the reachable cells of the bottom edge that counting did not get to, with their shortest length
+func addMissingExits(maze *Maze, reachable []bool, exits []MazeExitReport) []MazeExitReport {
+	found := map[string]bool{}
+	for _, exit := range exits {
+		found[exit.Exit] = true
+	}
+	distances := mazeDistances(maze)
+	y := maze.GridHeight - 1
+	for x := uint16(0); x < maze.GridWidth; x++ {
+		address := toApiAddress(x, y)
+		if !reachable[int(y)*int(maze.GridWidth)+int(x)] || found[address] {
+			continue
+		}
+		length := uint64(distances.at(x, y) + 1)
+		exits = append(exits, MazeExitReport{Exit: address, ShortestLength: length, LongestLength: length})
+	}
+	return exits
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestMaze builds a maze from rows where '#' is a wall and every other character is open
+func newTestMaze(entrance string, rows ...string) *Maze {
+	x, y, err := readPosition(entrance)
+	if err != nil {
+		panic(err)
+	}
+	maze := &Maze{EntranceX: x, EntranceY: y}
+	maze.InitWalls(uint16(len(rows[0])), uint16(len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			maze.SetWall(uint16(x), uint16(y), c == '#')
+		}
+	}
+	return maze
+}
+
+func issueCodes(report *MazeValidationReport) []string {
+	codes := []string{}
+	for _, issue := range report.Issues {
+		codes = append(codes, issue.Code)
+	}
+	return codes
+}
+
+func Test_mazeControllerImpl_ValidateMaze(t *testing.T) {
+	m := &mazeControllerImpl{
+		mazeSolver: NewMazeSolver(),
+	}
+
+	t.Run("tree", func(t *testing.T) {
+		report, err := m.ValidateMaze(newTestMaze("B1",
+			"#.###",
+			"#...#",
+			"###.#",
+		))
+		assert.Nil(t, err)
+		assert.True(t, report.Valid)
+		assert.True(t, report.Perfect)
+		assert.Equal(t, 0, report.Loops)
+		assert.Equal(t, []MazeExitReport{{Exit: "D3", Solutions: 1, ShortestLength: 5, LongestLength: 5}}, report.Exits)
+		assert.Empty(t, report.Issues)
+	})
+
+	t.Run("loop and unreachable region", func(t *testing.T) {
+		report, err := m.ValidateMaze(newTestMaze("B1",
+			"#.###",
+			"#..#.",
+			"#..##",
+			"##.##",
+		))
+		assert.Nil(t, err)
+		assert.True(t, report.Valid)
+		assert.False(t, report.Perfect)
+		assert.Equal(t, 1, report.Loops)
+		assert.Equal(t, 2, report.SolutionCount)
+		assert.Equal(t, []MazeRegion{{Size: 1, Cells: []string{"E2"}}}, report.UnreachableRegions)
+		assert.Equal(t, []string{ERROR_CODE_MAZE_UNREACHABLE_REGION, ERROR_CODE_MAZE_MULTIPLE_SOLUTIONS}, issueCodes(report))
+	})
+
+	t.Run("every exit and a bad entrance", func(t *testing.T) {
+		report, err := m.ValidateMaze(newTestMaze("B2",
+			"###",
+			"...",
+			"...",
+		))
+		assert.Nil(t, err)
+		assert.False(t, report.Valid)
+		assert.Equal(t, []string{
+			ERROR_CODE_MAZE_INVALID_ENTRANCE,
+			ERROR_CODE_MAZE_MULTIPLE_EXITS,
+			ERROR_CODE_MAZE_MULTIPLE_EXITS,
+			ERROR_CODE_MAZE_MULTIPLE_EXITS,
+		}, issueCodes(report))
+		exits := []string{}
+		for _, exit := range report.Exits {
+			exits = append(exits, exit.Exit)
+		}
+		assert.ElementsMatch(t, []string{"A3", "B3", "C3"}, exits)
+	})
+
+	t.Run("no solution", func(t *testing.T) {
+		report, err := m.ValidateMaze(newTestMaze("B1",
+			"#.#",
+			"###",
+			"#.#",
+		))
+		assert.Nil(t, err)
+		assert.False(t, report.Valid)
+		assert.Equal(t, []string{ERROR_CODE_MAZE_UNREACHABLE_REGION, ERROR_CODE_MAZE_NO_SOLUTION}, issueCodes(report))
+	})
+}
+
+func Test_mazeControllerImpl_ValidateMaze_Loops(t *testing.T) {
+	m := &mazeControllerImpl{
+		mazeSolver: NewMazeSolver(),
+	}
+	// An open room has more paths than can be counted
+	newRoom := func(exits uint16) *Maze {
+		maze := &Maze{EntranceX: 1, EntranceY: 0}
+		maze.InitWalls(20, 20)
+		for x := uint16(0); x < 20; x++ {
+			maze.SetWall(x, 0, x != 1)
+			maze.SetWall(x, 19, x >= exits)
+		}
+		return maze
+	}
+
+	t.Run("one exit", func(t *testing.T) {
+		start := time.Now()
+		report, err := m.ValidateMaze(newRoom(1))
+		assert.Nil(t, err)
+		assert.Less(t, time.Since(start), MAZE_VALIDATION_MAX_PATH_TIME+time.Second)
+		assert.True(t, report.Valid)
+		assert.True(t, report.SolutionCountCapped)
+		assert.Positive(t, report.Loops)
+		assert.Len(t, report.Exits, 1)
+		assert.Equal(t, "A20", report.Exits[0].Exit)
+		assert.Equal(t, []string{ERROR_CODE_MAZE_MULTIPLE_SOLUTIONS}, issueCodes(report))
+	})
+
+	t.Run("every reachable exit is reported", func(t *testing.T) {
+		report, err := m.ValidateMaze(newRoom(20))
+		assert.Nil(t, err)
+		assert.False(t, report.Valid)
+		assert.Len(t, report.Exits, 20)
+		for _, exit := range report.Exits {
+			assert.GreaterOrEqual(t, exit.ShortestLength, uint64(20), exit.Exit)
+		}
+	})
+}
+
+func Test_countMazeSolutions(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.###",
+		"#...#",
+		"#.#.#",
+		"#...#",
+		"##.##",
+	)
+	exits, complete := countMazeSolutions(maze, 1000, time.Now().Add(time.Minute))
+	assert.True(t, complete)
+	assert.Equal(t, []MazeExitReport{{Exit: "C5", Solutions: 2, ShortestLength: 6, LongestLength: 8}}, exits)
+
+	_, complete = countMazeSolutions(maze, 3, time.Now().Add(time.Minute))
+	assert.False(t, complete)
+}
+
+func Test_codedErrorField(t *testing.T) {
+	assert.Equal(t, "walls", codedErrorField(newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "bad").withField("walls"), "wallsData"))
+	assert.Equal(t, "wallsData", codedErrorField(newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "bad"), "wallsData"))
+	assert.Equal(t, "wallsData", codedErrorField(errors.New("bad"), "wallsData"))
+}
+
+func Test_mazeApiImpl_ValidateMaze(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "bbaaaaab").Return("abc", nil)
+	m := &mazeApiImpl{
+		mazeController:  &mazeControllerImpl{mazeSolver: NewMazeSolver()},
+		userController:  userController,
+		quotaController: newUnlimitedQuotaControllerMock(),
+	}
+
+	req := httptest.NewRequest("POST", "/maze/validate", serializeMazeApiDao(&MazeApiDao{
+		Entrace:  "B1",
+		GridSize: "3x3",
+		Walls:    &[]string{"A1", "C1", "Z9", "A2", "C2", "1A", "A3", "D1", "C3"},
+	}))
+	req.Header.Add("Authorization", "Bearer bbaaaaab")
+	w := httptest.NewRecorder()
+	m.ValidateMaze(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report MazeValidationReport
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&report))
+	assert.False(t, report.Valid)
+	assert.Equal(t, []MazeIssue{
+		{Severity: MAZE_ISSUE_SEVERITY_ERROR, Code: ERROR_CODE_MAZE_WALL_OUT_OF_RANGE, Message: "the wall Z9 is outside of the 3x3 grid", Field: "walls[2]", Position: "Z9"},
+		{Severity: MAZE_ISSUE_SEVERITY_ERROR, Code: ERROR_CODE_MAZE_INVALID_POSITION, Message: "Invalid position '1A'", Field: "walls[5]", Position: "1A"},
+		{Severity: MAZE_ISSUE_SEVERITY_ERROR, Code: ERROR_CODE_MAZE_WALL_OUT_OF_RANGE, Message: "the wall D1 is outside of the 3x3 grid", Field: "walls[7]", Position: "D1"},
+	}, report.Issues)
+	// The valid walls are still checked
+	assert.Equal(t, 1, report.SolutionCount)
+	assert.Equal(t, "B3", report.Exits[0].Exit)
+}
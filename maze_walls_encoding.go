@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// The walls of a MazeApiDao are either listed as positions in Walls, or sent as bitset in WallsData.
+// The bitset has one bit per cell in row order, least significant bit first, a set bit is a wall.
+const (
+	WALLS_ENCODING_LIST           = "list"
+	WALLS_ENCODING_BITSET         = "bitset-base64"
+	WALLS_ENCODING_BITSET_DEFLATE = "bitset-deflate-base64"
+)
+
+var WALLS_ENCODINGS = []string{WALLS_ENCODING_LIST, WALLS_ENCODING_BITSET, WALLS_ENCODING_BITSET_DEFLATE}
+
+func isValidWallsEncoding(encoding string) bool {
+	for _, e := range WALLS_ENCODINGS {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// readWallsEncoding reads the encoding of the walls in responses from the wallsEncoding query parameter
+func readWallsEncoding(r *http.Request) (string, error) {
+	encoding := orDefault(r.URL.Query().Get("wallsEncoding"), WALLS_ENCODING_LIST)
+	if !isValidWallsEncoding(encoding) {
+		return "", newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_ENCODING, fmt.Sprintf("the walls encoding must be one of %v", WALLS_ENCODINGS)).withField("wallsEncoding")
+	}
+	return encoding, nil
+}
+
+func bitsetSize(maze *Maze) int {
+	return (int(maze.GridWidth)*int(maze.GridHeight) + 7) / 8
+}
+
+// encodeWalls returns the walls of the maze as base64 bitset, optionally deflate compressed
+func encodeWalls(maze *Maze, encoding string) (string, error) {
+	// The walls of the maze are stored in the same layout, with up to one byte of padding
+	bitset := maze.Walls[:bitsetSize(maze)]
+	if encoding == WALLS_ENCODING_BITSET_DEFLATE {
+		var compressed bytes.Buffer
+		writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+		if err != nil {
+			return "", err
+		}
+		_, err = writer.Write(bitset)
+		if err != nil {
+			return "", err
+		}
+		err = writer.Close()
+		if err != nil {
+			return "", err
+		}
+		bitset = compressed.Bytes()
+	}
+	return base64.StdEncoding.EncodeToString(bitset), nil
+}
+
+// decodeWalls sets the walls of the maze from the encoded bitset. The bitset must have exactly the size
+// of the grid and unused bits of the last byte must not be set.
+func decodeWalls(maze *Maze, encoding string, data string) error {
+	bitset, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "the walls data is not valid base64: "+err.Error()).withField("wallsData")
+	}
+	size := bitsetSize(maze)
+	if encoding == WALLS_ENCODING_BITSET_DEFLATE {
+		// Read one byte more than needed to detect oversized data without inflating all of it
+		reader := flate.NewReader(bytes.NewReader(bitset))
+		bitset, err = io.ReadAll(io.LimitReader(reader, int64(size)+1))
+		if err != nil {
+			return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "the walls data can not be inflated: "+err.Error()).withField("wallsData")
+		}
+	}
+	if len(bitset) != size {
+		return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, fmt.Sprintf("the walls data of a %dx%d grid must have %d bytes, found %d", maze.GridWidth, maze.GridHeight, size, len(bitset))).withField("wallsData")
+	}
+	cells := int(maze.GridWidth) * int(maze.GridHeight)
+	if cells%8 != 0 && bitset[size-1]>>(cells%8) != 0 {
+		return newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, "the walls data has bits set after the last cell").withField("wallsData")
+	}
+	copy(maze.Walls, bitset)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/base64"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_encodeWalls(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	maze := &Maze{}
+	maze.InitWalls(500, 499)
+	for y := uint16(0); y < maze.GridHeight; y++ {
+		for x := uint16(0); x < maze.GridWidth; x++ {
+			maze.SetWall(x, y, random.Intn(3) == 0)
+		}
+	}
+
+	for _, encoding := range []string{WALLS_ENCODING_BITSET, WALLS_ENCODING_BITSET_DEFLATE} {
+		t.Run(encoding, func(t *testing.T) {
+			data, err := encodeWalls(maze, encoding)
+			assert.Nil(t, err)
+
+			decoded := &Maze{}
+			decoded.InitWalls(500, 499)
+			assert.Nil(t, decodeWalls(decoded, encoding, data))
+			assert.Equal(t, maze.Walls, decoded.Walls)
+		})
+	}
+}
+
+func Test_encodeWalls_Layout(t *testing.T) {
+	maze := newTestMaze("B1",
+		"#.#",
+		"#..",
+		"#.#",
+	)
+	data, err := encodeWalls(maze, WALLS_ENCODING_BITSET)
+	assert.Nil(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0b01001101, 0b00000001}), data)
+}
+
+func Test_decodeWalls_Errors(t *testing.T) {
+	deflated := func(bitset []byte) string {
+		maze := &Maze{GridWidth: uint16(len(bitset) * 8), GridHeight: 1, Walls: bitset}
+		data, err := encodeWalls(maze, WALLS_ENCODING_BITSET_DEFLATE)
+		assert.Nil(t, err)
+		return data
+	}
+	tests := []struct {
+		name     string
+		encoding string
+		data     string
+		message  string
+	}{
+		{"not base64", WALLS_ENCODING_BITSET, "!!", "the walls data is not valid base64: illegal base64 data at input byte 0"},
+		{"too short", WALLS_ENCODING_BITSET, base64.StdEncoding.EncodeToString([]byte{0}), "the walls data of a 3x3 grid must have 2 bytes, found 1"},
+		{"too long", WALLS_ENCODING_BITSET, base64.StdEncoding.EncodeToString([]byte{0, 0, 0}), "the walls data of a 3x3 grid must have 2 bytes, found 3"},
+		{"padding bits", WALLS_ENCODING_BITSET, base64.StdEncoding.EncodeToString([]byte{0, 2}), "the walls data has bits set after the last cell"},
+		{"not deflated", WALLS_ENCODING_BITSET_DEFLATE, base64.StdEncoding.EncodeToString([]byte{0xff, 0xff}), "the walls data can not be inflated: flate: corrupt input before offset 1"},
+		{"deflated too long", WALLS_ENCODING_BITSET_DEFLATE, deflated(make([]byte, 1000)), "the walls data of a 3x3 grid must have 2 bytes, found 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maze := &Maze{}
+			maze.InitWalls(3, 3)
+			err := decodeWalls(maze, tt.encoding, tt.data)
+			assert.ErrorIs(t, err, newCodedError(ERROR_CODE_MAZE_INVALID_WALLS_DATA, ""))
+			assert.Equal(t, tt.message, err.Error())
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// migrate runs statements that alter existing tables, statements that were already applied are skipped.
+func migrate(db *sql.DB, statements []string) error {
+	for _, statement := range statements {
+		_, err := db.Exec(statement)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_migrate(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	assert.Nil(t, err)
+
+	// A users table as it was created before roles existed
+	_, err = db.Exec("CREATE TABLE users (username VARCHAR(256) NOT NULL PRIMARY KEY, password_hash VARCHAR(256))")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO users (username, password_hash) VALUES ('abc', 'hash')")
+	assert.Nil(t, err)
+
+	// Running the migrations twice must not fail
+	assert.Nil(t, migrate(db, USER_REPO_MIGRATIONS))
+	assert.Nil(t, migrate(db, USER_REPO_MIGRATIONS))
+
+	u := &userRepositoryImpl{db: db}
+	got, err := u.SelectAccount("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, &UserAccount{Username: "abc", Role: ROLE_USER}, got)
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const OPENAPI_VERSION = "3.0.3"
+
+type OpenApiDocument struct {
+	OpenApi    string                     `json:"openapi"`
+	Info       OpenApiInfo                `json:"info"`
+	Servers    []OpenApiServer            `json:"servers"`
+	Paths      map[string]OpenApiPathItem `json:"paths"`
+	Components OpenApiComponents          `json:"components"`
+}
+
+type OpenApiInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenApiServer struct {
+	Url string `json:"url"`
+}
+
+// OpenApiPathItem maps the lower case HTTP method to the operation
+type OpenApiPathItem map[string]*OpenApiOperation
+
+type OpenApiOperation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary"`
+	OperationId string                     `json:"operationId"`
+	Security    []map[string][]string      `json:"security"`
+	Parameters  []OpenApiParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenApiRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenApiResponse `json:"responses"`
+}
+
+type OpenApiParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *OpenApiSchema `json:"schema"`
+}
+
+type OpenApiRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenApiMediaType `json:"content"`
+}
+
+type OpenApiResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenApiMediaType `json:"content,omitempty"`
+}
+
+type OpenApiMediaType struct {
+	Schema *OpenApiSchema `json:"schema"`
+}
+
+type OpenApiSchema struct {
+	Ref         string                    `json:"$ref,omitempty"`
+	Type        string                    `json:"type,omitempty"`
+	Format      string                    `json:"format,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Enum        []string                  `json:"enum,omitempty"`
+	Default     interface{}               `json:"default,omitempty"`
+	Minimum     *float64                  `json:"minimum,omitempty"`
+	Maximum     *float64                  `json:"maximum,omitempty"`
+	Items       *OpenApiSchema            `json:"items,omitempty"`
+	Properties  map[string]*OpenApiSchema `json:"properties,omitempty"`
+	Required    []string                  `json:"required,omitempty"`
+}
+
+type OpenApiComponents struct {
+	Schemas         map[string]*OpenApiSchema        `json:"schemas"`
+	SecuritySchemes map[string]OpenApiSecurityScheme `json:"securitySchemes"`
+}
+
+type OpenApiSecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+// NewOpenApi serves the document of the API version with the prefix, the paths in the document are relative to it.
+func NewOpenApi(prefix string) ApiEndpoint {
+	document, err := json.Marshal(newOpenApiDocument(prefix))
+	if err != nil {
+		panic(err)
+	}
+	return &openApiImpl{
+		document: document,
+	}
+}
+
+type openApiImpl struct {
+	document []byte
+}
+
+func (o *openApiImpl) Init(router *mux.Router) {
+	router.HandleFunc("/openapi.json", o.GetDocument).Methods("GET")
+}
+
+func (o *openApiImpl) GetDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(o.document)
+}
+
+// newOpenApiDocument describes every route that the ApiEndpoints register. Schemas are derived from the
+// DAO structs and their json tags, so they can not drift from what the handlers encode and decode.
+func newOpenApiDocument(prefix string) *OpenApiDocument {
+	schemas := openApiSchemas{}
+	document := &OpenApiDocument{
+		OpenApi: OPENAPI_VERSION,
+		Info: OpenApiInfo{
+			Title:       "Codingchallenge MazeAPI",
+			Description: "Generates, stores and solves very simple mazes.",
+			Version:     "1.0.0",
+		},
+		Servers: []OpenApiServer{{Url: prefix}},
+		Paths:   map[string]OpenApiPathItem{},
+		Components: OpenApiComponents{
+			Schemas: schemas,
+			SecuritySchemes: map[string]OpenApiSecurityScheme{
+				"session": {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	for _, operation := range openApiOperations(schemas) {
+		operation.addTo(document, schemas)
+	}
+	return document
+}
+
+func openApiOperations(schemas openApiSchemas) []*openApiOperation {
+	mazeId := "the id of the maze"
+	steps := &OpenApiSchema{Type: "string", Enum: []string{"min", "max"}, Default: "min"}
+	size := &OpenApiSchema{Type: "integer", Minimum: floatPtr(MAZE_GENERATE_MIN_SIZE), Maximum: floatPtr(65535), Default: 16}
+	rfc3339 := &OpenApiSchema{Type: "string", Format: "date-time"}
+	wallsEncoding := &OpenApiSchema{Type: "string", Enum: WALLS_ENCODINGS, Default: WALLS_ENCODING_LIST}
+
+	return []*openApiOperation{
+		// User
+		newOpenApiOperation("POST", "/user", "user", "createUser", "Register a new user").public().
+			jsonBody(User{}).json(http.StatusCreated, "The user was created", CreateUserResponse{}),
+		newOpenApiOperation("DELETE", "/user", "user", "deleteUser", "Delete the own account with all its data, teams the account is the last owner of get a new owner or are deleted when empty").
+			query("export", "return the data of the account before it is deleted", &OpenApiSchema{Type: "boolean", Default: false}).
+			json(http.StatusOK, "The account was deleted, the response contains the exported data", UserExportDao{}).
+			empty(http.StatusNoContent, "The account was deleted"),
+		newOpenApiOperation("GET", "/user/export", "user", "exportUser", "Export all data of the own account").
+			json(http.StatusOK, "The data of the account", UserExportDao{}),
+		newOpenApiOperation("GET", "/user/usage", "user", "getUsage", "Show the quota usage of the own account").
+			json(http.StatusOK, "The usage and limits", Usage{}),
+		newOpenApiOperation("POST", "/user/password", "user", "changePassword", "Change the password, all other sessions are revoked").
+			jsonBody(ChangePasswordDao{}).empty(http.StatusNoContent, "The password was changed"),
+		newOpenApiOperation("POST", "/user/2fa", "user", "enrollTwoFactor", "Start the enrollment of two-factor authentication").
+			json(http.StatusCreated, "The secret and the recovery codes", TwoFactorEnrollment{}),
+		newOpenApiOperation("POST", "/user/2fa/activate", "user", "activateTwoFactor", "Activate two-factor authentication with a first code").
+			jsonBody(TwoFactorCodeDao{}).empty(http.StatusNoContent, "Two-factor authentication is active"),
+		newOpenApiOperation("POST", "/login", "user", "login", "Log in with username and password").public().
+			jsonBody(User{}).json(http.StatusOK, "The session, or the token for the second factor", LoginResult{}),
+		newOpenApiOperation("POST", "/login/2fa", "user", "loginTwoFactor", "Complete a login with the second factor").public().
+			jsonBody(TwoFactorLoginDao{}).json(http.StatusOK, "The session", LoginResult{}),
+
+		// Maze
+		newOpenApiOperation("GET", "/maze", "maze", "getMyMazes", "List the own mazes").
+			query("wallsEncoding", "list the walls or send them as bitset", wallsEncoding).
+			query("minDifficulty", "only list mazes with at least this difficulty", &OpenApiSchema{Type: "number", Minimum: floatPtr(0), Maximum: floatPtr(100)}).
+			query("maxDifficulty", "only list mazes with at most this difficulty", &OpenApiSchema{Type: "number", Minimum: floatPtr(0), Maximum: floatPtr(100)}).
+			query("sort", "sort by difficulty, easiest first or with '-' hardest first, the mazes are listed in the order they were stored otherwise", &OpenApiSchema{Type: "string", Enum: []string{MAZE_SORT_DIFFICULTY, MAZE_SORT_DIFFICULTY_DESC}}).
+			json(http.StatusOK, "The mazes of the user", MyMazesDao{}),
+		newOpenApiOperation("POST", "/maze", "maze", "createMaze", "Store a maze").
+			jsonBody(MazeApiDao{}).
+			text("The maze drawn with '#' for walls, '.' for open cells, 'E' for the entrance and an optional 'X' for the exit on the last line").
+			json(http.StatusCreated, "The maze was stored", CreateMazeResponse{}),
+		newOpenApiOperation("POST", "/maze/import/image", "maze", "importMazeImage", "Read a maze from an image").
+			query("cellSize", "the edge length of a cell in pixels, detected from the image if missing", &OpenApiSchema{Type: "integer", Minimum: floatPtr(1)}).
+			query("threshold", "cells darker than the threshold are walls", &OpenApiSchema{Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(255), Default: MAZE_IMAGE_DEFAULT_THRESHOLD}).
+			query("marker", "the colour of the entrance cell as #rrggbb, without marker the entrance is the only gap in the top, left or right border", &OpenApiSchema{Type: "string", Default: "#00ff00"}).
+			query("entrance", "the address of the entrance, overrides the detection", &OpenApiSchema{Type: "string"}).
+			query("save", "store the maze as well", &OpenApiSchema{Type: "boolean", Default: false}).
+			binaryIn(CONTENT_TYPE_PNG, "image/gif", "image/jpeg").
+			json(http.StatusOK, "The maze read from the image", ImportedMazeDao{}).
+			json(http.StatusCreated, "The maze was read from the image and stored", ImportedMazeDao{}),
+		newOpenApiOperation("POST", "/maze/pdf", "maze", "printMazes", "Print stored mazes as PDF, several mazes can share a page").
+			pdfOptions(steps).
+			jsonBody(MazePdfRequestDao{}).
+			binary(http.StatusOK, "The mazes as PDF, followed by their solutions if requested", CONTENT_TYPE_PDF),
+		newOpenApiOperation("POST", "/maze/validate", "maze", "validateMaze", "Report all problems of a maze without storing it").
+			jsonBody(MazeApiDao{}).json(http.StatusOK, "The validation report", MazeValidationReport{}),
+		newOpenApiOperation("POST", "/maze/draw", "maze", "drawMaze", "Draw a maze with an optional path").
+			query("format", "draw a PNG image or an SVG drawing, SVG starts from cells of 10 with markers", &OpenApiSchema{Type: "string", Enum: []string{MAZE_DRAW_FORMAT_PNG, MAZE_DRAW_FORMAT_SVG}, Default: MAZE_DRAW_FORMAT_PNG}).
+			drawOptions(1).
+			jsonBody(DrawMazeApiDao{}).
+			binary(http.StatusOK, "The maze as image", CONTENT_TYPE_PNG).
+			content(http.StatusOK, "The maze as image", CONTENT_TYPE_SVG, &OpenApiSchema{Type: "string"}),
+		newOpenApiOperation("GET", "/maze/generate", "maze", "generateMaze", "Generate a random maze").
+			query("width", "the width of the grid", size).
+			query("height", "the height of the grid", size).
+			query("wallsEncoding", "list the walls or send them as bitset", wallsEncoding).
+			query("difficulty", fmt.Sprintf("generate until the difficulty is within the band of a level or within %g of a number from 0 to 100, small grids may not reach the hard levels, the grid may have up to %d cells", MAZE_GENERATE_DIFFICULTY_TOLERANCE, MAZE_GENERATE_DIFFICULTY_MAX_CELLS), &OpenApiSchema{Type: "string", Description: fmt.Sprintf("one of %v or a number", mazeDifficultyLevelNames())}).
+			json(http.StatusOK, "The generated maze, with the achieved difficulty if one was requested", GeneratedMazeDao{}),
+		newOpenApiOperation("GET", "/maze/generate/animation", "maze", "animateGeneration", "Generate a random maze and show how its cells were carved").
+			query("width", "the width of the grid", size).
+			query("height", "the height of the grid", size).
+			animationOptions().
+			binary(http.StatusOK, "The carving as animated image", CONTENT_TYPE_GIF),
+		newOpenApiOperation("GET", "/maze/{mazeId}", "maze", "getMaze", "Show a stored maze in the representation selected by the Accept header").
+			path("mazeId", mazeId).
+			query("wallsEncoding", "list the walls or send them as bitset, only for JSON", wallsEncoding).
+			drawOptions(1).
+			json(http.StatusOK, "The maze", MazeWithIdDao{}).
+			content(http.StatusOK, "The maze", CONTENT_TYPE_TEXT, &OpenApiSchema{Type: "string"}).
+			binary(http.StatusOK, "The maze", CONTENT_TYPE_PNG).
+			content(http.StatusOK, "The maze", CONTENT_TYPE_SVG, &OpenApiSchema{Type: "string"}).
+			binary(http.StatusOK, "The maze", CONTENT_TYPE_BINARY),
+		newOpenApiOperation("GET", "/maze/{mazeId}/distances", "maze", "getMazeDistances", "Show the distance of every cell from the entrance").
+			path("mazeId", mazeId).
+			query("format", "return the distances as JSON or draw them as PNG image, the Accept header decides if it is missing", &OpenApiSchema{Type: "string", Enum: []string{MAZE_DISTANCE_FORMAT_JSON, MAZE_DRAW_FORMAT_PNG}}).
+			drawOptions(MAZE_DISTANCE_DEFAULT_CELL_SIZE).
+			json(http.StatusOK, "The distances, walls and unreachable cells are -1", MazeDistancesDao{}).
+			binary(http.StatusOK, "The open cells coloured by their distance, unreachable open cells stand out in their own colour", CONTENT_TYPE_PNG),
+		newOpenApiOperation("GET", "/maze/{mazeId}/export", "maze", "exportMaze", "Download a stored maze as ASCII art").
+			path("mazeId", mazeId).
+			content(http.StatusOK, "The maze with the exit marked as 'X'", CONTENT_TYPE_TEXT, &OpenApiSchema{Type: "string"}),
+		newOpenApiOperation("GET", "/maze/{mazeId}/image", "maze", "getMazeImage", "Draw a stored maze").
+			path("mazeId", mazeId).
+			imageOptions().
+			binary(http.StatusOK, "The maze as image", CONTENT_TYPE_PNG).
+			content(http.StatusOK, "The maze as image", CONTENT_TYPE_SVG, &OpenApiSchema{Type: "string"}).
+			empty(http.StatusNotModified, "The image did not change since the ETag in If-None-Match"),
+		newOpenApiOperation("GET", "/maze/{mazeId}/pdf", "maze", "printMaze", "Print a stored maze as PDF").
+			path("mazeId", mazeId).
+			pdfOptions(steps).
+			binary(http.StatusOK, "The maze as PDF, followed by its solution if requested", CONTENT_TYPE_PDF),
+		newOpenApiOperation("GET", "/maze/{mazeId}/solution", "maze", "findSolution", "Solve a stored maze").
+			path("mazeId", mazeId).
+			query("steps", "return the shortest or the longest path", steps).
+			json(http.StatusOK, "The solution", MazeSolution{}),
+		newOpenApiOperation("GET", "/maze/{mazeId}/solution/animation", "maze", "animateSolution", "Show how the solver searches a stored maze").
+			path("mazeId", mazeId).
+			query("steps", "end with the shortest or the longest path", steps).
+			animationOptions().
+			binary(http.StatusOK, "The search as animated image, the visited cells and then the solution", CONTENT_TYPE_GIF),
+		newOpenApiOperation("GET", "/maze/{mazeId}/solution/image", "maze", "getSolutionImage", "Draw a stored maze with its solution").
+			path("mazeId", mazeId).
+			query("steps", "draw the shortest or the longest path", steps).
+			imageOptions().
+			binary(http.StatusOK, "The maze with the solution as image", CONTENT_TYPE_PNG).
+			content(http.StatusOK, "The maze with the solution as image", CONTENT_TYPE_SVG, &OpenApiSchema{Type: "string"}).
+			empty(http.StatusNotModified, "The image did not change since the ETag in If-None-Match"),
+		newOpenApiOperation("GET", "/maze/{mazeId}/stats", "maze", "getMazeStats", "Describe the structure of a stored maze and score its difficulty").
+			path("mazeId", mazeId).
+			json(http.StatusOK, "The stats, the solution is the shortest path", MazeStatsDao{}),
+
+		// Team
+		newOpenApiOperation("GET", "/teams", "team", "getMyTeams", "List the teams of the user").
+			json(http.StatusOK, "The teams with the role of the user", TeamListDao{}),
+		newOpenApiOperation("POST", "/teams", "team", "createTeam", "Create a team owned by the user").
+			jsonBody(CreateTeamDao{}).json(http.StatusCreated, "The team was created", CreateTeamResponse{}),
+		newOpenApiOperation("GET", "/teams/{teamId}", "team", "getTeam", "Show a team with its members").
+			json(http.StatusOK, "The team", TeamDao{}),
+		newOpenApiOperation("PUT", "/teams/{teamId}/members/{username}", "team", "setTeamMember", "Add a member or change the role of a member").
+			jsonBody(TeamMemberRoleDao{}).empty(http.StatusNoContent, "The membership was saved"),
+		newOpenApiOperation("DELETE", "/teams/{teamId}/members/{username}", "team", "removeTeamMember", "Remove a member or leave the team").
+			empty(http.StatusNoContent, "The member was removed"),
+		newOpenApiOperation("GET", "/teams/{teamId}/maze", "team", "getTeamMazes", "List the mazes of a team").
+			query("wallsEncoding", "list the walls or send them as bitset", wallsEncoding).
+			json(http.StatusOK, "The mazes of the team", MyMazesDao{}),
+		newOpenApiOperation("POST", "/teams/{teamId}/maze", "team", "createTeamMaze", "Store a maze for a team").
+			jsonBody(MazeApiDao{}).json(http.StatusCreated, "The maze was stored", CreateTeamMazeResponse{}),
+
+		// Admin
+		newOpenApiOperation("GET", "/admin/users", "admin", "listUsers", "List all accounts").
+			json(http.StatusOK, "All accounts", UserListDao{}),
+		newOpenApiOperation("PUT", "/admin/users/{username}/role", "admin", "setUserRole", "Change the role of an account").
+			jsonBody(RoleDao{}).empty(http.StatusNoContent, "The role was changed"),
+		newOpenApiOperation("POST", "/admin/users/{username}/disable", "admin", "disableUser", "Disable an account and revoke its sessions").
+			empty(http.StatusNoContent, "The account was disabled"),
+		newOpenApiOperation("POST", "/admin/users/{username}/enable", "admin", "enableUser", "Enable a disabled account").
+			empty(http.StatusNoContent, "The account was enabled"),
+		newOpenApiOperation("POST", "/admin/users/{username}/logout", "admin", "forceLogout", "Revoke all sessions of an account").
+			empty(http.StatusNoContent, "The sessions were revoked"),
+		newOpenApiOperation("GET", "/admin/maze/{mazeId}", "admin", "adminGetMaze", "Show any maze with its owner").
+			path("mazeId", mazeId).
+			json(http.StatusOK, "The maze", AdminMazeDao{}),
+		newOpenApiOperation("DELETE", "/admin/maze/{mazeId}", "admin", "adminDeleteMaze", "Delete any maze").
+			path("mazeId", mazeId).
+			empty(http.StatusNoContent, "The maze was deleted"),
+		newOpenApiOperation("GET", "/admin/audit", "admin", "getAuditLog", "Query the audit log").
+			query("event", "only events of this type", &OpenApiSchema{Type: "string"}).
+			query("actor", "only events caused by this user", &OpenApiSchema{Type: "string"}).
+			query("subject", "only events affecting this user or maze", &OpenApiSchema{Type: "string"}).
+			query("since", "only events at or after this time", rfc3339).
+			query("until", "only events before this time", rfc3339).
+			query("offset", "the number of events to skip", &OpenApiSchema{Type: "integer", Minimum: floatPtr(0), Default: 0}).
+			query("limit", "the page size", &OpenApiSchema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(AUDIT_MAX_PAGE_SIZE), Default: AUDIT_DEFAULT_PAGE_SIZE}).
+			json(http.StatusOK, "A page of audit events", AuditLogDao{}),
+		newOpenApiOperation("GET", "/admin/audit/export", "admin", "exportAuditLog", "Export the audit log as JSON Lines").
+			query("event", "only events of this type", &OpenApiSchema{Type: "string"}).
+			query("actor", "only events caused by this user", &OpenApiSchema{Type: "string"}).
+			query("subject", "only events affecting this user or maze", &OpenApiSchema{Type: "string"}).
+			query("since", "only events at or after this time", rfc3339).
+			query("until", "only events before this time", rfc3339).
+			content(http.StatusOK, "One audit event per line", "application/jsonl", schemas.of(reflect.TypeOf(AuditEvent{}))),
+
+		// Documentation
+		newOpenApiOperation("GET", "/openapi.json", "documentation", "getOpenApi", "This document").public().
+			content(http.StatusOK, "The OpenAPI document", "application/json", &OpenApiSchema{Type: "object"}),
+	}
+}
+
+// openApiOperation collects an operation together with the types that are turned into schemas once it is added
+type openApiOperation struct {
+	method      string
+	template    string
+	operation   *OpenApiOperation
+	requestType reflect.Type
+	textBody    string
+	binaryBody  []string
+	responses   map[int]reflect.Type
+}
+
+var OPENAPI_PATH_PARAMETER_PATTERN = regexp.MustCompile(`{([^}:]+)(:[^}]+)?}`)
+
+func newOpenApiOperation(method string, path string, tag string, operationId string, summary string) *openApiOperation {
+	o := &openApiOperation{
+		method:   method,
+		template: path,
+		operation: &OpenApiOperation{
+			Tags:        []string{tag},
+			Summary:     summary,
+			OperationId: operationId,
+			Security:    []map[string][]string{{"session": {}}},
+			Responses:   map[string]OpenApiResponse{},
+		},
+		responses: map[int]reflect.Type{},
+	}
+	for _, match := range OPENAPI_PATH_PARAMETER_PATTERN.FindAllStringSubmatch(path, -1) {
+		o.path(match[1], "")
+	}
+	return o
+}
+
+// public removes the session requirement
+func (o *openApiOperation) public() *openApiOperation {
+	o.operation.Security = []map[string][]string{}
+	return o
+}
+
+// path sets the description of a path parameter, path parameters are added by newOpenApiOperation
+func (o *openApiOperation) path(name string, description string) *openApiOperation {
+	for i, parameter := range o.operation.Parameters {
+		if parameter.In == "path" && parameter.Name == name {
+			o.operation.Parameters[i].Description = description
+			return o
+		}
+	}
+	o.operation.Parameters = append(o.operation.Parameters, OpenApiParameter{
+		Name:        name,
+		In:          "path",
+		Description: description,
+		Required:    true,
+		Schema:      &OpenApiSchema{Type: "string"},
+	})
+	return o
+}
+
+func (o *openApiOperation) query(name string, description string, schema *OpenApiSchema) *openApiOperation {
+	o.operation.Parameters = append(o.operation.Parameters, OpenApiParameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      schema,
+	})
+	return o
+}
+
+// drawOptions adds the query parameters that style drawings, see readMazeDrawOptions
+func (o *openApiOperation) drawOptions(defaultCellSize int) *openApiOperation {
+	flag := &OpenApiSchema{Type: "boolean", Default: false}
+	return o.
+		query("cellSize", "the edge length of a cell in pixels", &OpenApiSchema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(MAZE_DRAW_MAX_CELL_SIZE), Default: defaultCellSize}).
+		query("margin", "the space around the maze in pixels", &OpenApiSchema{Type: "integer", Minimum: floatPtr(0), Maximum: floatPtr(MAZE_DRAW_MAX_MARGIN), Default: 0}).
+		query("theme", "the colours of the drawing", &OpenApiSchema{Type: "string", Enum: mazeThemeNames(), Default: MAZE_DRAW_DEFAULT_THEME}).
+		query("grid", fmt.Sprintf("draw grid lines between the open cells, needs a cellSize of at least %d", MAZE_DRAW_MIN_GRID_CELL_SIZE), flag).
+		query("labels", fmt.Sprintf("label the columns and rows, needs a cellSize of at least %d", MAZE_DRAW_MIN_LABEL_CELL_SIZE), flag).
+		query("markers", "mark the entrance and the exit", flag)
+}
+
+// imageOptions adds the format and the options of drawings of stored mazes
+func (o *openApiOperation) imageOptions() *openApiOperation {
+	return o.
+		query("format", "draw a PNG image or an SVG drawing, the Accept header decides if it is missing", &OpenApiSchema{Type: "string", Enum: []string{MAZE_DRAW_FORMAT_PNG, MAZE_DRAW_FORMAT_SVG}}).
+		drawOptions(1)
+}
+
+// pdfOptions adds the query parameters of printed mazes, see readMazePdfOptions
+func (o *openApiOperation) pdfOptions(steps *OpenApiSchema) *openApiOperation {
+	return o.
+		query("pageSize", "the size of the pages", &OpenApiSchema{Type: "string", Enum: mazePdfPageSizeNames(), Default: MAZE_PDF_PAGE_A4}).
+		query("perPage", "the number of mazes on a page", &OpenApiSchema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(MAZE_PDF_MAX_PER_PAGE), Default: 1}).
+		query("title", "the title on every page and in the metadata", &OpenApiSchema{Type: "string", Default: MAZE_PDF_DEFAULT_TITLE}).
+		query("solutions", "add pages with the solutions, each consumes a solve of the quota", &OpenApiSchema{Type: "boolean", Default: false}).
+		query("steps", "print the shortest or the longest path as solution", steps)
+}
+
+// animationOptions adds the query parameters of animations, see readMazeAnimationOptions
+func (o *openApiOperation) animationOptions() *openApiOperation {
+	return o.
+		drawOptions(MAZE_ANIMATION_DEFAULT_CELL_SIZE).
+		query("fps", "the frames per second", &OpenApiSchema{Type: "integer", Minimum: floatPtr(1), Maximum: floatPtr(MAZE_ANIMATION_MAX_FPS), Default: MAZE_ANIMATION_DEFAULT_FPS}).
+		query("maxFrames", "the most frames of the animation, longer runs show several steps per frame", &OpenApiSchema{Type: "integer", Minimum: floatPtr(2), Maximum: floatPtr(MAZE_ANIMATION_MAX_FRAMES), Default: MAZE_ANIMATION_DEFAULT_MAX_FRAMES})
+}
+
+func (o *openApiOperation) jsonBody(body interface{}) *openApiOperation {
+	o.requestType = reflect.TypeOf(body)
+	return o
+}
+
+// text accepts a plain text body next to or instead of the JSON body
+func (o *openApiOperation) text(description string) *openApiOperation {
+	o.textBody = description
+	return o
+}
+
+// binaryIn accepts a binary body in any of the content types
+func (o *openApiOperation) binaryIn(contentTypes ...string) *openApiOperation {
+	o.binaryBody = contentTypes
+	return o
+}
+
+func (o *openApiOperation) json(status int, description string, body interface{}) *openApiOperation {
+	o.operation.Responses[statusKey(status)] = OpenApiResponse{Description: description}
+	o.responses[status] = reflect.TypeOf(body)
+	return o
+}
+
+func (o *openApiOperation) empty(status int, description string) *openApiOperation {
+	o.operation.Responses[statusKey(status)] = OpenApiResponse{Description: description}
+	return o
+}
+
+func (o *openApiOperation) binary(status int, description string, contentType string) *openApiOperation {
+	return o.content(status, description, contentType, &OpenApiSchema{Type: "string", Format: "binary"})
+}
+
+// content adds a representation to the response, responses may offer several content types
+func (o *openApiOperation) content(status int, description string, contentType string, schema *OpenApiSchema) *openApiOperation {
+	response, ok := o.operation.Responses[statusKey(status)]
+	if !ok {
+		response = OpenApiResponse{Description: description}
+	}
+	if response.Content == nil {
+		response.Content = map[string]OpenApiMediaType{}
+	}
+	response.Content[contentType] = OpenApiMediaType{Schema: schema}
+	o.operation.Responses[statusKey(status)] = response
+	return o
+}
+
+func (o *openApiOperation) addTo(document *OpenApiDocument, schemas openApiSchemas) {
+	if o.requestType != nil || o.textBody != "" || len(o.binaryBody) > 0 {
+		o.operation.RequestBody = &OpenApiRequestBody{
+			Required: true,
+			Content:  map[string]OpenApiMediaType{},
+		}
+	}
+	if o.requestType != nil {
+		o.operation.RequestBody.Content["application/json"] = OpenApiMediaType{Schema: schemas.of(o.requestType)}
+	}
+	if o.textBody != "" {
+		o.operation.RequestBody.Content["text/plain"] = OpenApiMediaType{Schema: &OpenApiSchema{Type: "string", Description: o.textBody}}
+	}
+	for _, contentType := range o.binaryBody {
+		o.operation.RequestBody.Content[contentType] = OpenApiMediaType{Schema: &OpenApiSchema{Type: "string", Format: "binary"}}
+	}
+	for status, responseType := range o.responses {
+		response := o.operation.Responses[statusKey(status)]
+		if response.Content == nil {
+			response.Content = map[string]OpenApiMediaType{}
+		}
+		response.Content["application/json"] = OpenApiMediaType{Schema: schemas.of(responseType)}
+		o.operation.Responses[statusKey(status)] = response
+	}
+	o.operation.Responses["default"] = OpenApiResponse{
+		Description: "The error, see the code for details",
+		Content:     map[string]OpenApiMediaType{"application/json": {Schema: schemas.of(reflect.TypeOf(ErrorResponse{}))}},
+	}
+
+	pathItem, ok := document.Paths[o.template]
+	if !ok {
+		pathItem = OpenApiPathItem{}
+		document.Paths[o.template] = pathItem
+	}
+	pathItem[strings.ToLower(o.method)] = o.operation
+}
+
+// openApiSchemas holds the component schemas by type name
+type openApiSchemas map[string]*OpenApiSchema
+
+var TIME_TYPE = reflect.TypeOf(time.Time{})
+
+// of returns the schema of the type, named structs are added as component and referenced
+func (s openApiSchemas) of(t reflect.Type) *OpenApiSchema {
+	switch {
+	case t.Kind() == reflect.Ptr:
+		return s.of(t.Elem())
+	case t == TIME_TYPE:
+		return &OpenApiSchema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct:
+		if _, ok := s[t.Name()]; !ok {
+			// Register first, so that recursive types terminate
+			schema := &OpenApiSchema{Type: "object", Properties: map[string]*OpenApiSchema{}}
+			s[t.Name()] = schema
+			s.addProperties(schema, t)
+		}
+		return &OpenApiSchema{Ref: "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &OpenApiSchema{Type: "array", Items: s.of(t.Elem())}
+	case t.Kind() == reflect.String:
+		return &OpenApiSchema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &OpenApiSchema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		return &OpenApiSchema{Type: "integer", Format: integerFormat(t)}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		return &OpenApiSchema{Type: "integer", Format: integerFormat(t), Minimum: floatPtr(0)}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &OpenApiSchema{Type: "number"}
+	}
+	return &OpenApiSchema{}
+}
+
+// addProperties follows encoding/json: embedded structs are flattened and omitempty fields are optional
+func (s openApiSchemas) addProperties(schema *OpenApiSchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			s.addProperties(schema, field.Type)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = s.of(field.Type)
+		if !strings.Contains(field.Tag.Get("json"), ",omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func integerFormat(t reflect.Type) string {
+	if t.Bits() > 32 {
+		return "int64"
+	}
+	return "int32"
+}
+
+func statusKey(status int) string {
+	return strconv.Itoa(status)
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
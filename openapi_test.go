@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOpenApiTestRouter() *mux.Router {
+	router := mux.NewRouter()
+	initApiVersion(router, ApiVersion{
+		Prefix: "/v1",
+		Endpoints: []ApiEndpoint{
+			NewUserApi(nil, nil, nil),
+			NewMazeApi(nil, nil, nil, nil),
+			NewAdminApi(nil, nil, nil),
+			NewTeamApi(nil, nil, nil, nil),
+			NewOpenApi("/v1"),
+		},
+	})
+	return router
+}
+
+func Test_openApi_CoversAllRoutes(t *testing.T) {
+	document := newOpenApiDocument("/v1")
+	routed := map[string]bool{}
+
+	err := newOpenApiTestRouter().Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// The subrouter of the version has no methods
+			return nil
+		}
+		path = strings.TrimPrefix(path, document.Servers[0].Url)
+		for _, method := range methods {
+			routed[method+" "+path] = true
+			_, ok := document.Paths[path][strings.ToLower(method)]
+			assert.True(t, ok, "the route %s %s is missing in the OpenAPI document", method, path)
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+
+	for path, pathItem := range document.Paths {
+		for method := range pathItem {
+			assert.True(t, routed[strings.ToUpper(method)+" "+path], "the OpenAPI document describes %s %s which is not routed", method, path)
+		}
+	}
+}
+
+func Test_openApi_Schemas(t *testing.T) {
+	document := newOpenApiDocument("/v1")
+	schemas := document.Components.Schemas
+
+	// Embedded structs are flattened
+	assert.Equal(t, []string{"entrance", "gridSize", "path"}, schemas["DrawMazeApiDao"].Required)
+	assert.Contains(t, schemas["DrawMazeApiDao"].Properties, "walls")
+	assert.Equal(t, "array", schemas["DrawMazeApiDao"].Properties["path"].Type)
+	assert.Equal(t, []string{"length", "path", "exit"}, schemas["MazeSolution"].Required)
+	assert.Equal(t, "int64", schemas["MazeSolution"].Properties["length"].Format)
+	// omitempty fields are optional
+	assert.Empty(t, schemas["LoginResult"].Required)
+	assert.Equal(t, "date-time", schemas["Usage"].Properties["resetsAt"].Format)
+	assert.Equal(t, "#/components/schemas/UsageCounter", schemas["Usage"].Properties["storedMazes"].Ref)
+
+	// Every reference can be resolved
+	encoded, err := json.Marshal(document)
+	assert.Nil(t, err)
+	for _, ref := range strings.Split(string(encoded), `"$ref":"#/components/schemas/`)[1:] {
+		name := ref[:strings.Index(ref, `"`)]
+		assert.Contains(t, schemas, name)
+	}
+
+	// Path parameters are taken from the template
+	parameters := document.Paths["/teams/{teamId}/members/{username}"]["put"].Parameters
+	assert.Equal(t, "teamId", parameters[0].Name)
+	assert.Equal(t, "username", parameters[1].Name)
+	assert.True(t, parameters[1].Required)
+	assert.Empty(t, document.Paths["/login"]["post"].Security)
+	assert.NotEmpty(t, document.Paths["/maze"]["get"].Security)
+}
+
+func Test_openApiImpl_GetDocument(t *testing.T) {
+	w := httptest.NewRecorder()
+	newOpenApiTestRouter().ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var document OpenApiDocument
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&document))
+	assert.Equal(t, OPENAPI_VERSION, document.OpenApi)
+	assert.Equal(t, []OpenApiServer{{Url: "/v1"}}, document.Servers)
+	assert.Contains(t, document.Paths, "/maze/{mazeId}/solution")
+}
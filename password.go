@@ -23,15 +23,61 @@ var (
 	ErrIncompatibleVersion = errors.New("incompatible version of argon2")
 )
 
+type Argon2Params struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	KeyLen  uint32
+}
+
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:  ARGON2_PARAMETER_MEMORY,
+		Time:    ARGON2_PARAMETER_TIME,
+		Threads: ARGON2_PARAMETER_THREADS,
+		KeyLen:  ARGON2_PARAMETER_KEYLEN,
+	}
+}
+
+// Validate rejects parameters that argon2 can not work with, argon2 needs at least 8 KiB of memory per thread
+func (p Argon2Params) Validate() error {
+	if p.Time < 1 {
+		return errors.New("the argon2 time must be at least 1")
+	}
+	if p.Threads < 1 {
+		return errors.New("the argon2 threads must be at least 1")
+	}
+	if uint64(p.Memory) < 8*uint64(p.Threads) {
+		return fmt.Errorf("the argon2 memory must be at least 8 KiB per thread, %d KiB for %d threads", 8*uint64(p.Threads), p.Threads)
+	}
+	if p.KeyLen < 1 {
+		return errors.New("the argon2 key length must be at least 1")
+	}
+	return nil
+}
+
 func hashPassword(password string) (string, error) {
+	return hashPasswordWithParams(password, DefaultArgon2Params())
+}
+
+func hashPasswordWithParams(password string, params Argon2Params) (string, error) {
 	salt, err := generateRandomBytes(16)
 	if err != nil {
 		return "", err
 	}
-	hash := argon2.IDKey([]byte(password), salt, ARGON2_PARAMETER_TIME, ARGON2_PARAMETER_MEMORY, ARGON2_PARAMETER_THREADS, ARGON2_PARAMETER_KEYLEN)
+	hash := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
 	b64Salt := base64.RawStdEncoding.EncodeToString(salt)
 	b64Hash := base64.RawStdEncoding.EncodeToString(hash)
-	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, ARGON2_PARAMETER_MEMORY, ARGON2_PARAMETER_TIME, ARGON2_PARAMETER_THREADS, b64Salt, b64Hash), nil
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Memory, params.Time, params.Threads, b64Salt, b64Hash), nil
+}
+
+// needsRehash reports whether the encoded hash was created with other parameters than the given ones.
+func needsRehash(encodedHash string, params Argon2Params) (bool, error) {
+	memory, iterations, threads, _, hash, err := decodeHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+	return memory != params.Memory || iterations != params.Time || threads != uint32(params.Threads) || uint32(len(hash)) != params.KeyLen, nil
 }
 
 func comparePasswordAndHash(password string, encodedHash string) (bool, error) {
@@ -40,7 +86,7 @@ func comparePasswordAndHash(password string, encodedHash string) (bool, error) {
 		return false, err
 	}
 
-	otherHash := argon2.IDKey([]byte(password), salt, iterations, memory, uint8(threads), ARGON2_PARAMETER_KEYLEN)
+	otherHash := argon2.IDKey([]byte(password), salt, iterations, memory, uint8(threads), uint32(len(hash)))
 
 	if subtle.ConstantTimeCompare(hash, otherHash) == 1 {
 		return true, nil
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed breached_passwords.txt
+var defaultBreachedPasswords string
+
+type PasswordPolicy struct {
+	MinLength               int
+	BreachedPasswords       map[string]struct{}
+	RejectSimilarToUsername bool
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	breachedPasswords, err := LoadBreachedPasswords(strings.NewReader(defaultBreachedPasswords))
+	if err != nil {
+		panic(err)
+	}
+	return PasswordPolicy{
+		MinLength:               8,
+		BreachedPasswords:       breachedPasswords,
+		RejectSimilarToUsername: true,
+	}
+}
+
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "the password does not meet the password policy: " + strings.Join(e.Violations, "; ")
+}
+
+// LoadBreachedPasswords reads one password per line, empty lines and lines starting with # are ignored.
+func LoadBreachedPasswords(r io.Reader) (map[string]struct{}, error) {
+	passwords := map[string]struct{}{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords, scanner.Err()
+}
+
+// Validate returns a PasswordPolicyError listing every rule the password violates.
+func (p *PasswordPolicy) Validate(username string, password string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("the password must be at least %d characters long", p.MinLength))
+	}
+
+	if _, breached := p.BreachedPasswords[strings.ToLower(password)]; breached {
+		violations = append(violations, "the password appears in a list of breached passwords")
+	}
+
+	if p.RejectSimilarToUsername && isSimilarToUsername(username, password) {
+		violations = append(violations, "the password must not be similar to the username")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func isSimilarToUsername(username string, password string) bool {
+	username = strings.ToLower(username)
+	password = strings.ToLower(password)
+	if utf8.RuneCountInString(username) < 3 {
+		return false
+	}
+
+	if strings.Contains(password, username) || strings.Contains(username, password) {
+		return true
+	}
+	if password == reverseString(username) {
+		return true
+	}
+	// The distance is at least the difference of the lengths
+	lengthDifference := utf8.RuneCountInString(username) - utf8.RuneCountInString(password)
+	if lengthDifference > 2 || lengthDifference < -2 {
+		return false
+	}
+	return levenshteinDistance(username, password) <= 2
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func levenshteinDistance(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j] + 1
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+			if previous[j-1]+cost < current[j] {
+				current[j] = previous[j-1] + cost
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	got, err := LoadBreachedPasswords(strings.NewReader("# comment\nPassword\n\n  letmein  \n"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]struct{}{
+		"password": {},
+		"letmein":  {},
+	}, got)
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	tests := []struct {
+		name           string
+		username       string
+		password       string
+		wantViolations []string
+	}{
+		{
+			name:     "valid password",
+			username: "alice",
+			password: "correct horse battery",
+		},
+		{
+			name:     "too short",
+			username: "alice",
+			password: "x7#k",
+			wantViolations: []string{
+				"the password must be at least 8 characters long",
+			},
+		},
+		{
+			name:     "breached password ignores case",
+			username: "alice",
+			password: "Password123",
+			wantViolations: []string{
+				"the password appears in a list of breached passwords",
+			},
+		},
+		{
+			name:     "contains username",
+			username: "alice",
+			password: "alice2023!",
+			wantViolations: []string{
+				"the password must not be similar to the username",
+			},
+		},
+		{
+			name:     "reversed username",
+			username: "mazebuilder",
+			password: "redliubezam",
+			wantViolations: []string{
+				"the password must not be similar to the username",
+			},
+		},
+		{
+			name:     "all violations at once",
+			username: "admin",
+			password: "admin",
+			wantViolations: []string{
+				"the password must be at least 8 characters long",
+				"the password appears in a list of breached passwords",
+				"the password must not be similar to the username",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.username, tt.password)
+			if tt.wantViolations == nil {
+				assert.Nil(t, err)
+				return
+			}
+			var policyErr *PasswordPolicyError
+			assert.True(t, errors.As(err, &policyErr))
+			assert.Equal(t, tt.wantViolations, policyErr.Violations)
+		})
+	}
+}
+
+func Test_levenshteinDistance(t *testing.T) {
+	assert.Equal(t, 0, levenshteinDistance("maze", "maze"))
+	assert.Equal(t, 1, levenshteinDistance("maze", "mazes"))
+	assert.Equal(t, 3, levenshteinDistance("kitten", "sitting"))
+	assert.Equal(t, 4, levenshteinDistance("", "maze"))
+}
+
+func Test_isSimilarToUsername(t *testing.T) {
+	assert.True(t, isSimilarToUsername("alice", "alcie"))
+	assert.True(t, isSimilarToUsername("alice", "alice in wonderland"))
+	assert.False(t, isSimilarToUsername("alice", "wonderland"))
+	assert.False(t, isSimilarToUsername(strings.Repeat("a", 1000), strings.Repeat("b", 2000)))
+}
@@ -35,3 +35,57 @@ func TestComparePasswordAndHashNoMatch(t *testing.T) {
 	assert.Nil(t, err)
 	assert.False(t, match)
 }
+
+func TestHashPasswordWithParams(t *testing.T) {
+	params := Argon2Params{
+		Memory:  8 * 1024,
+		Time:    2,
+		Threads: 1,
+		KeyLen:  16,
+	}
+	hash, err := hashPasswordWithParams("MyPass", params)
+	assert.Nil(t, err)
+	assert.Contains(t, hash, "$m=8192,t=2,p=1$")
+
+	match, err := comparePasswordAndHash("MyPass", hash)
+	assert.Nil(t, err)
+	assert.True(t, match)
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := hashPassword("MyPass")
+	assert.Nil(t, err)
+
+	rehash, err := needsRehash(hash, DefaultArgon2Params())
+	assert.Nil(t, err)
+	assert.False(t, rehash)
+
+	params := DefaultArgon2Params()
+	params.Time = 2
+	rehash, err = needsRehash(hash, params)
+	assert.Nil(t, err)
+	assert.True(t, rehash)
+
+	params = DefaultArgon2Params()
+	params.KeyLen = 64
+	rehash, err = needsRehash(hash, params)
+	assert.Nil(t, err)
+	assert.True(t, rehash)
+
+	_, err = needsRehash("invalid", DefaultArgon2Params())
+	assert.Equal(t, ErrInvalidHash, err)
+}
+
+func TestArgon2Params_Validate(t *testing.T) {
+	assert.Nil(t, DefaultArgon2Params().Validate())
+	for _, change := range []func(p *Argon2Params){
+		func(p *Argon2Params) { p.Time = 0 },
+		func(p *Argon2Params) { p.Threads = 0 },
+		func(p *Argon2Params) { p.Memory = 8*uint32(p.Threads) - 1 },
+		func(p *Argon2Params) { p.KeyLen = 0 },
+	} {
+		params := DefaultArgon2Params()
+		change(&params)
+		assert.NotNil(t, params.Validate())
+	}
+}
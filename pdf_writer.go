@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PDF_HELVETICA_WIDTHS are the widths of the printable ASCII characters in the standard font Helvetica,
+// in thousandths of the font size starting at the space
+var PDF_HELVETICA_WIDTHS = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// PDF_HELVETICA_DEFAULT_WIDTH is used for the Latin-1 characters above ASCII, most of them are as wide as a digit
+const PDF_HELVETICA_DEFAULT_WIDTH = 556
+
+// pdfWriter collects the objects of a PDF 1.4 document with their offsets for the cross-reference table.
+// Objects are reserved before they are written, so they can reference each other in any order.
+type pdfWriter struct {
+	buffer  bytes.Buffer
+	offsets []int
+}
+
+func newPdfWriter() *pdfWriter {
+	p := &pdfWriter{}
+	// The comment with bytes above 127 tells transfer programs that the file is binary
+	p.buffer.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	return p
+}
+
+// reserve returns the number of the next object
+func (p *pdfWriter) reserve() int {
+	p.offsets = append(p.offsets, -1)
+	return len(p.offsets)
+}
+
+func (p *pdfWriter) object(id int, body string) {
+	p.offsets[id-1] = p.buffer.Len()
+	fmt.Fprintf(&p.buffer, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// stream writes the data deflated, the dictionary holds the entries next to Length and Filter
+func (p *pdfWriter) stream(id int, dictionary string, data []byte) error {
+	var compressed bytes.Buffer
+	deflater := zlib.NewWriter(&compressed)
+	_, err := deflater.Write(data)
+	if err != nil {
+		return err
+	}
+	err = deflater.Close()
+	if err != nil {
+		return err
+	}
+	p.offsets[id-1] = p.buffer.Len()
+	fmt.Fprintf(&p.buffer, "%d 0 obj\n<<%s/Length %d /Filter /FlateDecode>>\nstream\n", id, dictionary, compressed.Len())
+	p.buffer.Write(compressed.Bytes())
+	p.buffer.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+// writeTo ends the document with the cross-reference table and the trailer, every reserved object must be written by then
+func (p *pdfWriter) writeTo(w io.Writer, root int, info int) error {
+	start := p.buffer.Len()
+	fmt.Fprintf(&p.buffer, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for i, offset := range p.offsets {
+		if offset < 0 {
+			return fmt.Errorf("the pdf object %d was reserved but not written", i+1)
+		}
+		fmt.Fprintf(&p.buffer, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&p.buffer, "trailer\n<</Size %d /Root %d 0 R /Info %d 0 R>>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, root, info, start)
+	_, err := w.Write(p.buffer.Bytes())
+	return err
+}
+
+// pdfString writes the text as literal string. The bytes are Latin-1, which WinAnsiEncoding and PDFDocEncoding
+// share for the printable characters, everything else is replaced by '?'.
+func pdfString(text string) string {
+	var result strings.Builder
+	result.WriteByte('(')
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			result.WriteByte('\\')
+			result.WriteRune(r)
+		case (r >= 0x20 && r < 0x7f) || (r >= 0xa0 && r <= 0xff):
+			result.WriteByte(byte(r))
+		default:
+			result.WriteByte('?')
+		}
+	}
+	result.WriteByte(')')
+	return result.String()
+}
+
+// pdfTextWidth is the width of the text in Helvetica in points
+func pdfTextWidth(text string, fontSize float64) float64 {
+	width := 0
+	for _, r := range text {
+		if r >= 0x20 && r < 0x7f {
+			width += PDF_HELVETICA_WIDTHS[r-0x20]
+		} else {
+			width += PDF_HELVETICA_DEFAULT_WIDTH
+		}
+	}
+	return float64(width) * fontSize / 1000
+}
+
+// pdfNumber writes the value with at most three decimals, which is finer than any printer resolves
+func pdfNumber(value float64) string {
+	return strconv.FormatFloat(math.Round(value*1000)/1000, 'f', -1, 64)
+}
+
+// pdfColor writes the colour as the operands of rg and RG
+func pdfColor(c color.RGBA) string {
+	return fmt.Sprintf("%s %s %s", pdfNumber(float64(c.R)/255), pdfNumber(float64(c.G)/255), pdfNumber(float64(c.B)/255))
+}
+
+func pdfDate(t time.Time) string {
+	return t.UTC().Format("D:20060102150405Z")
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPdf is a PDF that was checked against its cross-reference table, streams are inflated
+type testPdf struct {
+	objects map[int]string
+	streams map[int]string
+	trailer string
+}
+
+var TEST_PDF_STARTXREF_PATTERN = regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`)
+var TEST_PDF_STREAM_PATTERN = regexp.MustCompile(`^(<<[^\n]*/Length (\d+) /Filter /FlateDecode>>)\nstream\n`)
+
+// readTestPdf follows startxref to the cross-reference table and reads every object at its offset
+func readTestPdf(t *testing.T, data []byte) *testPdf {
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	match := TEST_PDF_STARTXREF_PATTERN.FindSubmatch(data)
+	if !assert.NotNil(t, match, "startxref is missing") {
+		return nil
+	}
+	start, _ := strconv.Atoi(string(match[1]))
+	lines := strings.Split(string(data[start:]), "\n")
+	assert.Equal(t, "xref", lines[0])
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	assert.Equal(t, "0000000000 65535 f ", lines[2])
+
+	pdf := &testPdf{objects: map[int]string{}, streams: map[int]string{}}
+	for id := 1; id < count; id++ {
+		entry := lines[2+id]
+		assert.Len(t, entry, 19, "the entries have 20 bytes with the line feed")
+		offset, _ := strconv.Atoi(entry[:10])
+		header := fmt.Sprintf("%d 0 obj\n", id)
+		if !assert.Equal(t, header, string(data[offset:offset+len(header)]), "offset of object %d", id) {
+			continue
+		}
+		body := data[offset+len(header):]
+		if stream := TEST_PDF_STREAM_PATTERN.FindSubmatch(body); stream != nil {
+			length, _ := strconv.Atoi(string(stream[2]))
+			compressed := body[len(stream[0]) : len(stream[0])+length]
+			assert.True(t, bytes.HasPrefix(body[len(stream[0])+length:], []byte("\nendstream\nendobj\n")))
+			reader, err := zlib.NewReader(bytes.NewReader(compressed))
+			assert.Nil(t, err)
+			content, err := io.ReadAll(reader)
+			assert.Nil(t, err)
+			pdf.objects[id] = string(stream[1])
+			pdf.streams[id] = string(content)
+			continue
+		}
+		end := bytes.Index(body, []byte("\nendobj\n"))
+		pdf.objects[id] = string(body[:end])
+	}
+	pdf.trailer = lines[2+count]
+	return pdf
+}
+
+// find returns the ids of the objects that contain the text, in ascending order
+func (p *testPdf) find(text string) []int {
+	ids := []int{}
+	for id := 1; id <= len(p.objects); id++ {
+		if strings.Contains(p.objects[id], text) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func Test_pdfWriter(t *testing.T) {
+	pdf := newPdfWriter()
+	catalog, pages, info := pdf.reserve(), pdf.reserve(), pdf.reserve()
+	content := pdf.reserve()
+	assert.Nil(t, pdf.stream(content, "", []byte("0 0 1 1 re f\n")))
+	pdf.object(pages, "<</Type /Pages /Kids [] /Count 0>>")
+	pdf.object(info, "<</Title (t)>>")
+	pdf.object(catalog, "<</Type /Catalog /Pages 2 0 R>>")
+
+	var out bytes.Buffer
+	assert.Nil(t, pdf.writeTo(&out, catalog, info))
+	document := readTestPdf(t, out.Bytes())
+	assert.Equal(t, "<</Type /Catalog /Pages 2 0 R>>", document.objects[catalog])
+	assert.Equal(t, "0 0 1 1 re f\n", document.streams[content])
+	assert.Equal(t, "trailer", document.trailer)
+	assert.Contains(t, out.String(), "<</Size 5 /Root 1 0 R /Info 3 0 R>>")
+}
+
+func Test_pdfWriter_MissingObject(t *testing.T) {
+	pdf := newPdfWriter()
+	pdf.reserve()
+	assert.NotNil(t, pdf.writeTo(io.Discard, 1, 1))
+}
+
+func Test_pdfString(t *testing.T) {
+	assert.Equal(t, `(Maze \(1\) \\ a)`, pdfString(`Maze (1) \ a`))
+	assert.Equal(t, "(Gr\xfc\xdfe ?)", pdfString("Grüße 🙂"))
+}
+
+func Test_pdfTextWidth(t *testing.T) {
+	assert.InDelta(t, 13.9, pdfTextWidth("1 2", 10), 1e-9)
+	assert.InDelta(t, 5.56, pdfTextWidth("ß", 10), 1e-9)
+}
+
+func Test_pdfNumber(t *testing.T) {
+	assert.Equal(t, "12", pdfNumber(12))
+	assert.Equal(t, "0.333", pdfNumber(1.0/3))
+	assert.Equal(t, "-2.5", pdfNumber(-2.5))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaPolicy limits what a single user may do, a limit of 0 means unlimited.
+type QuotaPolicy struct {
+	MaxStoredMazes    uint64
+	MaxGridCells      uint64
+	GenerationsPerDay uint64
+	SolvesPerDay      uint64
+}
+
+func DefaultQuotaPolicy() QuotaPolicy {
+	return QuotaPolicy{
+		MaxStoredMazes:    1000,
+		MaxGridCells:      250000,
+		GenerationsPerDay: 500,
+		SolvesPerDay:      1000,
+	}
+}
+
+// QuotaExceededError is returned when a request would exceed a fixed limit, retrying does not help.
+type QuotaExceededError struct {
+	Message string
+}
+
+func (e *QuotaExceededError) Error() string {
+	return e.Message
+}
+
+// DailyLimitExceededError is returned when the calls per day for an action are used up.
+type DailyLimitExceededError struct {
+	Action     string
+	Limit      uint64
+	RetryAfter time.Duration
+}
+
+func (e *DailyLimitExceededError) Error() string {
+	return fmt.Sprintf("the daily limit of %d %s calls is reached, retry in %d seconds", e.Limit, e.Action, retryAfterSeconds(e.RetryAfter))
+}
+
+type UsageCounter struct {
+	Used  uint64 `json:"used"`
+	Limit uint64 `json:"limit"`
+}
+
+type Usage struct {
+	StoredMazes  UsageCounter `json:"storedMazes"`
+	Generations  UsageCounter `json:"generations"`
+	Solves       UsageCounter `json:"solves"`
+	MaxGridCells uint64       `json:"maxGridCells"`
+	ResetsAt     time.Time    `json:"resetsAt"`
+}
+
+type QuotaController interface {
+	CheckGridSize(width uint16, height uint16) error
+	CheckStoredMazes(userId string) error
+	Consume(userId string, action string) error
+	GetUsage(userId string) (*Usage, error)
+}
+
+func NewQuotaController(usageRepository UsageRepository, mazeRepository MazeRepository, policy QuotaPolicy) QuotaController {
+	return &quotaControllerImpl{
+		usageRepository: usageRepository,
+		mazeRepository:  mazeRepository,
+		policy:          policy,
+		clock:           time.Now,
+	}
+}
+
+type quotaControllerImpl struct {
+	usageRepository UsageRepository
+	mazeRepository  MazeRepository
+	policy          QuotaPolicy
+	clock           func() time.Time
+}
+
+func (q *quotaControllerImpl) CheckGridSize(width uint16, height uint16) error {
+	cells := uint64(width) * uint64(height)
+	if q.policy.MaxGridCells != 0 && cells > q.policy.MaxGridCells {
+		return &QuotaExceededError{
+			Message: fmt.Sprintf("the grid %dx%d has %d cells, at most %d cells are allowed", width, height, cells, q.policy.MaxGridCells),
+		}
+	}
+	return nil
+}
+
+func (q *quotaControllerImpl) CheckStoredMazes(userId string) error {
+	if q.policy.MaxStoredMazes == 0 {
+		return nil
+	}
+	count, err := q.mazeRepository.CountByUserId(userId)
+	if err != nil {
+		return err
+	}
+	if count >= q.policy.MaxStoredMazes {
+		return storedMazesExceededError(q.policy.MaxStoredMazes)
+	}
+	return nil
+}
+
+// storedMazesExceededError is returned by CheckStoredMazes before the maze is validated, and by the repository that
+// enforces the limit when the maze is inserted
+func storedMazesExceededError(maxStoredMazes uint64) error {
+	return &QuotaExceededError{
+		Message: fmt.Sprintf("at most %d mazes can be stored, delete mazes to store new ones", maxStoredMazes),
+	}
+}
+
+// Consume counts a call of the action for today and fails once the daily limit is reached, rejected calls are not
+// counted.
+func (q *quotaControllerImpl) Consume(userId string, action string) error {
+	now := q.clock().UTC()
+	limit := q.dailyLimit(action)
+	allowed, err := q.usageRepository.Increment(userId, usageDay(now), action, limit)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return &DailyLimitExceededError{
+			Action:     action,
+			Limit:      limit,
+			RetryAfter: nextUsageDay(now).Sub(now),
+		}
+	}
+	return nil
+}
+
+func (q *quotaControllerImpl) GetUsage(userId string) (*Usage, error) {
+	now := q.clock().UTC()
+	storedMazes, err := q.mazeRepository.CountByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	generations, err := q.usageRepository.SelectCount(userId, usageDay(now), USAGE_ACTION_GENERATE)
+	if err != nil {
+		return nil, err
+	}
+	solves, err := q.usageRepository.SelectCount(userId, usageDay(now), USAGE_ACTION_SOLVE)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Usage{
+		StoredMazes:  UsageCounter{Used: storedMazes, Limit: q.policy.MaxStoredMazes},
+		Generations:  UsageCounter{Used: generations, Limit: q.policy.GenerationsPerDay},
+		Solves:       UsageCounter{Used: solves, Limit: q.policy.SolvesPerDay},
+		MaxGridCells: q.policy.MaxGridCells,
+		ResetsAt:     nextUsageDay(now),
+	}, nil
+}
+
+func (q *quotaControllerImpl) dailyLimit(action string) uint64 {
+	switch action {
+	case USAGE_ACTION_GENERATE:
+		return q.policy.GenerationsPerDay
+	case USAGE_ACTION_SOLVE:
+		return q.policy.SolvesPerDay
+	}
+	return 0
+}
+
+func usageDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+func nextUsageDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQuotaController(storedMazes uint64, policy QuotaPolicy, now *time.Time) *quotaControllerImpl {
+	mazeRepository := &MazeRepositoryMock{}
+	mazeRepository.On("CountByUserId", "abc").Return(storedMazes, nil)
+	return &quotaControllerImpl{
+		usageRepository: &usageRepositoryImpl{db: newUsageTestDb()},
+		mazeRepository:  mazeRepository,
+		policy:          policy,
+		clock: func() time.Time {
+			return *now
+		},
+	}
+}
+
+func Test_quotaControllerImpl_CheckGridSize(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := newTestQuotaController(0, QuotaPolicy{MaxGridCells: 100}, &now)
+
+	assert.Nil(t, q.CheckGridSize(10, 10))
+	var quotaExceeded *QuotaExceededError
+	assert.True(t, errors.As(q.CheckGridSize(10, 11), &quotaExceeded))
+	assert.Contains(t, quotaExceeded.Message, "110 cells")
+
+	// Grids larger than uint16 * uint16 must not overflow
+	q.policy.MaxGridCells = 65535*65535 - 1
+	assert.NotNil(t, q.CheckGridSize(65535, 65535))
+
+	q.policy.MaxGridCells = 0
+	assert.Nil(t, q.CheckGridSize(65535, 65535))
+}
+
+func Test_quotaControllerImpl_CheckStoredMazes(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, newTestQuotaController(9, QuotaPolicy{MaxStoredMazes: 10}, &now).CheckStoredMazes("abc"))
+	var quotaExceeded *QuotaExceededError
+	assert.True(t, errors.As(newTestQuotaController(10, QuotaPolicy{MaxStoredMazes: 10}, &now).CheckStoredMazes("abc"), &quotaExceeded))
+	assert.Nil(t, newTestQuotaController(10, QuotaPolicy{}, &now).CheckStoredMazes("abc"))
+}
+
+func Test_quotaControllerImpl_Consume(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	q := newTestQuotaController(4, QuotaPolicy{GenerationsPerDay: 2, SolvesPerDay: 0}, &now)
+
+	assert.Nil(t, q.Consume("abc", USAGE_ACTION_GENERATE))
+	assert.Nil(t, q.Consume("abc", USAGE_ACTION_GENERATE))
+	// Rejected calls do not count
+	for i := 0; i < 2; i++ {
+		err := q.Consume("abc", USAGE_ACTION_GENERATE)
+		var dailyLimitExceeded *DailyLimitExceededError
+		assert.True(t, errors.As(err, &dailyLimitExceeded))
+		assert.Equal(t, time.Hour, dailyLimitExceeded.RetryAfter)
+	}
+
+	// Solves are unlimited but still counted
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, q.Consume("abc", USAGE_ACTION_SOLVE))
+	}
+
+	usage, err := q.GetUsage("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, &Usage{
+		StoredMazes: UsageCounter{Used: 4, Limit: 0},
+		Generations: UsageCounter{Used: 2, Limit: 2},
+		Solves:      UsageCounter{Used: 5, Limit: 0},
+		ResetsAt:    time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	}, usage)
+
+	// A new day starts with fresh counters
+	now = now.Add(2 * time.Hour)
+	assert.Nil(t, q.Consume("abc", USAGE_ACTION_GENERATE))
+}
@@ -0,0 +1,53 @@
+package main
+
+const (
+	ROLE_USER      = "user"
+	ROLE_MODERATOR = "moderator"
+	ROLE_ADMIN     = "admin"
+)
+
+var roleRanks = map[string]int{
+	ROLE_USER:      1,
+	ROLE_MODERATOR: 2,
+	ROLE_ADMIN:     3,
+}
+
+func isValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// hasRole reports whether the role includes the permissions of the required role.
+func hasRole(role string, requiredRole string) bool {
+	rank, ok := roleRanks[role]
+	if !ok {
+		return false
+	}
+	return rank >= roleRanks[requiredRole]
+}
+
+const (
+	TEAM_ROLE_VIEWER = "viewer"
+	TEAM_ROLE_EDITOR = "editor"
+	TEAM_ROLE_OWNER  = "owner"
+)
+
+var teamRoleRanks = map[string]int{
+	TEAM_ROLE_VIEWER: 1,
+	TEAM_ROLE_EDITOR: 2,
+	TEAM_ROLE_OWNER:  3,
+}
+
+func isValidTeamRole(role string) bool {
+	_, ok := teamRoleRanks[role]
+	return ok
+}
+
+// hasTeamRole reports whether the team role includes the permissions of the required team role.
+func hasTeamRole(role string, requiredRole string) bool {
+	rank, ok := teamRoleRanks[role]
+	if !ok {
+		return false
+	}
+	return rank >= teamRoleRanks[requiredRole]
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hasRole(t *testing.T) {
+	tests := []struct {
+		role         string
+		requiredRole string
+		want         bool
+	}{
+		{role: ROLE_USER, requiredRole: ROLE_USER, want: true},
+		{role: ROLE_USER, requiredRole: ROLE_MODERATOR, want: false},
+		{role: ROLE_MODERATOR, requiredRole: ROLE_MODERATOR, want: true},
+		{role: ROLE_MODERATOR, requiredRole: ROLE_ADMIN, want: false},
+		{role: ROLE_ADMIN, requiredRole: ROLE_MODERATOR, want: true},
+		{role: "unknown", requiredRole: ROLE_USER, want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, hasRole(tt.role, tt.requiredRole), "%s requires %s", tt.role, tt.requiredRole)
+	}
+}
@@ -7,6 +7,7 @@ const SESSION_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS sessions (session_
 type SessionRepository interface {
 	Insert(sessionId string, userId string) error
 	SelectBySessionId(sessionId string) (string, error)
+	DeleteByUserId(userId string, exceptSessionId string) error
 }
 
 func NewSessionRepository(db *sql.DB) SessionRepository {
@@ -42,3 +43,9 @@ func (s *sessionRepositoryImpl) SelectBySessionId(sessionId string) (string, err
 	}
 	return userId, nil
 }
+
+// DeleteByUserId removes all sessions of the user except the one with exceptSessionId, which may be empty.
+func (s *sessionRepositoryImpl) DeleteByUserId(userId string, exceptSessionId string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE user_id = ? AND session_id != ?", userId, exceptSessionId)
+	return err
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"database/sql"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func newSessionTestDb() *sql.DB {
@@ -115,3 +117,20 @@ func Test_sessionRepositoryImpl_SelectBySessionId(t *testing.T) {
 		})
 	}
 }
+
+func Test_sessionRepositoryImpl_DeleteByUserId(t *testing.T) {
+	s := &sessionRepositoryImpl{
+		db: newSessionTestDb(),
+	}
+	assert.Nil(t, s.Insert("session1", "abc"))
+	assert.Nil(t, s.Insert("session2", "abc"))
+	assert.Nil(t, s.Insert("session3", "other"))
+
+	assert.Nil(t, s.DeleteByUserId("abc", "session2"))
+
+	for sessionId, want := range map[string]string{"session1": "", "session2": "abc", "session3": "other"} {
+		got, err := s.SelectBySessionId(sessionId)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+}
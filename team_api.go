@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type CreateTeamDao struct {
+	Name string `json:"name"`
+}
+
+type CreateTeamResponse struct {
+	TeamId string `json:"teamId"`
+}
+
+type TeamListDao struct {
+	Teams []Team `json:"teams"`
+}
+
+type TeamDao struct {
+	Team
+	Members []TeamMember `json:"members"`
+}
+
+type TeamMemberRoleDao struct {
+	Role string `json:"role"`
+}
+
+type CreateTeamMazeResponse struct {
+	MazeId string `json:"mazeId"`
+}
+
+func NewTeamApi(teamController TeamController, mazeController MazeController, userController UserController, quotaController QuotaController) ApiEndpoint {
+	return &teamApiImpl{
+		teamController:  teamController,
+		mazeController:  mazeController,
+		userController:  userController,
+		quotaController: quotaController,
+	}
+}
+
+type teamApiImpl struct {
+	teamController  TeamController
+	mazeController  MazeController
+	userController  UserController
+	quotaController QuotaController
+}
+
+func (t *teamApiImpl) Init(router *mux.Router) {
+	router.HandleFunc("/teams", t.GetMyTeams).Methods("GET")
+	router.HandleFunc("/teams", t.CreateTeam).Methods("POST").Headers("Content-Type", "application/json")
+	router.HandleFunc("/teams/{teamId}", t.GetTeam).Methods("GET")
+	router.HandleFunc("/teams/{teamId}/members/{username}", t.SetMember).Methods("PUT").Headers("Content-Type", "application/json")
+	router.HandleFunc("/teams/{teamId}/members/{username}", t.RemoveMember).Methods("DELETE")
+	router.HandleFunc("/teams/{teamId}/maze", t.GetTeamMazes).Methods("GET")
+	router.HandleFunc("/teams/{teamId}/maze", t.CreateTeamMaze).Methods("POST").Headers("Content-Type", "application/json")
+}
+
+func (t *teamApiImpl) GetMyTeams(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	teams, err := t.teamController.GetUserTeams(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, TeamListDao{Teams: teams})
+}
+
+func (t *teamApiImpl) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	var team CreateTeamDao
+	err = json.NewDecoder(r.Body).Decode(&team)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+
+	// Process request
+	teamId, err := t.teamController.CreateTeam(userId, team.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	writeJson(w, http.StatusCreated, CreateTeamResponse{TeamId: teamId})
+}
+
+func (t *teamApiImpl) GetTeam(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	team, members, err := t.teamController.GetTeam(userId, mux.Vars(r)["teamId"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, TeamDao{Team: *team, Members: members})
+}
+
+func (t *teamApiImpl) SetMember(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	var role TeamMemberRoleDao
+	err = json.NewDecoder(r.Body).Decode(&role)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+
+	// Process request
+	err = t.teamController.SetMember(userId, mux.Vars(r)["teamId"], mux.Vars(r)["username"], role.Role)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *teamApiImpl) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = t.teamController.RemoveMember(userId, mux.Vars(r)["teamId"], mux.Vars(r)["username"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *teamApiImpl) GetTeamMazes(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	teamId := mux.Vars(r)["teamId"]
+	err = t.teamController.CheckTeamRole(userId, teamId, TEAM_ROLE_VIEWER)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	wallsEncoding, err := readWallsEncoding(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	mazes, err := t.mazeController.GetTeamMazes(teamId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	response := MyMazesDao{Mazes: []MazeWithIdDao{}}
+	for _, maze := range mazes {
+		mazeDao, err := toEncodedMazeApiDao(maze, wallsEncoding)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		mazeWithId := MazeWithIdDao{
+			Id:         maze.Id,
+			MazeApiDao: *mazeDao,
+		}
+		if maze.Stats != nil {
+			mazeWithId.Difficulty = &maze.Stats.Difficulty
+		}
+		response.Mazes = append(response.Mazes, mazeWithId)
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+func (t *teamApiImpl) CreateTeamMaze(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, t.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	teamId := mux.Vars(r)["teamId"]
+	err = t.teamController.CheckTeamRole(userId, teamId, TEAM_ROLE_EDITOR)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	var mazeDao MazeApiDao
+	err = json.NewDecoder(r.Body).Decode(&mazeDao)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+	// Team mazes count towards the quota of their author
+	maze, err := fromMazeApiDao(&mazeDao, t.quotaController.CheckGridSize)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = t.quotaController.CheckStoredMazes(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Process request
+	mazeId, err := t.mazeController.CreateTeamMaze(userId, teamId, maze)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	writeJson(w, http.StatusCreated, CreateTeamMazeResponse{MazeId: mazeId})
+}
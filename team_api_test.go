@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type TeamControllerMock struct {
+	mock.Mock
+}
+
+func (m *TeamControllerMock) CreateTeam(username string, name string) (string, error) {
+	args := m.Called(username, name)
+	return args.String(0), args.Error(1)
+}
+
+func (m *TeamControllerMock) GetUserTeams(username string) ([]Team, error) {
+	args := m.Called(username)
+	return args.Get(0).([]Team), args.Error(1)
+}
+
+func (m *TeamControllerMock) GetTeam(username string, teamId string) (*Team, []TeamMember, error) {
+	args := m.Called(username, teamId)
+	return args.Get(0).(*Team), args.Get(1).([]TeamMember), args.Error(2)
+}
+
+func (m *TeamControllerMock) SetMember(username string, teamId string, member string, role string) error {
+	args := m.Called(username, teamId, member, role)
+	return args.Error(0)
+}
+
+func (m *TeamControllerMock) RemoveMember(username string, teamId string, member string) error {
+	args := m.Called(username, teamId, member)
+	return args.Error(0)
+}
+
+func (m *TeamControllerMock) CheckTeamRole(username string, teamId string, requiredRole string) error {
+	args := m.Called(username, teamId, requiredRole)
+	return args.Error(0)
+}
+
+func newTeamTestRouter() (*mux.Router, *TeamControllerMock, *MazeControllerMock) {
+	teamController := &TeamControllerMock{}
+	mazeController := &MazeControllerMock{}
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "sessionid").Return("alice", nil)
+	router := mux.NewRouter()
+	NewTeamApi(teamController, mazeController, userController, newUnlimitedQuotaControllerMock()).Init(router)
+	return router, teamController, mazeController
+}
+
+func newTeamTestRequest(method string, url string, body any) *http.Request {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			panic(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	r := httptest.NewRequest(method, url, reader)
+	r.Header.Add("Authorization", "Bearer sessionid")
+	if body != nil {
+		r.Header.Add("Content-Type", "application/json")
+	}
+	return r
+}
+
+func Test_teamApiImpl_CreateTeam(t *testing.T) {
+	router, teamController, _ := newTeamTestRouter()
+	teamController.On("CreateTeam", "alice", "Puzzle Team").Return("8Wa", nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTeamTestRequest("POST", "/teams", &CreateTeamDao{Name: "Puzzle Team"}))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var got CreateTeamResponse
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, "8Wa", got.TeamId)
+}
+
+func Test_teamApiImpl_CreateTeamMaze(t *testing.T) {
+	tests := []struct {
+		name     string
+		roleErr  error
+		wantCode int
+	}{
+		{name: "editor creates maze", roleErr: nil, wantCode: http.StatusCreated},
+		{name: "viewer can not create mazes", roleErr: ErrTeamRoleRequired, wantCode: http.StatusForbidden},
+		{name: "non member does not see the team", roleErr: ErrTeamNotFound, wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, teamController, mazeController := newTeamTestRouter()
+			teamController.On("CheckTeamRole", "alice", "8Wa", TEAM_ROLE_EDITOR).Return(tt.roleErr)
+			mazeController.On("CreateTeamMaze", "alice", "8Wa", mock.Anything).Return("E42", nil)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newTeamTestRequest("POST", "/teams/8Wa/maze", &MazeApiDao{
+				Entrace:  "A1",
+				GridSize: "8x8",
+				Walls:    &[]string{"B2"},
+			}))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusCreated {
+				var got CreateTeamMazeResponse
+				assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+				assert.Equal(t, "E42", got.MazeId)
+				mazeController.AssertCalled(t, "CreateTeamMaze", "alice", "8Wa", mock.MatchedBy(func(maze *Maze) bool {
+					return maze.GridWidth == 8 && maze.IsWall(1, 1)
+				}))
+			} else {
+				mazeController.AssertNotCalled(t, "CreateTeamMaze", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func Test_teamApiImpl_GetTeamMazes(t *testing.T) {
+	router, teamController, mazeController := newTeamTestRouter()
+	teamController.On("CheckTeamRole", "alice", "8Wa", TEAM_ROLE_VIEWER).Return(nil)
+	maze := &Maze{Id: "E42", TeamId: "8Wa", EntranceX: 1, Stats: &MazeStats{Difficulty: 12.5}}
+	maze.InitWalls(8, 8)
+	mazeController.On("GetTeamMazes", "8Wa").Return([]*Maze{maze}, nil)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTeamTestRequest("GET", "/teams/8Wa/maze", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got MyMazesDao
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Equal(t, 1, len(got.Mazes))
+	assert.Equal(t, "E42", got.Mazes[0].Id)
+	assert.Equal(t, "B1", got.Mazes[0].Entrace)
+	assert.Equal(t, 12.5, *got.Mazes[0].Difficulty)
+	assert.Equal(t, &[]string{}, got.Mazes[0].Walls)
+
+	// The walls can be sent as bitset like in the own maze list
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTeamTestRequest("GET", "/teams/8Wa/maze?wallsEncoding=bitset-base64", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	got = MyMazesDao{}
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&got))
+	assert.Nil(t, got.Mazes[0].Walls)
+	assert.Equal(t, WALLS_ENCODING_BITSET, got.Mazes[0].WallsEncoding)
+	assert.NotEmpty(t, got.Mazes[0].WallsData)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTeamTestRequest("GET", "/teams/8Wa/maze?wallsEncoding=rle", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"strings"
+)
+
+var (
+	ErrTeamNotFound     = newCodedError(ERROR_CODE_TEAM_NOT_FOUND, "team not found")
+	ErrTeamRoleRequired = newCodedError(ERROR_CODE_TEAM_ROLE_REQUIRED, "the team role is not sufficient")
+	ErrTeamLastOwner    = newCodedError(ERROR_CODE_TEAM_LAST_OWNER, "a team must keep at least one owner")
+)
+
+type TeamController interface {
+	CreateTeam(username string, name string) (string, error)
+	GetUserTeams(username string) ([]Team, error)
+	GetTeam(username string, teamId string) (*Team, []TeamMember, error)
+	SetMember(username string, teamId string, member string, role string) error
+	RemoveMember(username string, teamId string, member string) error
+	CheckTeamRole(username string, teamId string, requiredRole string) error
+}
+
+func NewTeamController(teamRepository TeamRepository, userRepository UserRepository) TeamController {
+	return &teamControllerImpl{
+		teamRepository: teamRepository,
+		userRepository: userRepository,
+	}
+}
+
+type teamControllerImpl struct {
+	teamRepository TeamRepository
+	userRepository UserRepository
+}
+
+func (t *teamControllerImpl) CreateTeam(username string, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) < 3 || len(name) > 64 {
+		return "", newCodedError(ERROR_CODE_TEAM_INVALID_NAME, "the team name must be between 3 and 64 characters long").withField("name")
+	}
+	return t.teamRepository.Insert(name, username)
+}
+
+func (t *teamControllerImpl) GetUserTeams(username string) ([]Team, error) {
+	return t.teamRepository.SelectAllByUsername(username)
+}
+
+func (t *teamControllerImpl) GetTeam(username string, teamId string) (*Team, []TeamMember, error) {
+	err := t.CheckTeamRole(username, teamId, TEAM_ROLE_VIEWER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	team, err := t.teamRepository.SelectById(teamId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if team == nil {
+		return nil, nil, ErrTeamNotFound
+	}
+	members, err := t.teamRepository.SelectMembers(teamId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return team, members, nil
+}
+
+// SetMember adds the user to the team or changes the role of an existing member, only owners may do this.
+func (t *teamControllerImpl) SetMember(username string, teamId string, member string, role string) error {
+	if !isValidTeamRole(role) {
+		return newCodedError(ERROR_CODE_TEAM_INVALID_ROLE, "invalid team role").withField("role")
+	}
+	err := t.CheckTeamRole(username, teamId, TEAM_ROLE_OWNER)
+	if err != nil {
+		return err
+	}
+
+	account, err := t.userRepository.SelectAccount(member)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return ErrUserNotFound
+	}
+	if role != TEAM_ROLE_OWNER {
+		err = t.ensureOtherOwner(teamId, member)
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.teamRepository.UpsertMember(teamId, member, role)
+}
+
+// RemoveMember removes a member from the team, owners may remove anyone and every member may leave.
+func (t *teamControllerImpl) RemoveMember(username string, teamId string, member string) error {
+	requiredRole := TEAM_ROLE_OWNER
+	if username == member {
+		requiredRole = TEAM_ROLE_VIEWER
+	}
+	err := t.CheckTeamRole(username, teamId, requiredRole)
+	if err != nil {
+		return err
+	}
+
+	err = t.ensureOtherOwner(teamId, member)
+	if err != nil {
+		return err
+	}
+	return t.teamRepository.DeleteMember(teamId, member)
+}
+
+// CheckTeamRole returns ErrTeamNotFound for non members so that the existence of teams is not revealed.
+func (t *teamControllerImpl) CheckTeamRole(username string, teamId string, requiredRole string) error {
+	role, err := t.teamRepository.SelectMemberRole(teamId, username)
+	if err != nil {
+		return ErrTeamNotFound
+	}
+	if role == "" {
+		return ErrTeamNotFound
+	}
+	if !hasTeamRole(role, requiredRole) {
+		return ErrTeamRoleRequired
+	}
+	return nil
+}
+
+// ensureOtherOwner prevents that the last owner of a team is demoted or removed.
+func (t *teamControllerImpl) ensureOtherOwner(teamId string, member string) error {
+	members, err := t.teamRepository.SelectMembers(teamId)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Role == TEAM_ROLE_OWNER && m.Username != member {
+			return nil
+		}
+	}
+	for _, m := range members {
+		if m.Username == member && m.Role == TEAM_ROLE_OWNER {
+			return ErrTeamLastOwner
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type TeamRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *TeamRepositoryMock) Insert(name string, ownerUsername string) (string, error) {
+	args := m.Called(name, ownerUsername)
+	return args.String(0), args.Error(1)
+}
+
+func (m *TeamRepositoryMock) SelectById(teamId string) (*Team, error) {
+	args := m.Called(teamId)
+	return args.Get(0).(*Team), args.Error(1)
+}
+
+func (m *TeamRepositoryMock) SelectAllByUsername(username string) ([]Team, error) {
+	args := m.Called(username)
+	return args.Get(0).([]Team), args.Error(1)
+}
+
+func (m *TeamRepositoryMock) SelectMembers(teamId string) ([]TeamMember, error) {
+	args := m.Called(teamId)
+	return args.Get(0).([]TeamMember), args.Error(1)
+}
+
+func (m *TeamRepositoryMock) SelectMemberRole(teamId string, username string) (string, error) {
+	args := m.Called(teamId, username)
+	return args.String(0), args.Error(1)
+}
+
+func (m *TeamRepositoryMock) UpsertMember(teamId string, username string, role string) error {
+	args := m.Called(teamId, username, role)
+	return args.Error(0)
+}
+
+func (m *TeamRepositoryMock) DeleteMember(teamId string, username string) error {
+	args := m.Called(teamId, username)
+	return args.Error(0)
+}
+
+func newTeamRepositoryMock() *TeamRepositoryMock {
+	m := &TeamRepositoryMock{}
+	m.On("SelectMemberRole", "team", "alice").Return(TEAM_ROLE_OWNER, nil)
+	m.On("SelectMemberRole", "team", "bob").Return(TEAM_ROLE_EDITOR, nil)
+	m.On("SelectMemberRole", "team", mock.Anything).Return("", nil)
+	m.On("SelectMembers", "team").Return([]TeamMember{
+		{Username: "alice", Role: TEAM_ROLE_OWNER},
+		{Username: "bob", Role: TEAM_ROLE_EDITOR},
+	}, nil)
+	m.On("UpsertMember", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	m.On("DeleteMember", mock.Anything, mock.Anything).Return(nil)
+	return m
+}
+
+func Test_teamControllerImpl_CheckTeamRole(t *testing.T) {
+	c := &teamControllerImpl{
+		teamRepository: newTeamRepositoryMock(),
+	}
+
+	assert.Nil(t, c.CheckTeamRole("alice", "team", TEAM_ROLE_OWNER))
+	assert.Nil(t, c.CheckTeamRole("bob", "team", TEAM_ROLE_EDITOR))
+	assert.ErrorIs(t, c.CheckTeamRole("bob", "team", TEAM_ROLE_OWNER), ErrTeamRoleRequired)
+	assert.ErrorIs(t, c.CheckTeamRole("carol", "team", TEAM_ROLE_VIEWER), ErrTeamNotFound)
+}
+
+func Test_teamControllerImpl_CreateTeam(t *testing.T) {
+	teamRepository := &TeamRepositoryMock{}
+	teamRepository.On("Insert", mock.Anything, mock.Anything).Return("team", nil)
+	c := &teamControllerImpl{
+		teamRepository: teamRepository,
+	}
+
+	got, err := c.CreateTeam("alice", "  Puzzle Team ")
+	assert.Nil(t, err)
+	assert.Equal(t, "team", got)
+	teamRepository.AssertCalled(t, "Insert", "Puzzle Team", "alice")
+
+	_, err = c.CreateTeam("alice", "ab")
+	assert.NotNil(t, err)
+}
+
+func Test_teamControllerImpl_SetMember(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		member   string
+		role     string
+		wantErr  error
+	}{
+		{name: "owner adds member", username: "alice", member: "carol", role: TEAM_ROLE_VIEWER},
+		{name: "editor can not add members", username: "bob", member: "carol", role: TEAM_ROLE_VIEWER, wantErr: ErrTeamRoleRequired},
+		{name: "non member can not add members", username: "carol", member: "dave", role: TEAM_ROLE_VIEWER, wantErr: ErrTeamNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teamRepository := newTeamRepositoryMock()
+			userRepository := &UserRepositoryMock{}
+			userRepository.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: tt.member, Role: ROLE_USER}, nil)
+			c := &teamControllerImpl{
+				teamRepository: teamRepository,
+				userRepository: userRepository,
+			}
+
+			err := c.SetMember(tt.username, "team", tt.member, tt.role)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				teamRepository.AssertNotCalled(t, "UpsertMember", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.Nil(t, err)
+			teamRepository.AssertCalled(t, "UpsertMember", "team", tt.member, tt.role)
+		})
+	}
+}
+
+func Test_teamControllerImpl_LastOwner(t *testing.T) {
+	teamRepository := newTeamRepositoryMock()
+	userRepository := &UserRepositoryMock{}
+	userRepository.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: "alice", Role: ROLE_USER}, nil)
+	c := &teamControllerImpl{
+		teamRepository: teamRepository,
+		userRepository: userRepository,
+	}
+
+	assert.NotNil(t, c.SetMember("alice", "team", "alice", TEAM_ROLE_EDITOR))
+	assert.NotNil(t, c.RemoveMember("alice", "team", "alice"))
+	teamRepository.AssertNotCalled(t, "UpsertMember", mock.Anything, mock.Anything, mock.Anything)
+	teamRepository.AssertNotCalled(t, "DeleteMember", mock.Anything, mock.Anything)
+
+	// Other members can leave on their own
+	assert.Nil(t, c.RemoveMember("bob", "team", "bob"))
+	teamRepository.AssertCalled(t, "DeleteMember", "team", "bob")
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/speps/go-hashids"
+)
+
+const TEAM_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS teams (id INTEGER PRIMARY KEY, name VARCHAR(256) NOT NULL)"
+const TEAM_MEMBER_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS team_members (team_id INTEGER NOT NULL, username VARCHAR(256) NOT NULL, role VARCHAR(16) NOT NULL, PRIMARY KEY (team_id, username))"
+
+type Team struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role,omitempty"`
+}
+
+type TeamMember struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+type TeamRepository interface {
+	Insert(name string, ownerUsername string) (string, error)
+	SelectById(teamId string) (*Team, error)
+	SelectAllByUsername(username string) ([]Team, error)
+	SelectMembers(teamId string) ([]TeamMember, error)
+	SelectMemberRole(teamId string, username string) (string, error)
+	UpsertMember(teamId string, username string, role string) error
+	DeleteMember(teamId string, username string) error
+}
+
+func NewTeamRepository(db *sql.DB, hashid *hashids.HashID) TeamRepository {
+	_, err := db.Exec(TEAM_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(TEAM_MEMBER_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return &teamRepositoryImpl{
+		db:     db,
+		hashid: hashid,
+	}
+}
+
+type teamRepositoryImpl struct {
+	db     *sql.DB
+	hashid *hashids.HashID
+}
+
+// Insert creates the team with the user as its first owner.
+func (t *teamRepositoryImpl) Insert(name string, ownerUsername string) (string, error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO teams (name) VALUES (?)", name)
+	if err != nil {
+		return "", err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec("INSERT INTO team_members (team_id, username, role) VALUES (?, ?, ?)", id, ownerUsername, TEAM_ROLE_OWNER)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return t.hashid.EncodeInt64([]int64{id})
+}
+
+func (t *teamRepositoryImpl) SelectById(teamId string) (*Team, error) {
+	decoded, err := t.hashid.DecodeInt64WithError(teamId)
+	if err != nil {
+		return nil, err
+	}
+
+	team := &Team{Id: teamId}
+	err = t.db.QueryRow("SELECT name FROM teams WHERE id = ?", decoded[0]).Scan(&team.Name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return team, nil
+}
+
+// SelectAllByUsername returns the teams the user is a member of together with the role of the user.
+func (t *teamRepositoryImpl) SelectAllByUsername(username string) ([]Team, error) {
+	rows, err := t.db.Query("SELECT teams.id, teams.name, team_members.role FROM teams JOIN team_members ON team_members.team_id = teams.id WHERE team_members.username = ? ORDER BY teams.id", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []Team{}
+	for rows.Next() {
+		var team Team
+		var id int64
+		err = rows.Scan(&id, &team.Name, &team.Role)
+		if err != nil {
+			return nil, err
+		}
+		team.Id, err = t.hashid.EncodeInt64([]int64{id})
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	return teams, nil
+}
+
+func (t *teamRepositoryImpl) SelectMembers(teamId string) ([]TeamMember, error) {
+	decoded, err := t.hashid.DecodeInt64WithError(teamId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := t.db.Query("SELECT username, role FROM team_members WHERE team_id = ? ORDER BY username", decoded[0])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []TeamMember{}
+	for rows.Next() {
+		var member TeamMember
+		err = rows.Scan(&member.Username, &member.Role)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// SelectMemberRole returns an empty role if the user is not a member of the team.
+func (t *teamRepositoryImpl) SelectMemberRole(teamId string, username string) (string, error) {
+	decoded, err := t.hashid.DecodeInt64WithError(teamId)
+	if err != nil {
+		return "", err
+	}
+
+	var role string
+	err = t.db.QueryRow("SELECT role FROM team_members WHERE team_id = ? AND username = ?", decoded[0], username).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return role, nil
+}
+
+func (t *teamRepositoryImpl) UpsertMember(teamId string, username string, role string) error {
+	decoded, err := t.hashid.DecodeInt64WithError(teamId)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.db.Exec("INSERT OR REPLACE INTO team_members (team_id, username, role) VALUES (?, ?, ?)", decoded[0], username, role)
+	return err
+}
+
+func (t *teamRepositoryImpl) DeleteMember(teamId string, username string) error {
+	decoded, err := t.hashid.DecodeInt64WithError(teamId)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.db.Exec("DELETE FROM team_members WHERE team_id = ? AND username = ?", decoded[0], username)
+	return err
+}
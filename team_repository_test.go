@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTeamTestDb() *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(TEAM_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(TEAM_MEMBER_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func Test_teamRepositoryImpl(t *testing.T) {
+	r := &teamRepositoryImpl{
+		db:     newTeamTestDb(),
+		hashid: newHashId(),
+	}
+
+	teamId, err := r.Insert("Puzzle Team", "alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "8Wa", teamId)
+	otherTeamId, err := r.Insert("Other Team", "bob")
+	assert.Nil(t, err)
+
+	team, err := r.SelectById(teamId)
+	assert.Nil(t, err)
+	assert.Equal(t, &Team{Id: teamId, Name: "Puzzle Team"}, team)
+
+	assert.Nil(t, r.UpsertMember(teamId, "bob", TEAM_ROLE_VIEWER))
+	assert.Nil(t, r.UpsertMember(teamId, "bob", TEAM_ROLE_EDITOR))
+
+	members, err := r.SelectMembers(teamId)
+	assert.Nil(t, err)
+	assert.Equal(t, []TeamMember{
+		{Username: "alice", Role: TEAM_ROLE_OWNER},
+		{Username: "bob", Role: TEAM_ROLE_EDITOR},
+	}, members)
+
+	teams, err := r.SelectAllByUsername("bob")
+	assert.Nil(t, err)
+	assert.Equal(t, []Team{
+		{Id: teamId, Name: "Puzzle Team", Role: TEAM_ROLE_EDITOR},
+		{Id: otherTeamId, Name: "Other Team", Role: TEAM_ROLE_OWNER},
+	}, teams)
+
+	role, err := r.SelectMemberRole(teamId, "carol")
+	assert.Nil(t, err)
+	assert.Equal(t, "", role)
+
+	assert.Nil(t, r.DeleteMember(teamId, "bob"))
+	role, err = r.SelectMemberRole(teamId, "bob")
+	assert.Nil(t, err)
+	assert.Equal(t, "", role)
+
+	unknownTeamId, err := newHashId().EncodeInt64([]int64{99})
+	assert.Nil(t, err)
+	team, err = r.SelectById(unknownTeamId)
+	assert.Nil(t, err)
+	assert.Nil(t, team)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TOTP_ISSUER           = "MazeApi"
+	TOTP_PERIOD           = 30
+	TOTP_DIGITS           = 6
+	TOTP_SKEW             = 1
+	TOTP_SECRET_LENGTH    = 20
+	RECOVERY_CODE_COUNT   = 10
+	RECOVERY_CODE_LENGTH  = 10
+	RECOVERY_CODE_LETTERS = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var totpSecretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func generateTotpSecret() (string, error) {
+	secret, err := generateRandomBytes(TOTP_SECRET_LENGTH)
+	if err != nil {
+		return "", err
+	}
+	return totpSecretEncoding.EncodeToString(secret), nil
+}
+
+func totpUri(issuer string, accountName string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTP_DIGITS))
+	query.Set("period", fmt.Sprint(TOTP_PERIOD))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+accountName) + "?" + query.Encode()
+}
+
+func totpCounter(t time.Time) uint64 {
+	return uint64(t.Unix()) / TOTP_PERIOD
+}
+
+// totpCode computes the code for the counter as described in RFC 4226 and RFC 6238.
+func totpCode(secret []byte, counter uint64, digits int) string {
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulo)
+}
+
+// verifyTotp checks the code against the time steps around now and returns the matching counter.
+// Codes with a counter at or below lastCounter were already used and are rejected.
+func verifyTotp(secret string, code string, now time.Time, lastCounter uint64) (bool, uint64, error) {
+	key, err := totpSecretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return false, 0, err
+	}
+
+	current := totpCounter(now)
+	for counter := current - TOTP_SKEW; counter <= current+TOTP_SKEW; counter++ {
+		if counter <= lastCounter {
+			continue
+		}
+		expected := totpCode(key, counter, TOTP_DIGITS)
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return true, counter, nil
+		}
+	}
+	return false, 0, nil
+}
+
+func generateRecoveryCodes() ([]string, error) {
+	var codes []string
+	for i := 0; i < RECOVERY_CODE_COUNT; i++ {
+		randomBytes, err := generateRandomBytes(RECOVERY_CODE_LENGTH)
+		if err != nil {
+			return nil, err
+		}
+		code := make([]byte, RECOVERY_CODE_LENGTH)
+		for j, b := range randomBytes {
+			code[j] = RECOVERY_CODE_LETTERS[int(b)%len(RECOVERY_CODE_LETTERS)]
+		}
+		codes = append(codes, string(code[:RECOVERY_CODE_LENGTH/2])+"-"+string(code[RECOVERY_CODE_LENGTH/2:]))
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode uses a plain sha256 since recovery codes are random and not guessable like passwords.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(code)), "-", "")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_totpCode(t *testing.T) {
+	// Test vectors of RFC 6238 for SHA1
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tt := range tests {
+		got := totpCode(secret, totpCounter(time.Unix(tt.unix, 0)), 8)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func Test_verifyTotp(t *testing.T) {
+	secret := totpSecretEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name        string
+		code        string
+		lastCounter uint64
+		want        bool
+	}{
+		{name: "current code", code: "081804", want: true},
+		{name: "previous code within skew", code: totpCode([]byte("12345678901234567890"), totpCounter(now)-1, 6), want: true},
+		{name: "code too old", code: totpCode([]byte("12345678901234567890"), totpCounter(now)-2, 6), want: false},
+		{name: "code already used", code: "081804", lastCounter: totpCounter(now), want: false},
+		{name: "wrong code", code: "123456", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counter, err := verifyTotp(secret, tt.code, now, tt.lastCounter)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+			if got {
+				assert.Greater(t, counter, tt.lastCounter)
+			}
+		})
+	}
+}
+
+func Test_totpUri(t *testing.T) {
+	got := totpUri("MazeApi", "alice", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, "otpauth://totp/MazeApi:alice?algorithm=SHA1&digits=6&issuer=MazeApi&period=30&secret=JBSWY3DPEHPK3PXP", got)
+}
+
+func Test_generateRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes()
+	assert.Nil(t, err)
+	assert.Equal(t, RECOVERY_CODE_COUNT, len(codes))
+	for _, code := range codes {
+		assert.Regexp(t, "^[a-z0-9]{5}-[a-z0-9]{5}$", code)
+		assert.Equal(t, hashRecoveryCode(code), hashRecoveryCode(strings.ToUpper(strings.ReplaceAll(code, "-", ""))))
+	}
+}
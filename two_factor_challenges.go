@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	TWO_FACTOR_CHALLENGE_LIFETIME     = 5 * time.Minute
+	TWO_FACTOR_CHALLENGE_MAX_FAILURES = 5
+)
+
+type twoFactorChallenge struct {
+	username  string
+	expiresAt time.Time
+	failures  int
+}
+
+// twoFactorChallenges keeps the logins that passed the password check and wait for the second factor.
+// The zero value is ready to use.
+type twoFactorChallenges struct {
+	mutex      sync.Mutex
+	challenges map[string]*twoFactorChallenge
+}
+
+// Create starts a challenge that expires after TWO_FACTOR_CHALLENGE_LIFETIME, expired challenges that were never
+// completed are removed.
+func (c *twoFactorChallenges) Create(token string, username string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.challenges == nil {
+		c.challenges = map[string]*twoFactorChallenge{}
+	}
+	for key, challenge := range c.challenges {
+		if !now.Before(challenge.expiresAt) {
+			delete(c.challenges, key)
+		}
+	}
+	c.challenges[token] = &twoFactorChallenge{
+		username:  username,
+		expiresAt: now.Add(TWO_FACTOR_CHALLENGE_LIFETIME),
+	}
+}
+
+// Get returns the username of the challenge, expired challenges are removed.
+func (c *twoFactorChallenges) Get(token string, now time.Time) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	challenge, ok := c.challenges[token]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(challenge.expiresAt) {
+		delete(c.challenges, token)
+		return "", false
+	}
+	return challenge.username, true
+}
+
+// RegisterFailure counts a wrong code and discards the challenge once too many codes were wrong.
+func (c *twoFactorChallenges) RegisterFailure(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	challenge, ok := c.challenges[token]
+	if !ok {
+		return
+	}
+	challenge.failures++
+	if challenge.failures >= TWO_FACTOR_CHALLENGE_MAX_FAILURES {
+		delete(c.challenges, token)
+	}
+}
+
+func (c *twoFactorChallenges) Remove(token string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.challenges, token)
+}
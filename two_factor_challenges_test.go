@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_twoFactorChallenges(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	var c twoFactorChallenges
+
+	_, ok := c.Get("token", now)
+	assert.False(t, ok)
+
+	c.Create("token", "abc", now)
+	username, ok := c.Get("token", now)
+	assert.True(t, ok)
+	assert.Equal(t, "abc", username)
+
+	// Too many wrong codes discard the challenge
+	for i := 0; i < TWO_FACTOR_CHALLENGE_MAX_FAILURES; i++ {
+		_, ok = c.Get("token", now)
+		assert.True(t, ok)
+		c.RegisterFailure("token")
+	}
+	_, ok = c.Get("token", now)
+	assert.False(t, ok)
+
+	// Expired challenges are gone
+	c.Create("token", "abc", now)
+	_, ok = c.Get("token", now.Add(TWO_FACTOR_CHALLENGE_LIFETIME))
+	assert.False(t, ok)
+
+	// Challenges that are never completed are swept by the next one
+	c.Create("abandoned", "abc", now)
+	c.Create("other", "def", now.Add(TWO_FACTOR_CHALLENGE_LIFETIME))
+	assert.Len(t, c.challenges, 1)
+	_, ok = c.Get("other", now.Add(TWO_FACTOR_CHALLENGE_LIFETIME))
+	assert.True(t, ok)
+}
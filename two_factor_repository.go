@@ -0,0 +1,107 @@
+package main
+
+import "database/sql"
+
+const TWO_FACTOR_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS two_factor (username VARCHAR(256) NOT NULL PRIMARY KEY, secret VARCHAR(64) NOT NULL, enabled INTEGER NOT NULL DEFAULT 0, last_counter INTEGER NOT NULL DEFAULT 0)"
+const RECOVERY_CODE_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS recovery_codes (username VARCHAR(256) NOT NULL, code_hash CHAR(64) NOT NULL, used INTEGER NOT NULL DEFAULT 0, PRIMARY KEY (username, code_hash))"
+
+type TwoFactor struct {
+	Secret      string
+	Enabled     bool
+	LastCounter uint64
+}
+
+type TwoFactorRepository interface {
+	Upsert(username string, secret string, recoveryCodeHashes []string) error
+	SelectByUsername(username string) (*TwoFactor, error)
+	Enable(username string) error
+	UpdateLastCounter(username string, counter uint64) (bool, error)
+	UseRecoveryCode(username string, codeHash string) (bool, error)
+}
+
+func NewTwoFactorRepository(db *sql.DB) TwoFactorRepository {
+	_, err := db.Exec(TWO_FACTOR_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(RECOVERY_CODE_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return &twoFactorRepositoryImpl{
+		db: db,
+	}
+}
+
+type twoFactorRepositoryImpl struct {
+	db *sql.DB
+}
+
+// Upsert stores a new, not yet enabled secret and replaces all recovery codes of the user.
+func (t *twoFactorRepositoryImpl) Upsert(username string, secret string, recoveryCodeHashes []string) error {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT OR REPLACE INTO two_factor (username, secret, enabled, last_counter) VALUES (?, ?, 0, 0)", username, secret)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.Exec("INSERT INTO recovery_codes (username, code_hash) VALUES (?, ?)", username, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (t *twoFactorRepositoryImpl) SelectByUsername(username string) (*TwoFactor, error) {
+	twoFactor := &TwoFactor{}
+	err := t.db.QueryRow("SELECT secret, enabled, last_counter FROM two_factor WHERE username = ?", username).Scan(&twoFactor.Secret, &twoFactor.Enabled, &twoFactor.LastCounter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return twoFactor, nil
+}
+
+func (t *twoFactorRepositoryImpl) Enable(username string) error {
+	_, err := t.db.Exec("UPDATE two_factor SET enabled = 1 WHERE username = ?", username)
+	return err
+}
+
+// UpdateLastCounter moves the counter forward and reports whether it was lower, so every code is accepted only once.
+func (t *twoFactorRepositoryImpl) UpdateLastCounter(username string, counter uint64) (bool, error) {
+	result, err := t.db.Exec("UPDATE two_factor SET last_counter = ? WHERE username = ? AND last_counter < ?", counter, username, counter)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// UseRecoveryCode marks the code as used and reports whether it was a valid unused code.
+func (t *twoFactorRepositoryImpl) UseRecoveryCode(username string, codeHash string) (bool, error) {
+	result, err := t.db.Exec("UPDATE recovery_codes SET used = 1 WHERE username = ? AND code_hash = ? AND used = 0", username, codeHash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTwoFactorTestDb() *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(TWO_FACTOR_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(RECOVERY_CODE_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func Test_twoFactorRepositoryImpl(t *testing.T) {
+	r := &twoFactorRepositoryImpl{
+		db: newTwoFactorTestDb(),
+	}
+
+	got, err := r.SelectByUsername("abc")
+	assert.Nil(t, err)
+	assert.Nil(t, got)
+
+	assert.Nil(t, r.Upsert("abc", "SECRET1", []string{"hash1", "hash2"}))
+	assert.Nil(t, r.Upsert("abc", "SECRET2", []string{"hash3"}))
+	got, err = r.SelectByUsername("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, &TwoFactor{Secret: "SECRET2"}, got)
+
+	assert.Nil(t, r.Enable("abc"))
+	for _, tt := range []struct {
+		counter     uint64
+		wantUpdated bool
+	}{{10, true}, {10, false}, {5, false}} {
+		updated, err := r.UpdateLastCounter("abc", tt.counter)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.wantUpdated, updated, tt.counter)
+	}
+	got, err = r.SelectByUsername("abc")
+	assert.Nil(t, err)
+	assert.Equal(t, &TwoFactor{Secret: "SECRET2", Enabled: true, LastCounter: 10}, got)
+
+	// Codes of the first enrollment were replaced and every code works only once
+	used, err := r.UseRecoveryCode("abc", "hash1")
+	assert.Nil(t, err)
+	assert.False(t, used)
+	used, err = r.UseRecoveryCode("abc", "hash3")
+	assert.Nil(t, err)
+	assert.True(t, used)
+	used, err = r.UseRecoveryCode("abc", "hash3")
+	assert.Nil(t, err)
+	assert.False(t, used)
+}
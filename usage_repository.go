@@ -0,0 +1,56 @@
+package main
+
+import "database/sql"
+
+const USAGE_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS usage_counters (user_id VARCHAR(256) NOT NULL, day CHAR(10) NOT NULL, action VARCHAR(32) NOT NULL, count INTEGER NOT NULL, PRIMARY KEY (user_id, day, action))"
+
+const (
+	USAGE_ACTION_GENERATE = "generate"
+	USAGE_ACTION_SOLVE    = "solve"
+)
+
+// UsageRepository counts calls per user, day (YYYY-MM-DD in UTC) and action.
+type UsageRepository interface {
+	Increment(userId string, day string, action string, limit uint64) (bool, error)
+	SelectCount(userId string, day string, action string) (uint64, error)
+}
+
+func NewUsageRepository(db *sql.DB) UsageRepository {
+	_, err := db.Exec(USAGE_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return &usageRepositoryImpl{
+		db: db,
+	}
+}
+
+type usageRepositoryImpl struct {
+	db *sql.DB
+}
+
+// Increment adds one call unless the count for the day reached the limit, a limit of 0 counts every call. It reports
+// whether the call was counted, the check and the update are a single statement.
+func (u *usageRepositoryImpl) Increment(userId string, day string, action string, limit uint64) (bool, error) {
+	result, err := u.db.Exec("INSERT INTO usage_counters (user_id, day, action, count) VALUES (?, ?, ?, 1) ON CONFLICT (user_id, day, action) DO UPDATE SET count = count + 1 WHERE ? = 0 OR count < ?", userId, day, action, limit, limit)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (u *usageRepositoryImpl) SelectCount(userId string, day string, action string) (uint64, error) {
+	var count uint64
+	err := u.db.QueryRow("SELECT count FROM usage_counters WHERE user_id = ? AND day = ? AND action = ?", userId, day, action).Scan(&count)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return count, nil
+}
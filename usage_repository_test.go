@@ -0,0 +1,61 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUsageTestDb() *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	_, err = db.Exec(USAGE_REPO_CREATE_TABLE)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+func Test_usageRepositoryImpl(t *testing.T) {
+	u := &usageRepositoryImpl{
+		db: newUsageTestDb(),
+	}
+
+	count, err := u.SelectCount("abc", "2024-03-01", USAGE_ACTION_GENERATE)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), count)
+
+	for i := uint64(1); i <= 3; i++ {
+		counted, err := u.Increment("abc", "2024-03-01", USAGE_ACTION_GENERATE, 0)
+		assert.Nil(t, err)
+		assert.True(t, counted)
+		count, err = u.SelectCount("abc", "2024-03-01", USAGE_ACTION_GENERATE)
+		assert.Nil(t, err)
+		assert.Equal(t, i, count)
+	}
+
+	// The limit stops the counter
+	counted, err := u.Increment("abc", "2024-03-01", USAGE_ACTION_GENERATE, 4)
+	assert.Nil(t, err)
+	assert.True(t, counted)
+	counted, err = u.Increment("abc", "2024-03-01", USAGE_ACTION_GENERATE, 4)
+	assert.Nil(t, err)
+	assert.False(t, counted)
+	count, err = u.SelectCount("abc", "2024-03-01", USAGE_ACTION_GENERATE)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(4), count)
+
+	// Counters are separate per action, day and user
+	counted, err = u.Increment("abc", "2024-03-01", USAGE_ACTION_SOLVE, 1)
+	assert.Nil(t, err)
+	assert.True(t, counted)
+	counted, err = u.Increment("abc", "2024-03-02", USAGE_ACTION_GENERATE, 1)
+	assert.Nil(t, err)
+	assert.True(t, counted)
+	count, err = u.SelectCount("other", "2024-03-01", USAGE_ACTION_GENERATE)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), count)
+}
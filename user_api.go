@@ -2,70 +2,298 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// USER_MAX_BODY_SIZE limits the JSON bodies of the user endpoints, they only carry credentials and codes
+const USER_MAX_BODY_SIZE = 16 << 10
+
 type User struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
-func NewUserApi(userController UserController) ApiEndpoint {
+type CreateUserResponse struct {
+	UserId string `json:"userId"`
+}
+
+type TwoFactorLoginDao struct {
+	TwoFactorToken string `json:"twoFactorToken"`
+	Code           string `json:"code"`
+}
+
+type TwoFactorCodeDao struct {
+	Code string `json:"code"`
+}
+
+type ChangePasswordDao struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type UserExportDao struct {
+	Username   string          `json:"username"`
+	ExportedAt time.Time       `json:"exportedAt"`
+	Mazes      []MazeWithIdDao `json:"mazes"`
+}
+
+func NewUserApi(userController UserController, mazeController MazeController, quotaController QuotaController) ApiEndpoint {
 	return &userApiImpl{
-		userController: userController,
+		userController:  userController,
+		mazeController:  mazeController,
+		quotaController: quotaController,
 	}
 }
 
 type userApiImpl struct {
-	userController UserController
+	userController  UserController
+	mazeController  MazeController
+	quotaController QuotaController
 }
 
 func (u *userApiImpl) Init(router *mux.Router) {
 	router.HandleFunc("/user", u.CreateUser).Methods("POST").Headers("Content-Type", "application/json")
+	router.HandleFunc("/user", u.DeleteUser).Methods("DELETE")
+	router.HandleFunc("/user/export", u.ExportUser).Methods("GET")
+	router.HandleFunc("/user/usage", u.GetUsage).Methods("GET")
+	router.HandleFunc("/user/password", u.ChangePassword).Methods("POST").Headers("Content-Type", "application/json")
+	router.HandleFunc("/user/2fa", u.EnrollTwoFactor).Methods("POST")
+	router.HandleFunc("/user/2fa/activate", u.ActivateTwoFactor).Methods("POST").Headers("Content-Type", "application/json")
 	router.HandleFunc("/login", u.Login).Methods("POST").Headers("Content-Type", "application/json")
+	router.HandleFunc("/login/2fa", u.LoginTwoFactor).Methods("POST").Headers("Content-Type", "application/json")
 }
 
 func (u *userApiImpl) CreateUser(w http.ResponseWriter, r *http.Request) {
 	// Read request
 	var user User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, USER_MAX_BODY_SIZE)).Decode(&user)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeInvalidJson(w, err)
 		return
 	}
 
 	// Process request
 	userId, err := u.userController.CreateUser(user.Username, user.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	// Write response
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte(fmt.Sprintf(`{"userId": %s}`, userId)))
+	writeJson(w, http.StatusCreated, CreateUserResponse{UserId: userId})
 }
 
 func (u *userApiImpl) Login(w http.ResponseWriter, r *http.Request) {
 	// Read request
 	var user User
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, USER_MAX_BODY_SIZE)).Decode(&user)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+
+	// Process request
+	result, err := u.userController.Login(user.Username, user.Password, clientIp(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	writeJson(w, http.StatusOK, result)
+}
+
+func (u *userApiImpl) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Read request
+	var login TwoFactorLoginDao
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, USER_MAX_BODY_SIZE)).Decode(&login)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+
+	// Process request
+	result, err := u.userController.VerifyTwoFactor(login.TwoFactorToken, login.Code, clientIp(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	writeJson(w, http.StatusOK, result)
+}
+
+func (u *userApiImpl) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, u.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	enrollment, err := u.userController.EnrollTwoFactor(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusCreated, enrollment)
+}
+
+func (u *userApiImpl) ActivateTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, u.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	var code TwoFactorCodeDao
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, USER_MAX_BODY_SIZE)).Decode(&code)
+	if err != nil {
+		writeInvalidJson(w, err)
+		return
+	}
+
+	// Process request
+	err = u.userController.ActivateTwoFactor(userId, code.Code)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *userApiImpl) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	sessionId, err := getSessionId(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	userId, err := getUserId(r, u.userController)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+
+	// Read request
+	var changePassword ChangePasswordDao
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, USER_MAX_BODY_SIZE)).Decode(&changePassword)
+	if err != nil {
+		writeInvalidJson(w, err)
 		return
 	}
 
 	// Process request
-	sessionId, err := u.userController.Login(user.Username, user.Password)
+	err = u.userController.ChangePassword(userId, sessionId, changePassword.CurrentPassword, changePassword.NewPassword)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// Write response
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *userApiImpl) ExportUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, u.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	export, err := u.exportUser(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, export)
+}
+
+func (u *userApiImpl) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, u.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	usage, err := u.quotaController.GetUsage(userId)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJson(w, http.StatusOK, usage)
+}
+
+// DeleteUser deletes the account with all its data, with ?export=true the data is returned in the response.
+func (u *userApiImpl) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := getUserId(r, u.userController)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	exportParam := orDefault(r.URL.Query().Get("export"), "false")
+	if exportParam != "true" && exportParam != "false" {
+		writeInvalidParameter(w, "export", "the export parameter must be either 'true' or 'false'")
+		return
+	}
+
+	// Export has to happen before the data is gone
+	var export *UserExportDao
+	if exportParam == "true" {
+		export, err = u.exportUser(userId)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	err = u.userController.DeleteUser(userId)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 
 	// Write response
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(fmt.Sprintf(`{"sessionId": %s}`, sessionId)))
+	if export == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJson(w, http.StatusOK, export)
+}
+
+func (u *userApiImpl) exportUser(userId string) (*UserExportDao, error) {
+	mazes, err := u.mazeController.GetUserMazes(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &UserExportDao{
+		Username:   userId,
+		ExportedAt: time.Now().UTC(),
+		Mazes:      []MazeWithIdDao{},
+	}
+	for _, maze := range mazes {
+		export.Mazes = append(export.Mazes, MazeWithIdDao{
+			Id:         maze.Id,
+			MazeApiDao: *toMazeApiDao(maze),
+		})
+	}
+	return export, nil
+}
+
+func clientIp(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
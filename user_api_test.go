@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -19,9 +22,24 @@ func (m *UserControllerMock) CreateUser(username string, password string) (strin
 	return args.String(0), args.Error(1)
 }
 
-func (m *UserControllerMock) Login(username string, password string) (string, error) {
-	args := m.Called(username, password)
-	return args.String(0), args.Error(1)
+func (m *UserControllerMock) Login(username string, password string, ip string) (*LoginResult, error) {
+	args := m.Called(username, password, ip)
+	return args.Get(0).(*LoginResult), args.Error(1)
+}
+
+func (m *UserControllerMock) VerifyTwoFactor(twoFactorToken string, code string, ip string) (*LoginResult, error) {
+	args := m.Called(twoFactorToken, code, ip)
+	return args.Get(0).(*LoginResult), args.Error(1)
+}
+
+func (m *UserControllerMock) EnrollTwoFactor(username string) (*TwoFactorEnrollment, error) {
+	args := m.Called(username)
+	return args.Get(0).(*TwoFactorEnrollment), args.Error(1)
+}
+
+func (m *UserControllerMock) ActivateTwoFactor(username string, code string) error {
+	args := m.Called(username, code)
+	return args.Error(0)
 }
 
 func (m *UserControllerMock) GetUserForSession(sessionId string) (string, error) {
@@ -29,6 +47,41 @@ func (m *UserControllerMock) GetUserForSession(sessionId string) (string, error)
 	return args.String(0), args.Error(1)
 }
 
+func (m *UserControllerMock) ChangePassword(username string, sessionId string, currentPassword string, newPassword string) error {
+	args := m.Called(username, sessionId, currentPassword, newPassword)
+	return args.Error(0)
+}
+
+func (m *UserControllerMock) DeleteUser(username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+
+func (m *UserControllerMock) GetAccount(username string) (*UserAccount, error) {
+	args := m.Called(username)
+	return args.Get(0).(*UserAccount), args.Error(1)
+}
+
+func (m *UserControllerMock) ListAccounts() ([]UserAccount, error) {
+	args := m.Called()
+	return args.Get(0).([]UserAccount), args.Error(1)
+}
+
+func (m *UserControllerMock) SetRole(actor string, username string, role string) error {
+	args := m.Called(actor, username, role)
+	return args.Error(0)
+}
+
+func (m *UserControllerMock) SetDisabled(actor string, username string, disabled bool) error {
+	args := m.Called(actor, username, disabled)
+	return args.Error(0)
+}
+
+func (m *UserControllerMock) ForceLogout(actor string, username string) error {
+	args := m.Called(actor, username)
+	return args.Error(0)
+}
+
 func Test_userApiImpl_CreateUser(t *testing.T) {
 	body, err := json.Marshal(&User{
 		Username: "abc",
@@ -68,6 +121,20 @@ func Test_userApiImpl_CreateUser(t *testing.T) {
 				m.AssertCalled(t, "CreateUser", mock.Anything, mock.Anything)
 			},
 		},
+		{
+			name: "Body too large",
+			fields: fields{
+				userController: &UserControllerMock{},
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("POST", "/user", strings.NewReader(`{"username":"abc","password":"`+strings.Repeat("x", USER_MAX_BODY_SIZE)+`"}`)),
+			},
+			verify: func(t *testing.T, f *fields) {
+				m := f.userController.(*UserControllerMock)
+				m.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -83,6 +150,13 @@ func Test_userApiImpl_CreateUser(t *testing.T) {
 }
 
 func Test_userApiImpl_Login(t *testing.T) {
+	body, err := json.Marshal(&User{
+		Username: "abc",
+		Password: "abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 	type fields struct {
 		userController UserController
 	}
@@ -91,27 +165,47 @@ func Test_userApiImpl_Login(t *testing.T) {
 		r *http.Request
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		args   args
-		verify func(t *testing.T, f *fields)
+		name           string
+		fields         fields
+		args           args
+		verify         func(t *testing.T, f *fields)
+		verifyResponse func(t *testing.T, w *httptest.ResponseRecorder)
 	}{
 		{
 			name: "Login",
 			fields: fields{
 				userController: func() UserController {
 					m := &UserControllerMock{}
-					m.On("Login", mock.Anything, mock.Anything).Return("sessionId", nil)
+					m.On("Login", mock.Anything, mock.Anything, mock.Anything).Return(&LoginResult{SessionId: "sessionId"}, nil)
 					return m
 				}(),
 			},
 			args: args{
 				w: httptest.NewRecorder(),
-				r: httptest.NewRequest("POST", "/login", nil),
+				r: httptest.NewRequest("POST", "/login", bytes.NewReader(body)),
 			},
 			verify: func(t *testing.T, f *fields) {
 				m := f.userController.(*UserControllerMock)
-				m.AssertCalled(t, "Login", mock.Anything, mock.Anything)
+				m.AssertCalled(t, "Login", mock.Anything, mock.Anything, "192.0.2.1")
+			},
+		},
+		{
+			name: "Login throttled",
+			fields: fields{
+				userController: func() UserController {
+					m := &UserControllerMock{}
+					m.On("Login", mock.Anything, mock.Anything, mock.Anything).Return((*LoginResult)(nil), &TooManyLoginAttemptsError{RetryAfter: 1500 * time.Millisecond})
+					return m
+				}(),
+			},
+			args: args{
+				w: httptest.NewRecorder(),
+				r: httptest.NewRequest("POST", "/login", bytes.NewReader(body)),
+			},
+			verify: func(t *testing.T, f *fields) {},
+			verifyResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusTooManyRequests, w.Code)
+				assert.Equal(t, "2", w.Header().Get("Retry-After"))
 			},
 		},
 	}
@@ -121,6 +215,149 @@ func Test_userApiImpl_Login(t *testing.T) {
 				userController: tt.fields.userController,
 			}
 			u.Login(tt.args.w, tt.args.r)
+			if tt.verify != nil {
+				tt.verify(t, &tt.fields)
+			}
+			if tt.verifyResponse != nil {
+				tt.verifyResponse(t, tt.args.w.(*httptest.ResponseRecorder))
+			}
 		})
 	}
 }
+
+func Test_userApiImpl_ChangePassword(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "sessionid").Return("abc", nil)
+	userController.On("ChangePassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	u := &userApiImpl{
+		userController: userController,
+	}
+
+	body, err := json.Marshal(&ChangePasswordDao{
+		CurrentPassword: "old password",
+		NewPassword:     "new password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/user/password", bytes.NewReader(body))
+	r.Header.Add("Authorization", "Bearer sessionid")
+	u.ChangePassword(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	userController.AssertCalled(t, "ChangePassword", "abc", "sessionid", "old password", "new password")
+}
+
+func Test_userApiImpl_DeleteUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		verify func(t *testing.T, w *httptest.ResponseRecorder, mazeController *MazeControllerMock)
+	}{
+		{
+			name: "Delete without export",
+			url:  "/user",
+			verify: func(t *testing.T, w *httptest.ResponseRecorder, mazeController *MazeControllerMock) {
+				assert.Equal(t, http.StatusNoContent, w.Code)
+				assert.Empty(t, w.Body.String())
+				mazeController.AssertNotCalled(t, "GetUserMazes", mock.Anything)
+			},
+		},
+		{
+			name: "Delete with export",
+			url:  "/user?export=true",
+			verify: func(t *testing.T, w *httptest.ResponseRecorder, mazeController *MazeControllerMock) {
+				assert.Equal(t, http.StatusOK, w.Code)
+				var export UserExportDao
+				assert.Nil(t, json.NewDecoder(w.Body).Decode(&export))
+				assert.Equal(t, "abc", export.Username)
+				assert.Equal(t, 1, len(export.Mazes))
+				assert.Equal(t, "8Wa", export.Mazes[0].Id)
+				assert.Equal(t, "10x10", export.Mazes[0].GridSize)
+			},
+		},
+		{
+			name: "Invalid export parameter",
+			url:  "/user?export=yes",
+			verify: func(t *testing.T, w *httptest.ResponseRecorder, mazeController *MazeControllerMock) {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userController := &UserControllerMock{}
+			userController.On("GetUserForSession", "sessionid").Return("abc", nil)
+			userController.On("DeleteUser", "abc").Return(nil)
+			mazeController := &MazeControllerMock{}
+			maze := &Maze{Id: "8Wa", EntranceX: 1}
+			maze.InitWalls(10, 10)
+			mazeController.On("GetUserMazes", "abc").Return([]*Maze{maze}, nil)
+			u := &userApiImpl{
+				userController: userController,
+				mazeController: mazeController,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("DELETE", tt.url, nil)
+			r.Header.Add("Authorization", "Bearer sessionid")
+			u.DeleteUser(w, r)
+
+			tt.verify(t, w, mazeController)
+			if w.Code < 300 {
+				userController.AssertCalled(t, "DeleteUser", "abc")
+			} else {
+				userController.AssertNotCalled(t, "DeleteUser", mock.Anything)
+			}
+		})
+	}
+}
+
+func Test_userApiImpl_LoginTwoFactor(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("VerifyTwoFactor", "token", "123456", "192.0.2.1").Return(&LoginResult{SessionId: "sessionid"}, nil)
+	u := &userApiImpl{
+		userController: userController,
+	}
+
+	body, err := json.Marshal(&TwoFactorLoginDao{
+		TwoFactorToken: "token",
+		Code:           "123456",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	u.LoginTwoFactor(w, httptest.NewRequest("POST", "/login/2fa", bytes.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result LoginResult
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&result))
+	assert.Equal(t, "sessionid", result.SessionId)
+}
+
+func Test_userApiImpl_GetUsage(t *testing.T) {
+	userController := &UserControllerMock{}
+	userController.On("GetUserForSession", "sessionid").Return("abc", nil)
+	quotaController := &QuotaControllerMock{}
+	quotaController.On("GetUsage", "abc").Return(&Usage{
+		StoredMazes: UsageCounter{Used: 3, Limit: 10},
+		Generations: UsageCounter{Used: 1, Limit: 5},
+	}, nil)
+	u := &userApiImpl{
+		userController:  userController,
+		quotaController: quotaController,
+	}
+
+	r := httptest.NewRequest("GET", "/user/usage", nil)
+	r.Header.Add("Authorization", "Bearer sessionid")
+	w := httptest.NewRecorder()
+	u.GetUsage(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var usage Usage
+	assert.Nil(t, json.NewDecoder(w.Body).Decode(&usage))
+	assert.Equal(t, UsageCounter{Used: 3, Limit: 10}, usage.StoredMazes)
+	assert.Equal(t, UsageCounter{Used: 1, Limit: 5}, usage.Generations)
+}
@@ -1,36 +1,97 @@
 package main
 
 import (
-	"errors"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"math/rand"
+	"time"
+	"unicode/utf8"
 )
 
+type LoginResult struct {
+	SessionId         string `json:"sessionId,omitempty"`
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	TwoFactorToken    string `json:"twoFactorToken,omitempty"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret        string   `json:"secret"`
+	Uri           string   `json:"otpauthUri"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
 type UserController interface {
 	CreateUser(username string, password string) (string, error)
-	Login(username string, password string) (string, error)
+	Login(username string, password string, ip string) (*LoginResult, error)
+	VerifyTwoFactor(twoFactorToken string, code string, ip string) (*LoginResult, error)
+	EnrollTwoFactor(username string) (*TwoFactorEnrollment, error)
+	ActivateTwoFactor(username string, code string) error
 	GetUserForSession(sessionId string) (string, error)
+	ChangePassword(username string, sessionId string, currentPassword string, newPassword string) error
+	DeleteUser(username string) error
+	GetAccount(username string) (*UserAccount, error)
+	ListAccounts() ([]UserAccount, error)
+	SetRole(actor string, username string, role string) error
+	SetDisabled(actor string, username string, disabled bool) error
+	ForceLogout(actor string, username string) error
 }
 
-func NewUserController(userRepository UserRepository, sessionRepository SessionRepository) UserController {
+// USERNAME_MAX_LENGTH and PASSWORD_MAX_BYTES are checked before the password policy and the hashing run
+const (
+	USERNAME_MAX_LENGTH = 64
+	PASSWORD_MAX_BYTES  = 1024
+)
+
+var (
+	ErrUserNotFound            = newCodedError(ERROR_CODE_USER_NOT_FOUND, "user not found")
+	ErrAccountDisabled         = newCodedError(ERROR_CODE_AUTH_ACCOUNT_DISABLED, "the account is disabled")
+	ErrInvalidTwoFactorCode    = newCodedError(ERROR_CODE_AUTH_INVALID_TWO_FACTOR, "invalid two-factor code")
+	ErrTwoFactorAlreadyEnabled = newCodedError(ERROR_CODE_TWO_FACTOR_ALREADY_ENABLED, "two-factor authentication is already enabled")
+)
+
+func NewUserController(userRepository UserRepository, sessionRepository SessionRepository, twoFactorRepository TwoFactorRepository, loginThrottle LoginThrottle, auditLog AuditLog, argon2Params Argon2Params, passwordPolicy PasswordPolicy) UserController {
 	return &userControllerImpl{
-		userRepository:    userRepository,
-		sessionRepository: sessionRepository,
+		userRepository:      userRepository,
+		sessionRepository:   sessionRepository,
+		twoFactorRepository: twoFactorRepository,
+		loginThrottle:       loginThrottle,
+		auditLog:            auditLog,
+		argon2Params:        argon2Params,
+		passwordPolicy:      passwordPolicy,
+		clock:               time.Now,
 	}
 }
 
 type userControllerImpl struct {
-	userRepository    UserRepository
-	sessionRepository SessionRepository
+	userRepository      UserRepository
+	sessionRepository   SessionRepository
+	twoFactorRepository TwoFactorRepository
+	loginThrottle       LoginThrottle
+	auditLog            AuditLog
+	argon2Params        Argon2Params
+	passwordPolicy      PasswordPolicy
+	clock               func() time.Time
+	twoFactorChallenges twoFactorChallenges
 }
 
 func (u *userControllerImpl) CreateUser(username string, password string) (string, error) {
-	if (len(username) < 3) || (len(password) < 3) {
-		return "", errors.New("username and password must be at least 3 characters long")
+	if len(username) < 3 {
+		return "", newCodedError(ERROR_CODE_USER_INVALID_USERNAME, "username must be at least 3 characters long").withField("username")
+	}
+	if utf8.RuneCountInString(username) > USERNAME_MAX_LENGTH {
+		return "", newCodedError(ERROR_CODE_USER_INVALID_USERNAME, fmt.Sprintf("username must be at most %d characters long", USERNAME_MAX_LENGTH)).withField("username")
+	}
+	err := checkPasswordLength(password, "password")
+	if err != nil {
+		return "", err
+	}
+	err = u.passwordPolicy.Validate(username, password)
+	if err != nil {
+		return "", err
 	}
 
-	hash, err := hashPassword(password)
-	log.Println(hash)
+	hash, err := hashPasswordWithParams(password, u.argon2Params)
 	if err != nil {
 		return "", err
 	}
@@ -38,37 +99,221 @@ func (u *userControllerImpl) CreateUser(username string, password string) (strin
 	if err != nil {
 		return "", err
 	}
+	u.auditLog.Record(AUDIT_EVENT_USER_CREATED, username, username, "", "")
 
 	return userId, nil
 }
 
-func (u *userControllerImpl) Login(username string, password string) (string, error) {
+func (u *userControllerImpl) Login(username string, password string, ip string) (*LoginResult, error) {
+	// Count the attempt and reject throttled ones before doing any expensive work
+	err := u.loginThrottle.RegisterAttempt(username, ip)
+	if err != nil {
+		u.auditLog.Record(AUDIT_EVENT_LOGIN_FAILURE, username, username, ip, "throttled")
+		return nil, err
+	}
+
 	// Find user in db
 	hash, err := u.userRepository.SelectByUsername(username)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if hash == "" {
-		return "", errors.New("invalid username or password")
+		return nil, u.loginFailed(username, ip)
 	}
 
 	// Check password
 	passwordOk, err := comparePasswordAndHash(password, hash)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if !passwordOk {
-		return "", errors.New("invalid username or password")
+		return nil, u.loginFailed(username, ip)
+	}
+	account, err := u.userRepository.SelectAccount(username)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.Disabled {
+		u.auditLog.Record(AUDIT_EVENT_LOGIN_FAILURE, username, username, ip, "account disabled")
+		return nil, ErrAccountDisabled
+	}
+	u.upgradePasswordHash(username, password, hash)
+
+	// Ask for the second factor if enabled, the throttle is only reset once the login is complete
+	twoFactor, err := u.twoFactorRepository.SelectByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		token, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		u.twoFactorChallenges.Create(token, username, u.clock())
+		return &LoginResult{
+			TwoFactorRequired: true,
+			TwoFactorToken:    token,
+		}, nil
+	}
+
+	return u.completeLogin(username, ip)
+}
+
+// VerifyTwoFactor completes a login with either a totp code or an unused recovery code.
+func (u *userControllerImpl) VerifyTwoFactor(twoFactorToken string, code string, ip string) (*LoginResult, error) {
+	username, ok := u.twoFactorChallenges.Get(twoFactorToken, u.clock())
+	if !ok {
+		return nil, newCodedError(ERROR_CODE_AUTH_INVALID_TWO_FACTOR_TOKEN, "invalid or expired two-factor token")
+	}
+	err := u.loginThrottle.RegisterAttempt(username, ip)
+	if err != nil {
+		u.auditLog.Record(AUDIT_EVENT_LOGIN_FAILURE, username, username, ip, "throttled")
+		return nil, err
+	}
+
+	twoFactor, err := u.twoFactorRepository.SelectByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor == nil || !twoFactor.Enabled {
+		return nil, newCodedError(ERROR_CODE_TWO_FACTOR_NOT_ENABLED, "two-factor authentication is not enabled")
+	}
+
+	valid, counter, err := verifyTotp(twoFactor.Secret, code, u.clock(), twoFactor.LastCounter)
+	if err != nil {
+		return nil, err
+	}
+	if valid {
+		// A code that was used concurrently does not move the counter and is rejected
+		valid, err = u.twoFactorRepository.UpdateLastCounter(username, counter)
+	} else {
+		valid, err = u.twoFactorRepository.UseRecoveryCode(username, hashRecoveryCode(code))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		u.twoFactorChallenges.RegisterFailure(twoFactorToken)
+		u.auditLog.Record(AUDIT_EVENT_LOGIN_FAILURE, username, username, ip, "invalid two-factor code")
+		return nil, ErrInvalidTwoFactorCode
 	}
 
-	// Create session
+	u.twoFactorChallenges.Remove(twoFactorToken)
+	return u.completeLogin(username, ip)
+}
+
+// EnrollTwoFactor creates a new secret and recovery codes, they are used once ActivateTwoFactor confirmed a code.
+func (u *userControllerImpl) EnrollTwoFactor(username string) (*TwoFactorEnrollment, error) {
+	twoFactor, err := u.twoFactorRepository.SelectByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor != nil && twoFactor.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := generateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	recoveryCodes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodeHashes []string
+	for _, code := range recoveryCodes {
+		recoveryCodeHashes = append(recoveryCodeHashes, hashRecoveryCode(code))
+	}
+	err = u.twoFactorRepository.Upsert(username, secret, recoveryCodeHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		Secret:        secret,
+		Uri:           totpUri(TOTP_ISSUER, username, secret),
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (u *userControllerImpl) ActivateTwoFactor(username string, code string) error {
+	twoFactor, err := u.twoFactorRepository.SelectByUsername(username)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil {
+		return newCodedError(ERROR_CODE_TWO_FACTOR_NOT_ENROLLED, "two-factor authentication must be enrolled first")
+	}
+	if twoFactor.Enabled {
+		return ErrTwoFactorAlreadyEnabled
+	}
+
+	valid, counter, err := verifyTotp(twoFactor.Secret, code, u.clock(), twoFactor.LastCounter)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidTwoFactorCode
+	}
+	updated, err := u.twoFactorRepository.UpdateLastCounter(username, counter)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrInvalidTwoFactorCode
+	}
+	return u.twoFactorRepository.Enable(username)
+}
+
+// completeLogin resets the throttle once every factor was checked and creates the session
+func (u *userControllerImpl) completeLogin(username string, ip string) (*LoginResult, error) {
+	err := u.loginThrottle.RegisterSuccess(username, ip)
+	if err != nil {
+		return nil, err
+	}
+	return u.createSession(username, ip)
+}
+
+func (u *userControllerImpl) createSession(username string, ip string) (*LoginResult, error) {
 	sessionId := randStringBytesRmndr(32)
-	err = u.sessionRepository.Insert(sessionId, username)
+	err := u.sessionRepository.Insert(sessionId, username)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	u.auditLog.Record(AUDIT_EVENT_LOGIN_SUCCESS, username, username, ip, "")
+
+	return &LoginResult{SessionId: sessionId}, nil
+}
 
-	return sessionId, nil
+func (u *userControllerImpl) loginFailed(username string, ip string) error {
+	u.auditLog.Record(AUDIT_EVENT_LOGIN_FAILURE, username, username, ip, "invalid username or password")
+	return newCodedError(ERROR_CODE_AUTH_INVALID_CREDENTIALS, "invalid username or password")
+}
+
+// upgradePasswordHash rehashes the password if the stored hash was created with outdated argon2 parameters.
+// A failed upgrade must not fail the login, it will be retried on the next login.
+func (u *userControllerImpl) upgradePasswordHash(username string, password string, hash string) {
+	rehash, err := needsRehash(hash, u.argon2Params)
+	if err != nil || !rehash {
+		return
+	}
+	newHash, err := hashPasswordWithParams(password, u.argon2Params)
+	if err != nil {
+		log.Println("failed to rehash password:", err)
+		return
+	}
+	err = u.userRepository.UpdatePasswordHash(username, newHash)
+	if err != nil {
+		log.Println("failed to store rehashed password:", err)
+	}
+}
+
+func randomToken() (string, error) {
+	b, err := generateRandomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 const letters = "abcdefghijklmnopqrstuvwxyz1234567890"
@@ -81,6 +326,152 @@ func randStringBytesRmndr(n int) string {
 	return string(b)
 }
 
+// GetUserForSession returns an empty user id if the session is unknown or the account is disabled.
 func (u *userControllerImpl) GetUserForSession(sessionId string) (string, error) {
-	return u.sessionRepository.SelectBySessionId(sessionId)
+	userId, err := u.sessionRepository.SelectBySessionId(sessionId)
+	if err != nil || userId == "" {
+		return "", err
+	}
+	account, err := u.userRepository.SelectAccount(userId)
+	if err != nil {
+		return "", err
+	}
+	if account == nil || account.Disabled {
+		return "", nil
+	}
+	return userId, nil
+}
+
+// ChangePassword replaces the password and revokes all sessions of the user except the current one.
+func (u *userControllerImpl) ChangePassword(username string, sessionId string, currentPassword string, newPassword string) error {
+	err := checkPasswordLength(currentPassword, "currentPassword")
+	if err != nil {
+		return err
+	}
+	err = checkPasswordLength(newPassword, "newPassword")
+	if err != nil {
+		return err
+	}
+	hash, err := u.userRepository.SelectByUsername(username)
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return ErrUserNotFound
+	}
+
+	passwordOk, err := comparePasswordAndHash(currentPassword, hash)
+	if err != nil {
+		return err
+	}
+	if !passwordOk {
+		return newCodedError(ERROR_CODE_USER_WRONG_PASSWORD, "the current password is wrong").withField("currentPassword")
+	}
+
+	err = u.passwordPolicy.Validate(username, newPassword)
+	if err != nil {
+		return err
+	}
+	newHash, err := hashPasswordWithParams(newPassword, u.argon2Params)
+	if err != nil {
+		return err
+	}
+	err = u.userRepository.UpdatePasswordHash(username, newHash)
+	if err != nil {
+		return err
+	}
+
+	err = u.sessionRepository.DeleteByUserId(username, sessionId)
+	if err != nil {
+		return err
+	}
+	u.auditLog.Record(AUDIT_EVENT_SESSION_REVOKED, username, username, "", "password changed")
+	return nil
+}
+
+func (u *userControllerImpl) DeleteUser(username string) error {
+	err := u.userRepository.Delete(username)
+	if err != nil {
+		return err
+	}
+	u.auditLog.Record(AUDIT_EVENT_USER_DELETED, username, username, "", "")
+	return nil
+}
+
+func (u *userControllerImpl) GetAccount(username string) (*UserAccount, error) {
+	return u.userRepository.SelectAccount(username)
+}
+
+func (u *userControllerImpl) ListAccounts() ([]UserAccount, error) {
+	return u.userRepository.SelectAllAccounts()
+}
+
+// SetRole changes the role of the user, the old and the new role are kept in the audit log.
+func (u *userControllerImpl) SetRole(actor string, username string, role string) error {
+	if !isValidRole(role) {
+		return newCodedError(ERROR_CODE_USER_INVALID_ROLE, "invalid role").withField("role")
+	}
+	account, err := u.existingAccount(username)
+	if err != nil {
+		return err
+	}
+	err = u.userRepository.UpdateRole(username, role)
+	if err != nil {
+		return err
+	}
+	u.auditLog.Record(AUDIT_EVENT_ROLE_CHANGE, actor, username, "", fmt.Sprintf("%s -> %s", account.Role, role))
+	return nil
+}
+
+// SetDisabled disables or enables the account, disabling also ends all sessions of the user.
+func (u *userControllerImpl) SetDisabled(actor string, username string, disabled bool) error {
+	_, err := u.existingAccount(username)
+	if err != nil {
+		return err
+	}
+	err = u.userRepository.UpdateDisabled(username, disabled)
+	if err != nil {
+		return err
+	}
+	if !disabled {
+		return nil
+	}
+	err = u.sessionRepository.DeleteByUserId(username, "")
+	if err != nil {
+		return err
+	}
+	u.auditLog.Record(AUDIT_EVENT_SESSION_REVOKED, actor, username, "", "account disabled")
+	return nil
+}
+
+func (u *userControllerImpl) ForceLogout(actor string, username string) error {
+	_, err := u.existingAccount(username)
+	if err != nil {
+		return err
+	}
+	err = u.sessionRepository.DeleteByUserId(username, "")
+	if err != nil {
+		return err
+	}
+	u.auditLog.Record(AUDIT_EVENT_SESSION_REVOKED, actor, username, "", "forced logout")
+	return nil
+}
+
+// checkPasswordLength bounds the input of the password policy and of argon2
+func checkPasswordLength(password string, field string) error {
+	if len(password) > PASSWORD_MAX_BYTES {
+		return newCodedError(ERROR_CODE_INVALID_PARAMETER, fmt.Sprintf("the password must be at most %d bytes long", PASSWORD_MAX_BYTES)).withField(field)
+	}
+	return nil
+}
+
+func (u *userControllerImpl) existingAccount(username string) (*UserAccount, error) {
+	account, err := u.userRepository.SelectAccount(username)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrUserNotFound
+	}
+	return account, nil
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -21,6 +23,11 @@ func (m *SessionRepositoryMock) SelectBySessionId(sessionId string) (string, err
 	return args.String(0), args.Error(1)
 }
 
+func (m *SessionRepositoryMock) DeleteByUserId(userId string, exceptSessionId string) error {
+	args := m.Called(userId, exceptSessionId)
+	return args.Error(0)
+}
+
 type UserRepositoryMock struct {
 	mock.Mock
 }
@@ -35,6 +42,86 @@ func (m *UserRepositoryMock) SelectByUsername(username string) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+func (m *UserRepositoryMock) UpdatePasswordHash(username string, passwordHash string) error {
+	args := m.Called(username, passwordHash)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) Delete(username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) SelectAccount(username string) (*UserAccount, error) {
+	args := m.Called(username)
+	return args.Get(0).(*UserAccount), args.Error(1)
+}
+
+func (m *UserRepositoryMock) SelectAllAccounts() ([]UserAccount, error) {
+	args := m.Called()
+	return args.Get(0).([]UserAccount), args.Error(1)
+}
+
+func (m *UserRepositoryMock) UpdateRole(username string, role string) error {
+	args := m.Called(username, role)
+	return args.Error(0)
+}
+
+func (m *UserRepositoryMock) UpdateDisabled(username string, disabled bool) error {
+	args := m.Called(username, disabled)
+	return args.Error(0)
+}
+
+type TwoFactorRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *TwoFactorRepositoryMock) Upsert(username string, secret string, recoveryCodeHashes []string) error {
+	args := m.Called(username, secret, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepositoryMock) SelectByUsername(username string) (*TwoFactor, error) {
+	args := m.Called(username)
+	return args.Get(0).(*TwoFactor), args.Error(1)
+}
+
+func (m *TwoFactorRepositoryMock) Enable(username string) error {
+	args := m.Called(username)
+	return args.Error(0)
+}
+
+func (m *TwoFactorRepositoryMock) UpdateLastCounter(username string, counter uint64) (bool, error) {
+	args := m.Called(username, counter)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *TwoFactorRepositoryMock) UseRecoveryCode(username string, codeHash string) (bool, error) {
+	args := m.Called(username, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+type LoginThrottleMock struct {
+	mock.Mock
+}
+
+func (m *LoginThrottleMock) RegisterAttempt(username string, ip string) error {
+	args := m.Called(username, ip)
+	return args.Error(0)
+}
+
+func (m *LoginThrottleMock) RegisterSuccess(username string, ip string) error {
+	args := m.Called(username, ip)
+	return args.Error(0)
+}
+
+func newLoginThrottleMock() *LoginThrottleMock {
+	m := &LoginThrottleMock{}
+	m.On("RegisterAttempt", mock.Anything, mock.Anything).Return(nil)
+	m.On("RegisterSuccess", mock.Anything, mock.Anything).Return(nil)
+	return m
+}
+
 func Test_userControllerImpl_CreateUser(t *testing.T) {
 	type fields struct {
 		userRepository UserRepository
@@ -76,20 +163,68 @@ func Test_userControllerImpl_CreateUser(t *testing.T) {
 			},
 			args: args{
 				username: "abc",
-				password: "abc",
+				password: "correct horse",
 			},
 			wantErr: false,
 			verify: func(t *testing.T, f *fields, got string) {
 				assert.Equal(t, "userid", got)
 				m := f.userRepository.(*UserRepositoryMock)
-				m.AssertCalled(t, "Insert", mock.Anything, mock.Anything)
+				m.AssertCalled(t, "Insert", "abc", mock.Anything)
+			},
+		},
+		{
+			name: "username too long",
+			fields: fields{
+				userRepository: &UserRepositoryMock{},
+			},
+			args: args{
+				username: strings.Repeat("ä", USERNAME_MAX_LENGTH+1),
+				password: "correct horse",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got string) {
+				m := f.userRepository.(*UserRepositoryMock)
+				m.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "password too long",
+			fields: fields{
+				userRepository: &UserRepositoryMock{},
+			},
+			args: args{
+				username: strings.Repeat("ä", USERNAME_MAX_LENGTH),
+				password: strings.Repeat("x", PASSWORD_MAX_BYTES+1),
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got string) {
+				m := f.userRepository.(*UserRepositoryMock)
+				m.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "password violates the policy",
+			fields: fields{
+				userRepository: &UserRepositoryMock{},
+			},
+			args: args{
+				username: "abcdef",
+				password: "abcdef1",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got string) {
+				m := f.userRepository.(*UserRepositoryMock)
+				m.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			u := &userControllerImpl{
+				auditLog:       newAuditLogMock(),
 				userRepository: tt.fields.userRepository,
+				argon2Params:   DefaultArgon2Params(),
+				passwordPolicy: DefaultPasswordPolicy(),
 			}
 			got, err := u.CreateUser(tt.args.username, tt.args.password)
 			if (err != nil) != tt.wantErr {
@@ -108,20 +243,27 @@ func Test_userControllerImpl_CreateUser(t *testing.T) {
 func Test_userControllerImpl_Login(t *testing.T) {
 	password := "abc"
 	passwordHash := "$argon2id$v=19$m=65536,t=1,p=4$lizu6Pb8PTek6GrGM84e1Q$s8RyFZLrr3tMYQYUV+resj0pLfdVmm3OHSgmH8PrZGI"
+	passwordHashOutdated, err := hashPasswordWithParams(password, Argon2Params{Memory: 8 * 1024, Time: 1, Threads: 1, KeyLen: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
 	type fields struct {
 		userRepository    UserRepository
 		sessionRepository SessionRepository
+		loginThrottle     LoginThrottle
+		twoFactor         *TwoFactor
 	}
 	type args struct {
 		username string
 		password string
+		ip       string
 	}
 	tests := []struct {
 		name    string
 		fields  fields
 		args    args
 		wantErr bool
-		verify  func(t *testing.T, f *fields, got string)
+		verify  func(t *testing.T, f *fields, got *LoginResult)
 	}{
 		{
 			name: "username and password ok",
@@ -129,6 +271,7 @@ func Test_userControllerImpl_Login(t *testing.T) {
 				userRepository: func() UserRepository {
 					m := &UserRepositoryMock{}
 					m.On("SelectByUsername", mock.Anything).Return(passwordHash, nil)
+					m.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
 					return m
 				}(),
 				sessionRepository: func() SessionRepository {
@@ -136,24 +279,196 @@ func Test_userControllerImpl_Login(t *testing.T) {
 					m.On("Insert", mock.Anything, mock.Anything).Return(nil)
 					return m
 				}(),
+				loginThrottle: newLoginThrottleMock(),
 			},
 			args: args{
 				username: "abc",
 				password: password,
+				ip:       "192.0.2.1",
 			},
 			wantErr: false,
-			verify: func(t *testing.T, f *fields, got string) {
-				assert.NotEmpty(t, got)
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				assert.NotEmpty(t, got.SessionId)
+				m := f.loginThrottle.(*LoginThrottleMock)
+				m.AssertCalled(t, "RegisterSuccess", "abc", "192.0.2.1")
+				r := f.userRepository.(*UserRepositoryMock)
+				r.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "two-factor enabled asks for the second factor",
+			fields: fields{
+				userRepository: func() UserRepository {
+					m := &UserRepositoryMock{}
+					m.On("SelectByUsername", mock.Anything).Return(passwordHash, nil)
+					m.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+					return m
+				}(),
+				sessionRepository: &SessionRepositoryMock{},
+				loginThrottle:     newLoginThrottleMock(),
+				twoFactor: &TwoFactor{
+					Secret:  "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+					Enabled: true,
+				},
+			},
+			args: args{
+				username: "abc",
+				password: password,
+				ip:       "192.0.2.1",
+			},
+			wantErr: false,
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				assert.Empty(t, got.SessionId)
+				assert.True(t, got.TwoFactorRequired)
+				assert.NotEmpty(t, got.TwoFactorToken)
+				m := f.sessionRepository.(*SessionRepositoryMock)
+				m.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+				// The password alone does not reset the throttle
+				l := f.loginThrottle.(*LoginThrottleMock)
+				l.AssertNotCalled(t, "RegisterSuccess", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "outdated hash is upgraded",
+			fields: fields{
+				userRepository: func() UserRepository {
+					m := &UserRepositoryMock{}
+					m.On("SelectByUsername", mock.Anything).Return(passwordHashOutdated, nil)
+					m.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+					m.On("UpdatePasswordHash", mock.Anything, mock.Anything).Return(nil)
+					return m
+				}(),
+				sessionRepository: func() SessionRepository {
+					m := &SessionRepositoryMock{}
+					m.On("Insert", mock.Anything, mock.Anything).Return(nil)
+					return m
+				}(),
+				loginThrottle: newLoginThrottleMock(),
+			},
+			args: args{
+				username: "abc",
+				password: password,
+				ip:       "192.0.2.1",
+			},
+			wantErr: false,
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				assert.NotEmpty(t, got.SessionId)
+				r := f.userRepository.(*UserRepositoryMock)
+				r.AssertCalled(t, "UpdatePasswordHash", "abc", mock.MatchedBy(func(hash string) bool {
+					rehash, err := needsRehash(hash, DefaultArgon2Params())
+					return err == nil && !rehash
+				}))
+			},
+		},
+		{
+			name: "disabled account can not login",
+			fields: fields{
+				userRepository: func() UserRepository {
+					m := &UserRepositoryMock{}
+					m.On("SelectByUsername", mock.Anything).Return(passwordHash, nil)
+					m.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: "abc", Role: ROLE_USER, Disabled: true}, nil)
+					return m
+				}(),
+				sessionRepository: &SessionRepositoryMock{},
+				loginThrottle:     newLoginThrottleMock(),
+			},
+			args: args{
+				username: "abc",
+				password: password,
+				ip:       "192.0.2.1",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				m := f.sessionRepository.(*SessionRepositoryMock)
+				m.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "wrong password registers failure",
+			fields: fields{
+				userRepository: func() UserRepository {
+					m := &UserRepositoryMock{}
+					m.On("SelectByUsername", mock.Anything).Return(passwordHash, nil)
+					m.On("SelectAccount", mock.Anything).Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+					return m
+				}(),
+				sessionRepository: &SessionRepositoryMock{},
+				loginThrottle:     newLoginThrottleMock(),
+			},
+			args: args{
+				username: "abc",
+				password: "wrong",
+				ip:       "192.0.2.1",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				m := f.loginThrottle.(*LoginThrottleMock)
+				m.AssertCalled(t, "RegisterAttempt", "abc", "192.0.2.1")
+				m.AssertNotCalled(t, "RegisterSuccess", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "unknown user registers the attempt",
+			fields: fields{
+				userRepository: func() UserRepository {
+					m := &UserRepositoryMock{}
+					m.On("SelectByUsername", mock.Anything).Return("", nil)
+					return m
+				}(),
+				sessionRepository: &SessionRepositoryMock{},
+				loginThrottle:     newLoginThrottleMock(),
+			},
+			args: args{
+				username: "unknown",
+				password: password,
+				ip:       "192.0.2.1",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				m := f.loginThrottle.(*LoginThrottleMock)
+				m.AssertCalled(t, "RegisterAttempt", "unknown", "192.0.2.1")
+				m.AssertNotCalled(t, "RegisterSuccess", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "throttled login does not check the password",
+			fields: fields{
+				userRepository:    &UserRepositoryMock{},
+				sessionRepository: &SessionRepositoryMock{},
+				loginThrottle: func() LoginThrottle {
+					m := &LoginThrottleMock{}
+					m.On("RegisterAttempt", mock.Anything, mock.Anything).Return(&TooManyLoginAttemptsError{RetryAfter: time.Second})
+					return m
+				}(),
+			},
+			args: args{
+				username: "abc",
+				password: password,
+				ip:       "192.0.2.1",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields, got *LoginResult) {
+				m := f.userRepository.(*UserRepositoryMock)
+				m.AssertNotCalled(t, "SelectByUsername", mock.Anything)
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			u := &userControllerImpl{
+				auditLog:          newAuditLogMock(),
 				userRepository:    tt.fields.userRepository,
 				sessionRepository: tt.fields.sessionRepository,
+				twoFactorRepository: func() TwoFactorRepository {
+					m := &TwoFactorRepositoryMock{}
+					m.On("SelectByUsername", mock.Anything).Return(tt.fields.twoFactor, nil)
+					return m
+				}(),
+				loginThrottle: tt.fields.loginThrottle,
+				argon2Params:  DefaultArgon2Params(),
+				clock:         time.Now,
 			}
-			got, err := u.Login(tt.args.username, tt.args.password)
+			got, err := u.Login(tt.args.username, tt.args.password, tt.args.ip)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("userControllerImpl.Login() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -164,3 +479,310 @@ func Test_userControllerImpl_Login(t *testing.T) {
 		})
 	}
 }
+
+func Test_userControllerImpl_ChangePassword(t *testing.T) {
+	passwordHash, err := hashPassword("old password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	type fields struct {
+		userRepository    UserRepository
+		sessionRepository SessionRepository
+	}
+	type args struct {
+		currentPassword string
+		newPassword     string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+		verify  func(t *testing.T, f *fields)
+	}{
+		{
+			name: "password changed",
+			args: args{
+				currentPassword: "old password",
+				newPassword:     "new password",
+			},
+			wantErr: false,
+			verify: func(t *testing.T, f *fields) {
+				u := f.userRepository.(*UserRepositoryMock)
+				u.AssertCalled(t, "UpdatePasswordHash", "abc", mock.Anything)
+				s := f.sessionRepository.(*SessionRepositoryMock)
+				s.AssertCalled(t, "DeleteByUserId", "abc", "sessionid")
+			},
+		},
+		{
+			name: "current password wrong",
+			args: args{
+				currentPassword: "wrong password",
+				newPassword:     "new password",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields) {
+				u := f.userRepository.(*UserRepositoryMock)
+				u.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything)
+				s := f.sessionRepository.(*SessionRepositoryMock)
+				s.AssertNotCalled(t, "DeleteByUserId", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "new password too long",
+			args: args{
+				currentPassword: "old password",
+				newPassword:     strings.Repeat("x", PASSWORD_MAX_BYTES+1),
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields) {
+				u := f.userRepository.(*UserRepositoryMock)
+				u.AssertNotCalled(t, "SelectByUsername", mock.Anything)
+				u.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything)
+			},
+		},
+		{
+			name: "new password violates the policy",
+			args: args{
+				currentPassword: "old password",
+				newPassword:     "abc",
+			},
+			wantErr: true,
+			verify: func(t *testing.T, f *fields) {
+				u := f.userRepository.(*UserRepositoryMock)
+				u.AssertNotCalled(t, "UpdatePasswordHash", mock.Anything, mock.Anything)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userRepository := &UserRepositoryMock{}
+			userRepository.On("SelectByUsername", "abc").Return(passwordHash, nil)
+			userRepository.On("UpdatePasswordHash", mock.Anything, mock.Anything).Return(nil)
+			sessionRepository := &SessionRepositoryMock{}
+			sessionRepository.On("DeleteByUserId", mock.Anything, mock.Anything).Return(nil)
+			u := &userControllerImpl{
+				auditLog:          newAuditLogMock(),
+				userRepository:    userRepository,
+				sessionRepository: sessionRepository,
+				argon2Params:      DefaultArgon2Params(),
+				passwordPolicy:    DefaultPasswordPolicy(),
+			}
+			err := u.ChangePassword("abc", "sessionid", tt.args.currentPassword, tt.args.newPassword)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("userControllerImpl.ChangePassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			tt.verify(t, &fields{
+				userRepository:    userRepository,
+				sessionRepository: sessionRepository,
+			})
+		})
+	}
+}
+
+func Test_userControllerImpl_TwoFactor(t *testing.T) {
+	passwordHash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := newTwoFactorTestDb()
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	userRepository := &UserRepositoryMock{}
+	userRepository.On("SelectByUsername", "abc").Return(passwordHash, nil)
+	userRepository.On("SelectAccount", "abc").Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+	sessionRepository := &SessionRepositoryMock{}
+	sessionRepository.On("Insert", mock.Anything, "abc").Return(nil)
+	loginThrottle := newLoginThrottleMock()
+	u := &userControllerImpl{
+		auditLog:            newAuditLogMock(),
+		userRepository:      userRepository,
+		sessionRepository:   sessionRepository,
+		twoFactorRepository: &twoFactorRepositoryImpl{db: db},
+		loginThrottle:       loginThrottle,
+		argon2Params:        DefaultArgon2Params(),
+		clock: func() time.Time {
+			return now
+		},
+	}
+	codeAt := func(secret string, at time.Time) string {
+		key, err := totpSecretEncoding.DecodeString(secret)
+		assert.Nil(t, err)
+		return totpCode(key, totpCounter(at), TOTP_DIGITS)
+	}
+
+	// Enroll and activate
+	enrollment, err := u.EnrollTwoFactor("abc")
+	assert.Nil(t, err)
+	assert.Contains(t, enrollment.Uri, "otpauth://totp/MazeApi:abc?")
+	assert.Contains(t, enrollment.Uri, "secret="+enrollment.Secret)
+	assert.Equal(t, RECOVERY_CODE_COUNT, len(enrollment.RecoveryCodes))
+	assert.NotNil(t, u.ActivateTwoFactor("abc", "000000"))
+	assert.Nil(t, u.ActivateTwoFactor("abc", codeAt(enrollment.Secret, now)))
+	_, err = u.EnrollTwoFactor("abc")
+	assert.NotNil(t, err)
+
+	// Login with a code of the next time step, the activation code can not be replayed
+	now = now.Add(TOTP_PERIOD * time.Second)
+	result, err := u.Login("abc", "correct horse", "192.0.2.1")
+	assert.Nil(t, err)
+	assert.True(t, result.TwoFactorRequired)
+	_, err = u.VerifyTwoFactor(result.TwoFactorToken, codeAt(enrollment.Secret, now.Add(-TOTP_PERIOD*time.Second)), "192.0.2.1")
+	assert.NotNil(t, err)
+	loginThrottle.AssertNumberOfCalls(t, "RegisterAttempt", 2)
+	loginThrottle.AssertNotCalled(t, "RegisterSuccess", mock.Anything, mock.Anything)
+	result, err = u.VerifyTwoFactor(result.TwoFactorToken, codeAt(enrollment.Secret, now), "192.0.2.1")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, result.SessionId)
+	loginThrottle.AssertCalled(t, "RegisterSuccess", "abc", "192.0.2.1")
+
+	// The same code is not accepted for a second login
+	result, err = u.Login("abc", "correct horse", "192.0.2.1")
+	assert.Nil(t, err)
+	_, err = u.VerifyTwoFactor(result.TwoFactorToken, codeAt(enrollment.Secret, now), "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidTwoFactorCode)
+
+	// A recovery code works exactly once
+	for i, wantErr := range []bool{false, true} {
+		result, err = u.Login("abc", "correct horse", "192.0.2.1")
+		assert.Nil(t, err)
+		_, err = u.VerifyTwoFactor(result.TwoFactorToken, strings.ToUpper(enrollment.RecoveryCodes[0]), "192.0.2.1")
+		assert.Equal(t, wantErr, err != nil, "attempt %d", i)
+	}
+
+	// The challenge expires
+	result, err = u.Login("abc", "correct horse", "192.0.2.1")
+	assert.Nil(t, err)
+	now = now.Add(TWO_FACTOR_CHALLENGE_LIFETIME)
+	_, err = u.VerifyTwoFactor(result.TwoFactorToken, codeAt(enrollment.Secret, now), "192.0.2.1")
+	assert.NotNil(t, err)
+}
+
+func Test_userControllerImpl_GetUserForSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		userId  string
+		account *UserAccount
+		want    string
+	}{
+		{
+			name:    "active account",
+			userId:  "abc",
+			account: &UserAccount{Username: "abc", Role: ROLE_USER},
+			want:    "abc",
+		},
+		{
+			name:    "disabled account",
+			userId:  "abc",
+			account: &UserAccount{Username: "abc", Role: ROLE_USER, Disabled: true},
+			want:    "",
+		},
+		{
+			name:    "deleted account",
+			userId:  "abc",
+			account: nil,
+			want:    "",
+		},
+		{
+			name:   "unknown session",
+			userId: "",
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessionRepository := &SessionRepositoryMock{}
+			sessionRepository.On("SelectBySessionId", "sessionid").Return(tt.userId, nil)
+			userRepository := &UserRepositoryMock{}
+			userRepository.On("SelectAccount", tt.userId).Return(tt.account, nil)
+			u := &userControllerImpl{
+				auditLog:          newAuditLogMock(),
+				userRepository:    userRepository,
+				sessionRepository: sessionRepository,
+			}
+			got, err := u.GetUserForSession("sessionid")
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_userControllerImpl_SetDisabled(t *testing.T) {
+	userRepository := &UserRepositoryMock{}
+	userRepository.On("SelectAccount", "abc").Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+	userRepository.On("SelectAccount", "unknown").Return((*UserAccount)(nil), nil)
+	userRepository.On("UpdateDisabled", mock.Anything, mock.Anything).Return(nil)
+	sessionRepository := &SessionRepositoryMock{}
+	sessionRepository.On("DeleteByUserId", mock.Anything, mock.Anything).Return(nil)
+	u := &userControllerImpl{
+		auditLog:          newAuditLogMock(),
+		userRepository:    userRepository,
+		sessionRepository: sessionRepository,
+	}
+
+	auditLog := u.auditLog.(*AuditLogMock)
+
+	assert.Nil(t, u.SetDisabled("admin", "abc", false))
+	sessionRepository.AssertNotCalled(t, "DeleteByUserId", mock.Anything, mock.Anything)
+	auditLog.AssertNotCalled(t, "Record", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	assert.Nil(t, u.SetDisabled("admin", "abc", true))
+	userRepository.AssertCalled(t, "UpdateDisabled", "abc", true)
+	sessionRepository.AssertCalled(t, "DeleteByUserId", "abc", "")
+	auditLog.AssertCalled(t, "Record", AUDIT_EVENT_SESSION_REVOKED, "admin", "abc", "", "account disabled")
+
+	assert.NotNil(t, u.SetDisabled("admin", "unknown", true))
+	userRepository.AssertNotCalled(t, "UpdateDisabled", "unknown", mock.Anything)
+}
+
+func Test_userControllerImpl_SetRole(t *testing.T) {
+	userRepository := &UserRepositoryMock{}
+	userRepository.On("SelectAccount", "abc").Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+	userRepository.On("UpdateRole", mock.Anything, mock.Anything).Return(nil)
+	u := &userControllerImpl{
+		auditLog:       newAuditLogMock(),
+		userRepository: userRepository,
+	}
+
+	auditLog := u.auditLog.(*AuditLogMock)
+
+	assert.Nil(t, u.SetRole("admin", "abc", ROLE_MODERATOR))
+	userRepository.AssertCalled(t, "UpdateRole", "abc", ROLE_MODERATOR)
+	auditLog.AssertCalled(t, "Record", AUDIT_EVENT_ROLE_CHANGE, "admin", "abc", "", "user -> moderator")
+
+	assert.NotNil(t, u.SetRole("admin", "abc", "superuser"))
+	userRepository.AssertNotCalled(t, "UpdateRole", "abc", "superuser")
+	auditLog.AssertNumberOfCalls(t, "Record", 1)
+}
+
+func Test_userControllerImpl_LoginAudit(t *testing.T) {
+	passwordHash, err := hashPassword("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userRepository := &UserRepositoryMock{}
+	userRepository.On("SelectByUsername", "abc").Return(passwordHash, nil)
+	userRepository.On("SelectAccount", "abc").Return(&UserAccount{Username: "abc", Role: ROLE_USER}, nil)
+	sessionRepository := &SessionRepositoryMock{}
+	sessionRepository.On("Insert", mock.Anything, "abc").Return(nil)
+	twoFactorRepository := &TwoFactorRepositoryMock{}
+	twoFactorRepository.On("SelectByUsername", "abc").Return((*TwoFactor)(nil), nil)
+	auditLog := newAuditLogMock()
+	u := &userControllerImpl{
+		auditLog:            auditLog,
+		userRepository:      userRepository,
+		sessionRepository:   sessionRepository,
+		twoFactorRepository: twoFactorRepository,
+		loginThrottle:       newLoginThrottleMock(),
+		argon2Params:        DefaultArgon2Params(),
+		clock:               time.Now,
+	}
+
+	_, err = u.Login("abc", "wrong password", "192.0.2.1")
+	assert.NotNil(t, err)
+	auditLog.AssertCalled(t, "Record", AUDIT_EVENT_LOGIN_FAILURE, "abc", "abc", "192.0.2.1", "invalid username or password")
+	auditLog.AssertNotCalled(t, "Record", AUDIT_EVENT_LOGIN_SUCCESS, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	_, err = u.Login("abc", "password", "192.0.2.1")
+	assert.Nil(t, err)
+	auditLog.AssertCalled(t, "Record", AUDIT_EVENT_LOGIN_SUCCESS, "abc", "abc", "192.0.2.1", "")
+}
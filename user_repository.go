@@ -2,15 +2,37 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 
+	"github.com/mattn/go-sqlite3"
 	"github.com/speps/go-hashids"
 )
 
-const USER_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS users (username VARCHAR(256) NOT NULL PRIMARY KEY, password_hash VARCHAR(256))"
+const USER_REPO_CREATE_TABLE = "CREATE TABLE IF NOT EXISTS users (username VARCHAR(256) NOT NULL PRIMARY KEY, password_hash VARCHAR(256), role VARCHAR(16) NOT NULL DEFAULT 'user', disabled INTEGER NOT NULL DEFAULT 0)"
+
+// USER_REPO_MIGRATIONS add the columns that were introduced after the table was first created
+var USER_REPO_MIGRATIONS = []string{
+	"ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'user'",
+	"ALTER TABLE users ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0",
+}
+
+var ErrUserExists = newCodedError(ERROR_CODE_USER_ALREADY_EXISTS, "the username is already taken")
+
+type UserAccount struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled"`
+}
 
 type UserRepository interface {
 	Insert(username string, passwordHash string) (string, error)
 	SelectByUsername(username string) (string, error)
+	UpdatePasswordHash(username string, passwordHash string) error
+	Delete(username string) error
+	SelectAccount(username string) (*UserAccount, error)
+	SelectAllAccounts() ([]UserAccount, error)
+	UpdateRole(username string, role string) error
+	UpdateDisabled(username string, disabled bool) error
 }
 
 func NewUserRepository(db *sql.DB) UserRepository {
@@ -18,6 +40,10 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	if err != nil {
 		panic(err)
 	}
+	err = migrate(db, USER_REPO_MIGRATIONS)
+	if err != nil {
+		panic(err)
+	}
 	return &userRepositoryImpl{
 		db: db,
 	}
@@ -30,6 +56,10 @@ type userRepositoryImpl struct {
 
 func (u *userRepositoryImpl) Insert(username string, passwordHash string) (string, error) {
 	_, err := u.db.Exec("INSERT INTO users (username, password_hash) VALUES (?, ?)", username, passwordHash)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return "", ErrUserExists
+	}
 	if err != nil {
 		return "", err
 	}
@@ -47,3 +77,143 @@ func (u *userRepositoryImpl) SelectByUsername(username string) (string, error) {
 	}
 	return passwordHash, nil
 }
+
+func (u *userRepositoryImpl) UpdatePasswordHash(username string, passwordHash string) error {
+	_, err := u.db.Exec("UPDATE users SET password_hash = ? WHERE username = ?", passwordHash, username)
+	return err
+}
+
+// Delete removes the user together with all own mazes, sessions and two-factor data of the user in a single transaction.
+// Team mazes stay with the team and team memberships are removed. Teams the user is the last owner of are handed over
+// to another member, see handOverTeams.
+func (u *userRepositoryImpl) Delete(username string) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = handOverTeams(tx, username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM mazes WHERE user_id = ? AND team_id IS NULL", username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM sessions WHERE user_id = ?", username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM two_factor WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM team_members WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM usage_counters WHERE user_id = ?", username)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// handOverTeams keeps every team with an owner when the user leaves. The editors come first, then the viewers, in
+// the order of their names. Teams without other members are deleted together with their mazes.
+func handOverTeams(tx *sql.Tx, username string) error {
+	rows, err := tx.Query("SELECT team_id FROM team_members m WHERE username = ? AND role = ? AND NOT EXISTS (SELECT 1 FROM team_members o WHERE o.team_id = m.team_id AND o.username != ? AND o.role = ?)", username, TEAM_ROLE_OWNER, username, TEAM_ROLE_OWNER)
+	if err != nil {
+		return err
+	}
+	teamIds := []int64{}
+	for rows.Next() {
+		var teamId int64
+		err = rows.Scan(&teamId)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		teamIds = append(teamIds, teamId)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, teamId := range teamIds {
+		var successor string
+		err = tx.QueryRow("SELECT username FROM team_members WHERE team_id = ? AND username != ? ORDER BY role = ? DESC, username LIMIT 1", teamId, username, TEAM_ROLE_EDITOR).Scan(&successor)
+		if err == nil {
+			_, err = tx.Exec("UPDATE team_members SET role = ? WHERE team_id = ? AND username = ?", TEAM_ROLE_OWNER, teamId, successor)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM mazes WHERE team_id = ?", teamId)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM teams WHERE id = ?", teamId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *userRepositoryImpl) SelectAccount(username string) (*UserAccount, error) {
+	account := &UserAccount{}
+	err := u.db.QueryRow("SELECT username, role, disabled FROM users WHERE username = ?", username).Scan(&account.Username, &account.Role, &account.Disabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return account, nil
+}
+
+func (u *userRepositoryImpl) SelectAllAccounts() ([]UserAccount, error) {
+	rows, err := u.db.Query("SELECT username, role, disabled FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []UserAccount{}
+	for rows.Next() {
+		var account UserAccount
+		err = rows.Scan(&account.Username, &account.Role, &account.Disabled)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func (u *userRepositoryImpl) UpdateRole(username string, role string) error {
+	_, err := u.db.Exec("UPDATE users SET role = ? WHERE username = ?", role, username)
+	return err
+}
+
+func (u *userRepositoryImpl) UpdateDisabled(username string, disabled bool) error {
+	_, err := u.db.Exec("UPDATE users SET disabled = ? WHERE username = ?", disabled, username)
+	return err
+}
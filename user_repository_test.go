@@ -6,6 +6,7 @@ import (
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/speps/go-hashids"
+	"github.com/stretchr/testify/assert"
 )
 
 func newUserTestDb() *sql.DB {
@@ -149,3 +150,164 @@ func Test_userRepositoryImpl_SelectByUsername(t *testing.T) {
 		})
 	}
 }
+
+func Test_userRepositoryImpl_UpdatePasswordHash(t *testing.T) {
+	u := &userRepositoryImpl{
+		db:     newUserTestDb(),
+		hashid: newHashId(),
+	}
+	_, err := u.Insert("username", "old")
+	assert.Nil(t, err)
+
+	err = u.UpdatePasswordHash("username", "new")
+	assert.Nil(t, err)
+
+	got, err := u.SelectByUsername("username")
+	assert.Nil(t, err)
+	assert.Equal(t, "new", got)
+}
+
+func Test_userRepositoryImpl_Delete(t *testing.T) {
+	db := newUserTestDb()
+	_, err := db.Exec(MAZE_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+	_, err = db.Exec(SESSION_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+	_, err = db.Exec(TWO_FACTOR_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+	_, err = db.Exec(RECOVERY_CODE_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+	_, err = db.Exec(TEAM_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+	_, err = db.Exec(TEAM_MEMBER_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+	_, err = db.Exec(USAGE_REPO_CREATE_TABLE)
+	assert.Nil(t, err)
+
+	u := &userRepositoryImpl{
+		db:     db,
+		hashid: newHashId(),
+	}
+	m := &mazeRepositoryImpl{
+		db:     db,
+		hashid: newHashId(),
+	}
+	s := &sessionRepositoryImpl{
+		db: db,
+	}
+	for _, username := range []string{"username", "other"} {
+		_, err = u.Insert(username, "password")
+		assert.Nil(t, err)
+		_, err = m.Insert(username, 3, 4, 10, 10, []byte{1, 2, 3, 4, 5}, 0)
+		assert.Nil(t, err)
+		assert.Nil(t, s.Insert("session_"+username, username))
+	}
+
+	assert.Nil(t, u.Delete("username"))
+
+	hash, err := u.SelectByUsername("username")
+	assert.Nil(t, err)
+	assert.Equal(t, "", hash)
+	mazes, err := m.SelectAllByUserId("username")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(mazes))
+	sessionUser, err := s.SelectBySessionId("session_username")
+	assert.Nil(t, err)
+	assert.Equal(t, "", sessionUser)
+
+	// Other users are untouched
+	count, err := m.CountAll()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), count)
+	sessionUser, err = s.SelectBySessionId("session_other")
+	assert.Nil(t, err)
+	assert.Equal(t, "other", sessionUser)
+}
+
+func Test_userRepositoryImpl_Delete_Teams(t *testing.T) {
+	db := newTeamTestDb()
+	for _, statement := range []string{USER_REPO_CREATE_TABLE, MAZE_REPO_CREATE_TABLE, SESSION_REPO_CREATE_TABLE, TWO_FACTOR_REPO_CREATE_TABLE, RECOVERY_CODE_REPO_CREATE_TABLE, USAGE_REPO_CREATE_TABLE} {
+		_, err := db.Exec(statement)
+		assert.Nil(t, err)
+	}
+	u := &userRepositoryImpl{db: db, hashid: newHashId()}
+	m := &mazeRepositoryImpl{db: db, hashid: newHashId()}
+	teams := &teamRepositoryImpl{db: db, hashid: newHashId()}
+
+	// alice is the last owner of all teams
+	handedOver, err := teams.Insert("Handed over", "alice")
+	assert.Nil(t, err)
+	assert.Nil(t, teams.UpsertMember(handedOver, "bob", TEAM_ROLE_VIEWER))
+	assert.Nil(t, teams.UpsertMember(handedOver, "carol", TEAM_ROLE_EDITOR))
+	coOwned, err := teams.Insert("Co-owned", "alice")
+	assert.Nil(t, err)
+	assert.Nil(t, teams.UpsertMember(coOwned, "bob", TEAM_ROLE_OWNER))
+	assert.Nil(t, teams.UpsertMember(coOwned, "carol", TEAM_ROLE_EDITOR))
+	alone, err := teams.Insert("Alone", "alice")
+	assert.Nil(t, err)
+	_, err = m.InsertForTeam(handedOver, "alice", 1, 0, 3, 3, []byte{1}, 0)
+	assert.Nil(t, err)
+	_, err = m.InsertForTeam(alone, "alice", 1, 0, 3, 3, []byte{1}, 0)
+	assert.Nil(t, err)
+
+	assert.Nil(t, u.Delete("alice"))
+
+	members, err := teams.SelectMembers(handedOver)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []TeamMember{{Username: "bob", Role: TEAM_ROLE_VIEWER}, {Username: "carol", Role: TEAM_ROLE_OWNER}}, members)
+	mazes, err := m.SelectAllByTeamId(handedOver)
+	assert.Nil(t, err)
+	assert.Len(t, mazes, 1)
+
+	members, err = teams.SelectMembers(coOwned)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []TeamMember{{Username: "bob", Role: TEAM_ROLE_OWNER}, {Username: "carol", Role: TEAM_ROLE_EDITOR}}, members)
+
+	team, err := teams.SelectById(alone)
+	assert.Nil(t, err)
+	assert.Nil(t, team)
+	count, err := m.CountAll()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), count)
+}
+
+func Test_userRepositoryImpl_Accounts(t *testing.T) {
+	u := &userRepositoryImpl{
+		db:     newUserTestDb(),
+		hashid: newHashId(),
+	}
+	_, err := u.Insert("bob", "password")
+	assert.Nil(t, err)
+	_, err = u.Insert("alice", "password")
+	assert.Nil(t, err)
+
+	got, err := u.SelectAccount("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, &UserAccount{Username: "alice", Role: ROLE_USER}, got)
+
+	assert.Nil(t, u.UpdateRole("alice", ROLE_ADMIN))
+	assert.Nil(t, u.UpdateDisabled("bob", true))
+
+	accounts, err := u.SelectAllAccounts()
+	assert.Nil(t, err)
+	assert.Equal(t, []UserAccount{
+		{Username: "alice", Role: ROLE_ADMIN},
+		{Username: "bob", Role: ROLE_USER, Disabled: true},
+	}, accounts)
+
+	got, err = u.SelectAccount("unknown")
+	assert.Nil(t, err)
+	assert.Nil(t, got)
+}
+
+func Test_userRepositoryImpl_InsertDuplicate(t *testing.T) {
+	u := &userRepositoryImpl{
+		db:     newUserTestDb(),
+		hashid: newHashId(),
+	}
+	_, err := u.Insert("bob", "password")
+	assert.Nil(t, err)
+
+	_, err = u.Insert("bob", "password")
+	assert.ErrorIs(t, err, ErrUserExists)
+}